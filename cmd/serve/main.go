@@ -3,13 +3,19 @@ package serve
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/docker/libnetwork/resolvconf"
 	"github.com/docker/libnetwork/types"
 	"github.com/place1/wg-access-server/internal/services"
 	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/internal/totp"
 	"github.com/place1/wg-access-server/pkg/authnz"
 	"github.com/place1/wg-access-server/pkg/authnz/authconfig"
 	"github.com/place1/wg-access-server/pkg/authnz/authsession"
@@ -23,10 +29,17 @@ import (
 	"github.com/place1/wg-embed/pkg/wgembed"
 
 	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/audit"
 	"github.com/place1/wg-access-server/internal/config"
 	"github.com/place1/wg-access-server/internal/devices"
 	"github.com/place1/wg-access-server/internal/dnsproxy"
+	"github.com/place1/wg-access-server/internal/lifecycle"
 	"github.com/place1/wg-access-server/internal/network"
+	"github.com/place1/wg-access-server/internal/rbac"
+	"github.com/place1/wg-access-server/internal/scim"
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
@@ -36,7 +49,9 @@ func Register(app *kingpin.Application) *servecmd {
 	cli.Flag("config", "Path to a wg-access-server config file").Envar("WG_CONFIG").StringVar(&cmd.ConfigFilePath)
 	cli.Flag("admin-username", "Admin username (defaults to admin)").Envar("WG_ADMIN_USERNAME").Default("admin").StringVar(&cmd.AppConfig.AdminUsername)
 	cli.Flag("admin-password", "Admin password (provide plaintext, stored in-memory only)").Envar("WG_ADMIN_PASSWORD").StringVar(&cmd.AppConfig.AdminPassword)
+	cli.Flag("session-secret", "A secret that signs session cookies so that users stay signed in across restarts and replicas").Envar("WG_SESSION_SECRET").StringVar(&cmd.AppConfig.Auth.Sessions.Secret)
 	cli.Flag("port", "The port that the web ui server will listen on").Envar("WG_PORT").Default("8000").IntVar(&cmd.AppConfig.Port)
+	cli.Flag("metrics-address", "The host:port that Prometheus metrics are served on (e.g. 127.0.0.1:9586). Disabled by default").Envar("WG_METRICS_ADDRESS").StringVar(&cmd.AppConfig.MetricsAddress)
 	cli.Flag("external-host", "The external origin of the server (e.g. https://mydomain.com)").Envar("WG_EXTERNAL_HOST").StringVar(&cmd.AppConfig.ExternalHost)
 	cli.Flag("storage", "The storage backend connection string").Envar("WG_STORAGE").Default("memory://").StringVar(&cmd.AppConfig.Storage)
 	cli.Flag("disable-metadata", "Disable metadata collection (i.e. metrics)").Envar("WG_DISABLE_METADATA").Default("false").BoolVar(&cmd.AppConfig.DisableMetadata)
@@ -44,11 +59,19 @@ func Register(app *kingpin.Application) *servecmd {
 	cli.Flag("wireguard-interface", "Set the wireguard interface name").Default("wg0").Envar("WG_WIREGUARD_INTERFACE").StringVar(&cmd.AppConfig.WireGuard.Interface)
 	cli.Flag("wireguard-private-key", "Wireguard private key").Envar("WG_WIREGUARD_PRIVATE_KEY").StringVar(&cmd.AppConfig.WireGuard.PrivateKey)
 	cli.Flag("wireguard-port", "The port that the Wireguard server will listen on").Envar("WG_WIREGUARD_PORT").Default("51820").IntVar(&cmd.AppConfig.WireGuard.Port)
+	cli.Flag("wireguard-mtu", "The MTU of the Wireguard interface and client configs").Envar("WG_WIREGUARD_MTU").Default("1420").IntVar(&cmd.AppConfig.WireGuard.MTU)
+	cli.Flag("wireguard-persistent-keepalive", "The PersistentKeepalive interval (seconds) for client configs. 0 disables keepalives").Envar("WG_WIREGUARD_PERSISTENT_KEEPALIVE").Default("0").IntVar(&cmd.AppConfig.WireGuard.PersistentKeepalive)
+	cli.Flag("wireguard-endpoint", "The host:port that clients will connect to (defaults to the web ui's host and the wireguard port)").Envar("WG_WIREGUARD_ENDPOINT").StringVar(&cmd.AppConfig.WireGuard.Endpoint)
 	cli.Flag("vpn-cidr", "The network CIDR for the VPN").Envar("WG_VPN_CIDR").Default("10.44.0.0/24").StringVar(&cmd.AppConfig.VPN.CIDR)
 	cli.Flag("vpn-gateway-interface", "The gateway network interface (i.e. eth0)").Envar("WG_VPN_GATEWAY_INTERFACE").Default(detectDefaultInterface()).StringVar(&cmd.AppConfig.VPN.GatewayInterface)
 	cli.Flag("vpn-allowed-ips", "A list of networks that VPN clients will be allowed to connect to via the VPN").Envar("WG_VPN_ALLOWED_IPS").Default("0.0.0.0/0").StringsVar(&cmd.AppConfig.VPN.AllowedIPs)
+	cli.Flag("shaping-enabled", "Enable or disable per device bandwidth limits").Envar("WG_SHAPING_ENABLED").Default("false").BoolVar(&cmd.AppConfig.Shaping.Enabled)
 	cli.Flag("dns-enabled", "Enable or disable the embedded dns proxy server (useful for development)").Envar("WG_DNS_ENABLED").Default("true").BoolVar(&cmd.AppConfig.DNS.Enabled)
-	cli.Flag("dns-upstream", "An upstream DNS server to proxy DNS traffic to. Defaults to resolveconf or 1.1.1.1").Envar("WG_DNS_UPSTREAM").Default(detectDNSUpstream()).StringsVar(&cmd.AppConfig.DNS.Upstream)
+	cli.Flag("dns-upstream", "An upstream DNS server to proxy DNS traffic to (ip, https:// or tls:// url). Defaults to resolveconf or 1.1.1.1").Envar("WG_DNS_UPSTREAM").Default(detectDNSUpstream()...).StringsVar(&cmd.AppConfig.DNS.Upstream)
+	cli.Flag("dns-upstream-strategy", "How upstream DNS servers are chosen: failover, round-robin or fastest").Envar("WG_DNS_UPSTREAM_STRATEGY").Default("failover").StringVar(&cmd.AppConfig.DNS.UpstreamStrategy)
+	cli.Flag("dns-upstream-timeout", "How long to wait for an upstream DNS server before trying the next one").Envar("WG_DNS_UPSTREAM_TIMEOUT").Default("2s").DurationVar(&cmd.AppConfig.DNS.UpstreamTimeout)
+	cli.Flag("dns-query-log", "Record which device resolved which name. Disable for privacy").Envar("WG_DNS_QUERY_LOG").Default("true").BoolVar(&cmd.AppConfig.DNS.QueryLog.Enabled)
+	cli.Flag("dns-domain", "A DNS zone in which devices get records i.e. <device>.<owner>.<domain>. Disabled by default").Envar("WG_DNS_DOMAIN").StringVar(&cmd.AppConfig.DNS.Domain)
 	return cmd
 }
 
@@ -64,54 +87,82 @@ func (cmd *servecmd) Name() string {
 func (cmd *servecmd) Run() {
 	conf := cmd.ReadConfig()
 
-	// The server's IP within the VPN virtual network
-	vpnip := network.ServerVPNIP(conf.VPN.CIDR)
+	if err := config.ValidateWireGuard(conf); err != nil {
+		logrus.Fatal(errors.Wrap(err, "invalid wireguard config"))
+	}
+
+	// The default profile followed by any additional
+	// profiles from the config file
+	profiles := conf.VPNProfiles()
+	if err := config.ValidateProfiles(profiles); err != nil {
+		logrus.Fatal(errors.Wrap(err, "invalid vpn profiles"))
+	}
+	if err := rbac.ValidateRoles(conf.Roles); err != nil {
+		logrus.Fatal(errors.Wrap(err, "invalid roles"))
+	}
 
 	// Allow traffic to wg-access-server's peer endpoint.
 	// This is important because clients will send traffic
 	// to the embedded DNS proxy using the VPN IP
-	conf.VPN.AllowedIPs = append(conf.VPN.AllowedIPs, fmt.Sprintf("%s/32", vpnip.IP.String()))
+	for i := range profiles {
+		vpnip := network.ServerVPNIP(profiles[i].CIDR)
+		profiles[i].AllowedIPs = append(profiles[i].AllowedIPs, fmt.Sprintf("%s/32", vpnip.IP.String()))
+	}
+	conf.VPN.AllowedIPs = profiles[0].AllowedIPs
+
+	// WireGuard Server (one interface per profile)
+	wgs := map[string]wgembed.WireGuardInterface{}
+	for _, profile := range profiles {
+		wgs[profile.Name] = wgembed.NewNoOpInterface()
+		if !conf.WireGuard.Enabled {
+			continue
+		}
 
-	// WireGuard Server
-	wg := wgembed.NewNoOpInterface()
-	if conf.WireGuard.Enabled {
-		wgimpl, err := wgembed.New(conf.WireGuard.Interface)
+		wgimpl, err := wgembed.New(profile.Interface)
 		if err != nil {
-			logrus.Fatal(errors.Wrap(err, "failed to create wireguard interface"))
+			logrus.Fatal(errors.Wrapf(err, "failed to create wireguard interface %s", profile.Interface))
 		}
 		defer wgimpl.Close()
-		wg = wgimpl
+		wgs[profile.Name] = wgimpl
 
-		logrus.Infof("starting wireguard server on 0.0.0.0:%d", conf.WireGuard.Port)
+		logrus.Infof("starting wireguard server on 0.0.0.0:%d", profile.Port)
 
+		// The server's IP within the VPN virtual network
+		vpnip := network.ServerVPNIP(profile.CIDR)
+		port := profile.Port
 		wgconfig := &wgembed.ConfigFile{
 			Interface: wgembed.IfaceConfig{
 				PrivateKey: conf.WireGuard.PrivateKey,
 				Address:    vpnip.String(),
-				ListenPort: &conf.WireGuard.Port,
+				ListenPort: &port,
 			},
 		}
 
-		if err := wg.LoadConfig(wgconfig); err != nil {
+		if err := wgimpl.LoadConfig(wgconfig); err != nil {
 			logrus.Fatal(errors.Wrap(err, "failed to load wireguard config"))
 		}
 
-		logrus.Infof("wireguard VPN network is %s", conf.VPN.CIDR)
+		// wgembed's ConfigFile has no MTU and LoadConfig resets the
+		// interface to 1420, so the MTU is set after every LoadConfig
+		if err := network.ConfigureMTU(profile.Interface, conf.WireGuard.MTU); err != nil {
+			logrus.Fatal(errors.Wrap(err, "failed to set wireguard mtu"))
+		}
+
+		logrus.Infof("wireguard VPN network is %s", profile.CIDR)
 
-		if err := network.ConfigureForwarding(conf.WireGuard.Interface, conf.VPN.GatewayInterface, conf.VPN.CIDR, conf.VPN.AllowedIPs); err != nil {
+		if err := network.ConfigureForwarding(profile.Chain, profile.Interface, profile.GatewayInterface, profile.CIDR, profile.AllowedIPs); err != nil {
 			logrus.Fatal(err)
 		}
 	}
 
-	// DNS Server
-	if conf.DNS.Enabled {
-		dns, err := dnsproxy.New(dnsproxy.DNSServerOpts{
-			Upstream: conf.DNS.Upstream,
-		})
-		if err != nil {
-			logrus.Fatal(errors.Wrap(err, "failed to start dns server"))
+	if conf.WireGuard.Enabled && conf.Shaping.Enabled {
+		ifaces := []string{}
+		for _, profile := range profiles {
+			ifaces = append(ifaces, profile.Interface, profile.GatewayInterface)
+		}
+		if err := network.ConfigureShaping(ifaces); err != nil {
+			logrus.Fatal(errors.Wrap(err, "failed to configure bandwidth shaping"))
 		}
-		defer dns.Close()
 	}
 
 	// Storage
@@ -124,10 +175,113 @@ func (cmd *servecmd) Run() {
 	}
 	defer storageBackend.Close()
 
-	// Services
-	deviceManager := devices.New(wg, storageBackend, conf.VPN.CIDR)
-	if err := deviceManager.StartSync(conf.DisableMetadata); err != nil {
-		logrus.Fatal(errors.Wrap(err, "failed to sync"))
+	// Services (one device manager per profile)
+	deviceManagers := devices.Profiles{}
+	reserved := reservedPorts(conf, profiles)
+	for _, profile := range profiles {
+		deviceManager := devices.New(wgs[profile.Name], storageBackend, profile)
+		deviceManager.SetReservedPorts(reserved)
+		deviceManager.SetDefaultBandwidthLimits(conf.Shaping.Limits)
+		deviceManager.SetDNSFilterExemptions(conf.DNS.Blocklist.Exempt)
+		if conf.WireGuard.Enabled && conf.Shaping.Enabled {
+			deviceManager.StartShaping(profile.Interface, profile.GatewayInterface)
+		}
+		if conf.WireGuard.Enabled && !conf.DisableMetadata {
+			if err := deviceManager.StartAccounting(profile.AllowedIPs); err != nil {
+				logrus.Fatal(errors.Wrap(err, "failed to configure traffic accounting"))
+			}
+		}
+		if err := deviceManager.StartSync(conf.DisableMetadata); err != nil {
+			logrus.Fatal(errors.Wrap(err, "failed to sync"))
+		}
+		if conf.WireGuard.Enabled {
+			if err := deviceManager.StartPortForwarding(profile.Interface, profile.GatewayInterface); err != nil {
+				logrus.Fatal(errors.Wrap(err, "failed to configure port forwarding"))
+			}
+		}
+		deviceManagers[profile.Name] = deviceManager
+	}
+
+	// DNS Server
+	var queryLog *dnsproxy.QueryLog
+	healthChecks := []services.HealthCheck{}
+	if conf.DNS.Enabled {
+		strategy, err := dnsproxy.ParseStrategy(conf.DNS.UpstreamStrategy)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		// The DNS server is only reachable via the VPN.
+		// The wireguard interfaces don't exist if wireguard
+		// is disabled so we listen on all interfaces instead.
+		addresses := []string{}
+		if conf.WireGuard.Enabled {
+			for _, profile := range profiles {
+				addresses = append(addresses, network.ServerVPNIP(profile.CIDR).IP.String())
+			}
+		}
+		forward := []dnsproxy.ForwardRule{}
+		for _, rule := range conf.DNS.Forward {
+			forward = append(forward, dnsproxy.ForwardRule{Domain: rule.Domain, Upstream: rule.Upstream})
+		}
+		var zone *dnsproxy.Zone
+		if conf.DNS.Domain != "" {
+			zone = dnsproxy.NewZone(conf.DNS.Domain)
+			if err := zone.WatchDevices(storageBackend); err != nil {
+				logrus.Fatal(errors.Wrap(err, "failed to create dns records for devices"))
+			}
+		}
+		records, err := dnsproxy.NewRecords(staticRecords(conf.DNS.Records))
+		if err != nil {
+			logrus.Fatal(errors.Wrap(err, "invalid dns records"))
+		}
+		go cmd.reloadDNSRecords(records)
+		var blocklist *dnsproxy.Blocklist
+		if len(conf.DNS.Blocklist.Sources) > 0 {
+			blocklist, err = dnsproxy.NewBlocklist(dnsproxy.BlocklistOpts{
+				Sources:  conf.DNS.Blocklist.Sources,
+				Allow:    conf.DNS.Blocklist.Allow,
+				Refresh:  conf.DNS.Blocklist.Refresh,
+				Response: dnsproxy.BlockResponse(conf.DNS.Blocklist.Response),
+			})
+			if err != nil {
+				logrus.Fatal(errors.Wrap(err, "failed to load dns blocklists"))
+			}
+			defer blocklist.Close()
+		}
+		if conf.DNS.QueryLog.Enabled {
+			queryLog, err = dnsproxy.NewQueryLog(dnsproxy.QueryLogOpts{
+				MaxEntries: conf.DNS.QueryLog.MaxEntries,
+				Retention:  conf.DNS.QueryLog.Retention,
+				Sink:       conf.DNS.QueryLog.Sink,
+			})
+			if err != nil {
+				logrus.Fatal(errors.Wrap(err, "failed to create dns query log"))
+			}
+			defer queryLog.Close()
+		}
+		dns, err := dnsproxy.New(dnsproxy.DNSServerOpts{
+			Addresses: addresses,
+			Upstream:  conf.DNS.Upstream,
+			Forward:   forward,
+			Strategy:  strategy,
+			Timeout:   conf.DNS.UpstreamTimeout,
+			Cache: dnsproxy.CacheOpts{
+				Size:     conf.DNS.Cache.Size,
+				MinTTL:   conf.DNS.Cache.MinTTL,
+				MaxTTL:   conf.DNS.Cache.MaxTTL,
+				Prefetch: conf.DNS.Cache.Prefetch,
+			},
+			Records:   records,
+			Zone:      zone,
+			Blocklist: blocklist,
+			Devices:   deviceManagers,
+			QueryLog:  queryLog,
+		})
+		if err != nil {
+			logrus.Fatal(errors.Wrap(err, "failed to start dns server"))
+		}
+		defer dns.Close()
+		healthChecks = append(healthChecks, services.HealthCheck{Name: "dns", Check: dns.Health})
 	}
 
 	router := mux.NewRouter()
@@ -135,12 +289,91 @@ func (cmd *servecmd) Run() {
 	router.Use(services.RecoveryMiddleware)
 
 	// Health check endpoint
-	router.PathPrefix("/health").Handler(services.HealthEndpoint())
+	router.PathPrefix("/health").Handler(services.HealthEndpoint(healthChecks...))
+
+	// Audit log
+	auditLog, err := audit.New(conf.Audit.Sink)
+	if err != nil {
+		logrus.Fatal(errors.Wrap(err, "failed to create audit log"))
+	}
+	defer auditLog.Close()
 
 	// Authentication middleware
+	var sessionStorage storage.Storage
+	tokens := apitokens.New(storageBackend)
 	if conf.Auth.IsEnabled() {
-		router.Use(authnz.NewMiddleware(conf.Auth, claimsMiddleware(conf)))
+		// secrets in storage are encrypted with the session secret
+		var box *secrets.Box
+		if conf.Auth.Sessions.Secret != "" {
+			if box, err = secrets.New(conf.Auth.Sessions.Secret); err != nil {
+				logrus.Fatal(errors.Wrap(err, "failed to create secrets box"))
+			}
+		}
+		var sessionBackend authsession.SessionBackend
+		if conf.Auth.Sessions.ServerSide {
+			if box == nil {
+				logrus.Fatal("server-side sessions require a session secret (auth.sessions.secret) to encrypt sessions")
+			}
+			sessionStorage = storageBackend
+			sessionBackend = storage.NewSessionBackend(storageBackend, box)
+		}
+		// scim users and groups of the scim provider
+		var directory *scim.Directory
+		if conf.SCIM.Enabled {
+			if conf.SCIM.Provider == "" {
+				logrus.Fatal("scim requires the name of the authentication provider of its users (scim.provider)")
+			}
+			directory = scim.NewDirectory(storageBackend, conf.SCIM.Provider)
+		}
+		var deprovisioner *lifecycle.Deprovisioner
+		if conf.Lifecycle.Enabled || conf.SCIM.Enabled {
+			deprovisioner, err = lifecycle.NewDeprovisioner(storageBackend, deviceManagers, auditLog, conf.Lifecycle.Action)
+			if err != nil {
+				logrus.Fatal(errors.Wrap(err, "invalid lifecycle config"))
+			}
+		}
+		var secondFactors authsession.SecondFactors
+		if conf.Auth.Basic != nil && conf.Auth.Basic.TOTP {
+			if box == nil {
+				logrus.Fatal("totp requires a session secret (auth.sessions.secret) to encrypt totp secrets")
+			}
+			secondFactors = totp.New(storageBackend, box)
+		}
+		// users are only recorded for the lifecycle job
+		var users authsession.UserRecorder
+		if conf.Lifecycle.Enabled {
+			if box == nil {
+				logrus.Warn("no session secret is configured - refresh tokens won't be stored and oidc users won't be checked by the lifecycle job")
+			}
+			recorder := lifecycle.NewRecorder(storageBackend, deprovisioner, box)
+			if directory != nil {
+				// users that were deactivated over scim stay deprovisioned
+				recorder.SetReactivationCheck(directory.Allowed)
+			}
+			users = recorder
+		}
+		auth := authnz.New(conf.Auth, claimsMiddleware(conf, directory, storageBackend), sessionBackend, tokens, users, secondFactors, auditLog)
+		router.Use(auth.Middleware)
+		if conf.Lifecycle.Enabled {
+			checkers := lifecycle.ProviderCheckers(auth.Providers(), box)
+			if directory != nil {
+				// the scim directory is the source of truth for its users
+				checkers[conf.SCIM.Provider] = directory.Checker()
+			}
+			lifecycle.New(lifecycle.Opts{
+				Storage:       storageBackend,
+				Checkers:      checkers,
+				Deprovisioner: deprovisioner,
+				Interval:      conf.Lifecycle.Interval,
+			}).Start()
+		}
+		if conf.SCIM.Enabled {
+			router.PathPrefix(scim.Prefix).Handler(scim.NewHandler(directory, deprovisioner))
+		}
 	} else {
+		if conf.SCIM.Enabled {
+			logrus.Fatal("scim requires authentication to be enabled")
+		}
 		logrus.Warn("[DEPRECATION NOTICE] using wg-access-server without an admin user is deprecated and will be removed in an upcoming minor release.")
 		router.Use(func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -160,8 +393,12 @@ func (cmd *servecmd) Run() {
 	// Grpc api
 	site.PathPrefix("/api").Handler(services.ApiRouter(&services.ApiServices{
 		Config:        conf,
-		DeviceManager: deviceManager,
-		Wg:            wg,
+		DeviceManager: deviceManagers[""],
+		Profiles:      deviceManagers,
+		Wg:            wgs[""],
+		QueryLog:      queryLog,
+		Sessions:      sessionStorage,
+		Tokens:        tokens,
 	}))
 
 	// Static website
@@ -169,6 +406,19 @@ func (cmd *servecmd) Run() {
 
 	publicRouter := router
 
+	// Prometheus metrics endpoint. It's served on its own
+	// address because metrics include owner and device names.
+	if conf.MetricsAddress != "" {
+		metricsRouter := http.NewServeMux()
+		metricsRouter.Handle("/metrics", promhttp.Handler())
+		go func() {
+			logrus.Infof("metrics listening on %v", conf.MetricsAddress)
+			if err := http.ListenAndServe(conf.MetricsAddress, metricsRouter); err != nil {
+				logrus.Fatal(errors.Wrap(err, "unable to start metrics http server"))
+			}
+		}()
+	}
+
 	// Listen
 	address := fmt.Sprintf("0.0.0.0:%d", conf.Port)
 	srv := &http.Server{
@@ -232,16 +482,99 @@ func (cmd *servecmd) ReadConfig() *config.AppConfig {
 	return &cmd.AppConfig
 }
 
-func claimsMiddleware(conf *config.AppConfig) authsession.ClaimsMiddleware {
+func staticRecords(records []config.DNSRecord) []dnsproxy.StaticRecord {
+	result := []dnsproxy.StaticRecord{}
+	for _, record := range records {
+		result = append(result, dnsproxy.StaticRecord{
+			Name:  record.Name,
+			Type:  record.Type,
+			Value: record.Value,
+			TTL:   record.TTL,
+		})
+	}
+	return result
+}
+
+// reloadDNSRecords replaces the static dns records with
+// the records in the config file whenever the process
+// receives a SIGHUP.
+func (cmd *servecmd) reloadDNSRecords(records *dnsproxy.Records) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if cmd.ConfigFilePath == "" {
+			logrus.Warn("received SIGHUP but there's no config file to reload dns records from")
+			continue
+		}
+		b, err := ioutil.ReadFile(cmd.ConfigFilePath)
+		if err != nil {
+			logrus.Error(errors.Wrap(err, "failed to read config file"))
+			continue
+		}
+		conf := config.AppConfig{}
+		if err := yaml.Unmarshal(b, &conf); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to parse config file"))
+			continue
+		}
+		if err := records.Set(staticRecords(conf.DNS.Records)); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to reload dns records"))
+			continue
+		}
+		logrus.Infof("reloaded %d dns records", len(conf.DNS.Records))
+	}
+}
+
+func claimsMiddleware(conf *config.AppConfig, directory *scim.Directory, users storage.Storage) authsession.ClaimsMiddleware {
 	return func(user *authsession.Identity) error {
-		if user.Subject == conf.AdminUsername {
+		// cookie sessions can't be revoked so deprovisioned users
+		// are signed out here. they're reactivated when they sign
+		// in again before their new session gets here.
+		if stored, err := users.GetUser(user.Provider, user.Subject); err == nil && stored.Deprovisioned {
+			return authsession.ErrUserDisabled
+		}
+		// scim groups are added first so that roles can be assigned by them
+		if directory != nil {
+			if err := directory.Claims(user); err != nil {
+				return err
+			}
+		}
+		// other providers could have a user with the same name
+		if user.Provider == "basic" && user.Subject == conf.AdminUsername {
 			user.Claims.Add("admin", "true")
 		}
+		rbac.Assign(user, conf.Roles)
+		// api tokens only keep the admin claim and roles with the admin scope
+		apitokens.Restrict(user)
 		return nil
 	}
 }
 
-func detectDNSUpstream() string {
+// reservedPorts returns the ports that the
+// server listens on on every address
+func reservedPorts(conf *config.AppConfig, profiles []config.Profile) []devices.ReservedPort {
+	ports := []devices.ReservedPort{
+		{Protocol: "tcp", Port: conf.Port, Name: "the web ui"},
+	}
+	for _, profile := range profiles {
+		ports = append(ports, devices.ReservedPort{Protocol: "udp", Port: profile.Port, Name: "wireguard"})
+	}
+	if conf.DNS.Enabled {
+		ports = append(ports,
+			devices.ReservedPort{Protocol: "udp", Port: 53, Name: "the dns server"},
+			devices.ReservedPort{Protocol: "tcp", Port: 53, Name: "the dns server"},
+		)
+	}
+	if conf.MetricsAddress != "" {
+		if _, port, err := net.SplitHostPort(conf.MetricsAddress); err == nil {
+			if n, err := strconv.Atoi(port); err == nil {
+				ports = append(ports, devices.ReservedPort{Protocol: "tcp", Port: n, Name: "the metrics server"})
+			}
+		}
+	}
+	return ports
+}
+
+func detectDNSUpstream() []string {
 	upstream := []string{}
 	if r, err := resolvconf.Get(); err == nil {
 		upstream = resolvconf.GetNameservers(r.Content, types.IPv4)
@@ -250,7 +583,7 @@ func detectDNSUpstream() string {
 		logrus.Warn("failed to get nameservers from /etc/resolv.conf defaulting to 1.1.1.1 for DNS instead")
 		upstream = []string{"1.1.1.1"}
 	}
-	return upstream[0]
+	return upstream
 }
 
 func detectDefaultInterface() string {
@@ -0,0 +1,202 @@
+package apitokens
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/securecookie"
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/rbac"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// the scopes that api tokens can have
+const (
+	ScopeDevicesRead  = "devices:read"
+	ScopeDevicesWrite = "devices:write"
+	ScopeAdmin        = "admin"
+	// ScopeSCIM allows an identity provider to
+	// provision users and groups over scim
+	ScopeSCIM = "scim"
+)
+
+// ScopeClaim is added to identities that are authenticated
+// with an api token, once for each of the token's scopes.
+const ScopeClaim = "api-token-scope"
+
+// tokens are prefixed so that they're easy to recognise
+// i.e. by secret scanners
+const tokenPrefix = "wgat_"
+
+// the subjects of service accounts are this prefix and
+// the token's id so they can't collide with each other
+// or the subjects of people
+const serviceAccountPrefix = "service-account:"
+
+type CreateOpts struct {
+	Name   string
+	Scopes []string
+	// ServiceAccount tokens act as a service account
+	// of their own instead of their creator
+	ServiceAccount bool
+	// ExpiresAt is optional
+	ExpiresAt *time.Time
+}
+
+// Tokens creates and verifies api tokens
+type Tokens struct {
+	storage storage.Storage
+}
+
+func New(s storage.Storage) *Tokens {
+	return &Tokens{storage: s}
+}
+
+// ValidateScopes checks that scopes are valid
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least 1 scope is required")
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeDevicesRead, ScopeDevicesWrite, ScopeAdmin, ScopeSCIM:
+		default:
+			return fmt.Errorf("unknown scope '%s' - must be %s, %s, %s or %s", scope, ScopeDevicesRead, ScopeDevicesWrite, ScopeAdmin, ScopeSCIM)
+		}
+	}
+	return nil
+}
+
+// Create creates a token for the creator or a service account.
+// The token is returned once and can't be retrieved later.
+func (t *Tokens) Create(creator *authsession.Identity, opts CreateOpts) (string, *storage.APIToken, error) {
+	if err := ValidateScopes(opts.Scopes); err != nil {
+		return "", nil, err
+	}
+
+	token := tokenPrefix + strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	record := &storage.APIToken{
+		ID:        uuid.New().String(),
+		Hash:      hash(token),
+		Name:      opts.Name,
+		Scopes:    strings.Join(opts.Scopes, " "),
+		CreatedAt: time.Now(),
+		ExpiresAt: opts.ExpiresAt,
+
+		CreatedBy:         creator.Subject,
+		CreatedByProvider: creator.Provider,
+	}
+
+	if opts.ServiceAccount {
+		record.ServiceAccount = true
+		record.Owner = serviceAccountPrefix + record.ID
+		record.OwnerName = opts.Name
+		record.OwnerProvider = "service-account"
+	} else {
+		record.Owner = creator.Subject
+		record.OwnerName = creator.Name
+		record.OwnerEmail = creator.Email
+		record.OwnerEmailVerified = creator.EmailVerified
+		record.OwnerProvider = creator.Provider
+	}
+
+	if err := t.storage.SaveAPIToken(record); err != nil {
+		return "", nil, err
+	}
+	return token, record, nil
+}
+
+// VerifyToken returns the identity that a token acts as.
+// The identity only has a ScopeClaim for each of the token's
+// scopes. The owner's current claims are added by the claims
+// middleware, like for a session, and Restrict must be called
+// after that. Service accounts with the admin scope are admins
+// until they're revoked, which happens when their creator is
+// deprovisioned.
+func (t *Tokens) VerifyToken(token string) (*authsession.Identity, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, errors.New("invalid api token")
+	}
+	record, err := t.storage.GetAPITokenByHash(hash(token))
+	if err != nil {
+		return nil, errors.New("invalid api token")
+	}
+	if record.ExpiresAt != nil && !time.Now().Before(*record.ExpiresAt) {
+		return nil, errors.New("api token expired")
+	}
+
+	claims := authsession.Claims{}
+	for _, scope := range Scopes(record) {
+		claims.Add(ScopeClaim, scope)
+		if scope == ScopeAdmin && record.ServiceAccount {
+			claims.Add("admin", "true")
+		}
+	}
+
+	return &authsession.Identity{
+		Provider: record.OwnerProvider,
+		Subject:  record.Owner,
+		Name:     record.OwnerName,
+		Email:    record.OwnerEmail,
+		Claims:   claims,
+		// the email is matched with scim users
+		EmailVerified: record.OwnerEmailVerified,
+	}, nil
+}
+
+// Restrict removes the admin claim and roles from the identity of
+// a token without the admin scope. A token with the admin scope
+// keeps them only while its owner is still an admin or has a role.
+func Restrict(identity *authsession.Identity) {
+	if !IsToken(identity) || HasScope(identity, ScopeAdmin) {
+		return
+	}
+	claims := authsession.Claims{}
+	for _, c := range identity.Claims {
+		if c.Name != "admin" && c.Name != rbac.RoleClaim {
+			claims.Add(c.Name, c.Value)
+		}
+	}
+	identity.Claims = claims
+}
+
+// Scopes returns the scopes of a token
+func Scopes(token *storage.APIToken) []string {
+	return strings.Fields(token.Scopes)
+}
+
+// IsToken reports if an identity was authenticated with an api token
+func IsToken(identity *authsession.Identity) bool {
+	return identity.Claims.Contains(ScopeClaim)
+}
+
+// HasScope reports if an identity was authenticated
+// with an api token that has a scope
+func HasScope(identity *authsession.Identity, scope string) bool {
+	return identity.Claims.Has(ScopeClaim, scope)
+}
+
+// List returns the tokens of an owner or all tokens if owner is empty
+func (t *Tokens) List(owner string) ([]*storage.APIToken, error) {
+	return t.storage.ListAPITokens(owner)
+}
+
+func (t *Tokens) Get(id string) (*storage.APIToken, error) {
+	return t.storage.GetAPIToken(id)
+}
+
+// Revoke deletes a token so it can't be used anymore
+func (t *Tokens) Revoke(token *storage.APIToken) error {
+	return t.storage.DeleteAPIToken(token)
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package apitokens
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/place1/wg-access-server/internal/rbac"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens(t *testing.T) {
+	require := require.New(t)
+
+	s := storage.NewMemoryStorage()
+	tokens := New(s)
+
+	alice := &authsession.Identity{Provider: "ldap", Subject: "alice", Name: "Alice"}
+	alice.Claims.Add("admin", "true")
+	alice.Claims.Add("group", "engineering")
+	alice.Claims.Add(rbac.RoleClaim, rbac.RoleHelpdesk)
+
+	token, record, err := tokens.Create(alice, CreateOpts{
+		Name:   "provisioning",
+		Scopes: []string{ScopeDevicesRead, ScopeDevicesWrite},
+	})
+	require.NoError(err)
+	require.True(strings.HasPrefix(token, tokenPrefix))
+	require.NotContains(record.Hash, token)
+
+	identity, err := tokens.VerifyToken(token)
+	require.NoError(err)
+	require.Equal("alice", identity.Subject)
+	require.Equal("ldap", identity.Provider)
+	require.True(IsToken(identity))
+	require.True(HasScope(identity, ScopeDevicesWrite))
+	require.False(HasScope(identity, ScopeAdmin))
+	// the owner's claims are added by the claims middleware
+	require.False(identity.Claims.Contains("group"))
+	require.False(identity.Claims.Contains("admin"))
+
+	// the admin claim and roles require the admin scope
+	identity.Claims.Add("admin", "true")
+	identity.Claims.Add(rbac.RoleClaim, rbac.RoleHelpdesk)
+	identity.Claims.Add("group", "engineering")
+	Restrict(identity)
+	require.False(identity.Claims.Contains("admin"))
+	require.False(identity.Claims.Contains(rbac.RoleClaim))
+	require.True(identity.Claims.Has("group", "engineering"))
+	require.True(HasScope(identity, ScopeDevicesWrite))
+
+	token, _, err = tokens.Create(alice, CreateOpts{Name: "admin", Scopes: []string{ScopeAdmin}})
+	require.NoError(err)
+	identity, err = tokens.VerifyToken(token)
+	require.NoError(err)
+	require.False(identity.Claims.Contains("admin"), "only while the owner is an admin")
+	identity.Claims.Add("admin", "true")
+	Restrict(identity)
+	require.True(identity.Claims.Contains("admin"))
+
+	// service accounts
+	token, _, err = tokens.Create(alice, CreateOpts{
+		Name:           "terraform",
+		Scopes:         []string{ScopeAdmin},
+		ServiceAccount: true,
+	})
+	require.NoError(err)
+	identity, err = tokens.VerifyToken(token)
+	require.NoError(err)
+	require.Equal("terraform", identity.Name)
+	require.True(identity.Claims.Contains("admin"))
+	require.False(identity.Claims.Contains("group"))
+	saved, err := tokens.List(identity.Subject)
+	require.NoError(err)
+	require.Len(saved, 1)
+	require.Equal("service-account:"+saved[0].ID, identity.Subject)
+	require.Equal("alice", saved[0].CreatedBy)
+
+	// service accounts with the same name are different accounts
+	token, _, err = tokens.Create(alice, CreateOpts{
+		Name:           "terraform",
+		Scopes:         []string{ScopeDevicesRead},
+		ServiceAccount: true,
+	})
+	require.NoError(err)
+	other, err := tokens.VerifyToken(token)
+	require.NoError(err)
+	require.NotEqual(identity.Subject, other.Subject)
+
+	// invalid tokens
+	_, err = tokens.VerifyToken("wgat_invalid")
+	require.Error(err)
+	_, err = tokens.VerifyToken(record.Hash)
+	require.Error(err)
+	_, _, err = tokens.Create(alice, CreateOpts{Name: "bad", Scopes: []string{"devices:*"}})
+	require.Error(err)
+
+	// expired tokens
+	expired := time.Now().Add(-time.Minute)
+	token, _, err = tokens.Create(alice, CreateOpts{
+		Name:      "old",
+		Scopes:    []string{ScopeDevicesRead},
+		ExpiresAt: &expired,
+	})
+	require.NoError(err)
+	_, err = tokens.VerifyToken(token)
+	require.Error(err)
+
+	// revoked tokens
+	token, record, err = tokens.Create(alice, CreateOpts{Name: "revoked", Scopes: []string{ScopeDevicesRead}})
+	require.NoError(err)
+	require.NoError(tokens.Revoke(record))
+	_, err = tokens.VerifyToken(token)
+	require.Error(err)
+
+	owned, err := tokens.List("alice")
+	require.NoError(err)
+	require.Len(owned, 3)
+}
@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Event is something that happened to a user or device
+// that administrators may need to review later.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Action is what happened i.e. "device.disabled"
+	Action string `json:"action"`
+	// Actor is who or what did it i.e. "lifecycle"
+	Actor string `json:"actor"`
+	// User is the affected user i.e. "ldap/alice"
+	User string `json:"user,omitempty"`
+	// Device is the name of the affected device
+	Device string `json:"device,omitempty"`
+	// Address is the source IP address of a login
+	Address string `json:"address,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Log records audit events. Events are logged and
+// written to the sink if there is one.
+type Log struct {
+	lock sync.Mutex
+	sink io.WriteCloser
+}
+
+// New creates a Log. sink is a file path, "syslog"
+// or "syslog://host:514" and may be empty.
+func New(sink string) (*Log, error) {
+	w, err := openSink(sink)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open audit log sink %s", sink)
+	}
+	return &Log{sink: w}, nil
+}
+
+func openSink(sink string) (io.WriteCloser, error) {
+	switch {
+	case sink == "":
+		return nil, nil
+	case sink == "syslog":
+		return syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, "wg-access-server")
+	case strings.HasPrefix(sink, "syslog://"):
+		u, err := url.Parse(sink)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address")
+		}
+		return syslog.Dial("udp", u.Host, syslog.LOG_NOTICE|syslog.LOG_AUTH, "wg-access-server")
+	}
+	return os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+func (l *Log) Close() error {
+	if l.sink != nil {
+		return l.sink.Close()
+	}
+	return nil
+}
+
+// Record logs an event. A nil Log only logs the event.
+func (l *Log) Record(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"action":  event.Action,
+		"actor":   event.Actor,
+		"user":    event.User,
+		"device":  event.Device,
+		"address": event.Address,
+		"reason":  event.Reason,
+	}).Info("audit event")
+
+	if l == nil || l.sink == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = l.sink.Write(append(line, '\n'))
+	}
+	if err != nil {
+		logrus.Error(errors.Wrap(err, "failed to write to audit log sink"))
+	}
+}
+
+// LoginFailed records a failed password login.
+// It implements authsession.LoginAuditor.
+func (l *Log) LoginFailed(provider string, username string, address string, locked bool) {
+	l.Record(Event{
+		Action:  "login.failed",
+		Actor:   "auth",
+		User:    provider + "/" + username,
+		Address: address,
+		Reason:  "wrong username or password",
+	})
+	if locked {
+		l.Record(Event{
+			Action:  "login.locked",
+			Actor:   "auth",
+			User:    provider + "/" + username,
+			Address: address,
+			Reason:  "too many failed logins",
+		})
+	}
+}
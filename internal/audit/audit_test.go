@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSink(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	log, err := New(path)
+	require.NoError(err)
+	log.Record(Event{Action: "device.disabled", Actor: "lifecycle", User: "ldap/alice", Device: "laptop"})
+	log.Record(Event{Action: "user.deprovisioned", Actor: "lifecycle", User: "ldap/alice"})
+	require.NoError(log.Close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(lines, 2)
+
+	event := Event{}
+	require.NoError(json.Unmarshal([]byte(lines[0]), &event))
+	require.Equal("device.disabled", event.Action)
+	require.Equal("laptop", event.Device)
+	require.False(event.Time.IsZero())
+
+	// a nil log still logs events
+	var nilLog *Log
+	nilLog.Record(Event{Action: "device.deleted"})
+}
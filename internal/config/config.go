@@ -1,6 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/place1/wg-access-server/pkg/authnz/authconfig"
 )
 
@@ -16,6 +22,12 @@ type AppConfig struct {
 	// Port sets the port that the web UI will listen on.
 	// Defaults to 8000
 	Port int `yaml:"port"`
+	// MetricsAddress is the host:port that Prometheus metrics
+	// are served on at /metrics. The metrics include owner and
+	// device names so they're served separately from the web UI,
+	// i.e. on a private network or localhost.
+	// Metrics aren't served if it's empty (the default).
+	MetricsAddress string `yaml:"metricsAddress"`
 	// ExternalAddress is the address that clients
 	// use to connect to the wireguard interface
 	// By default, this will be empty and the web ui
@@ -51,6 +63,24 @@ type AppConfig struct {
 		// The WireGuard ListenPort
 		// Defaults to 51820
 		Port int `yaml:"port"`
+		// The MTU of the WireGuard interface.
+		// This value is also included in client
+		// config files.
+		// Defaults to 1420
+		MTU int `yaml:"mtu"`
+		// PersistentKeepalive is the interval in seconds
+		// at which clients send keepalive packets to the server.
+		// Clients behind NAT (i.e. mobile networks) need this
+		// to stay connected while idle.
+		// Devices can override this value.
+		// Defaults to 0 (disabled)
+		PersistentKeepalive int `yaml:"persistentKeepalive"`
+		// Endpoint is the host:port that clients will connect to.
+		// By default client config files use the web ui's host
+		// and the WireGuard port.
+		// This is useful when the WireGuard server is reachable
+		// at a different address to the web ui.
+		Endpoint string `yaml:"endpoint"`
 	} `yaml:"wireguard"`
 	// Configure VPN related settings (networking)
 	VPN struct {
@@ -71,6 +101,25 @@ type AppConfig struct {
 		// defaults to ["0.0.0.0/0"]
 		AllowedIPs []string `yaml:"allowedIPs"`
 	} `yaml:"vpn"`
+	// Profiles are additional VPN networks that are served
+	// alongside the default network configured above.
+	// Each profile has its own WireGuard interface and
+	// users pick a profile when they add a device.
+	Profiles []Profile `yaml:"profiles"`
+	// Configure per device bandwidth limits
+	Shaping struct {
+		// Enabled turns on bandwidth shaping for devices.
+		// Limits are enforced using tc classes on the wireguard
+		// interface (traffic to devices) and the VPN gateway
+		// interface (traffic from devices).
+		// Defaults to false
+		Enabled bool `yaml:"enabled"`
+		// Limits are default bandwidth limits that are given
+		// to new devices based on the claims of their owner.
+		// The first matching limit is used.
+		// Admins can override the limits of individual devices.
+		Limits []BandwidthLimit `yaml:"limits"`
+	} `yaml:"shaping"`
 	// Configure the embeded DNS server
 	DNS struct {
 		// Enabled allows you to turn on/off
@@ -81,8 +130,52 @@ type AppConfig struct {
 		// DNS servers to which client DNS requests will be sent to.
 		// Defaults the host's upstream DNS servers (via resolveconf)
 		// or 1.1.1.1 if resolveconf cannot be used.
-		// NOTE: currently wg-access-server will only use the first upstream.
+		// Upstreams may include a port (e.g. 10.0.0.53:5353)
+		// or be a DNS-over-HTTPS (https://1.1.1.1/dns-query) or
+		// DNS-over-TLS (tls://9.9.9.9:853) url.
+		// Encrypted upstreams are verified using the host's
+		// root certificates.
 		Upstream []string `yaml:"upstream"`
+		// UpstreamStrategy decides which upstream is tried first:
+		// "failover" uses the upstreams in the order above,
+		// "round-robin" spreads queries across all upstreams and
+		// "fastest" prefers the upstream with the lowest latency.
+		// Failed queries (timeout or SERVFAIL) are retried on the
+		// next upstream and repeatedly failing upstreams are skipped
+		// for a while.
+		// Defaults to "failover"
+		UpstreamStrategy string `yaml:"upstreamStrategy"`
+		// UpstreamTimeout is how long to wait for an upstream
+		// before retrying the query on the next one.
+		// Defaults to 2s
+		UpstreamTimeout time.Duration `yaml:"upstreamTimeout"`
+		// Forward sends queries for some domains to other
+		// upstreams, i.e. internal zones that only resolve
+		// on an office DNS server. The most specific
+		// matching domain wins. Everything else goes to
+		// the upstreams above.
+		Forward []DNSForward `yaml:"forward"`
+		// Blocklist filters ads, trackers and malware
+		// domains for VPN clients.
+		// Disabled by default
+		Blocklist DNSBlocklist `yaml:"blocklist"`
+		// Cache configures how responses from
+		// the upstreams are cached.
+		Cache DNSCache `yaml:"cache"`
+		// QueryLog records the DNS queries of each
+		// device for incident response.
+		QueryLog DNSQueryLog `yaml:"queryLog"`
+		// Records are static DNS records that are answered
+		// authoritatively instead of going upstream.
+		// They're reloaded from the config file on SIGHUP.
+		Records []DNSRecord `yaml:"records"`
+		// Domain is a zone (e.g. "vpn.internal") in which
+		// the DNS server creates records for each device
+		// i.e. <device>.<owner>.vpn.internal resolves to
+		// the device's VPN IP. Reverse (PTR) lookups of
+		// device IPs are answered too.
+		// Disabled by default
+		Domain string `yaml:"domain"`
 	} `yaml:"dns"`
 	// Auth configures optional authentication backends
 	// to controll access to the web ui.
@@ -91,4 +184,59 @@ type AppConfig struct {
 	// If no authentication backends are configured then
 	// the server will not require any authentication.
 	Auth authconfig.AuthConfig `yaml:"auth"`
+	// Roles give users permissions beyond managing
+	// their own devices. The admin user is always an admin.
+	Roles []RoleAssignment `yaml:"roles"`
+	// Lifecycle periodically checks device owners with their
+	// identity provider and disables or deletes the devices
+	// of users that have been deprovisioned.
+	// Disabled by default
+	Lifecycle Lifecycle `yaml:"lifecycle"`
+	// Audit configures where audit events are written.
+	// Events are always logged.
+	Audit Audit `yaml:"audit"`
+	// SCIM lets an identity provider push users and
+	// groups to wg-access-server.
+	// Disabled by default
+	SCIM SCIM `yaml:"scim"`
+}
+
+// ValidateWireGuard checks the WireGuard settings
+// that are included in client config files.
+func ValidateWireGuard(c *AppConfig) error {
+	if c.WireGuard.PersistentKeepalive < 0 || c.WireGuard.PersistentKeepalive > 65535 {
+		return fmt.Errorf("wireguard persistentKeepalive must be between 0 and 65535 seconds")
+	}
+	// 1280 is the minimum MTU of ipv6
+	if c.WireGuard.MTU != 0 && (c.WireGuard.MTU < 1280 || c.WireGuard.MTU > 65535) {
+		return fmt.Errorf("wireguard mtu must be between 1280 and 65535")
+	}
+	if err := validateEndpoint(c.WireGuard.Endpoint); err != nil {
+		return errors.Wrap(err, "invalid wireguard endpoint")
+	}
+	for _, p := range c.Profiles {
+		if err := validateEndpoint(p.Endpoint); err != nil {
+			return errors.Wrapf(err, "invalid endpoint for vpn profile '%s'", p.Name)
+		}
+	}
+	return nil
+}
+
+// validateEndpoint checks that an endpoint
+// is empty or a host:port
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("%s has no host", endpoint)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%s must have a port between 1 and 65535", endpoint)
+	}
+	return nil
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWireGuard(t *testing.T) {
+	require := require.New(t)
+
+	for keepalive, valid := range map[int]bool{-1: false, 0: true, 25: true, 65535: true, 65536: false} {
+		conf := &AppConfig{}
+		conf.WireGuard.PersistentKeepalive = keepalive
+		if valid {
+			require.NoError(ValidateWireGuard(conf), keepalive)
+		} else {
+			require.Error(ValidateWireGuard(conf), keepalive)
+		}
+	}
+
+	for mtu, valid := range map[int]bool{0: true, 1279: false, 1280: true, 1420: true, 65535: true, 65536: false, -1: false} {
+		conf := &AppConfig{}
+		conf.WireGuard.MTU = mtu
+		if valid {
+			require.NoError(ValidateWireGuard(conf), mtu)
+		} else {
+			require.Error(ValidateWireGuard(conf), mtu)
+		}
+	}
+
+	for endpoint, valid := range map[string]bool{
+		"":                      true,
+		"vpn.example.com:51820": true,
+		"1.2.3.4:51820":         true,
+		"[::1]:51820":           true,
+		"vpn.example.com":       false,
+		":51820":                false,
+		"vpn.example.com:0":     false,
+		"vpn.example.com:wg":    false,
+	} {
+		conf := &AppConfig{}
+		conf.WireGuard.Endpoint = endpoint
+		conf.Profiles = []Profile{{Name: "guests", Endpoint: endpoint}}
+		if valid {
+			require.NoError(ValidateWireGuard(conf), endpoint)
+		} else {
+			require.Error(ValidateWireGuard(conf), endpoint)
+			conf.WireGuard.Endpoint = ""
+			require.Error(ValidateWireGuard(conf), "profile endpoints are checked too: %s", endpoint)
+		}
+	}
+}
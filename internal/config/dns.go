@@ -0,0 +1,101 @@
+package config
+
+import "time"
+
+// DNSForward sends DNS queries for a domain
+// to specific upstream DNS servers.
+type DNSForward struct {
+	// Domain matches itself and all of its
+	// subdomains e.g. "corp.example.com"
+	Domain string `yaml:"domain"`
+	// Upstream are the DNS servers for the domain.
+	// They support the same addresses as dns.upstream.
+	Upstream []string `yaml:"upstream"`
+}
+
+// DNSBlocklist configures DNS filtering of ads,
+// trackers and malware for VPN clients.
+type DNSBlocklist struct {
+	// Sources are blocklist files or http(s) urls.
+	// Both hosts files ("0.0.0.0 ads.example.com")
+	// and domain lists (one domain per line) are supported.
+	// Filtering is disabled if there are no sources.
+	Sources []string `yaml:"sources"`
+	// Allow are domains that are never blocked even
+	// if they are in a blocklist.
+	Allow []string `yaml:"allow"`
+	// Refresh is how often the sources are reloaded.
+	// Defaults to 24h
+	Refresh time.Duration `yaml:"refresh"`
+	// Response is the answer to blocked queries:
+	// "nxdomain" or "null" (0.0.0.0 and ::).
+	// Defaults to "nxdomain"
+	Response string `yaml:"response"`
+	// Exempt lists claims whose devices aren't filtered.
+	// Exemptions are decided when a device is added.
+	Exempt []DNSBlocklistExemption `yaml:"exempt"`
+}
+
+type DNSBlocklistExemption struct {
+	// Claim is the name of a claim that the device
+	// owner must have to be exempt from filtering.
+	Claim string `yaml:"claim"`
+	// Value optionally restricts the exemption to
+	// owners with a specific value for the claim.
+	Value string `yaml:"value"`
+}
+
+// DNSCache configures the DNS proxy's response cache.
+type DNSCache struct {
+	// Size is the maximum number of cached responses.
+	// Defaults to 10000
+	Size int `yaml:"size"`
+	// MinTTL raises the TTL of short lived records
+	// so that they're cached for at least this long.
+	// It doesn't apply to negative answers and must
+	// not be greater than MaxTTL.
+	// Defaults to 0 (the record's TTL is used)
+	MinTTL time.Duration `yaml:"minTTL"`
+	// MaxTTL limits how long records are cached.
+	// Defaults to 24h
+	MaxTTL time.Duration `yaml:"maxTTL"`
+	// Prefetch refreshes popular responses from
+	// the upstream shortly before they expire.
+	// Defaults to false
+	Prefetch bool `yaml:"prefetch"`
+}
+
+// DNSQueryLog configures the logging of
+// DNS queries from VPN devices.
+type DNSQueryLog struct {
+	// Enabled records which device resolved which name.
+	// Admins can search recent queries via the API.
+	// Disable it if this is a privacy concern.
+	// Defaults to true
+	Enabled bool `yaml:"enabled"`
+	// Retention is how long queries are kept in memory.
+	// Defaults to 24h
+	Retention time.Duration `yaml:"retention"`
+	// MaxEntries is the number of queries kept in memory.
+	// Defaults to 10000
+	MaxEntries int `yaml:"maxEntries"`
+	// Sink optionally writes each query as a json line
+	// to a file path, "syslog" for the local syslog daemon
+	// or "syslog://host:514" for a remote syslog server.
+	Sink string `yaml:"sink"`
+}
+
+// DNSRecord is a static DNS record that's answered
+// by the embedded DNS server.
+type DNSRecord struct {
+	// Name is the record's name e.g. "git.internal"
+	Name string `yaml:"name"`
+	// Type is one of A, AAAA, CNAME or TXT
+	Type string `yaml:"type"`
+	// Value is an IP address (A/AAAA),
+	// a domain name (CNAME) or text (TXT)
+	Value string `yaml:"value"`
+	// TTL in seconds.
+	// Defaults to 300
+	TTL uint32 `yaml:"ttl"`
+}
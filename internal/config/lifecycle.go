@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+type Lifecycle struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often device owners are checked.
+	// Users that signed in or refreshed their session
+	// more recently than this aren't checked.
+	// Defaults to 1h
+	Interval time.Duration `yaml:"interval"`
+	// Action is what happens to the devices of a
+	// deprovisioned user: "disable" or "delete".
+	// Disabled devices are enabled again if the user
+	// signs in again.
+	// Defaults to "disable"
+	Action string `yaml:"action"`
+}
+
+type Audit struct {
+	// Sink optionally writes each audit event as a line
+	// of json to a file path, "syslog" (the local syslog
+	// daemon) or "syslog://host:514" (a remote syslog
+	// server over udp).
+	Sink string `yaml:"sink"`
+}
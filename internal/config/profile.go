@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// DefaultChain is the iptables chain prefix
+// of the default VPN profile.
+const DefaultChain = "WG_ACCESS_SERVER"
+
+// iptables chain names are limited to 28 characters
+// and the longest suffix we add is "_POSTROUTING"
+const maxChainLength = 16
+
+// linux network interface names are limited to 15 characters
+const maxInterfaceLength = 15
+
+// profile names are used in interface and
+// iptables chain names and in the api
+var validProfileName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Profile is a VPN network with its own WireGuard
+// interface. Devices are bound to a profile when they
+// are created.
+type Profile struct {
+	// Name identifies the profile. It must be unique and
+	// only contain lowercase letters, digits and '-'.
+	// The default profile (configured by the top level
+	// wireguard and vpn settings) has an empty name.
+	Name string `yaml:"name"`
+	// Claim and Value limit which users may create devices
+	// in this profile. If Value is empty then any value of
+	// the claim is accepted.
+	// If Claim is empty then all users may use the profile.
+	Claim string `yaml:"claim"`
+	Value string `yaml:"value"`
+	// The network interface name of the profile's
+	// WireGuard network device.
+	// Defaults to "wg-" followed by the profile name
+	Interface string `yaml:"interface"`
+	// The WireGuard ListenPort (required)
+	Port int `yaml:"port"`
+	// Endpoint is the host:port that clients will connect to.
+	// Defaults to the host of wireguard.endpoint (or the web ui's host)
+	// and the profile's port.
+	Endpoint string `yaml:"endpoint"`
+	// CIDR configures the network address space
+	// that devices in this profile will be allocated
+	// an IP address from (required)
+	// It must not overlap with any other profile.
+	CIDR string `yaml:"cidr"`
+	// GatewayInterface will be used in iptable forwarding
+	// rules for this profile.
+	// Defaults to vpn.gatewayInterface
+	GatewayInterface string `yaml:"gatewayInterface"`
+	// The "AllowedIPs" for devices in this profile.
+	// defaults to ["0.0.0.0/0"]
+	AllowedIPs []string `yaml:"allowedIPs"`
+	// Configure the DNS servers of the profile's devices
+	DNS struct {
+		// Servers are included in client config files
+		// instead of the embedded DNS proxy. This is useful
+		// to hand out an internal DNS server to split
+		// tunnel devices.
+		// Defaults to the embedded DNS proxy (if enabled).
+		Servers []string `yaml:"servers"`
+	} `yaml:"dns"`
+	// Chain is the prefix of the iptables chains that hold the
+	// profile's forwarding rules. It may be at most 16 characters.
+	// Defaults to "WG_" followed by the upper case profile name
+	Chain string `yaml:"chain"`
+}
+
+// VPNProfiles returns the default profile followed by
+// any additional profiles from the config file.
+// Defaults are filled in for any missing settings.
+func (c *AppConfig) VPNProfiles() []Profile {
+	def := Profile{
+		Interface:        c.WireGuard.Interface,
+		Port:             c.WireGuard.Port,
+		Endpoint:         c.WireGuard.Endpoint,
+		CIDR:             c.VPN.CIDR,
+		GatewayInterface: c.VPN.GatewayInterface,
+		AllowedIPs:       c.VPN.AllowedIPs,
+		Chain:            DefaultChain,
+	}
+
+	profiles := []Profile{def}
+	for _, p := range c.Profiles {
+		if p.Interface == "" {
+			p.Interface = "wg-" + p.Name
+		}
+		if p.GatewayInterface == "" {
+			p.GatewayInterface = c.VPN.GatewayInterface
+		}
+		if len(p.AllowedIPs) == 0 {
+			p.AllowedIPs = []string{"0.0.0.0/0"}
+		}
+		if p.Chain == "" {
+			p.Chain = "WG_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// ValidateProfiles checks that profiles don't conflict with each other.
+func ValidateProfiles(profiles []Profile) error {
+	names := map[string]bool{}
+	ifaces := map[string]bool{}
+	ports := map[int]bool{}
+	chains := map[string]bool{}
+	networks := []*net.IPNet{}
+
+	for _, p := range profiles {
+		if names[p.Name] {
+			return fmt.Errorf("vpn profile names must be unique: '%s'", p.Name)
+		}
+		names[p.Name] = true
+		// only the default profile has no name
+		if p.Name != "" && !validProfileName.MatchString(p.Name) {
+			return fmt.Errorf("vpn profile name '%s' must only contain lowercase letters, digits and '-'", p.Name)
+		}
+
+		if p.Interface == "" || len(p.Interface) > maxInterfaceLength {
+			return fmt.Errorf("vpn profile '%s' interface must be between 1 and %d characters", p.Name, maxInterfaceLength)
+		}
+		if ifaces[p.Interface] {
+			return fmt.Errorf("vpn profile '%s' interface %s is already used by another profile", p.Name, p.Interface)
+		}
+		ifaces[p.Interface] = true
+
+		if p.Port <= 0 || p.Port > 65535 {
+			return fmt.Errorf("vpn profile '%s' port must be between 1 and 65535", p.Name)
+		}
+		if ports[p.Port] {
+			return fmt.Errorf("vpn profile '%s' port %d is already used by another profile", p.Name, p.Port)
+		}
+		ports[p.Port] = true
+
+		if p.Chain == "" || len(p.Chain) > maxChainLength {
+			return fmt.Errorf("vpn profile '%s' chain must be between 1 and %d characters", p.Name, maxChainLength)
+		}
+		if chains[p.Chain] {
+			return fmt.Errorf("vpn profile '%s' chain %s is already used by another profile", p.Name, p.Chain)
+		}
+		chains[p.Chain] = true
+
+		_, cidr, err := net.ParseCIDR(p.CIDR)
+		if err != nil {
+			return fmt.Errorf("vpn profile '%s' has an invalid cidr '%s'", p.Name, p.CIDR)
+		}
+		for _, other := range networks {
+			if other.Contains(cidr.IP) || cidr.Contains(other.IP) {
+				return fmt.Errorf("vpn profile '%s' cidr %s overlaps with %s", p.Name, cidr, other)
+			}
+		}
+		networks = append(networks, cidr)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVPNProfiles(t *testing.T) {
+	require := require.New(t)
+
+	conf := &AppConfig{}
+	conf.WireGuard.Interface = "wg0"
+	conf.WireGuard.Port = 51820
+	conf.VPN.CIDR = "10.44.0.0/24"
+	conf.VPN.GatewayInterface = "eth0"
+	conf.VPN.AllowedIPs = []string{"10.0.0.0/8"}
+	conf.Profiles = []Profile{
+		{Name: "office-lan", Port: 51821, CIDR: "10.45.0.0/24"},
+		{Name: "lab", Port: 51822, CIDR: "10.46.0.0/24", Interface: "wglab", GatewayInterface: "eth1", Chain: "LAB", AllowedIPs: []string{"192.168.0.0/16"}},
+	}
+
+	profiles := conf.VPNProfiles()
+	require.Len(profiles, 3)
+	require.Equal(Profile{
+		Interface:        "wg0",
+		Port:             51820,
+		CIDR:             "10.44.0.0/24",
+		GatewayInterface: "eth0",
+		AllowedIPs:       []string{"10.0.0.0/8"},
+		Chain:            DefaultChain,
+	}, profiles[0])
+
+	require.Equal("wg-office-lan", profiles[1].Interface)
+	require.Equal("eth0", profiles[1].GatewayInterface)
+	require.Equal([]string{"0.0.0.0/0"}, profiles[1].AllowedIPs)
+	require.Equal("WG_OFFICE_LAN", profiles[1].Chain)
+
+	require.Equal("wglab", profiles[2].Interface)
+	require.Equal("eth1", profiles[2].GatewayInterface)
+	require.Equal([]string{"192.168.0.0/16"}, profiles[2].AllowedIPs)
+	require.Equal("LAB", profiles[2].Chain)
+
+	require.NoError(ValidateProfiles(profiles))
+}
+
+func TestValidateProfiles(t *testing.T) {
+	profile := func(name string, iface string, port int, chain string, cidr string) Profile {
+		return Profile{Name: name, Interface: iface, Port: port, Chain: chain, CIDR: cidr}
+	}
+	def := profile("", "wg0", 51820, DefaultChain, "10.44.0.0/24")
+
+	for name, tc := range map[string]struct {
+		profile Profile
+		err     string
+	}{
+		"valid":            {profile("a", "wg-a", 51821, "WG_A", "10.45.0.0/24"), ""},
+		"duplicate name":   {profile("", "wg-a", 51821, "WG_A", "10.45.0.0/24"), "names must be unique"},
+		"invalid name":     {profile("Guest WiFi", "wg-a", 51821, "WG_A", "10.45.0.0/24"), "must only contain lowercase letters"},
+		"underscore name":  {profile("guest_wifi", "wg-a", 51821, "WG_A", "10.45.0.0/24"), "must only contain lowercase letters"},
+		"dashed name":      {profile("guest-wifi-2", "wg-a", 51821, "WG_A", "10.45.0.0/24"), ""},
+		"duplicate iface":  {profile("a", "wg0", 51821, "WG_A", "10.45.0.0/24"), "interface wg0 is already used"},
+		"long iface":       {profile("a", "wg-sixteen-chars", 51821, "WG_A", "10.45.0.0/24"), "between 1 and 15 characters"},
+		"duplicate port":   {profile("a", "wg-a", 51820, "WG_A", "10.45.0.0/24"), "port 51820 is already used"},
+		"invalid port":     {profile("a", "wg-a", 70000, "WG_A", "10.45.0.0/24"), "port must be between 1 and 65535"},
+		"duplicate chain":  {profile("a", "wg-a", 51821, DefaultChain, "10.45.0.0/24"), "is already used by another profile"},
+		"long chain":       {profile("a", "wg-a", 51821, "WG_SEVENTEEN_CHR", "10.45.0.0/24"), ""},
+		"too long chain":   {profile("a", "wg-a", 51821, "WG_SEVENTEEN_CHRS", "10.45.0.0/24"), "chain must be between 1 and 16 characters"},
+		"invalid cidr":     {profile("a", "wg-a", 51821, "WG_A", "10.45.0.0"), "invalid cidr"},
+		"overlapping cidr": {profile("a", "wg-a", 51821, "WG_A", "10.44.0.128/25"), "overlaps with 10.44.0.0/24"},
+		"containing cidr":  {profile("a", "wg-a", 51821, "WG_A", "10.0.0.0/8"), "overlaps with 10.44.0.0/24"},
+		"adjacent cidr":    {profile("a", "wg-a", 51821, "WG_A", "10.44.1.0/24"), ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateProfiles([]Profile{def, tc.profile})
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
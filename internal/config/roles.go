@@ -0,0 +1,21 @@
+package config
+
+// RoleAssignment gives a role to the users that match it.
+// At least one of Subject, Email or Claim must be set and
+// all of the fields that are set must match.
+type RoleAssignment struct {
+	// Role is "admin", "auditor", "helpdesk" or "user"
+	Role string `yaml:"role"`
+	// Provider optionally restricts the assignment to users
+	// of an authentication provider i.e. "basic" or the name
+	// of an oidc/gitlab/ldap provider
+	Provider string `yaml:"provider"`
+	// Subject is the user's id e.g. their username
+	Subject string `yaml:"subject"`
+	// Email is compared case insensitively
+	Email string `yaml:"email"`
+	// Claim and Value match users with a claim.
+	// If Value is empty then any value of the claim is accepted.
+	Claim string `yaml:"claim"`
+	Value string `yaml:"value"`
+}
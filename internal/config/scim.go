@@ -0,0 +1,9 @@
+package config
+
+type SCIM struct {
+	Enabled bool `yaml:"enabled"`
+	// Provider is the name of the authentication provider
+	// that the identity provider's users sign in with.
+	// Users of other providers aren't affected by scim.
+	Provider string `yaml:"provider"`
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type BandwidthLimit struct {
+	// Claim is the name of a claim that the device owner
+	// must have for this limit to apply.
+	// If empty then the limit applies to everyone.
+	Claim string `yaml:"claim"`
+	// Value optionally restricts the limit to owners
+	// with a specific value for the claim.
+	Value string `yaml:"value"`
+	// Ingress limits traffic sent to the device
+	// i.e. the device's download speed
+	Ingress Bitrate `yaml:"ingress"`
+	// Egress limits traffic sent by the device
+	// i.e. the device's upload speed
+	Egress Bitrate `yaml:"egress"`
+}
+
+// Bitrate is a rate in bits per second.
+// In the config file it can be written using tc style
+// units e.g. "512kbit", "10mbit" or "1gbit".
+// A rate of 0 means unlimited.
+type Bitrate uint64
+
+// MinBitrate is the lowest rate that can be enforced.
+// tc rates are in bytes per second so lower rates
+// would be rounded down to 0.
+const MinBitrate = 8
+
+var bitrateUnits = map[string]uint64{
+	"bit":  1,
+	"kbit": 1000,
+	"mbit": 1000 * 1000,
+	"gbit": 1000 * 1000 * 1000,
+}
+
+func ParseBitrate(value string) (Bitrate, error) {
+	rate, err := parseBitrate(value)
+	if err != nil {
+		return 0, err
+	}
+	if rate != 0 && rate < MinBitrate {
+		return 0, fmt.Errorf("bitrate is too small: %s - must be 0 or at least %dbit", value, MinBitrate)
+	}
+	return rate, nil
+}
+
+func parseBitrate(value string) (Bitrate, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	for _, unit := range []string{"kbit", "mbit", "gbit", "bit"} {
+		if strings.HasSuffix(value, unit) {
+			n, err := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(value, unit)), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid bitrate: %s", value)
+			}
+			if n > math.MaxUint64/bitrateUnits[unit] {
+				return 0, fmt.Errorf("bitrate is too large: %s", value)
+			}
+			return Bitrate(n * bitrateUnits[unit]), nil
+		}
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bitrate: %s", value)
+	}
+	return Bitrate(n), nil
+}
+
+// UnmarshalYAML will decode a tc style rate string into a Bitrate
+func (b *Bitrate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	rate, err := ParseBitrate(value)
+	if err != nil {
+		return errors.Wrap(err, "unable to process bandwidth limit")
+	}
+	*b = rate
+	return nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBitrate(t *testing.T) {
+	require := require.New(t)
+
+	for value, expected := range map[string]Bitrate{
+		"":         0,
+		"0":        0,
+		"8":        8,
+		"512":      512,
+		"512bit":   512,
+		"512kbit":  512 * 1000,
+		" 10Mbit ": 10 * 1000 * 1000,
+		"1gbit":    1000 * 1000 * 1000,
+	} {
+		rate, err := ParseBitrate(value)
+		require.NoError(err, value)
+		require.Equal(expected, rate, value)
+	}
+
+	for _, value := range []string{"fast", "-1mbit", "1.5mbit", "10mb", "99999999999999gbit", "18446744073709552kbit", "1", "7bit"} {
+		_, err := ParseBitrate(value)
+		require.Error(err, value)
+	}
+}
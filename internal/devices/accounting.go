@@ -0,0 +1,159 @@
+package devices
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/network"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+var deviceTrafficBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "wg_access_server_device_traffic_bytes_total",
+	Help: "Bytes exchanged between a device and an allowed network",
+}, []string{"owner", "device", "destination", "direction"})
+
+type accounting struct {
+	lock         sync.Mutex
+	destinations []string
+	devices      map[string]bool
+	last         map[[2]string]network.TrafficCounter
+}
+
+// StartAccounting counts the traffic that each device
+// exchanges with each of the given destination networks.
+// The counters are persisted by the metadata loop.
+// It must be called before StartSync.
+func (d *DeviceManager) StartAccounting(destinations []string) error {
+	if err := network.ConfigureAccounting(d.profile.Chain); err != nil {
+		return errors.Wrap(err, "failed to configure traffic accounting")
+	}
+	d.accounting = &accounting{
+		destinations: destinations,
+		devices:      map[string]bool{},
+		last:         map[[2]string]network.TrafficCounter{},
+	}
+	return nil
+}
+
+func (d *DeviceManager) ListDeviceTraffic(owner string) ([]*storage.DeviceTraffic, error) {
+	return d.storage.ListDeviceTraffic(owner)
+}
+
+func (d *DeviceManager) addDeviceAccounting(device *storage.Device) error {
+	if d.accounting == nil {
+		return nil
+	}
+	d.accounting.lock.Lock()
+	defer d.accounting.lock.Unlock()
+
+	ip, _ := MustParseCIDR(device.Address)
+	if d.accounting.devices[ip.String()] {
+		return nil
+	}
+	if err := network.SetDeviceAccounting(d.profile.Chain, ip.String(), d.accounting.destinations); err != nil {
+		return err
+	}
+	d.accounting.devices[ip.String()] = true
+	return nil
+}
+
+func (d *DeviceManager) removeDeviceAccounting(device *storage.Device) error {
+	if d.accounting == nil {
+		return nil
+	}
+	d.accounting.lock.Lock()
+	defer d.accounting.lock.Unlock()
+
+	ip, _ := MustParseCIDR(device.Address)
+	if err := network.RemoveDeviceAccounting(d.profile.Chain, ip.String(), d.accounting.destinations); err != nil {
+		return err
+	}
+	delete(d.accounting.devices, ip.String())
+	for k := range d.accounting.last {
+		if k[0] == ip.String() {
+			delete(d.accounting.last, k)
+		}
+	}
+	return nil
+}
+
+func syncTraffic(d *DeviceManager) {
+	if d.accounting == nil {
+		return
+	}
+	d.accounting.lock.Lock()
+	defer d.accounting.lock.Unlock()
+
+	counters, err := network.ReadAccounting(d.profile.Chain, d.accounting.destinations)
+	if err != nil {
+		logrus.Warn(errors.Wrap(err, "failed to read traffic counters"))
+		return
+	}
+
+	devices, err := d.listProfileDevices()
+	if err != nil {
+		logrus.Warn(errors.Wrap(err, "failed to list devices - traffic cannot be recorded"))
+		return
+	}
+	byAddress := map[string]*storage.Device{}
+	for _, device := range devices {
+		ip, _ := MustParseCIDR(device.Address)
+		byAddress[ip.String()] = device
+	}
+
+	existing, err := d.storage.ListDeviceTraffic("")
+	if err != nil {
+		logrus.Warn(errors.Wrap(err, "failed to list device traffic - traffic cannot be recorded"))
+		return
+	}
+
+	for _, counter := range counters {
+		k := [2]string{counter.Address, counter.Destination}
+		rx, tx := delta(d.accounting.last[k].ReceiveBytes, counter.ReceiveBytes), delta(d.accounting.last[k].TransmitBytes, counter.TransmitBytes)
+		d.accounting.last[k] = counter
+		if rx == 0 && tx == 0 {
+			continue
+		}
+
+		device, ok := byAddress[counter.Address]
+		if !ok {
+			continue
+		}
+
+		deviceTrafficBytes.WithLabelValues(device.Owner, device.Name, counter.Destination, "receive").Add(float64(rx))
+		deviceTrafficBytes.WithLabelValues(device.Owner, device.Name, counter.Destination, "transmit").Add(float64(tx))
+
+		traffic := &storage.DeviceTraffic{
+			Owner:       device.Owner,
+			Name:        device.Name,
+			Destination: counter.Destination,
+		}
+		for _, t := range existing {
+			if t.Owner == device.Owner && t.Name == device.Name && t.Destination == counter.Destination {
+				traffic = t
+				break
+			}
+		}
+		traffic.ReceiveBytes += int64(rx)
+		traffic.TransmitBytes += int64(tx)
+		traffic.UpdatedAt = time.Now()
+		if err := d.storage.SaveDeviceTraffic(traffic); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to save device traffic during metadata sync"))
+		}
+	}
+}
+
+// delta returns the amount a counter has increased by.
+// iptables counters are reset when rules are recreated
+// in which case the whole current value is new traffic.
+func delta(previous uint64, current uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
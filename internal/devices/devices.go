@@ -9,35 +9,78 @@ import (
 	"github.com/place1/wg-embed/pkg/wgembed"
 
 	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/config"
 	"github.com/place1/wg-access-server/internal/storage"
 	"github.com/place1/wg-access-server/pkg/authnz/authsession"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrUserDeprovisioned is returned when a deprovisioned user adds a device
+var ErrUserDeprovisioned = errors.New("the user was deprovisioned")
+
+// DeviceManager manages the devices of a single VPN profile.
 type DeviceManager struct {
-	wg      wgembed.WireGuardInterface
-	storage storage.Storage
-	cidr    string
+	wg            wgembed.WireGuardInterface
+	storage       storage.Storage
+	profile       config.Profile
+	portForwards  *portForwarding
+	reservedPorts []ReservedPort
+	shaping       *shaping
+	accounting    *accounting
+	limits        []config.BandwidthLimit
+	dnsExempt     []config.DNSBlocklistExemption
+	index         deviceIndex
+}
+
+func New(wg wgembed.WireGuardInterface, s storage.Storage, profile config.Profile) *DeviceManager {
+	return &DeviceManager{
+		wg:      wg,
+		storage: s,
+		profile: profile,
+	}
 }
 
-func New(wg wgembed.WireGuardInterface, s storage.Storage, cidr string) *DeviceManager {
-	return &DeviceManager{wg, s, cidr}
+// Profile returns the name of the VPN profile
+// that this DeviceManager is responsible for.
+func (d *DeviceManager) Profile() string {
+	return d.profile.Name
+}
+
+// Allows reports if a user may add devices to this
+// DeviceManager's VPN profile.
+func (d *DeviceManager) Allows(identity *authsession.Identity) bool {
+	claim, value := d.profile.Claim, d.profile.Value
+	if claim == "" {
+		return true
+	}
+	if value == "" {
+		return identity.Claims.Contains(claim)
+	}
+	return identity.Claims.Has(claim, value)
 }
 
 func (d *DeviceManager) StartSync(disableMetadataCollection bool) error {
 	// Start listening to the device add/remove events
 	d.storage.OnAdd(func(device *storage.Device) {
+		if device.Profile != d.profile.Name {
+			return
+		}
 		logrus.Debugf("storage event: device added: %s/%s", device.Owner, device.Name)
-		if err := d.wg.AddPeer(device.PublicKey, device.Address); err != nil {
-			logrus.Error(errors.Wrap(err, "failed to add wireguard peer"))
+		d.index.add(device)
+		if device.Disabled {
+			d.removePeer(device)
+		} else {
+			d.addPeer(device)
 		}
 	})
 
 	d.storage.OnDelete(func(device *storage.Device) {
-		logrus.Debugf("storage event: device removed: %s/%s", device.Owner, device.Name)
-		if err := d.wg.RemovePeer(device.PublicKey); err != nil {
-			logrus.Error(errors.Wrap(err, "failed to remove wireguard peer"))
+		if device.Profile != d.profile.Name {
+			return
 		}
+		logrus.Debugf("storage event: device removed: %s/%s", device.Owner, device.Name)
+		d.index.remove(device)
+		d.removePeer(device)
 	})
 
 	d.storage.OnReconnect(func() {
@@ -59,16 +102,53 @@ func (d *DeviceManager) StartSync(disableMetadataCollection bool) error {
 	return nil
 }
 
-func (d *DeviceManager) AddDevice(identity *authsession.Identity, name string, publicKey string) (*storage.Device, error) {
+// addPeer connects a device to the wireguard interface
+func (d *DeviceManager) addPeer(device *storage.Device) {
+	if err := d.wg.AddPeer(device.PublicKey, device.Address); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to add wireguard peer"))
+	}
+	if err := d.applyBandwidthLimit(device); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to apply device bandwidth limit"))
+	}
+	if err := d.addDeviceAccounting(device); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to add device traffic accounting"))
+	}
+}
+
+// removePeer disconnects a device from the wireguard interface
+func (d *DeviceManager) removePeer(device *storage.Device) {
+	if err := d.wg.RemovePeer(device.PublicKey); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to remove wireguard peer"))
+	}
+	if err := d.removeBandwidthLimit(device); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to remove device bandwidth limit"))
+	}
+	if err := d.removeDeviceAccounting(device); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to remove device traffic accounting"))
+	}
+}
+
+func (d *DeviceManager) AddDevice(identity *authsession.Identity, name string, publicKey string, persistentKeepalive int) (*storage.Device, error) {
 	if name == "" {
 		return nil, errors.New("device name must not be empty")
 	}
 
+	if persistentKeepalive < 0 || persistentKeepalive > 65535 {
+		return nil, errors.New("persistent keepalive must be between 0 and 65535 seconds")
+	}
+
+	// the lifecycle job would only disable the device again
+	if user, err := d.storage.GetUser(identity.Provider, identity.Subject); err == nil && user.Deprovisioned {
+		return nil, ErrUserDeprovisioned
+	}
+
 	clientAddr, err := d.nextClientAddress()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate an ip address for device")
 	}
 
+	ingress, egress := d.defaultBandwidthLimit(identity)
+
 	device := &storage.Device{
 		Owner:         identity.Subject,
 		OwnerName:     identity.Name,
@@ -78,6 +158,12 @@ func (d *DeviceManager) AddDevice(identity *authsession.Identity, name string, p
 		PublicKey:     publicKey,
 		Address:       clientAddr,
 		CreatedAt:     time.Now(),
+		Profile:       d.profile.Name,
+		IngressLimit:  ingress,
+		EgressLimit:   egress,
+
+		DNSFilterExempt:     d.dnsFilterExempt(identity),
+		PersistentKeepalive: persistentKeepalive,
 	}
 
 	if err := d.SaveDevice(device); err != nil {
@@ -92,30 +178,46 @@ func (d *DeviceManager) SaveDevice(device *storage.Device) error {
 }
 
 func (d *DeviceManager) sync() error {
-	devices, err := d.ListAllDevices()
+	devices, err := d.listProfileDevices()
 	if err != nil {
 		return errors.Wrap(err, "failed to list devices")
 	}
 
+	d.index.reset(devices)
+
+	enabled := []*storage.Device{}
+	for _, device := range devices {
+		if !device.Disabled {
+			enabled = append(enabled, device)
+		}
+	}
+
 	peers, err := d.wg.ListPeers()
 	if err != nil {
 		return errors.Wrap(err, "failed to list peers")
 	}
 
 	// Remove any peers for devices that are no longer in storage
+	// or have been disabled
 	for _, peer := range peers {
-		if !deviceListContains(devices, peer.PublicKey.String()) {
+		if !deviceListContains(enabled, peer.PublicKey.String()) {
 			if err := d.wg.RemovePeer(peer.PublicKey.String()); err != nil {
 				logrus.Error(errors.Wrapf(err, "failed to remove peer during sync: %s", peer.PublicKey.String()))
 			}
 		}
 	}
 
-	// Add peers for all devices in storage
-	for _, device := range devices {
+	// Add peers for all enabled devices in storage
+	for _, device := range enabled {
 		if err := d.wg.AddPeer(device.PublicKey, device.Address); err != nil {
 			logrus.Warn(errors.Wrapf(err, "failed to add device during sync: %s", device.Name))
 		}
+		if err := d.applyBandwidthLimit(device); err != nil {
+			logrus.Warn(errors.Wrapf(err, "failed to apply bandwidth limit during sync: %s", device.Name))
+		}
+		if err := d.addDeviceAccounting(device); err != nil {
+			logrus.Warn(errors.Wrapf(err, "failed to add traffic accounting during sync: %s", device.Name))
+		}
 	}
 
 	return nil
@@ -125,6 +227,22 @@ func (d *DeviceManager) ListAllDevices() ([]*storage.Device, error) {
 	return d.storage.List("")
 }
 
+// listProfileDevices returns the devices of all
+// users that belong to this DeviceManager's profile.
+func (d *DeviceManager) listProfileDevices() ([]*storage.Device, error) {
+	devices, err := d.storage.List("")
+	if err != nil {
+		return nil, err
+	}
+	result := []*storage.Device{}
+	for _, device := range devices {
+		if device.Profile == d.profile.Name {
+			result = append(result, device)
+		}
+	}
+	return result, nil
+}
+
 func (d *DeviceManager) ListDevices(user string) ([]*storage.Device, error) {
 	return d.storage.List(user)
 }
@@ -139,9 +257,34 @@ func (d *DeviceManager) DeleteDevice(user string, name string) error {
 		return err
 	}
 
+	if err := d.deletePortForwardsFor(device); err != nil {
+		return errors.Wrap(err, "failed to remove port forwards for device")
+	}
+
+	if err := d.storage.DeleteDeviceTraffic(device); err != nil {
+		return errors.Wrap(err, "failed to remove traffic records for device")
+	}
+
 	return nil
 }
 
+// SetDisabled disables or re-enables a device. Disabled devices
+// are kept in storage but can't connect to the VPN.
+func (d *DeviceManager) SetDisabled(owner string, name string, disabled bool) (*storage.Device, error) {
+	device, err := d.storage.Get(owner, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve device")
+	}
+
+	device.Disabled = disabled
+
+	if err := d.SaveDevice(device); err != nil {
+		return nil, errors.Wrap(err, "failed to save device")
+	}
+
+	return device, nil
+}
+
 func (d *DeviceManager) GetByPublicKey(publicKey string) (*storage.Device, error) {
 	return d.storage.GetByPublicKey(publicKey)
 }
@@ -152,12 +295,12 @@ func (d *DeviceManager) nextClientAddress() (string, error) {
 	nextIPLock.Lock()
 	defer nextIPLock.Unlock()
 
-	devices, err := d.ListDevices("")
+	devices, err := d.listProfileDevices()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to list devices")
 	}
 
-	vpnip, vpnsubnet := MustParseCIDR(d.cidr)
+	vpnip, vpnsubnet := MustParseCIDR(d.profile.CIDR)
 	ip := vpnip.Mask(vpnsubnet.Mask)
 
 	// TODO: read up on better ways to allocate client's IP
@@ -0,0 +1,22 @@
+package devices
+
+import (
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// SetDNSFilterExemptions configures the claims that exempt
+// new devices from the DNS blocklists.
+func (d *DeviceManager) SetDNSFilterExemptions(exempt []config.DNSBlocklistExemption) {
+	d.dnsExempt = exempt
+}
+
+func (d *DeviceManager) dnsFilterExempt(identity *authsession.Identity) bool {
+	for _, exempt := range d.dnsExempt {
+		if (exempt.Value == "" && identity.Claims.Contains(exempt.Claim)) ||
+			identity.Claims.Has(exempt.Claim, exempt.Value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package devices
+
+import (
+	"net"
+	"sync"
+
+	"github.com/place1/wg-access-server/internal/storage"
+)
+
+// deviceIndex finds devices by their VPN IP without
+// a storage round trip, i.e. for each DNS query.
+type deviceIndex struct {
+	lock    sync.RWMutex
+	devices map[string]*storage.Device
+}
+
+func (i *deviceIndex) add(device *storage.Device) {
+	ip, _ := MustParseCIDR(device.Address)
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.devices == nil {
+		i.devices = map[string]*storage.Device{}
+	}
+	i.devices[ip.String()] = device
+}
+
+func (i *deviceIndex) remove(device *storage.Device) {
+	ip, _ := MustParseCIDR(device.Address)
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if existing, ok := i.devices[ip.String()]; ok && existing.PublicKey == device.PublicKey {
+		delete(i.devices, ip.String())
+	}
+}
+
+func (i *deviceIndex) reset(devices []*storage.Device) {
+	index := map[string]*storage.Device{}
+	for _, device := range devices {
+		ip, _ := MustParseCIDR(device.Address)
+		index[ip.String()] = device
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.devices = index
+}
+
+func (i *deviceIndex) get(ip net.IP) (*storage.Device, bool) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	device, ok := i.devices[ip.String()]
+	return device, ok
+}
+
+// DeviceByIP returns the device that has a VPN IP
+// in this DeviceManager's profile.
+// StartSync must have been called first.
+func (d *DeviceManager) DeviceByIP(ip net.IP) (*storage.Device, bool) {
+	return d.index.get(ip)
+}
+
+// DeviceByIP returns the device that has a VPN IP
+// in any of the profiles.
+func (p Profiles) DeviceByIP(ip net.IP) (*storage.Device, bool) {
+	for _, d := range p {
+		if device, ok := d.DeviceByIP(ip); ok {
+			return device, true
+		}
+	}
+	return nil, false
+}
@@ -10,6 +10,7 @@ import (
 func metadataLoop(d *DeviceManager) {
 	for {
 		syncMetrics(d)
+		syncTraffic(d)
 		time.Sleep(30 * time.Second)
 	}
 }
@@ -29,14 +30,18 @@ func syncMetrics(d *DeviceManager) {
 		// but aren't connected at the moment.
 		// they may actually be connected to another replica.
 		if peer.Endpoint != nil {
-			if device, err := d.GetByPublicKey(peer.PublicKey.String()); err == nil {
+			if stored, err := d.GetByPublicKey(peer.PublicKey.String()); err == nil {
+				// only the metadata is saved because the device may
+				// have been disabled or limited since it was read
+				device := *stored
 				device.Endpoint = peer.Endpoint.IP.String()
 				device.ReceiveBytes = peer.ReceiveBytes
 				device.TransmitBytes = peer.TransmitBytes
 				if !peer.LastHandshakeTime.IsZero() {
-					device.LastHandshakeTime = &peer.LastHandshakeTime
+					handshake := peer.LastHandshakeTime
+					device.LastHandshakeTime = &handshake
 				}
-				if err := d.SaveDevice(device); err != nil {
+				if err := d.storage.SaveDeviceMetadata(&device); err != nil {
 					logrus.Error(errors.Wrap(err, "failed to save device during metadata sync"))
 				}
 			}
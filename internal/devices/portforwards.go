@@ -0,0 +1,206 @@
+package devices
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/network"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidPortForward is the cause of errors
+	// about port forwards that can't be added
+	ErrInvalidPortForward  = errors.New("invalid port forward")
+	ErrPortForwardExists   = errors.New("public port is already forwarded")
+	ErrPortForwardNotFound = errors.New("port forward doesn't exist")
+)
+
+// ReservedPort is a port that the server itself
+// listens on so it can't be forwarded to a device
+type ReservedPort struct {
+	Protocol string
+	Port     int
+	// Name is what listens on the port i.e. "the web ui"
+	Name string
+}
+
+type portForwarding struct {
+	lock         sync.Mutex
+	wgIface      string
+	gatewayIface string
+	applied      []network.PortForward
+}
+
+// StartPortForwarding applies the stored port forwarding rules
+// to the host's network and keeps them in sync.
+// Rules are re-read periodically so that changes made by
+// other replicas are eventually applied here too.
+func (d *DeviceManager) StartPortForwarding(wgIface string, gatewayIface string) error {
+	d.portForwards = &portForwarding{
+		wgIface:      wgIface,
+		gatewayIface: gatewayIface,
+	}
+
+	d.storage.OnReconnect(func() {
+		if err := d.syncPortForwards(); err != nil {
+			logrus.Error(errors.Wrap(err, "port forward sync after storage backend reconnect event failed"))
+		}
+	})
+
+	if err := d.syncPortForwards(); err != nil {
+		return errors.Wrap(err, "initial port forward sync from storage failed")
+	}
+
+	go func() {
+		for {
+			time.Sleep(30 * time.Second)
+			if err := d.syncPortForwards(); err != nil {
+				logrus.Warn(errors.Wrap(err, "port forward sync failed"))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// SetReservedPorts sets the ports of the server that can't be
+// forwarded. The port forwarding rules match every address of the
+// server so forwarding them would send its traffic to a device.
+func (d *DeviceManager) SetReservedPorts(ports []ReservedPort) {
+	d.reservedPorts = ports
+}
+
+func (d *DeviceManager) AddPortForward(pf *storage.PortForward) error {
+	pf.Protocol = strings.ToLower(pf.Protocol)
+	if pf.Protocol != "tcp" && pf.Protocol != "udp" {
+		return errors.Wrapf(ErrInvalidPortForward, "unsupported protocol '%s' - must be tcp or udp", pf.Protocol)
+	}
+	if !validPort(pf.PublicPort) || !validPort(pf.DevicePort) {
+		return errors.Wrap(ErrInvalidPortForward, "ports must be between 1 and 65535")
+	}
+	for _, reserved := range d.reservedPorts {
+		if reserved.Protocol == pf.Protocol && reserved.Port == pf.PublicPort {
+			return errors.Wrapf(ErrInvalidPortForward, "%s/%d is used by %s", pf.Protocol, pf.PublicPort, reserved.Name)
+		}
+	}
+
+	if _, err := d.storage.Get(pf.Owner, pf.DeviceName); err != nil {
+		return errors.Wrap(err, "failed to retrieve target device")
+	}
+
+	existing, err := d.ListPortForwards()
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if e.Protocol == pf.Protocol && e.PublicPort == pf.PublicPort {
+			return errors.Wrapf(ErrPortForwardExists, "%s/%d", pf.Protocol, pf.PublicPort)
+		}
+	}
+
+	pf.CreatedAt = time.Now()
+	if err := d.storage.SavePortForward(pf); err != nil {
+		return errors.Wrap(err, "failed to save port forward")
+	}
+
+	return d.syncPortForwards()
+}
+
+func (d *DeviceManager) ListPortForwards() ([]*storage.PortForward, error) {
+	return d.storage.ListPortForwards()
+}
+
+func (d *DeviceManager) DeletePortForward(protocol string, publicPort int) error {
+	pfs, err := d.ListPortForwards()
+	if err != nil {
+		return errors.Wrap(err, "failed to list port forwards")
+	}
+	for _, pf := range pfs {
+		if pf.Protocol == strings.ToLower(protocol) && pf.PublicPort == publicPort {
+			if err := d.storage.DeletePortForward(pf); err != nil {
+				return err
+			}
+			return d.syncPortForwards()
+		}
+	}
+	return ErrPortForwardNotFound
+}
+
+func (d *DeviceManager) deletePortForwardsFor(device *storage.Device) error {
+	pfs, err := d.ListPortForwards()
+	if err != nil {
+		return errors.Wrap(err, "failed to list port forwards")
+	}
+	for _, pf := range pfs {
+		if pf.Owner == device.Owner && pf.DeviceName == device.Name {
+			if err := d.storage.DeletePortForward(pf); err != nil {
+				return err
+			}
+		}
+	}
+	return d.syncPortForwards()
+}
+
+func (d *DeviceManager) syncPortForwards() error {
+	// port forwarding is only applied to the host
+	// when the embedded wireguard server is enabled
+	if d.portForwards == nil {
+		return nil
+	}
+
+	d.portForwards.lock.Lock()
+	defer d.portForwards.lock.Unlock()
+
+	pfs, err := d.ListPortForwards()
+	if err != nil {
+		return errors.Wrap(err, "failed to list port forwards")
+	}
+
+	rules := []network.PortForward{}
+	for _, pf := range pfs {
+		device, err := d.storage.Get(pf.Owner, pf.DeviceName)
+		if err != nil {
+			logrus.Warn(errors.Wrapf(err, "skipping port forward %s/%d for missing device %s/%s", pf.Protocol, pf.PublicPort, pf.Owner, pf.DeviceName))
+			continue
+		}
+		if device.Profile != d.profile.Name {
+			// the rule belongs to another profile's DeviceManager
+			continue
+		}
+		ip, _ := MustParseCIDR(device.Address)
+		rules = append(rules, network.PortForward{
+			Protocol:   pf.Protocol,
+			PublicPort: pf.PublicPort,
+			Address:    ip.String(),
+			DevicePort: pf.DevicePort,
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Protocol != rules[j].Protocol {
+			return rules[i].Protocol < rules[j].Protocol
+		}
+		return rules[i].PublicPort < rules[j].PublicPort
+	})
+
+	// avoid rewriting the iptables chains when nothing changed
+	if d.portForwards.applied != nil && reflect.DeepEqual(d.portForwards.applied, rules) {
+		return nil
+	}
+
+	if err := network.ConfigurePortForwarding(d.profile.Chain, d.portForwards.wgIface, d.portForwards.gatewayIface, rules); err != nil {
+		return err
+	}
+	d.portForwards.applied = rules
+
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
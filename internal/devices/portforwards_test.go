@@ -0,0 +1,66 @@
+package devices
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-embed/pkg/wgembed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddPortForward(t *testing.T) {
+	require := require.New(t)
+
+	s := storage.NewMemoryStorage()
+	require.NoError(s.Save(&storage.Device{Owner: "alice", Name: "laptop", PublicKey: "a", Address: "10.44.0.2/32"}))
+	require.NoError(s.Save(&storage.Device{Owner: "alice", Name: "phone", PublicKey: "b", Address: "10.44.0.3/32"}))
+	d := New(wgembed.NewNoOpInterface(), s, config.Profile{})
+	d.SetReservedPorts([]ReservedPort{
+		{Protocol: "udp", Port: 51820, Name: "wireguard"},
+		{Protocol: "tcp", Port: 8000, Name: "the web ui"},
+	})
+
+	forward := func(protocol string, publicPort int, device string, devicePort int) error {
+		return d.AddPortForward(&storage.PortForward{
+			Protocol:   protocol,
+			PublicPort: publicPort,
+			Owner:      "alice",
+			DeviceName: device,
+			DevicePort: devicePort,
+		})
+	}
+
+	require.NoError(forward("TCP", 2222, "laptop", 22))
+	require.NoError(forward("udp", 2222, "laptop", 22), "protocols have their own ports")
+	require.NoError(forward("tcp", 8080, "phone", 80))
+	pfs, err := d.ListPortForwards()
+	require.NoError(err)
+	require.Len(pfs, 3)
+
+	for _, invalid := range []error{
+		forward("icmp", 1234, "laptop", 1234),
+		forward("tcp", 0, "laptop", 22),
+		forward("tcp", 1234, "laptop", 65536),
+		forward("udp", 51820, "laptop", 51820),
+		forward("tcp", 8000, "laptop", 80),
+	} {
+		require.Equal(ErrInvalidPortForward, errors.Cause(invalid))
+	}
+	require.NoError(forward("tcp", 51820, "laptop", 51820), "only the reserved protocol is rejected")
+
+	err = forward("tcp", 2222, "phone", 22)
+	require.Equal(ErrPortForwardExists, errors.Cause(err))
+	require.Error(forward("tcp", 3333, "tablet", 22), "the device must exist")
+
+	require.Equal(ErrPortForwardNotFound, d.DeletePortForward("tcp", 3333))
+	require.NoError(d.DeletePortForward("tcp", 51820))
+
+	// the port forwards of deleted devices are deleted too
+	require.NoError(d.DeleteDevice("alice", "laptop"))
+	pfs, err = d.ListPortForwards()
+	require.NoError(err)
+	require.Len(pfs, 1)
+	require.Equal("phone", pfs[0].DeviceName)
+}
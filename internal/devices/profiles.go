@@ -0,0 +1,58 @@
+package devices
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// Profiles holds the DeviceManager of each
+// VPN profile keyed by the profile's name.
+type Profiles map[string]*DeviceManager
+
+// Get returns the DeviceManager of a VPN profile.
+func (p Profiles) Get(name string) (*DeviceManager, error) {
+	d, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("vpn profile '%s' doesn't exist", name)
+	}
+	return d, nil
+}
+
+// ForDevice returns the DeviceManager of the
+// VPN profile that a device belongs to.
+func (p Profiles) ForDevice(owner string, name string) (*DeviceManager, error) {
+	d, err := p.Get("")
+	if err != nil {
+		return nil, err
+	}
+	device, err := d.storage.Get(owner, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve device")
+	}
+	return p.Get(device.Profile)
+}
+
+// Allowed returns the DeviceManagers of the VPN profiles
+// that a user may add devices to, ordered by name.
+func (p Profiles) Allowed(identity *authsession.Identity) []*DeviceManager {
+	allowed := []*DeviceManager{}
+	for _, d := range p {
+		if d.Allows(identity) {
+			allowed = append(allowed, d)
+		}
+	}
+	sort.Slice(allowed, func(i, j int) bool {
+		return allowed[i].Profile() < allowed[j].Profile()
+	})
+	return allowed
+}
+
+// ProfileConfig returns the configuration of the
+// VPN profile that this DeviceManager is responsible for.
+func (d *DeviceManager) ProfileConfig() config.Profile {
+	return d.profile
+}
@@ -0,0 +1,94 @@
+package devices
+
+import (
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/internal/network"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// ErrInvalidBandwidthLimit is returned for limits
+// that are too small to be enforced
+var ErrInvalidBandwidthLimit = errors.Errorf("bandwidth limits must be 0 or at least %dbit", config.MinBitrate)
+
+type shaping struct {
+	wgIface      string
+	gatewayIface string
+}
+
+// SetDefaultBandwidthLimits configures the limits that are
+// given to new devices based on their owner's claims.
+// The limits are copied to devices when they're added so
+// changes to the config or to an owner's claims don't
+// affect existing devices.
+func (d *DeviceManager) SetDefaultBandwidthLimits(limits []config.BandwidthLimit) {
+	d.limits = limits
+}
+
+// StartShaping enforces device bandwidth limits on the host.
+// network.ConfigureShaping must have been called for the
+// interfaces first.
+// It must be called before StartSync so that the initial
+// device sync applies the limits of existing devices.
+func (d *DeviceManager) StartShaping(wgIface string, gatewayIface string) {
+	d.shaping = &shaping{
+		wgIface:      wgIface,
+		gatewayIface: gatewayIface,
+	}
+}
+
+// SetBandwidthLimit overrides the bandwidth limits of a device.
+// Rates are in bits per second and 0 means unlimited.
+func (d *DeviceManager) SetBandwidthLimit(owner string, name string, ingress uint64, egress uint64) (*storage.Device, error) {
+	for _, rate := range []uint64{ingress, egress} {
+		if rate != 0 && rate < config.MinBitrate {
+			return nil, ErrInvalidBandwidthLimit
+		}
+	}
+
+	device, err := d.storage.Get(owner, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve device")
+	}
+
+	device.IngressLimit = ingress
+	device.EgressLimit = egress
+
+	if err := d.SaveDevice(device); err != nil {
+		return nil, errors.Wrap(err, "failed to save device")
+	}
+
+	return device, nil
+}
+
+func (d *DeviceManager) defaultBandwidthLimit(identity *authsession.Identity) (uint64, uint64) {
+	for _, limit := range d.limits {
+		if limit.Claim == "" ||
+			(limit.Value == "" && identity.Claims.Contains(limit.Claim)) ||
+			identity.Claims.Has(limit.Claim, limit.Value) {
+			return uint64(limit.Ingress), uint64(limit.Egress)
+		}
+	}
+	return 0, 0
+}
+
+func (d *DeviceManager) applyBandwidthLimit(device *storage.Device) error {
+	if d.shaping == nil {
+		return nil
+	}
+	ip, _ := MustParseCIDR(device.Address)
+	return network.SetBandwidthLimit(d.shaping.wgIface, d.shaping.gatewayIface, network.BandwidthLimit{
+		Address: ip.String(),
+		Ingress: device.IngressLimit,
+		Egress:  device.EgressLimit,
+	})
+}
+
+func (d *DeviceManager) removeBandwidthLimit(device *storage.Device) error {
+	if d.shaping == nil {
+		return nil
+	}
+	ip, _ := MustParseCIDR(device.Address)
+	return network.RemoveBandwidthLimit(d.shaping.wgIface, d.shaping.gatewayIface, ip.String())
+}
@@ -0,0 +1,40 @@
+package devices
+
+import (
+	"testing"
+
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-embed/pkg/wgembed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthLimits(t *testing.T) {
+	require := require.New(t)
+
+	d := New(wgembed.NewNoOpInterface(), storage.NewMemoryStorage(), config.Profile{CIDR: "10.44.0.0/24"})
+	d.SetDefaultBandwidthLimits([]config.BandwidthLimit{
+		{Claim: "admin"},
+		{Ingress: 50000000, Egress: 10000000},
+	})
+
+	alice := &authsession.Identity{Provider: "basic", Subject: "alice"}
+	device, err := d.AddDevice(alice, "laptop", "a", 0)
+	require.NoError(err)
+	require.Equal(uint64(50000000), device.IngressLimit)
+	require.Equal(uint64(10000000), device.EgressLimit)
+
+	admin := &authsession.Identity{Provider: "basic", Subject: "bob"}
+	admin.Claims.Add("admin", "true")
+	device, err = d.AddDevice(admin, "laptop", "b", 0)
+	require.NoError(err)
+	require.Zero(device.IngressLimit)
+
+	device, err = d.SetBandwidthLimit("alice", "laptop", 8, 0)
+	require.NoError(err)
+	require.Equal(uint64(8), device.IngressLimit)
+	require.Zero(device.EgressLimit)
+	_, err = d.SetBandwidthLimit("alice", "laptop", 1000, 7)
+	require.Equal(ErrInvalidBandwidthLimit, err, "tc can't enforce rates below 1 byte per second")
+}
@@ -0,0 +1,260 @@
+package dnsproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+var blockedQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "wg_access_server_dns_blocked_queries_total",
+	Help: "DNS queries that were blocked by a blocklist",
+}, []string{"source"})
+
+// BlockResponse is how blocked queries are answered.
+type BlockResponse string
+
+const (
+	// BlockNXDomain answers blocked queries with NXDOMAIN
+	BlockNXDomain BlockResponse = "nxdomain"
+	// BlockNull answers blocked A/AAAA queries with 0.0.0.0 or ::
+	BlockNull BlockResponse = "null"
+)
+
+// the TTL of answers to blocked queries
+const blockedTTL = 60
+
+var blocklistDomain = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]*[a-z0-9_])?\.)+$`)
+
+// names in hosts files that aren't blocked
+var hostsFileNames = map[string]bool{
+	"localhost.":             true,
+	"localhost.localdomain.": true,
+	"local.":                 true,
+	"broadcasthost.":         true,
+	"ip6-localhost.":         true,
+	"ip6-loopback.":          true,
+}
+
+type BlocklistOpts struct {
+	// Sources are blocklist files or http(s) urls
+	Sources []string
+	// Allow are domains that are never blocked
+	Allow []string
+	// Refresh is how often the sources are reloaded.
+	// Defaults to 24 hours
+	Refresh time.Duration
+	// Response is the answer to blocked queries.
+	// Defaults to NXDOMAIN
+	Response BlockResponse
+}
+
+// Blocklist blocks DNS queries for domains in a set of
+// blocklists. A domain blocks all of its subdomains too.
+type Blocklist struct {
+	opts   BlocklistOpts
+	client *http.Client
+	stop   chan struct{}
+
+	lock sync.RWMutex
+	// domains in each source. a source keeps
+	// its previous domains if it fails to reload.
+	sources map[string]map[string]bool
+	// blocked domains and the first source
+	// that they were found in
+	domains map[string]string
+	allow   map[string]bool
+}
+
+// NewBlocklist loads the blocklists and reloads them
+// periodically until Close is called. Sources that fail
+// to load are logged and retried on the next refresh.
+func NewBlocklist(opts BlocklistOpts) (*Blocklist, error) {
+	if opts.Refresh == 0 {
+		opts.Refresh = 24 * time.Hour
+	}
+	switch opts.Response {
+	case "":
+		opts.Response = BlockNXDomain
+	case BlockNXDomain, BlockNull:
+	default:
+		return nil, fmt.Errorf("unknown dns blocklist response '%s' - must be nxdomain or null", opts.Response)
+	}
+
+	b := &Blocklist{
+		opts:    opts,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		stop:    make(chan struct{}),
+		sources: map[string]map[string]bool{},
+		domains: map[string]string{},
+		allow:   map[string]bool{},
+	}
+	for _, domain := range opts.Allow {
+		b.allow[dns.CanonicalName(domain)] = true
+	}
+
+	b.reload()
+	go b.refreshLoop()
+
+	return b, nil
+}
+
+func (b *Blocklist) Close() {
+	close(b.stop)
+}
+
+func (b *Blocklist) refreshLoop() {
+	ticker := time.NewTicker(b.opts.Refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.reload()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *Blocklist) reload() {
+	for _, source := range b.opts.Sources {
+		domains, err := b.load(source)
+		if err != nil {
+			logrus.Error(errors.Wrapf(err, "failed to load dns blocklist %s", source))
+			continue
+		}
+		logrus.Infof("loaded %d domains from dns blocklist %s", len(domains), source)
+		b.lock.Lock()
+		b.sources[source] = domains
+		b.lock.Unlock()
+	}
+
+	// sources are merged in their configured order so
+	// that a domain is attributed to the first source
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	merged := map[string]string{}
+	for _, source := range b.opts.Sources {
+		for domain := range b.sources[source] {
+			if _, ok := merged[domain]; !ok {
+				merged[domain] = source
+			}
+		}
+	}
+	b.domains = merged
+}
+
+func (b *Blocklist) load(source string) (map[string]bool, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		res, err := b.client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected http status %d", res.StatusCode)
+		}
+		return parseBlocklist(res.Body)
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseBlocklist(f)
+}
+
+// parseBlocklist reads the domains of a hosts file or
+// a domain list. Comments and invalid lines are skipped.
+func parseBlocklist(r io.Reader) (map[string]bool, error) {
+	domains := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#!"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// hosts files have an ip followed by hostnames
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			// i.e. "*.example.com" or "||example.com^"
+			field = strings.TrimPrefix(field, "*.")
+			field = strings.TrimPrefix(field, "||")
+			if i := strings.Index(field, "^"); i >= 0 {
+				field = field[:i]
+			}
+
+			domain := dns.CanonicalName(field)
+			if !blocklistDomain.MatchString(domain) || hostsFileNames[domain] {
+				continue
+			}
+			domains[domain] = true
+		}
+	}
+	return domains, scanner.Err()
+}
+
+// Blocked reports if a name is blocked and
+// the source of the blocklist that blocked it.
+func (b *Blocklist) Blocked(name string) (string, bool) {
+	name = dns.CanonicalName(name)
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	source := ""
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if b.allow[name[off:]] {
+			return "", false
+		}
+		if s, ok := b.domains[name[off:]]; ok && source == "" {
+			source = s
+		}
+	}
+	return source, source != ""
+}
+
+// Answer returns the response to a blocked query.
+func (b *Blocklist) Answer(r *dns.Msg, source string) *dns.Msg {
+	blockedQueries.WithLabelValues(source).Inc()
+
+	m := &dns.Msg{}
+	m.SetReply(r)
+
+	if b.opts.Response == BlockNXDomain {
+		m.Rcode = dns.RcodeNameError
+		return m
+	}
+
+	q := r.Question[0]
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: blockedTTL}
+	switch q.Qtype {
+	case dns.TypeA:
+		m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: net.IPv4zero})
+	case dns.TypeAAAA:
+		m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.IPv6zero})
+	}
+	return m
+}
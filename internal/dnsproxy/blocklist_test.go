@@ -0,0 +1,86 @@
+package dnsproxy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+const testHostsFile = `# a hosts file
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.com # trailing comment
+:: ads6.example.com
+`
+
+const testDomainList = `! an adblock style list
+malware.example.net
+*.wildcard.example.org
+||adblock.example.org^$third-party
+not a domain/path
+`
+
+func writeBlocklist(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "blocklist")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func TestBlocklist(t *testing.T) {
+	require := require.New(t)
+
+	hosts := writeBlocklist(t, testHostsFile)
+	defer os.Remove(hosts)
+	domains := writeBlocklist(t, testDomainList)
+	defer os.Remove(domains)
+
+	b, err := NewBlocklist(BlocklistOpts{
+		Sources: []string{hosts, domains, "/does/not/exist"},
+		Allow:   []string{"tracker.example.com"},
+	})
+	require.NoError(err)
+	defer b.Close()
+
+	blocked := map[string]string{
+		"ads.example.com":        hosts,
+		"cdn.ads.example.com":    hosts,
+		"ADS6.example.com":       hosts,
+		"malware.example.net":    domains,
+		"x.wildcard.example.org": domains,
+		"adblock.example.org":    domains,
+	}
+	for name, source := range blocked {
+		s, ok := b.Blocked(name)
+		require.True(ok, name)
+		require.Equal(source, s, name)
+	}
+
+	for _, name := range []string{"localhost", "example.com", "tracker.example.com", "a.tracker.example.com", "notads.example.com"} {
+		_, ok := b.Blocked(name)
+		require.False(ok, name)
+	}
+
+	response := b.Answer(query("ads.example.com"), hosts)
+	require.Equal(dns.RcodeNameError, response.Rcode)
+}
+
+func TestBlocklistNullResponse(t *testing.T) {
+	require := require.New(t)
+
+	b, err := NewBlocklist(BlocklistOpts{Response: BlockNull})
+	require.NoError(err)
+	defer b.Close()
+
+	response := b.Answer(query("ads.example.com"), "test")
+	require.Equal(dns.RcodeSuccess, response.Rcode)
+	require.Len(response.Answer, 1)
+	require.Equal("0.0.0.0", response.Answer[0].(*dns.A).A.String())
+
+	_, err = NewBlocklist(BlocklistOpts{Response: "refused"})
+	require.Error(err)
+}
@@ -0,0 +1,253 @@
+package dnsproxy
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// the default number of responses in the cache
+	defaultCacheSize = 10000
+	// the default maximum time a response is cached
+	defaultMaxTTL = 24 * time.Hour
+	// entries with at least this many hits are prefetched
+	prefetchMinHits = 3
+	// entries are prefetched when less than this
+	// fraction of their TTL remains (1/10th)
+	prefetchRemaining = 10
+)
+
+type CacheOpts struct {
+	// Size is the maximum number of cached responses.
+	// The least recently used responses are evicted first.
+	// Defaults to 10000
+	Size int
+	// MinTTL and MaxTTL clamp the TTLs of cached responses.
+	// MinTTL only applies to positive answers so that negative
+	// answers expire with their SOA minimum.
+	// MinTTL must not be greater than MaxTTL.
+	// MaxTTL defaults to 24 hours
+	MinTTL time.Duration
+	MaxTTL time.Duration
+	// Prefetch refreshes popular responses
+	// from the upstream before they expire.
+	Prefetch bool
+}
+
+// dnsCache is an LRU cache of upstream responses.
+// Positive responses are cached for the lowest TTL of
+// their records and negative responses (NXDOMAIN and
+// NODATA) for the SOA minimum (RFC 2308).
+type dnsCache struct {
+	opts CacheOpts
+	now  func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type cacheEntry struct {
+	key         string
+	msg         *dns.Msg
+	stored      time.Time
+	ttl         time.Duration
+	hits        int
+	prefetching bool
+}
+
+// validate checks the ttls of the options
+func (o CacheOpts) validate() error {
+	max := o.MaxTTL
+	if max == 0 {
+		max = defaultMaxTTL
+	}
+	if o.MinTTL < 0 || max < 0 {
+		return errors.New("dns cache ttls must not be negative")
+	}
+	if o.MinTTL > max {
+		return fmt.Errorf("dns cache minTTL (%s) must not be greater than maxTTL (%s)", o.MinTTL, max)
+	}
+	return nil
+}
+
+func newCache(opts CacheOpts) *dnsCache {
+	if opts.Size <= 0 {
+		opts.Size = defaultCacheSize
+	}
+	if opts.MaxTTL == 0 {
+		opts.MaxTTL = defaultMaxTTL
+	}
+	return &dnsCache{
+		opts:    opts,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// cacheKey identifies the responses to a query. The DO bit
+// is part of the key because DNSSEC records are only
+// included in responses to queries that set it.
+// The query must have exactly 1 question.
+func cacheKey(m *dns.Msg) string {
+	q := m.Question[0]
+	do := false
+	if opt := m.IsEdns0(); opt != nil {
+		do = opt.Do()
+	}
+	return fmt.Sprintf("%s:%d:%d:%t:%t", strings.ToLower(q.Name), q.Qtype, q.Qclass, do, m.CheckingDisabled)
+}
+
+// get returns a copy of a cached response with the TTLs of its
+// records decremented by the time it has been in the cache.
+// prefetch is true if the caller should refresh the response.
+func (c *dnsCache) get(key string) (m *dns.Msg, prefetch bool, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	age := c.now().Sub(entry.stored)
+	if age >= entry.ttl {
+		c.remove(elem)
+		dnsCacheEntries.Set(float64(c.lru.Len()))
+		return nil, false, false
+	}
+
+	c.lru.MoveToFront(elem)
+	entry.hits++
+
+	if c.opts.Prefetch && !entry.prefetching && entry.hits >= prefetchMinHits &&
+		entry.ttl-age < entry.ttl/prefetchRemaining {
+		entry.prefetching = true
+		prefetch = true
+	}
+
+	m = entry.msg.Copy()
+	elapsed := uint32(age / time.Second)
+	for _, rrs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if rr.Header().Ttl > elapsed {
+				rr.Header().Ttl -= elapsed
+			} else {
+				rr.Header().Ttl = 0
+			}
+		}
+	}
+	return m, prefetch, true
+}
+
+// set caches a response if it's cacheable and
+// returns how long it will be cached for.
+func (c *dnsCache) set(key string, m *dns.Msg) time.Duration {
+	m = m.Copy()
+	ttl, ok := c.clamp(m)
+	if !ok {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:    key,
+		msg:    m,
+		stored: c.now(),
+		ttl:    ttl,
+	})
+	for c.lru.Len() > c.opts.Size {
+		c.remove(c.lru.Back())
+	}
+	dnsCacheEntries.Set(float64(c.lru.Len()))
+	return ttl
+}
+
+func (c *dnsCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// len returns the number of cached responses.
+func (c *dnsCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// clamp limits the TTLs of a response's records to the min/max TTL
+// and returns how long the response can be cached for.
+// Responses that must not be cached return false.
+func (c *dnsCache) clamp(m *dns.Msg) (time.Duration, bool) {
+	if m.Truncated || len(m.Question) == 0 {
+		return 0, false
+	}
+
+	min, max := uint32(c.opts.MinTTL/time.Second), uint32(c.opts.MaxTTL/time.Second)
+
+	switch {
+	case m.Rcode == dns.RcodeSuccess && len(m.Answer) > 0:
+	case m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError:
+		// negative answers are cached for the SOA's minimum
+		// and are not cached at all without a SOA record
+		soa := negativeSOA(m)
+		if soa == nil {
+			return 0, false
+		}
+		if soa.Minttl < soa.Hdr.Ttl {
+			soa.Hdr.Ttl = soa.Minttl
+		}
+		// MinTTL would keep names that were just
+		// created unresolvable for longer
+		min = 0
+	default:
+		return 0, false
+	}
+
+	lowest := max
+	for _, rrs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, rr := range rrs {
+			hdr := rr.Header()
+			if hdr.Rrtype == dns.TypeOPT {
+				continue
+			}
+			if hdr.Ttl < min {
+				hdr.Ttl = min
+			}
+			if hdr.Ttl > max {
+				hdr.Ttl = max
+			}
+			if hdr.Ttl < lowest {
+				lowest = hdr.Ttl
+			}
+		}
+	}
+	if lowest == 0 {
+		return 0, false
+	}
+	return time.Duration(lowest) * time.Second, true
+}
+
+func negativeSOA(m *dns.Msg) *dns.SOA {
+	for _, rr := range m.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package dnsproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func testCache(opts CacheOpts) (*dnsCache, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	c := newCache(opts)
+	c.now = clock.Now
+	return c, clock
+}
+
+func answer(q *dns.Msg, ttls ...uint32) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetReply(q)
+	for _, ttl := range ttls {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: q.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   net.ParseIP("10.0.0.1"),
+		})
+	}
+	return m
+}
+
+func TestCacheUsesLowestTTL(t *testing.T) {
+	require := require.New(t)
+	c, clock := testCache(CacheOpts{})
+
+	q := query("example.com")
+	require.Equal(60*time.Second, c.set(cacheKey(q), answer(q, 300, 60)))
+
+	clock.now = clock.now.Add(45 * time.Second)
+	m, _, ok := c.get(cacheKey(q))
+	require.True(ok)
+	// ttls count down while cached
+	require.Equal(uint32(255), m.Answer[0].Header().Ttl)
+	require.Equal(uint32(15), m.Answer[1].Header().Ttl)
+
+	clock.now = clock.now.Add(15 * time.Second)
+	_, _, ok = c.get(cacheKey(q))
+	require.False(ok)
+}
+
+func TestCacheNegativeAnswers(t *testing.T) {
+	require := require.New(t)
+	c, _ := testCache(CacheOpts{})
+
+	q := query("missing.example.com")
+	nxdomain := answer(q)
+	nxdomain.Rcode = dns.RcodeNameError
+	require.Zero(c.set(cacheKey(q), nxdomain), "not cached without a SOA")
+
+	nxdomain.Ns = append(nxdomain.Ns, &dns.SOA{
+		Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Minttl: 300,
+	})
+	require.Equal(300*time.Second, c.set(cacheKey(q), nxdomain))
+
+	m, _, ok := c.get(cacheKey(q))
+	require.True(ok)
+	require.Equal(dns.RcodeNameError, m.Rcode)
+
+	servfail := answer(q)
+	servfail.Rcode = dns.RcodeServerFailure
+	require.Zero(c.set(cacheKey(query("other.example.com")), servfail))
+}
+
+func TestCacheClampsTTLs(t *testing.T) {
+	require := require.New(t)
+	c, _ := testCache(CacheOpts{MinTTL: time.Minute, MaxTTL: time.Hour})
+
+	q := query("example.com")
+	require.Equal(time.Minute, c.set(cacheKey(q), answer(q, 5)))
+	require.Equal(time.Hour, c.set(cacheKey(q), answer(q, 86400)))
+	m, _, _ := c.get(cacheKey(q))
+	require.Equal(uint32(3600), m.Answer[0].Header().Ttl)
+
+	// negative answers aren't raised to the MinTTL
+	nxdomain := answer(q)
+	nxdomain.Rcode = dns.RcodeNameError
+	nxdomain.Ns = append(nxdomain.Ns, &dns.SOA{
+		Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Minttl: 10,
+	})
+	require.Equal(10*time.Second, c.set(cacheKey(q), nxdomain))
+}
+
+func TestCacheOptsValidate(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(CacheOpts{}.validate())
+	require.NoError(CacheOpts{MinTTL: time.Hour, MaxTTL: time.Hour}.validate())
+	require.NoError(CacheOpts{MinTTL: time.Hour}.validate())
+	require.Error(CacheOpts{MinTTL: 2 * time.Hour, MaxTTL: time.Hour}.validate())
+	require.Error(CacheOpts{MinTTL: 48 * time.Hour}.validate(), "maxTTL defaults to 24h")
+	require.Error(CacheOpts{MinTTL: -time.Second}.validate())
+
+	_, err := New(DNSServerOpts{
+		Addresses: []string{"127.0.0.1"},
+		Upstream:  []string{"127.0.0.1:1"},
+		Cache:     CacheOpts{MinTTL: 2 * time.Hour, MaxTTL: time.Hour},
+	})
+	require.Error(err)
+}
+
+func TestCacheKeyIncludesDO(t *testing.T) {
+	plain := query("example.com")
+	dnssec := query("example.com")
+	dnssec.SetEdns0(4096, true)
+	require.NotEqual(t, cacheKey(plain), cacheKey(dnssec))
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	require := require.New(t)
+	c, _ := testCache(CacheOpts{Size: 2})
+
+	a, b, d := query("a.example.com"), query("b.example.com"), query("d.example.com")
+	c.set(cacheKey(a), answer(a, 60))
+	c.set(cacheKey(b), answer(b, 60))
+	c.get(cacheKey(a))
+	c.set(cacheKey(d), answer(d, 60))
+
+	require.Equal(2, c.len())
+	_, _, ok := c.get(cacheKey(b))
+	require.False(ok)
+	_, _, ok = c.get(cacheKey(a))
+	require.True(ok)
+}
+
+func TestCachePrefetch(t *testing.T) {
+	require := require.New(t)
+	c, clock := testCache(CacheOpts{Prefetch: true})
+
+	q := query("example.com")
+	c.set(cacheKey(q), answer(q, 100))
+
+	prefetches := 0
+	for i := 0; i < prefetchMinHits+2; i++ {
+		_, prefetch, ok := c.get(cacheKey(q))
+		require.True(ok)
+		require.False(prefetch, "too early to prefetch")
+	}
+
+	clock.now = clock.now.Add(95 * time.Second)
+	for i := 0; i < 2; i++ {
+		if _, prefetch, _ := c.get(cacheKey(q)); prefetch {
+			prefetches++
+		}
+	}
+	require.Equal(1, prefetches)
+}
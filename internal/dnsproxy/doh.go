@@ -0,0 +1,83 @@
+package dnsproxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const dohMediaType = "application/dns-message"
+
+// dohExchanger sends queries to a DNS-over-HTTPS
+// upstream (RFC 8484). Connections are kept alive
+// and reused between queries by the http client.
+type dohExchanger struct {
+	url    string
+	client *http.Client
+}
+
+func newDoHExchanger(url string, tlsConfig *tls.Config, timeout time.Duration) *dohExchanger {
+	return &dohExchanger{
+		url: url,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     tlsConfig,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: timeout,
+			},
+		},
+	}
+}
+
+func (d *dohExchanger) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	// the message ID should be 0 so that responses
+	// can be cached by http caches (RFC 8484 4.1)
+	q := m.Copy()
+	q.Id = 0
+	packed, err := q.Pack()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to pack dns query")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create doh request")
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh upstream responded with http status %d", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != dohMediaType {
+		return nil, fmt.Errorf("doh upstream responded with unexpected content type '%s'", ct)
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, res.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read doh response")
+	}
+
+	response := &dns.Msg{}
+	if err := response.Unpack(body); err != nil {
+		return nil, errors.Wrap(err, "failed to unpack doh response")
+	}
+	response.Id = m.Id
+	return response, nil
+}
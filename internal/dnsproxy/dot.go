@@ -0,0 +1,74 @@
+package dnsproxy
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// the number of idle connections that are kept
+// open to each DNS-over-TLS upstream
+const maxIdleTLSConns = 4
+
+// dotExchanger sends queries to a DNS-over-TLS
+// upstream (RFC 7858). Connections are kept open
+// and reused to avoid a TLS handshake per query.
+type dotExchanger struct {
+	address string
+	client  *dns.Client
+	idle    chan *dns.Conn
+}
+
+func newDoTExchanger(address string, tlsConfig *tls.Config, timeout time.Duration) *dotExchanger {
+	return &dotExchanger{
+		address: address,
+		client: &dns.Client{
+			Net:       "tcp-tls",
+			TLSConfig: tlsConfig,
+			Timeout:   timeout,
+		},
+		idle: make(chan *dns.Conn, maxIdleTLSConns),
+	}
+}
+
+func (d *dotExchanger) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	// an idle connection may have been closed by the
+	// upstream so we retry once on a fresh connection
+	if conn := d.get(); conn != nil {
+		if response, _, err := d.client.ExchangeWithConn(m, conn); err == nil {
+			d.put(conn)
+			return response, nil
+		}
+		conn.Close()
+	}
+
+	conn, err := d.client.Dial(d.address)
+	if err != nil {
+		return nil, err
+	}
+	response, _, err := d.client.ExchangeWithConn(m, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	d.put(conn)
+	return response, nil
+}
+
+func (d *dotExchanger) get() *dns.Conn {
+	select {
+	case conn := <-d.idle:
+		return conn
+	default:
+		return nil
+	}
+}
+
+func (d *dotExchanger) put(conn *dns.Conn) {
+	select {
+	case d.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
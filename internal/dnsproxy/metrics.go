@@ -0,0 +1,48 @@
+package dnsproxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 0.5ms to ~4s
+var dnsDurationBuckets = prometheus.ExponentialBuckets(0.0005, 2, 14)
+
+var (
+	dnsQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wg_access_server_dns_queries_total",
+		Help: "DNS queries answered by the DNS proxy",
+	}, []string{"answered_by", "rcode"})
+
+	dnsQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wg_access_server_dns_query_duration_seconds",
+		Help:    "Time taken to answer DNS queries",
+		Buckets: dnsDurationBuckets,
+	}, []string{"answered_by"})
+
+	dnsCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wg_access_server_dns_cache_lookups_total",
+		Help: "DNS cache lookups by result (hit or miss)",
+	}, []string{"result"})
+
+	dnsCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wg_access_server_dns_cache_entries",
+		Help: "DNS responses in the cache",
+	})
+
+	dnsUpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wg_access_server_dns_upstream_duration_seconds",
+		Help:    "Response time of upstream DNS servers",
+		Buckets: dnsDurationBuckets,
+	}, []string{"upstream"})
+
+	dnsUpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wg_access_server_dns_upstream_errors_total",
+		Help: "Failed queries (errors, timeouts and SERVFAIL) to upstream DNS servers",
+	}, []string{"upstream"})
+
+	dnsUpstreamHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wg_access_server_dns_upstream_healthy",
+		Help: "1 if an upstream DNS server is healthy, 0 if it's skipped after repeated failures",
+	}, []string{"upstream"})
+)
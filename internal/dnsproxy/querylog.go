@@ -0,0 +1,182 @@
+package dnsproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// the default number of queries kept in memory
+	defaultQueryLogEntries = 10000
+	// the default time queries are kept in memory
+	defaultQueryLogRetention = 24 * time.Hour
+)
+
+// how a query was answered
+const (
+	AnsweredByRecords   = "records"
+	AnsweredByZone      = "zone"
+	AnsweredByBlocklist = "blocklist"
+	AnsweredByCache     = "cache"
+	AnsweredByUpstream  = "upstream"
+)
+
+type QueryLogOpts struct {
+	// MaxEntries is the number of queries kept in memory.
+	// Defaults to 10000
+	MaxEntries int
+	// Retention is how long queries are kept in memory.
+	// Defaults to 24 hours
+	Retention time.Duration
+	// Sink optionally writes each query as a line of json
+	// to a file path, "syslog" (the local syslog daemon)
+	// or "syslog://host:514" (a remote syslog server over udp).
+	Sink string
+}
+
+// QueryLogEntry is a DNS query from a client.
+// Owner and Device are empty if the client isn't a VPN device.
+type QueryLogEntry struct {
+	Time       time.Time     `json:"time"`
+	ClientIP   string        `json:"client_ip"`
+	Owner      string        `json:"owner,omitempty"`
+	Device     string        `json:"device,omitempty"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Rcode      string        `json:"rcode"`
+	AnsweredBy string        `json:"answered_by"`
+	BlockedBy  string        `json:"blocked_by,omitempty"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// QueryLogFilter selects queries from the query log.
+// Empty fields match all queries.
+type QueryLogFilter struct {
+	Owner  string
+	Device string
+	// Domain matches the domain and its subdomains
+	Domain string
+	// Limit is the maximum number of queries returned
+	Limit int
+}
+
+// QueryLog keeps recent DNS queries in memory so that
+// they can be searched, i.e. during incident response.
+type QueryLog struct {
+	opts QueryLogOpts
+	sink io.WriteCloser
+
+	lock sync.Mutex
+	// ordered from oldest to newest
+	entries []QueryLogEntry
+}
+
+func NewQueryLog(opts QueryLogOpts) (*QueryLog, error) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultQueryLogEntries
+	}
+	if opts.Retention == 0 {
+		opts.Retention = defaultQueryLogRetention
+	}
+	sink, err := openQueryLogSink(opts.Sink)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open dns query log sink %s", opts.Sink)
+	}
+	return &QueryLog{
+		opts: opts,
+		sink: sink,
+	}, nil
+}
+
+func openQueryLogSink(sink string) (io.WriteCloser, error) {
+	switch {
+	case sink == "":
+		return nil, nil
+	case sink == "syslog":
+		return syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "wg-access-server")
+	case strings.HasPrefix(sink, "syslog://"):
+		u, err := url.Parse(sink)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address")
+		}
+		return syslog.Dial("udp", u.Host, syslog.LOG_INFO|syslog.LOG_DAEMON, "wg-access-server")
+	}
+	return os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+func (q *QueryLog) Close() error {
+	if q.sink != nil {
+		return q.sink.Close()
+	}
+	return nil
+}
+
+// Record adds a query to the log.
+func (q *QueryLog) Record(entry QueryLogEntry) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.entries = append(q.entries, entry)
+	if len(q.entries) > q.opts.MaxEntries {
+		q.entries = q.entries[len(q.entries)-q.opts.MaxEntries:]
+	}
+	cutoff := entry.Time.Add(-q.opts.Retention)
+	for len(q.entries) > 0 && q.entries[0].Time.Before(cutoff) {
+		q.entries = q.entries[1:]
+	}
+
+	if q.sink != nil {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = q.sink.Write(append(line, '\n'))
+		}
+		if err != nil {
+			logrus.Warn(errors.Wrap(err, "failed to write to dns query log sink"))
+		}
+	}
+}
+
+// Search returns the queries that match a filter, newest first.
+func (q *QueryLog) Search(filter QueryLogFilter) []QueryLogEntry {
+	domain := ""
+	if filter.Domain != "" {
+		domain = dns.CanonicalName(filter.Domain)
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	cutoff := time.Now().Add(-q.opts.Retention)
+	result := []QueryLogEntry{}
+	for i := len(q.entries) - 1; i >= 0; i-- {
+		entry := q.entries[i]
+		if entry.Time.Before(cutoff) {
+			break
+		}
+		if filter.Owner != "" && entry.Owner != filter.Owner {
+			continue
+		}
+		if filter.Device != "" && entry.Device != filter.Device {
+			continue
+		}
+		if domain != "" && !dns.IsSubDomain(domain, dns.CanonicalName(entry.Name)) {
+			continue
+		}
+		result = append(result, entry)
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
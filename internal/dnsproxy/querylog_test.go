@@ -0,0 +1,35 @@
+package dnsproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLogSearch(t *testing.T) {
+	require := require.New(t)
+
+	log, err := NewQueryLog(QueryLogOpts{MaxEntries: 3})
+	require.NoError(err)
+	defer log.Close()
+
+	now := time.Now()
+	log.Record(QueryLogEntry{Time: now, Owner: "alice", Device: "laptop", Name: "dropped.example.com."})
+	log.Record(QueryLogEntry{Time: now, Owner: "alice", Device: "laptop", Name: "www.example.com."})
+	log.Record(QueryLogEntry{Time: now, Owner: "alice", Device: "phone", Name: "example.org."})
+	log.Record(QueryLogEntry{Time: now, Owner: "bob", Device: "laptop", Name: "Example.com."})
+
+	// the oldest query was dropped
+	require.Len(log.Search(QueryLogFilter{}), 3)
+
+	results := log.Search(QueryLogFilter{Domain: "example.com"})
+	require.Len(results, 2)
+	require.Equal("bob", results[0].Owner, "newest first")
+
+	results = log.Search(QueryLogFilter{Owner: "alice", Device: "laptop"})
+	require.Len(results, 1)
+	require.Equal("www.example.com.", results[0].Name)
+
+	require.Len(log.Search(QueryLogFilter{Limit: 1}), 1)
+}
@@ -0,0 +1,154 @@
+package dnsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// the default TTL of static records
+const defaultRecordTTL = 300
+
+// the maximum number of CNAMEs that are followed
+// when answering a query for a static CNAME record
+const maxCNAMEChain = 8
+
+// StaticRecord is a DNS record that's
+// answered without asking an upstream.
+type StaticRecord struct {
+	Name string
+	// Type is one of A, AAAA, CNAME or TXT
+	Type  string
+	Value string
+	// TTL defaults to 300 seconds
+	TTL uint32
+}
+
+// Records answers queries for static records authoritatively.
+// The records can be replaced at any time using Set.
+type Records struct {
+	lock    sync.RWMutex
+	records map[string][]dns.RR
+}
+
+func NewRecords(records []StaticRecord) (*Records, error) {
+	r := &Records{}
+	if err := r.Set(records); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Set replaces all records. The existing records
+// are kept if any of the new records are invalid.
+func (r *Records) Set(records []StaticRecord) error {
+	parsed := map[string][]dns.RR{}
+	for _, record := range records {
+		rr, err := parseStaticRecord(record)
+		if err != nil {
+			return err
+		}
+		name := rr.Header().Name
+		for _, existing := range parsed[name] {
+			if existing.Header().Rrtype == dns.TypeCNAME || rr.Header().Rrtype == dns.TypeCNAME {
+				return fmt.Errorf("invalid dns record %s: a CNAME record can't have other records with the same name", record.Name)
+			}
+		}
+		parsed[name] = append(parsed[name], rr)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.records = parsed
+	return nil
+}
+
+func parseStaticRecord(record StaticRecord) (dns.RR, error) {
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = defaultRecordTTL
+	}
+	value := record.Value
+	switch strings.ToUpper(record.Type) {
+	case "A", "AAAA", "CNAME":
+	case "TXT":
+		if !strings.HasPrefix(value, `"`) {
+			value = strconv.Quote(value)
+		}
+	default:
+		return nil, fmt.Errorf("invalid dns record %s: unsupported type '%s' - must be A, AAAA, CNAME or TXT", record.Name, record.Type)
+	}
+	if _, ok := dns.IsDomainName(record.Name); !ok || record.Name == "" {
+		return nil, fmt.Errorf("invalid dns record name '%s'", record.Name)
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(record.Name), ttl, strings.ToUpper(record.Type), value))
+	if err != nil || rr == nil {
+		return nil, fmt.Errorf("invalid dns record %s: invalid %s value '%s'", record.Name, record.Type, record.Value)
+	}
+	rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+	return rr, nil
+}
+
+// Lookup answers a query for a static record.
+// CNAME records are followed if their target is also
+// a static record. The second return value is false if
+// there are no records with the query's name.
+func (r *Records) Lookup(m *dns.Msg) (*dns.Msg, bool) {
+	if len(m.Question) == 0 {
+		return nil, false
+	}
+	q := m.Question[0]
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	name := dns.CanonicalName(q.Name)
+	if _, ok := r.records[name]; !ok {
+		return nil, false
+	}
+
+	response := &dns.Msg{}
+	response.SetReply(m)
+	response.Authoritative = true
+
+	for i := 0; i < maxCNAMEChain; i++ {
+		rrs, ok := r.records[name]
+		if !ok {
+			break
+		}
+		if cname, ok := rrs[0].(*dns.CNAME); ok && q.Qtype != dns.TypeCNAME {
+			response.Answer = append(response.Answer, dns.Copy(cname))
+			name = dns.CanonicalName(cname.Target)
+			continue
+		}
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == q.Qtype || q.Qtype == dns.TypeANY {
+				response.Answer = append(response.Answer, dns.Copy(rr))
+			}
+		}
+		break
+	}
+	return response, true
+}
+
+// cnameTarget returns the name that a response's CNAME
+// chain ends at if the chain leaves the static records.
+func (r *Records) cnameTarget(response *dns.Msg) (string, bool) {
+	if len(response.Answer) == 0 {
+		return "", false
+	}
+	cname, ok := response.Answer[len(response.Answer)-1].(*dns.CNAME)
+	if !ok {
+		return "", false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if _, ok := r.records[dns.CanonicalName(cname.Target)]; ok {
+		return "", false
+	}
+	return cname.Target, true
+}
@@ -0,0 +1,47 @@
+package dnsproxy
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRecords(t *testing.T) {
+	require := require.New(t)
+
+	records, err := NewRecords([]StaticRecord{
+		{Name: "git.internal", Type: "A", Value: "10.0.1.10"},
+		{Name: "git.internal", Type: "txt", Value: "owner=platform", TTL: 60},
+		{Name: "code.internal", Type: "CNAME", Value: "git.internal"},
+	})
+	require.NoError(err)
+
+	response, ok := records.Lookup(zoneQuery("GIT.internal", dns.TypeA))
+	require.True(ok)
+	require.True(response.Authoritative)
+	require.Len(response.Answer, 1)
+	require.Equal("10.0.1.10", response.Answer[0].(*dns.A).A.String())
+	require.Equal(uint32(defaultRecordTTL), response.Answer[0].Header().Ttl)
+
+	response, _ = records.Lookup(zoneQuery("git.internal", dns.TypeTXT))
+	require.Equal([]string{"owner=platform"}, response.Answer[0].(*dns.TXT).Txt)
+
+	// cnames are followed
+	response, _ = records.Lookup(zoneQuery("code.internal", dns.TypeA))
+	require.Len(response.Answer, 2)
+	require.Equal("10.0.1.10", response.Answer[1].(*dns.A).A.String())
+
+	_, ok = records.Lookup(zoneQuery("other.internal", dns.TypeA))
+	require.False(ok)
+
+	// invalid records keep the existing records
+	require.Error(records.Set([]StaticRecord{{Name: "bad.internal", Type: "A", Value: "not an ip"}}))
+	require.Error(records.Set([]StaticRecord{{Name: "bad.internal", Type: "MX", Value: "10 mail.internal"}}))
+	_, ok = records.Lookup(zoneQuery("git.internal", dns.TypeA))
+	require.True(ok)
+
+	require.NoError(records.Set(nil))
+	_, ok = records.Lookup(zoneQuery("git.internal", dns.TypeA))
+	require.False(ok)
+}
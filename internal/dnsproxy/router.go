@@ -0,0 +1,63 @@
+package dnsproxy
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// ForwardRule sends queries for a domain and
+// its subdomains to specific upstream servers.
+type ForwardRule struct {
+	Domain   string
+	Upstream []string
+}
+
+// router picks the upstreams for a query. Queries are sent
+// to the upstreams of the most specific matching rule or to
+// the default upstreams if no rule matches.
+type router struct {
+	fallback *upstreamPool
+	rules    map[string]*upstreamPool
+}
+
+func newRouter(fallback *upstreamPool, rules []ForwardRule, strategy Strategy, timeout time.Duration, rootCAs *x509.CertPool) (*router, error) {
+	r := &router{
+		fallback: fallback,
+		rules:    map[string]*upstreamPool{},
+	}
+	for _, rule := range rules {
+		domain := dns.CanonicalName(rule.Domain)
+		if _, ok := dns.IsDomainName(domain); !ok || domain == "." {
+			return nil, fmt.Errorf("invalid dns forwarding domain '%s'", rule.Domain)
+		}
+		if _, ok := r.rules[domain]; ok {
+			return nil, fmt.Errorf("duplicate dns forwarding rule for '%s'", rule.Domain)
+		}
+		pool, err := newUpstreamPool(rule.Upstream, strategy, timeout, rootCAs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid dns forwarding rule for '%s'", rule.Domain)
+		}
+		r.rules[domain] = pool
+	}
+	return r, nil
+}
+
+// route returns the upstreams for a name. The name's suffixes
+// are matched from the longest to the shortest so that a rule
+// for "corp.example.com" matches "www.corp.example.com" but
+// not "notcorp.example.com".
+func (r *router) route(name string) *upstreamPool {
+	if len(r.rules) > 0 {
+		name = dns.CanonicalName(name)
+		for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+			if pool, ok := r.rules[name[off:]]; ok {
+				return pool
+			}
+		}
+	}
+	return r.fallback
+}
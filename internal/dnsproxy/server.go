@@ -1,62 +1,234 @@
 package dnsproxy
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/miekg/dns"
-	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/storage"
 	"github.com/sirupsen/logrus"
 )
 
+// the EDNS0 UDP buffer size that we advertise to clients and
+// upstreams. 1232 bytes avoids IP fragmentation on almost
+// all networks (see dnsflagday.net/2020).
+const defaultBufferSize = 1232
+
 type DNSServerOpts struct {
+	// Addresses are the IP addresses that the DNS
+	// server listens on (udp and tcp).
+	// Defaults to 0.0.0.0
+	Addresses []string
+	// Port is the port that the DNS server listens on.
+	// Defaults to 53
+	Port     int
 	Upstream []string
+	// Forward sends queries for some domains to
+	// other upstreams (i.e. internal zones that only
+	// resolve on an office DNS server).
+	// Optional
+	Forward []ForwardRule
+	// Strategy decides which upstream is tried first.
+	// Defaults to failover
+	Strategy Strategy
+	// Timeout is how long to wait for each upstream
+	// before trying the next one.
+	// Defaults to 2 seconds
+	Timeout time.Duration
+	// BufferSize is the EDNS0 UDP buffer size used
+	// with clients and upstreams.
+	// Defaults to 1232
+	BufferSize uint16
+	// RootCAs are used to verify the certificates of
+	// DNS-over-HTTPS and DNS-over-TLS upstreams.
+	// Defaults to the system's root CAs
+	RootCAs *x509.CertPool
+	// Cache configures the response cache.
+	Cache CacheOpts
+	// Zone is answered authoritatively instead
+	// of being sent to the upstreams.
+	// Optional
+	Zone *Zone
+	// Records are static records that are
+	// answered instead of going upstream.
+	// Optional
+	Records *Records
+	// Blocklist filters queries unless the device
+	// that sent them is exempt.
+	// Optional
+	Blocklist *Blocklist
+	// Devices finds the device that sent a query.
+	// Optional
+	Devices Devices
+	// QueryLog records the queries of each client.
+	// Optional
+	QueryLog *QueryLog
+}
+
+// Devices finds VPN devices by their VPN IP.
+type Devices interface {
+	DeviceByIP(ip net.IP) (*storage.Device, bool)
 }
 
 type DNSServer struct {
-	server   *dns.Server
-	client   *dns.Client
-	cache    *cache.Cache
-	upstream []string
+	servers    []*dns.Server
+	cache      *dnsCache
+	upstream   *router
+	bufferSize uint16
+	zone       *Zone
+	records    *Records
+	blocklist  *Blocklist
+	devices    Devices
+	queryLog   *QueryLog
+
+	lock    sync.Mutex
+	closed  bool
+	failure error
 }
 
 func New(opts DNSServerOpts) (*DNSServer, error) {
-	if len(opts.Upstream) == 0 {
-		return nil, errors.New("at least 1 upstream dns server is required for the dns proxy server to function")
+	if len(opts.Addresses) == 0 {
+		opts.Addresses = []string{"0.0.0.0"}
+	}
+	if opts.Port == 0 {
+		opts.Port = 53
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = StrategyFailover
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	if opts.BufferSize < dns.MinMsgSize {
+		opts.BufferSize = defaultBufferSize
+	}
+	if err := opts.Cache.validate(); err != nil {
+		return nil, err
 	}
 
-	addr := "0.0.0.0:53"
-	logrus.Infof("starting dns server on %s with upstreams: %s", addr, strings.Join(opts.Upstream, ", "))
+	fallback, err := newUpstreamPool(opts.Upstream, opts.Strategy, opts.Timeout, opts.RootCAs)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := newRouter(fallback, opts.Forward, opts.Strategy, opts.Timeout, opts.RootCAs)
+	if err != nil {
+		return nil, err
+	}
 
 	dnsServer := &DNSServer{
-		server: &dns.Server{
-			Addr: addr,
-			Net:  "udp",
-		},
-		client: &dns.Client{
-			SingleInflight: true,
-			Timeout:        5 * time.Second,
-		},
-		cache:    cache.New(10*time.Minute, 10*time.Minute),
-		upstream: opts.Upstream,
-	}
-	dnsServer.server.Handler = dnsServer
+		cache:      newCache(opts.Cache),
+		upstream:   upstream,
+		bufferSize: opts.BufferSize,
+		zone:       opts.Zone,
+		records:    opts.Records,
+		blocklist:  opts.Blocklist,
+		devices:    opts.Devices,
+		queryLog:   opts.QueryLog,
+	}
+
+	for _, rule := range opts.Forward {
+		logrus.Infof("forwarding dns queries for %s to: %s", rule.Domain, strings.Join(rule.Upstream, ", "))
+	}
+
+	for _, ip := range opts.Addresses {
+		addr := net.JoinHostPort(ip, strconv.Itoa(opts.Port))
+		logrus.Infof("starting dns server on %s (udp/tcp) with upstreams (%s): %s", addr, opts.Strategy, strings.Join(opts.Upstream, ", "))
+
+		// listen before returning so that
+		// bind errors fail fast
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			dnsServer.Close()
+			return nil, errors.Wrapf(err, "failed to listen on %s/udp", addr)
+		}
+		if err := dnsServer.serve(&dns.Server{PacketConn: pc, Handler: dnsServer}, addr+"/udp"); err != nil {
+			dnsServer.Close()
+			return nil, err
+		}
 
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			dnsServer.Close()
+			return nil, errors.Wrapf(err, "failed to listen on %s/tcp", addr)
+		}
+		if err := dnsServer.serve(&dns.Server{Listener: l, Handler: dnsServer}, addr+"/tcp"); err != nil {
+			dnsServer.Close()
+			return nil, err
+		}
+	}
+
+	return dnsServer, nil
+}
+
+// serve starts a dns server on its listener
+// and waits until it's ready for queries.
+func (d *DNSServer) serve(server *dns.Server, name string) error {
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() {
+		close(started)
+	}
+	d.servers = append(d.servers, server)
+
+	stopped := make(chan error, 1)
 	go func() {
-		if err := dnsServer.server.ListenAndServe(); err != nil {
-			logrus.Error(errors.Wrap(err, "failed to start dns server"))
+		err := server.ActivateAndServe()
+		if err == nil {
+			err = errors.New("dns server stopped")
+		}
+		stopped <- err
+
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if !d.closed {
+			logrus.Error(errors.Wrapf(err, "dns server on %s stopped", name))
+			d.failure = errors.Wrapf(err, "dns server on %s stopped", name)
 		}
 	}()
 
-	return dnsServer, nil
+	select {
+	case <-started:
+		return nil
+	case err := <-stopped:
+		return errors.Wrapf(err, "failed to start dns server on %s", name)
+	}
 }
 
 func (d *DNSServer) Close() error {
-	return d.server.Shutdown()
+	d.lock.Lock()
+	d.closed = true
+	d.lock.Unlock()
+
+	var result error
+	for _, server := range d.servers {
+		if err := server.Shutdown(); err != nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// Health returns an error if a listener has stopped
+// or if all of the upstream servers are unhealthy.
+func (d *DNSServer) Health() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return errors.New("dns server is closed")
+	}
+	if d.failure != nil {
+		return d.failure
+	}
+	if !d.upstream.fallback.healthy() {
+		return errors.New("all upstream dns servers are unhealthy")
+	}
+	return nil
 }
 
 func (d *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
@@ -71,14 +243,51 @@ func (d *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 
 	switch r.Opcode {
 	case dns.OpcodeQuery:
-		m, err := d.Lookup(r)
+		// like other resolvers we only answer queries with
+		// exactly 1 question and the lookup relies on it
+		if len(r.Question) != 1 {
+			m := &dns.Msg{}
+			m.SetRcodeFormatError(r)
+			w.WriteMsg(m)
+			return
+		}
+
+		start := time.Now()
+		client := clientIP(w.RemoteAddr())
+		device, _ := d.device(client)
+		entry := &QueryLogEntry{}
+
+		m, err := d.lookup(r, device == nil || !device.DNSFilterExempt, entry)
 		if err != nil {
 			logrus.Errorf("failed lookup record with error: %s\n%s", err.Error(), r)
 			dns.HandleFailed(w, r)
-			return
+			m = &dns.Msg{}
+			m.SetRcode(r, dns.RcodeServerFailure)
+		} else {
+			// SetReply resets the rcode (i.e. NXDOMAIN)
+			rcode := m.Rcode
+			m.SetReply(r)
+			m.Rcode = rcode
+			d.fitResponse(w, r, m)
+			w.WriteMsg(m)
+		}
+
+		dnsQueries.WithLabelValues(entry.AnsweredBy, dns.RcodeToString[m.Rcode]).Inc()
+		dnsQueryDuration.WithLabelValues(entry.AnsweredBy).Observe(time.Since(start).Seconds())
+
+		if d.queryLog != nil {
+			entry.Time = start
+			entry.ClientIP = client.String()
+			entry.Name = r.Question[0].Name
+			entry.Type = dns.TypeToString[r.Question[0].Qtype]
+			entry.Rcode = dns.RcodeToString[m.Rcode]
+			entry.Duration = time.Since(start)
+			if device != nil {
+				entry.Owner = device.Owner
+				entry.Device = device.Name
+			}
+			d.queryLog.Record(*entry)
 		}
-		m.SetReply(r)
-		w.WriteMsg(m)
 	default:
 		m := &dns.Msg{}
 		m.SetReply(r)
@@ -87,38 +296,172 @@ func (d *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 
 }
 
+// Lookup answers a query. Blocked names are filtered.
+// The query must have exactly 1 question.
 func (d *DNSServer) Lookup(m *dns.Msg) (*dns.Msg, error) {
-	key := makekey(m)
+	if len(m.Question) != 1 {
+		return nil, errors.New("dns queries must have exactly 1 question")
+	}
+	return d.lookup(m, true, &QueryLogEntry{})
+}
+
+// lookup answers a query and records
+// how it was answered in the entry.
+// The query must have exactly 1 question.
+func (d *DNSServer) lookup(m *dns.Msg, filter bool, entry *QueryLogEntry) (*dns.Msg, error) {
+	if d.records != nil {
+		if response, ok := d.records.Lookup(m); ok {
+			entry.AnsweredBy = AnsweredByRecords
+			// a CNAME to a name outside of the static
+			// records is resolved like any other query
+			if target, ok := d.records.cnameTarget(response); ok {
+				q := m.Copy()
+				q.Question[0].Name = target
+				if resolved, err := d.lookup(q, filter, &QueryLogEntry{}); err == nil {
+					response.Answer = append(response.Answer, resolved.Answer...)
+				}
+			}
+			return response, nil
+		}
+	}
+
+	// names in our own zone are never cached
+	// because they change with the devices
+	if d.zone != nil {
+		if response, ok := d.zone.Lookup(m); ok {
+			entry.AnsweredBy = AnsweredByZone
+			return response, nil
+		}
+	}
+
+	if filter && d.blocklist != nil {
+		if source, blocked := d.blocklist.Blocked(m.Question[0].Name); blocked {
+			logrus.Debugf("dns query blocked by %s: %s", source, prettyPrintMsg(m))
+			entry.AnsweredBy = AnsweredByBlocklist
+			entry.BlockedBy = source
+			return d.blocklist.Answer(m, source), nil
+		}
+	}
+
+	key := cacheKey(m)
 
 	// check the cache first
-	if item, found := d.cache.Get(key); found {
+	if response, prefetch, found := d.cache.get(key); found {
 		logrus.Debugf("dns cache hit %s", prettyPrintMsg(m))
-		return item.(*dns.Msg), nil
+		dnsCacheLookups.WithLabelValues("hit").Inc()
+		if prefetch {
+			go d.prefetch(key, m.Copy())
+		}
+		entry.AnsweredBy = AnsweredByCache
+		return response, nil
 	}
 
+	dnsCacheLookups.WithLabelValues("miss").Inc()
+
 	// fallback to upstream exchange
-	response, _, err := d.client.Exchange(m, net.JoinHostPort(d.upstream[0], "53"))
+	entry.AnsweredBy = AnsweredByUpstream
+	return d.exchange(key, m)
+}
+
+// exchange sends a query upstream and caches the response.
+func (d *DNSServer) exchange(key string, m *dns.Msg) (*dns.Msg, error) {
+	response, err := d.upstream.route(m.Question[0].Name).Exchange(d.upstreamQuery(m))
 	if err != nil {
 		return nil, err
 	}
 
-	if len(response.Answer) > 0 {
-		ttl := time.Duration(response.Answer[0].Header().Ttl) * time.Second
-		logrus.Debugf("caching dns response for %s for %v seconds", prettyPrintMsg(m), ttl)
-		d.cache.Set(key, response, ttl)
+	if ttl := d.cache.set(key, response); ttl > 0 {
+		logrus.Debugf("caching dns response for %s for %v", prettyPrintMsg(m), ttl)
 	}
 
 	return response, nil
 }
 
-func makekey(m *dns.Msg) string {
-	q := m.Question[0]
-	return fmt.Sprintf("%s:%d:%d", q.Name, q.Qtype, q.Qclass)
+// prefetch refreshes a popular cached response
+// before it expires.
+func (d *DNSServer) prefetch(key string, m *dns.Msg) {
+	logrus.Debugf("prefetching dns response for %s", prettyPrintMsg(m))
+	if _, err := d.exchange(key, m); err != nil {
+		logrus.Debugf("failed to prefetch dns response for %s: %s", prettyPrintMsg(m), err)
+	}
+}
+
+// device returns the VPN device with a client's IP.
+func (d *DNSServer) device(ip net.IP) (*storage.Device, bool) {
+	if d.devices == nil || ip == nil {
+		return nil, false
+	}
+	return d.devices.DeviceByIP(ip)
+}
+
+func clientIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	}
+	return nil
+}
+
+// upstreamQuery returns a copy of a client's query that
+// advertises our own EDNS0 buffer size so that upstreams
+// can send large responses without truncating them.
+// The client's EDNS0 flags (i.e. the DO bit) are kept.
+func (d *DNSServer) upstreamQuery(m *dns.Msg) *dns.Msg {
+	q := m.Copy()
+	if opt := q.IsEdns0(); opt != nil {
+		opt.SetUDPSize(d.bufferSize)
+	} else {
+		q.SetEdns0(d.bufferSize, false)
+	}
+	return q
+}
+
+// fitResponse makes a response fit the size that the client
+// can receive. UDP clients without EDNS0 only accept 512 bytes.
+// Truncated responses have the TC bit set so that the client
+// retries over TCP.
+func (d *DNSServer) fitResponse(w dns.ResponseWriter, r *dns.Msg, m *dns.Msg) {
+	size := dns.MinMsgSize
+	clientOpt := r.IsEdns0()
+	if clientOpt != nil && clientOpt.UDPSize() > dns.MinMsgSize {
+		size = int(clientOpt.UDPSize())
+		if size > int(d.bufferSize) {
+			size = int(d.bufferSize)
+		}
+	}
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		size = dns.MaxMsgSize
+	}
+
+	// an OPT record must only be included in the
+	// response if the client sent one
+	if opt := m.IsEdns0(); opt != nil {
+		if clientOpt == nil {
+			m.Extra = removeOPT(m.Extra)
+		} else {
+			opt.SetUDPSize(d.bufferSize)
+		}
+	}
+
+	m.Truncate(size)
+}
+
+func removeOPT(rrs []dns.RR) []dns.RR {
+	result := []dns.RR{}
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			result = append(result, rr)
+		}
+	}
+	return result
 }
 
 func prettyPrintMsg(m *dns.Msg) string {
 	if len(m.Question) > 0 {
-		return fmt.Sprintf("dns query for: %s", makekey(m))
+		q := m.Question[0]
+		return fmt.Sprintf("dns query for: %s:%d:%d", q.Name, q.Qtype, q.Qclass)
 	}
 	return m.String()
 }
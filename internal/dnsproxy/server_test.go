@@ -0,0 +1,151 @@
+package dnsproxy
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerFailsFastOnBindErrors(t *testing.T) {
+	require := require.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	_, err = New(DNSServerOpts{
+		Addresses: []string{"127.0.0.1"},
+		Port:      port,
+		Upstream:  []string{"127.0.0.1:1"},
+	})
+	require.Error(err)
+}
+
+func TestServerHealth(t *testing.T) {
+	require := require.New(t)
+
+	// find a port that's free for udp and tcp
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	records, err := NewRecords([]StaticRecord{{Name: "git.internal", Type: "A", Value: "10.0.1.10"}})
+	require.NoError(err)
+	server, err := New(DNSServerOpts{
+		Addresses: []string{"127.0.0.1"},
+		Port:      port,
+		Upstream:  []string{"127.0.0.1:1"},
+		Records:   records,
+	})
+	require.NoError(err)
+	require.NoError(server.Health())
+
+	// the server answers queries as soon as New returns
+	for _, network := range []string{"udp", "tcp"} {
+		client := &dns.Client{Net: network}
+		response, _, err := client.Exchange(zoneQuery("git.internal", dns.TypeA), l.Addr().String())
+		require.NoError(err, network)
+		require.Len(response.Answer, 1)
+	}
+
+	require.NoError(server.Close())
+	require.Error(server.Health())
+}
+
+func TestServerRejectsMalformedQueries(t *testing.T) {
+	require := require.New(t)
+
+	server, address := newTestServer(t, DNSServerOpts{Upstream: []string{"127.0.0.1:1"}})
+	defer server.Close()
+
+	none := &dns.Msg{}
+	none.Id = dns.Id()
+	two := zoneQuery("a.example.com", dns.TypeA)
+	two.Question = append(two.Question, zoneQuery("b.example.com", dns.TypeA).Question...)
+
+	for _, q := range []*dns.Msg{none, two} {
+		for _, network := range []string{"udp", "tcp"} {
+			client := &dns.Client{Net: network}
+			response, _, err := client.Exchange(q, address)
+			require.NoError(err, network)
+			require.Equal(dns.RcodeFormatError, response.Rcode, network)
+		}
+		_, err := server.Lookup(q)
+		require.Error(err)
+	}
+}
+
+// newTestServer starts a dns server on a free
+// localhost port and returns its address
+func newTestServer(t *testing.T, opts DNSServerOpts) (*DNSServer, string) {
+	// find a port that's free for udp and tcp
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	opts.Addresses = []string{"127.0.0.1"}
+	opts.Port = port
+	server, err := New(opts)
+	require.NoError(t, err)
+	return server, net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+func TestServerResponseSizes(t *testing.T) {
+	require := require.New(t)
+
+	stub, upstream, stop := newTruncatingStub(t)
+	defer stop()
+	server, address := newTestServer(t, DNSServerOpts{Upstream: []string{upstream}})
+	defer server.Close()
+
+	exchange := func(network string, bufferSize uint16) *dns.Msg {
+		q := zoneQuery("example.com", dns.TypeA)
+		if bufferSize > 0 {
+			q.SetEdns0(bufferSize, false)
+		}
+		client := &dns.Client{Net: network}
+		response, _, err := client.Exchange(q, address)
+		require.NoError(err, "%s/%d", network, bufferSize)
+		return response
+	}
+	// the size of a response as it was sent
+	size := func(m *dns.Msg) int {
+		m.Compress = true
+		packed, err := m.Pack()
+		require.NoError(err)
+		return len(packed)
+	}
+
+	// udp clients without EDNS0 only accept 512 bytes
+	response := exchange("udp", 0)
+	require.True(response.Truncated)
+	require.NotEmpty(response.Answer)
+	require.Nil(response.IsEdns0(), "the client didn't send an OPT record")
+	require.True(size(response) <= dns.MinMsgSize)
+	// the server advertises its own buffer size upstream and
+	// retries over tcp when the upstream truncates the response
+	require.Equal([]string{"udp/1232", "tcp/1232"}, stub.received())
+
+	// larger EDNS0 buffers are limited to the server's buffer size
+	response = exchange("udp", 4096)
+	require.True(response.Truncated)
+	require.NotNil(response.IsEdns0())
+	require.Equal(uint16(defaultBufferSize), response.IsEdns0().UDPSize())
+	require.True(size(response) <= defaultBufferSize)
+	require.True(size(response) > dns.MinMsgSize)
+
+	// tcp clients get the whole response
+	for _, bufferSize := range []uint16{0, 4096} {
+		response = exchange("tcp", bufferSize)
+		require.False(response.Truncated)
+		require.Len(response.Answer, 100)
+		require.Equal(bufferSize == 0, response.IsEdns0() == nil)
+	}
+	require.Empty(stub.received(), "the response is cached")
+}
@@ -0,0 +1,302 @@
+package dnsproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Strategy decides the order in which upstream
+// DNS servers are tried for each query.
+type Strategy string
+
+const (
+	// StrategyFailover always tries upstreams in the configured order
+	StrategyFailover Strategy = "failover"
+	// StrategyRoundRobin spreads queries evenly across upstreams
+	StrategyRoundRobin Strategy = "round-robin"
+	// StrategyFastest prefers the upstream with the lowest latency
+	StrategyFastest Strategy = "fastest"
+)
+
+// ParseStrategy validates a strategy name.
+// An empty name is the failover strategy.
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(name); s {
+	case "":
+		return StrategyFailover, nil
+	case StrategyFailover, StrategyRoundRobin, StrategyFastest:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown dns upstream strategy '%s' - must be failover, round-robin or fastest", name)
+}
+
+const (
+	// an upstream is marked as unhealthy after this
+	// many consecutive failed queries
+	maxFailures = 3
+	// unhealthy upstreams are only used as a last resort
+	// until this much time has passed
+	unhealthyBackoff = 30 * time.Second
+)
+
+// exchanger sends a query to a single upstream server.
+type exchanger interface {
+	Exchange(m *dns.Msg) (*dns.Msg, error)
+}
+
+// plainExchanger sends queries over UDP and
+// retries them over TCP if the answer is truncated.
+type plainExchanger struct {
+	udp     *dns.Client
+	tcp     *dns.Client
+	address string
+}
+
+func (u *plainExchanger) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	response, _, err := u.udp.Exchange(m, u.address)
+	if err == nil && response.Truncated {
+		logrus.Debugf("dns upstream %s truncated the response for %s, retrying over tcp", u.address, prettyPrintMsg(m))
+		response, _, err = u.tcp.Exchange(m, u.address)
+	}
+	return response, err
+}
+
+type upstream struct {
+	exchanger
+	name string
+
+	lock           sync.Mutex
+	failures       int
+	unhealthyUntil time.Time
+	// exponentially weighted moving average of the
+	// upstream's response time. 0 until the first response.
+	latency time.Duration
+}
+
+func (u *upstream) healthy(now time.Time) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return !now.Before(u.unhealthyUntil)
+}
+
+func (u *upstream) success(rtt time.Duration) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.failures >= maxFailures {
+		logrus.Infof("dns upstream %s is healthy again", u.name)
+		dnsUpstreamHealthy.WithLabelValues(u.name).Set(1)
+	}
+	u.failures = 0
+	u.unhealthyUntil = time.Time{}
+	if u.latency == 0 {
+		u.latency = rtt
+	} else {
+		u.latency = (u.latency*7 + rtt) / 8
+	}
+}
+
+func (u *upstream) failure() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.failures++
+	if u.failures >= maxFailures {
+		if u.failures == maxFailures {
+			logrus.Warnf("dns upstream %s is unhealthy after %d failed queries", u.name, u.failures)
+			dnsUpstreamHealthy.WithLabelValues(u.name).Set(0)
+		}
+		u.unhealthyUntil = time.Now().Add(unhealthyBackoff)
+	}
+}
+
+func (u *upstream) rtt() time.Duration {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.latency
+}
+
+// upstreamPool sends queries to a set of upstream servers.
+// A query is retried on the next upstream if an upstream
+// times out, fails or answers with SERVFAIL.
+type upstreamPool struct {
+	strategy  Strategy
+	upstreams []*upstream
+	next      uint32
+}
+
+func newUpstreamPool(addresses []string, strategy Strategy, timeout time.Duration, rootCAs *x509.CertPool) (*upstreamPool, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("at least 1 upstream dns server is required for the dns proxy server to function")
+	}
+
+	pool := &upstreamPool{
+		strategy: strategy,
+	}
+	for _, address := range addresses {
+		u, err := newUpstream(address, timeout, rootCAs)
+		if err != nil {
+			return nil, err
+		}
+		pool.upstreams = append(pool.upstreams, u)
+		dnsUpstreamHealthy.WithLabelValues(u.name).Set(1)
+	}
+	return pool, nil
+}
+
+// newUpstream creates an upstream from an address.
+// Supported addresses are:
+//
+//	1.1.1.1 or 1.1.1.1:53       plain dns (udp with tcp fallback)
+//	https://1.1.1.1/dns-query   DNS-over-HTTPS
+//	tls://9.9.9.9:853           DNS-over-TLS (port defaults to 853)
+//
+// TLS certificates are verified using rootCAs or
+// the system's root CAs if rootCAs is nil.
+func newUpstream(address string, timeout time.Duration, rootCAs *x509.CertPool) (*upstream, error) {
+	if !strings.Contains(address, "://") {
+		return newPlainUpstream(address, timeout)
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid dns upstream '%s'", address)
+	}
+	tlsConfig := &tls.Config{
+		ServerName: u.Hostname(),
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	switch u.Scheme {
+	case "udp":
+		return newPlainUpstream(u.Host, timeout)
+	case "https":
+		return &upstream{
+			name:      address,
+			exchanger: newDoHExchanger(address, tlsConfig, timeout),
+		}, nil
+	case "tls":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "853")
+		}
+		return &upstream{
+			name:      address,
+			exchanger: newDoTExchanger(host, tlsConfig, timeout),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported dns upstream scheme '%s' - must be udp, https or tls", u.Scheme)
+}
+
+func newPlainUpstream(address string, timeout time.Duration) (*upstream, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid dns upstream '%s'", address)
+	}
+	return &upstream{
+		name: address,
+		exchanger: &plainExchanger{
+			address: address,
+			udp: &dns.Client{
+				Net:            "udp",
+				SingleInflight: true,
+				Timeout:        timeout,
+			},
+			tcp: &dns.Client{
+				Net:            "tcp",
+				SingleInflight: true,
+				Timeout:        timeout,
+			},
+		},
+	}, nil
+}
+
+// Exchange sends a query upstream. Every upstream is tried
+// at most once and the last failure is returned if they all fail.
+func (p *upstreamPool) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	var lastResponse *dns.Msg
+	var lastErr error
+
+	for _, u := range p.order() {
+		start := time.Now()
+		response, err := u.Exchange(m)
+		if err != nil {
+			logrus.Debugf("dns upstream %s failed: %s", u.name, err)
+			u.failure()
+			dnsUpstreamErrors.WithLabelValues(u.name).Inc()
+			lastErr = errors.Wrapf(err, "dns upstream %s failed", u.name)
+			continue
+		}
+		if response.Rcode == dns.RcodeServerFailure {
+			logrus.Debugf("dns upstream %s answered SERVFAIL for %s", u.name, prettyPrintMsg(m))
+			u.failure()
+			dnsUpstreamErrors.WithLabelValues(u.name).Inc()
+			lastResponse, lastErr = response, nil
+			continue
+		}
+		rtt := time.Since(start)
+		u.success(rtt)
+		dnsUpstreamDuration.WithLabelValues(u.name).Observe(rtt.Seconds())
+		return response, nil
+	}
+
+	if lastResponse != nil {
+		return lastResponse, nil
+	}
+	return nil, lastErr
+}
+
+// healthy reports if at least one upstream is healthy.
+func (p *upstreamPool) healthy() bool {
+	now := time.Now()
+	for _, u := range p.upstreams {
+		if u.healthy(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// order returns the upstreams in the order they should be
+// tried for the next query. Unhealthy upstreams are moved
+// to the end so they are only used if nothing else works.
+func (p *upstreamPool) order() []*upstream {
+	ordered := make([]*upstream, len(p.upstreams))
+	copy(ordered, p.upstreams)
+
+	switch p.strategy {
+	case StrategyRoundRobin:
+		n := int(atomic.AddUint32(&p.next, 1)-1) % len(ordered)
+		ordered = append(append([]*upstream{}, ordered[n:]...), ordered[:n]...)
+	case StrategyFastest:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].rtt() < ordered[j].rtt()
+		})
+	}
+
+	now := time.Now()
+	healthy := []*upstream{}
+	unhealthy := []*upstream{}
+	for _, u := range ordered {
+		if u.healthy(now) {
+			healthy = append(healthy, u)
+		} else {
+			unhealthy = append(unhealthy, u)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
@@ -0,0 +1,423 @@
+package dnsproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+// stubHandler answers every A query with 10.0.0.1
+var stubHandler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	w.WriteMsg(stubAnswer(r))
+})
+
+func stubAnswer(r *dns.Msg) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Answer = append(m.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("10.0.0.1"),
+	})
+	return m
+}
+
+// newDoHStub starts a DNS-over-HTTPS server and
+// returns the number of tls connections it accepted.
+func newDoHStub(t *testing.T) (*httptest.Server, *int32) {
+	conns := int32(0)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		q := &dns.Msg{}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohMediaType || q.Unpack(body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		packed, _ := stubAnswer(q).Pack()
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(packed)
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.StartTLS()
+	return ts, &conns
+}
+
+type countingListener struct {
+	net.Listener
+	conns *int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(l.conns, 1)
+	}
+	return c, err
+}
+
+// newDoTStub starts a DNS-over-TLS server using the certificate
+// of an https test server and returns the number of tls
+// connections it accepted.
+func newDoTStub(t *testing.T, cert tls.Certificate) (*dns.Server, string, *int32) {
+	conns := int32(0)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	server := &dns.Server{
+		Listener: &countingListener{Listener: l, conns: &conns},
+		Net:      "tcp-tls",
+		Handler:  stubHandler,
+	}
+	go server.ActivateAndServe()
+	return server, l.Addr().String(), &conns
+}
+
+func certPool(ts *httptest.Server) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	return pool
+}
+
+func query(name string) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	return m
+}
+
+func TestDoHUpstream(t *testing.T) {
+	require := require.New(t)
+
+	ts, conns := newDoHStub(t)
+	defer ts.Close()
+	pool, err := newUpstreamPool([]string{ts.URL + "/dns-query"}, StrategyFailover, time.Second, certPool(ts))
+	require.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		q := query("example.com")
+		response, err := pool.Exchange(q)
+		require.NoError(err)
+		require.Equal(q.Id, response.Id)
+		require.Len(response.Answer, 1)
+		require.Equal("10.0.0.1", response.Answer[0].(*dns.A).A.String())
+	}
+
+	// the connection is reused between queries
+	require.Equal(int32(1), atomic.LoadInt32(conns))
+}
+
+func TestDoTUpstream(t *testing.T) {
+	require := require.New(t)
+
+	ts, _ := newDoHStub(t)
+	defer ts.Close()
+	server, address, conns := newDoTStub(t, ts.TLS.Certificates[0])
+	defer server.Shutdown()
+	pool, err := newUpstreamPool([]string{"tls://" + address}, StrategyFailover, time.Second, certPool(ts))
+	require.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		response, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+		require.Len(response.Answer, 1)
+		require.Equal("10.0.0.1", response.Answer[0].(*dns.A).A.String())
+	}
+
+	// the connection is reused between queries
+	require.Equal(int32(1), atomic.LoadInt32(conns))
+}
+
+func TestEncryptedUpstreamsVerifyCertificates(t *testing.T) {
+	require := require.New(t)
+
+	ts, _ := newDoHStub(t)
+	defer ts.Close()
+	server, address, _ := newDoTStub(t, ts.TLS.Certificates[0])
+	defer server.Shutdown()
+
+	// the stub certificates aren't signed by a system root CA
+	for _, upstream := range []string{ts.URL + "/dns-query", "tls://" + address} {
+		pool, err := newUpstreamPool([]string{upstream}, StrategyFailover, time.Second, nil)
+		require.NoError(err)
+
+		_, err = pool.Exchange(query("example.com"))
+		require.Error(err, upstream)
+	}
+}
+
+func TestUpstreamFailover(t *testing.T) {
+	require := require.New(t)
+
+	ts, _ := newDoHStub(t)
+	defer ts.Close()
+
+	// nothing listens on the first upstream
+	pool, err := newUpstreamPool([]string{"127.0.0.1:1", ts.URL + "/dns-query"}, StrategyFailover, 200*time.Millisecond, certPool(ts))
+	require.NoError(err)
+
+	for i := 0; i < maxFailures+1; i++ {
+		response, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+		require.Len(response.Answer, 1)
+	}
+
+	// the failed upstream is only tried as a last resort
+	require.Equal(ts.URL+"/dns-query", pool.order()[0].name)
+}
+
+// fakeExchanger answers queries with a result
+// after a delay and counts the queries it receives
+type fakeExchanger struct {
+	delay   time.Duration
+	rcode   int
+	err     error
+	queries int32
+}
+
+func (f *fakeExchanger) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	atomic.AddInt32(&f.queries, 1)
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	response := stubAnswer(m)
+	response.Rcode = f.rcode
+	return response, nil
+}
+
+func fakePool(strategy Strategy, exchangers ...*fakeExchanger) *upstreamPool {
+	pool := &upstreamPool{strategy: strategy}
+	for i, e := range exchangers {
+		pool.upstreams = append(pool.upstreams, &upstream{
+			name:      string(rune('a' + i)),
+			exchanger: e,
+		})
+	}
+	return pool
+}
+
+func TestUpstreamStrategies(t *testing.T) {
+	require := require.New(t)
+
+	queries := func(exchangers ...*fakeExchanger) []int32 {
+		result := []int32{}
+		for _, e := range exchangers {
+			result = append(result, atomic.SwapInt32(&e.queries, 0))
+		}
+		return result
+	}
+
+	// failover always starts with the first upstream
+	a, b := &fakeExchanger{}, &fakeExchanger{}
+	pool := fakePool(StrategyFailover, a, b)
+	for i := 0; i < 4; i++ {
+		_, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+	}
+	require.Equal([]int32{4, 0}, queries(a, b))
+
+	// and tries the next one on errors or SERVFAIL
+	a.rcode = dns.RcodeServerFailure
+	response, err := pool.Exchange(query("example.com"))
+	require.NoError(err)
+	require.Equal(dns.RcodeSuccess, response.Rcode)
+	require.Equal([]int32{1, 1}, queries(a, b))
+	a.rcode, a.err = dns.RcodeSuccess, errors.New("timeout")
+	_, err = pool.Exchange(query("example.com"))
+	require.NoError(err)
+	require.Equal([]int32{1, 1}, queries(a, b))
+
+	// the last SERVFAIL is returned if every upstream fails
+	b.rcode = dns.RcodeServerFailure
+	response, err = pool.Exchange(query("example.com"))
+	require.NoError(err)
+	require.Equal(dns.RcodeServerFailure, response.Rcode)
+	b.err = errors.New("timeout")
+	_, err = pool.Exchange(query("example.com"))
+	require.Error(err)
+
+	// round-robin spreads queries evenly
+	a, b, c := &fakeExchanger{}, &fakeExchanger{}, &fakeExchanger{}
+	pool = fakePool(StrategyRoundRobin, a, b, c)
+	for i := 0; i < 6; i++ {
+		_, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+	}
+	require.Equal([]int32{2, 2, 2}, queries(a, b, c))
+
+	// and still fails over to the next upstream
+	b.err = errors.New("timeout")
+	for i := 0; i < 3; i++ {
+		_, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+	}
+	require.Equal([]int32{1, 1, 2}, queries(a, b, c))
+
+	// fastest prefers the upstream with the lowest latency
+	// once every upstream has answered
+	slow, fast := &fakeExchanger{delay: 20 * time.Millisecond}, &fakeExchanger{}
+	pool = fakePool(StrategyFastest, slow, fast)
+	for i := 0; i < 2; i++ {
+		_, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+	}
+	require.Equal([]int32{1, 1}, queries(slow, fast), "upstreams without a latency are tried first")
+	for i := 0; i < 4; i++ {
+		_, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+	}
+	require.Equal([]int32{0, 4}, queries(slow, fast))
+
+	// unhealthy upstreams are only used as a last resort
+	fast.err = errors.New("timeout")
+	for i := 0; i < maxFailures; i++ {
+		_, err := pool.Exchange(query("example.com"))
+		require.NoError(err)
+	}
+	require.Equal("a", pool.order()[0].name)
+}
+
+// truncatingStub answers A queries with 100 records and
+// truncates udp responses to the size that the client can
+// receive, like a real dns server. It records the network
+// and EDNS0 buffer size of each query e.g. "udp/1232".
+type truncatingStub struct {
+	lock    sync.Mutex
+	queries []string
+}
+
+func (s *truncatingStub) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	for i := 0; i < 100; i++ {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.IPv4(10, 0, 0, byte(i)),
+		})
+	}
+
+	network, size := "udp", dns.MinMsgSize
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		network, size = "tcp", dns.MaxMsgSize
+	}
+	advertised := uint16(0)
+	if opt := r.IsEdns0(); opt != nil {
+		advertised = opt.UDPSize()
+		if network == "udp" && int(advertised) > size {
+			size = int(advertised)
+		}
+		m.SetEdns0(defaultBufferSize, false)
+	}
+	m.Truncate(size)
+
+	s.lock.Lock()
+	s.queries = append(s.queries, fmt.Sprintf("%s/%d", network, advertised))
+	s.lock.Unlock()
+	w.WriteMsg(m)
+}
+
+func (s *truncatingStub) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	queries := s.queries
+	s.queries = nil
+	return queries
+}
+
+// newTruncatingStub starts a truncatingStub on the same
+// udp and tcp port and returns its address
+func newTruncatingStub(t *testing.T) (*truncatingStub, string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := pc.LocalAddr().String()
+	l, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+
+	stub := &truncatingStub{}
+	servers := []*dns.Server{
+		{PacketConn: pc, Handler: stub},
+		{Listener: l, Handler: stub},
+	}
+	for _, server := range servers {
+		go server.ActivateAndServe()
+	}
+	return stub, address, func() {
+		for _, server := range servers {
+			server.Shutdown()
+		}
+	}
+}
+
+func TestPlainUpstreamRetriesOverTCP(t *testing.T) {
+	require := require.New(t)
+
+	stub, address, stop := newTruncatingStub(t)
+	defer stop()
+	u, err := newPlainUpstream(address, time.Second)
+	require.NoError(err)
+
+	// 100 records fit in 4096 bytes
+	q := query("example.com")
+	q.SetEdns0(4096, false)
+	response, err := u.Exchange(q)
+	require.NoError(err)
+	require.False(response.Truncated)
+	require.Len(response.Answer, 100)
+	require.Equal([]string{"udp/4096"}, stub.received())
+
+	// but not in 512 bytes so the query is retried over tcp
+	response, err = u.Exchange(query("example.com"))
+	require.NoError(err)
+	require.False(response.Truncated)
+	require.Len(response.Answer, 100)
+	require.Equal([]string{"udp/0", "tcp/0"}, stub.received())
+}
+
+func TestInvalidUpstreams(t *testing.T) {
+	require := require.New(t)
+
+	for _, upstream := range []string{"ftp://1.1.1.1", "https://", "tls:///"} {
+		_, err := newUpstreamPool([]string{upstream}, StrategyFailover, time.Second, nil)
+		require.Error(err, upstream)
+	}
+}
+
+func TestForwardRules(t *testing.T) {
+	require := require.New(t)
+
+	fallback, err := newUpstreamPool([]string{"1.1.1.1"}, StrategyFailover, time.Second, nil)
+	require.NoError(err)
+	r, err := newRouter(fallback, []ForwardRule{
+		{Domain: "corp.example.com", Upstream: []string{"10.0.0.53"}},
+		{Domain: "lab.corp.example.com.", Upstream: []string{"10.1.0.53"}},
+	}, StrategyFailover, time.Second, nil)
+	require.NoError(err)
+
+	routes := map[string]string{
+		"corp.example.com.":          "10.0.0.53:53",
+		"WWW.Corp.Example.com.":      "10.0.0.53:53",
+		"host.lab.corp.example.com.": "10.1.0.53:53",
+		"notcorp.example.com.":       "1.1.1.1:53",
+		"example.com.":               "1.1.1.1:53",
+		"corp.example.com.evil.com.": "1.1.1.1:53",
+	}
+	for name, upstream := range routes {
+		require.Equal(upstream, r.route(name).upstreams[0].name, name)
+	}
+
+	_, err = newRouter(fallback, []ForwardRule{{Domain: "corp.example.com"}}, StrategyFailover, time.Second, nil)
+	require.Error(err)
+}
@@ -0,0 +1,348 @@
+package dnsproxy
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// the TTL of records in a Zone. It's short
+// because devices can be added and removed at any time.
+const zoneTTL = 30
+
+// Zone answers authoritatively for the names in a DNS zone
+// and the reverse (PTR) names of their addresses.
+type Zone struct {
+	lock    sync.RWMutex
+	origin  string
+	serial  uint32
+	records map[string][]dns.RR
+	ptrs    map[string]dns.RR
+
+	// enabled devices by owner/name and the owner/names
+	// of the devices that map to each record name
+	devicesLock sync.Mutex
+	devices     map[string]*storage.Device
+	names       map[string]map[string]bool
+}
+
+func NewZone(origin string) *Zone {
+	return &Zone{
+		origin:  dns.CanonicalName(origin),
+		serial:  1,
+		records: map[string][]dns.RR{},
+		ptrs:    map[string]dns.RR{},
+		devices: map[string]*storage.Device{},
+		names:   map[string]map[string]bool{},
+	}
+}
+
+// Origin returns the fully qualified name of the zone.
+func (z *Zone) Origin() string {
+	return z.origin
+}
+
+// Set replaces the addresses of a name within the zone.
+// The name is relative to the zone's origin (e.g. "laptop.alice").
+func (z *Zone) Set(name string, ips ...net.IP) {
+	fqdn := z.fqdn(name)
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	z.remove(fqdn)
+	for _, ip := range ips {
+		var rr dns.RR
+		if ip4 := ip.To4(); ip4 != nil {
+			rr = &dns.A{Hdr: z.header(fqdn, dns.TypeA), A: ip4}
+		} else {
+			rr = &dns.AAAA{Hdr: z.header(fqdn, dns.TypeAAAA), AAAA: ip}
+		}
+		z.records[fqdn] = append(z.records[fqdn], rr)
+
+		reverse, err := dns.ReverseAddr(ip.String())
+		if err == nil {
+			z.ptrs[reverse] = &dns.PTR{Hdr: z.header(reverse, dns.TypePTR), Ptr: fqdn}
+		}
+	}
+	z.serial++
+}
+
+// Remove deletes a name from the zone.
+func (z *Zone) Remove(name string) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.remove(z.fqdn(name))
+	z.serial++
+}
+
+func (z *Zone) remove(fqdn string) {
+	for _, rr := range z.records[fqdn] {
+		var ip net.IP
+		switch rr := rr.(type) {
+		case *dns.A:
+			ip = rr.A
+		case *dns.AAAA:
+			ip = rr.AAAA
+		}
+		if reverse, err := dns.ReverseAddr(ip.String()); err == nil {
+			if ptr, ok := z.ptrs[reverse]; ok && ptr.(*dns.PTR).Ptr == fqdn {
+				delete(z.ptrs, reverse)
+			}
+		}
+	}
+	delete(z.records, fqdn)
+}
+
+// Lookup answers a query if it's for a name within the zone
+// or for the PTR record of an address in the zone.
+// The second return value is false if the query should be
+// sent upstream instead.
+func (z *Zone) Lookup(r *dns.Msg) (*dns.Msg, bool) {
+	if len(r.Question) == 0 {
+		return nil, false
+	}
+	q := r.Question[0]
+	name := dns.CanonicalName(q.Name)
+
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if q.Qtype == dns.TypePTR {
+		if ptr, ok := z.ptrs[name]; ok {
+			m.Answer = append(m.Answer, dns.Copy(ptr))
+			return m, true
+		}
+		// other reverse names are answered by the upstream
+		return nil, false
+	}
+
+	if !dns.IsSubDomain(z.origin, name) {
+		return nil, false
+	}
+
+	if name == z.origin && q.Qtype == dns.TypeSOA {
+		m.Answer = append(m.Answer, z.soa())
+		return m, true
+	}
+
+	for _, rr := range z.records[name] {
+		if rr.Header().Rrtype == q.Qtype || q.Qtype == dns.TypeANY {
+			m.Answer = append(m.Answer, dns.Copy(rr))
+		}
+	}
+	if len(m.Answer) > 0 {
+		return m, true
+	}
+
+	// the SOA record tells resolvers how long
+	// they can cache the negative answer
+	m.Ns = append(m.Ns, z.soa())
+	if !z.exists(name) {
+		m.Rcode = dns.RcodeNameError
+	}
+	return m, true
+}
+
+// exists reports if a name has records or has
+// children with records (an empty non-terminal).
+func (z *Zone) exists(name string) bool {
+	if name == z.origin {
+		return true
+	}
+	if _, ok := z.records[name]; ok {
+		return true
+	}
+	for fqdn := range z.records {
+		if strings.HasSuffix(fqdn, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *Zone) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     z.header(z.origin, dns.TypeSOA),
+		Ns:      "ns." + z.origin,
+		Mbox:    "hostmaster." + z.origin,
+		Serial:  z.serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  zoneTTL,
+	}
+}
+
+func (z *Zone) header(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: zoneTTL}
+}
+
+func (z *Zone) fqdn(name string) string {
+	return dns.CanonicalName(name + "." + z.origin)
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Label converts a string into a valid DNS label
+// i.e. "Alice's Laptop" becomes "alice-s-laptop".
+func Label(s string) string {
+	label := invalidLabelChars.ReplaceAllString(strings.ToLower(s), "-")
+	label = strings.Trim(label, "-")
+	if len(label) > 63 {
+		label = strings.Trim(label[:63], "-")
+	}
+	return label
+}
+
+// DeviceName returns the name of a device's records
+// relative to the zone i.e. "laptop.alice". The owner's
+// label is the local part of their email or their name
+// and falls back to their subject, which is often an
+// opaque id.
+func DeviceName(device *storage.Device) string {
+	name := Label(device.Name)
+	if owner := ownerLabel(device); owner != "" {
+		name = name + "." + owner
+	}
+	return name
+}
+
+func ownerLabel(device *storage.Device) string {
+	if email := strings.SplitN(device.OwnerEmail, "@", 2)[0]; Label(email) != "" {
+		return Label(email)
+	}
+	if Label(device.OwnerName) != "" {
+		return Label(device.OwnerName)
+	}
+	return Label(device.Owner)
+}
+
+// WatchDevices adds records for all devices in storage
+// and keeps them up to date as devices are added, removed
+// and disabled.
+func (z *Zone) WatchDevices(s storage.Storage) error {
+	s.OnAdd(z.addDevice)
+	s.OnDelete(z.removeDevice)
+	s.OnReconnect(func() {
+		if err := z.syncDevices(s); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to sync dns records after storage reconnect"))
+		}
+	})
+	return z.syncDevices(s)
+}
+
+func (z *Zone) syncDevices(s storage.Storage) error {
+	devices, err := s.List("")
+	if err != nil {
+		return errors.Wrap(err, "failed to list devices")
+	}
+	z.devicesLock.Lock()
+	names := z.names
+	z.devices = map[string]*storage.Device{}
+	z.names = map[string]map[string]bool{}
+	z.devicesLock.Unlock()
+	for name := range names {
+		z.update(name)
+	}
+	for _, device := range devices {
+		z.addDevice(device)
+	}
+	return nil
+}
+
+// addDevice is called when a device is saved. Disabled
+// devices don't have records.
+func (z *Zone) addDevice(device *storage.Device) {
+	if device.Disabled {
+		z.removeDevice(device)
+		return
+	}
+	key := device.Owner + "/" + device.Name
+	name := DeviceName(device)
+
+	z.devicesLock.Lock()
+	previous, ok := z.devices[key]
+	z.unindex(key, previous)
+	z.devices[key] = device
+	if z.names[name] == nil {
+		z.names[name] = map[string]bool{}
+	}
+	z.names[name][key] = true
+	z.devicesLock.Unlock()
+
+	if ok && DeviceName(previous) != name {
+		z.update(DeviceName(previous))
+	}
+	z.update(name)
+}
+
+func (z *Zone) removeDevice(device *storage.Device) {
+	key := device.Owner + "/" + device.Name
+
+	z.devicesLock.Lock()
+	previous, ok := z.devices[key]
+	z.unindex(key, previous)
+	delete(z.devices, key)
+	z.devicesLock.Unlock()
+
+	if ok {
+		z.update(DeviceName(previous))
+	}
+}
+
+// unindex removes a device from the index of
+// its name. devicesLock must be held.
+func (z *Zone) unindex(key string, device *storage.Device) {
+	if device == nil {
+		return
+	}
+	name := DeviceName(device)
+	delete(z.names[name], key)
+	if len(z.names[name]) == 0 {
+		delete(z.names, name)
+	}
+}
+
+// update sets the records of a name to the address of the
+// oldest device with that name. Device names like "My Laptop"
+// and "my-laptop" result in the same record.
+func (z *Zone) update(name string) {
+	if Label(strings.Split(name, ".")[0]) == "" {
+		return
+	}
+
+	z.devicesLock.Lock()
+	defer z.devicesLock.Unlock()
+
+	matches := []*storage.Device{}
+	for key := range z.names[name] {
+		matches = append(matches, z.devices[key])
+	}
+	if len(matches) == 0 {
+		z.Remove(name)
+		return
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+	})
+
+	ip, _, err := net.ParseCIDR(matches[0].Address)
+	if err != nil {
+		logrus.Warnf("device %s/%s has an invalid address '%s'", matches[0].Owner, matches[0].Name, matches[0].Address)
+		z.Remove(name)
+		return
+	}
+	z.Set(name, ip)
+}
@@ -0,0 +1,120 @@
+package dnsproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func zoneQuery(name string, qtype uint16) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	return m
+}
+
+func TestZoneDeviceRecords(t *testing.T) {
+	require := require.New(t)
+
+	s := storage.NewMemoryStorage()
+	require.NoError(s.Save(&storage.Device{Owner: "alice", Name: "Work Laptop", Address: "10.44.0.5/32"}))
+
+	zone := NewZone("vpn.internal")
+	require.NoError(zone.WatchDevices(s))
+
+	response, ok := zone.Lookup(zoneQuery("work-laptop.alice.vpn.internal", dns.TypeA))
+	require.True(ok)
+	require.True(response.Authoritative)
+	require.Len(response.Answer, 1)
+	require.Equal("10.44.0.5", response.Answer[0].(*dns.A).A.String())
+
+	response, ok = zone.Lookup(zoneQuery("5.0.44.10.in-addr.arpa", dns.TypePTR))
+	require.True(ok)
+	require.Equal("work-laptop.alice.vpn.internal.", response.Answer[0].(*dns.PTR).Ptr)
+
+	// names without records of the query type are NODATA
+	response, ok = zone.Lookup(zoneQuery("work-laptop.alice.vpn.internal", dns.TypeAAAA))
+	require.True(ok)
+	require.Equal(dns.RcodeSuccess, response.Rcode)
+	require.Empty(response.Answer)
+	require.Len(response.Ns, 1)
+
+	response, ok = zone.Lookup(zoneQuery("alice.vpn.internal", dns.TypeA))
+	require.True(ok)
+	require.Equal(dns.RcodeSuccess, response.Rcode)
+
+	// other names are answered by the upstream
+	_, ok = zone.Lookup(zoneQuery("example.com", dns.TypeA))
+	require.False(ok)
+	_, ok = zone.Lookup(zoneQuery("1.1.1.1.in-addr.arpa", dns.TypePTR))
+	require.False(ok)
+
+	device, err := s.Get("alice", "Work Laptop")
+	require.NoError(err)
+	require.NoError(s.Delete(device))
+
+	response, ok = zone.Lookup(zoneQuery("work-laptop.alice.vpn.internal", dns.TypeA))
+	require.True(ok)
+	require.Equal(dns.RcodeNameError, response.Rcode)
+	_, ok = zone.Lookup(zoneQuery("5.0.44.10.in-addr.arpa", dns.TypePTR))
+	require.False(ok)
+}
+
+func TestZoneDeviceNames(t *testing.T) {
+	require := require.New(t)
+
+	for expected, device := range map[string]*storage.Device{
+		"laptop.alice":       {Owner: "00u1abcd", OwnerName: "Alice Smith", OwnerEmail: "Alice@example.com", Name: "Laptop"},
+		"laptop.alice-smith": {Owner: "00u1abcd", OwnerName: "Alice Smith", Name: "Laptop"},
+		"laptop.00u1abcd":    {Owner: "00u1abcd", Name: "Laptop"},
+		"laptop":             {Owner: "!!!", Name: "Laptop"},
+	} {
+		require.Equal(expected, DeviceName(device))
+	}
+}
+
+func TestZoneDisabledAndDuplicateDevices(t *testing.T) {
+	require := require.New(t)
+
+	s := storage.NewMemoryStorage()
+	now := time.Now()
+	require.NoError(s.Save(&storage.Device{Owner: "alice", Name: "Laptop", Address: "10.44.0.5/32", CreatedAt: now}))
+	require.NoError(s.Save(&storage.Device{Owner: "alice", Name: "laptop!", Address: "10.44.0.6/32", CreatedAt: now.Add(time.Minute)}))
+
+	zone := NewZone("vpn.internal")
+	require.NoError(zone.WatchDevices(s))
+
+	address := func() string {
+		response, _ := zone.Lookup(zoneQuery("laptop.alice.vpn.internal", dns.TypeA))
+		if len(response.Answer) == 0 {
+			return ""
+		}
+		return response.Answer[0].(*dns.A).A.String()
+	}
+
+	// the oldest device with a name gets the record
+	require.Equal("10.44.0.5", address())
+
+	// disabled devices lose their records
+	device, err := s.Get("alice", "Laptop")
+	require.NoError(err)
+	disabled := *device
+	disabled.Disabled = true
+	require.NoError(s.Save(&disabled))
+	require.Equal("10.44.0.6", address())
+	_, ok := zone.Lookup(zoneQuery("5.0.44.10.in-addr.arpa", dns.TypePTR))
+	require.False(ok)
+
+	other, err := s.Get("alice", "laptop!")
+	require.NoError(err)
+	disabledOther := *other
+	disabledOther.Disabled = true
+	require.NoError(s.Save(&disabledOther))
+	require.Equal("", address())
+
+	// and get them back when they're enabled again
+	require.NoError(s.Save(device))
+	require.Equal("10.44.0.5", address())
+}
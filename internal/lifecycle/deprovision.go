@@ -0,0 +1,223 @@
+package lifecycle
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/audit"
+	"github.com/place1/wg-access-server/internal/devices"
+	"github.com/place1/wg-access-server/internal/storage"
+)
+
+const (
+	ActionDisable = "disable"
+	ActionDelete  = "delete"
+)
+
+// Deprovisioner revokes the access of users that were removed from
+// their identity provider and gives it back if they're reactivated.
+type Deprovisioner struct {
+	storage storage.Storage
+	devices devices.Profiles
+	audit   *audit.Log
+	action  string
+}
+
+// NewDeprovisioner creates a Deprovisioner. action is what happens
+// to the devices of deprovisioned users and defaults to ActionDisable.
+func NewDeprovisioner(s storage.Storage, profiles devices.Profiles, log *audit.Log, action string) (*Deprovisioner, error) {
+	if action == "" {
+		action = ActionDisable
+	}
+	if action != ActionDisable && action != ActionDelete {
+		return nil, fmt.Errorf("unknown lifecycle action '%s' - must be %s or %s", action, ActionDisable, ActionDelete)
+	}
+	return &Deprovisioner{
+		storage: s,
+		devices: profiles,
+		audit:   log,
+		action:  action,
+	}, nil
+}
+
+// Deprovision marks a user as deprovisioned, disables or deletes
+// their devices and revokes their api tokens and server-side sessions.
+// The admin service accounts that they created are revoked too.
+// actor and reason are recorded in the audit log.
+func (d *Deprovisioner) Deprovision(provider string, subject string, actor string, reason string) error {
+	user := &storage.User{Provider: provider, Subject: subject}
+	if stored, err := d.storage.GetUser(provider, subject); err == nil {
+		copy := *stored
+		user = &copy
+	}
+	if !user.Deprovisioned {
+		user.Deprovisioned = true
+		if err := d.storage.SaveUser(user); err != nil {
+			return errors.Wrap(err, "failed to save user")
+		}
+		d.audit.Record(audit.Event{
+			Action: "user.deprovisioned",
+			Actor:  actor,
+			User:   userName(provider, subject),
+			Reason: reason,
+		})
+	}
+
+	owned, err := d.storage.List(subject)
+	if err != nil {
+		return errors.Wrap(err, "failed to list devices")
+	}
+	for _, device := range owned {
+		if device.OwnerProvider != provider {
+			continue
+		}
+		if err := d.revokeDevice(device, actor); err != nil {
+			return errors.Wrapf(err, "failed to %s device %s", d.action, device.Name)
+		}
+	}
+
+	tokens, err := d.storage.ListAPITokens(subject)
+	if err != nil {
+		return errors.Wrap(err, "failed to list api tokens")
+	}
+	for _, token := range tokens {
+		if token.OwnerProvider != provider || token.ServiceAccount {
+			continue
+		}
+		if err := d.storage.DeleteAPIToken(token); err != nil {
+			return errors.Wrap(err, "failed to revoke api token")
+		}
+		d.audit.Record(audit.Event{
+			Action: "api-token.revoked",
+			Actor:  actor,
+			User:   userName(provider, subject),
+			Reason: fmt.Sprintf("token '%s' belonged to a deprovisioned user", token.Name),
+		})
+	}
+
+	// service accounts with the admin scope would otherwise
+	// keep the access of the admin that created them
+	all, err := d.storage.ListAPITokens("")
+	if err != nil {
+		return errors.Wrap(err, "failed to list api tokens")
+	}
+	for _, token := range all {
+		if !token.ServiceAccount || token.CreatedBy != subject || token.CreatedByProvider != provider || !hasAdminScope(token) {
+			continue
+		}
+		if err := d.storage.DeleteAPIToken(token); err != nil {
+			return errors.Wrap(err, "failed to revoke api token")
+		}
+		d.audit.Record(audit.Event{
+			Action: "api-token.revoked",
+			Actor:  actor,
+			User:   userName(provider, subject),
+			Reason: fmt.Sprintf("admin service account '%s' was created by a deprovisioned user", token.Name),
+		})
+	}
+
+	sessions, err := d.storage.ListSessions(subject)
+	if err != nil {
+		return errors.Wrap(err, "failed to list sessions")
+	}
+	for _, session := range sessions {
+		if session.Provider != provider {
+			continue
+		}
+		if err := d.storage.DeleteSession(session); err != nil {
+			return errors.Wrap(err, "failed to revoke session")
+		}
+	}
+	return nil
+}
+
+func (d *Deprovisioner) revokeDevice(device *storage.Device, actor string) error {
+	m, err := d.devices.Get(device.Profile)
+	if err != nil {
+		return err
+	}
+	event := audit.Event{
+		Actor:  actor,
+		User:   userName(device.OwnerProvider, device.Owner),
+		Device: device.Name,
+		Reason: "the device's owner was deprovisioned",
+	}
+	switch {
+	case d.action == ActionDelete:
+		if err := m.DeleteDevice(device.Owner, device.Name); err != nil {
+			return err
+		}
+		event.Action = "device.deleted"
+	case !device.Disabled:
+		if _, err := m.SetDisabled(device.Owner, device.Name, true); err != nil {
+			return err
+		}
+		event.Action = "device.disabled"
+	default:
+		return nil
+	}
+	d.audit.Record(event)
+	return nil
+}
+
+// Reactivate gives a deprovisioned user their disabled devices back.
+// Nothing happens if the user isn't deprovisioned.
+func (d *Deprovisioner) Reactivate(provider string, subject string, actor string, reason string) error {
+	stored, err := d.storage.GetUser(provider, subject)
+	if err != nil || !stored.Deprovisioned {
+		return nil
+	}
+	user := *stored
+	user.Deprovisioned = false
+	if err := d.storage.SaveUser(&user); err != nil {
+		return errors.Wrap(err, "failed to save user")
+	}
+	d.audit.Record(audit.Event{
+		Action: "user.reactivated",
+		Actor:  actor,
+		User:   userName(provider, subject),
+		Reason: reason,
+	})
+
+	owned, err := d.storage.List(subject)
+	if err != nil {
+		return errors.Wrap(err, "failed to list devices")
+	}
+	for _, device := range owned {
+		if device.OwnerProvider != provider || !device.Disabled {
+			continue
+		}
+		m, err := d.devices.Get(device.Profile)
+		if err != nil {
+			return err
+		}
+		if _, err := m.SetDisabled(device.Owner, device.Name, false); err != nil {
+			return err
+		}
+		d.audit.Record(audit.Event{
+			Action: "device.enabled",
+			Actor:  actor,
+			User:   userName(device.OwnerProvider, device.Owner),
+			Device: device.Name,
+			Reason: "the device's owner was reactivated",
+		})
+	}
+	return nil
+}
+
+func userName(provider string, subject string) string {
+	if provider == "" {
+		return subject
+	}
+	return provider + "/" + subject
+}
+
+func hasAdminScope(token *storage.APIToken) bool {
+	for _, scope := range apitokens.Scopes(token) {
+		if scope == apitokens.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/sirupsen/logrus"
+)
+
+// the actor of audit events
+const actor = "lifecycle"
+
+// the timeout for checking a single user
+const checkTimeout = 30 * time.Second
+
+// Checker checks if a user still exists with their identity
+// provider. It may update the user i.e. their refresh token.
+type Checker func(ctx context.Context, user *storage.User) (authruntime.UserStatus, error)
+
+type Opts struct {
+	Storage storage.Storage
+	// Checkers are keyed by the name of the provider
+	// of the users that they check
+	Checkers      map[string]Checker
+	Deprovisioner *Deprovisioner
+	// Interval defaults to 1h
+	Interval time.Duration
+}
+
+// Job periodically checks the owners of devices with
+// their identity provider and disables or deletes the
+// devices of users that have been deprovisioned.
+type Job struct {
+	opts Opts
+}
+
+func New(opts Opts) *Job {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	return &Job{opts}
+}
+
+// Start runs the job every interval
+func (j *Job) Start() {
+	go func() {
+		for {
+			if err := j.Run(context.Background()); err != nil {
+				logrus.Error(errors.Wrap(err, "lifecycle job failed"))
+			}
+			time.Sleep(j.opts.Interval)
+		}
+	}()
+}
+
+// Run checks the owners of all devices once
+func (j *Job) Run(ctx context.Context) error {
+	all, err := j.opts.Storage.List("")
+	if err != nil {
+		return errors.Wrap(err, "failed to list devices")
+	}
+
+	owners := map[[2]string]bool{}
+	for _, device := range all {
+		owners[[2]string{device.OwnerProvider, device.Owner}] = true
+	}
+
+	for key := range owners {
+		checker, ok := j.opts.Checkers[key[0]]
+		if !ok {
+			// i.e. service accounts
+			continue
+		}
+		if err := j.checkOwner(ctx, checker, key[0], key[1]); err != nil {
+			logrus.Warn(errors.Wrapf(err, "failed to check user %s/%s", key[0], key[1]))
+		}
+	}
+	return nil
+}
+
+func (j *Job) checkOwner(ctx context.Context, checker Checker, provider string, subject string) error {
+	user := &storage.User{Provider: provider, Subject: subject}
+	if stored, err := j.opts.Storage.GetUser(provider, subject); err == nil {
+		// a copy to compare with the stored user later
+		copy := *stored
+		user = &copy
+	}
+	before := *user
+
+	if user.Deprovisioned {
+		// the user isn't checked again but their access
+		// is revoked again in case it failed last time
+		return j.opts.Deprovisioner.Deprovision(provider, subject, actor, "the user was deprovisioned")
+	}
+
+	// users that are signing in are active
+	if time.Since(user.LastSeen) < j.opts.Interval {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	status, err := checker(ctx, user)
+	cancel()
+
+	now := time.Now()
+	user.CheckedAt = &now
+	if status == authruntime.UserDeprovisioned && err == nil {
+		// another replica or a sign in may have used
+		// the user's refresh token while it was checked
+		if latest, getErr := j.opts.Storage.GetUser(provider, subject); getErr == nil && changed(&before, latest) {
+			return nil
+		}
+	}
+	if saveErr := j.opts.Storage.SaveUser(user); saveErr != nil {
+		return errors.Wrap(saveErr, "failed to save user")
+	}
+	if err != nil || status != authruntime.UserDeprovisioned {
+		return err
+	}
+	return j.opts.Deprovisioner.Deprovision(provider, subject, actor, "the identity provider reported that the user was removed or disabled")
+}
+
+// changed reports if a user was seen or checked
+// since they were read from storage
+func changed(before *storage.User, after *storage.User) bool {
+	if !before.LastSeen.Equal(after.LastSeen) || !bytes.Equal(before.RefreshToken, after.RefreshToken) {
+		return true
+	}
+	if before.CheckedAt == nil || after.CheckedAt == nil {
+		return before.CheckedAt != after.CheckedAt
+	}
+	return !before.CheckedAt.Equal(*after.CheckedAt)
+}
+
+// ProviderCheckers creates the Checkers of the authentication
+// providers that can check users. Refresh tokens are decrypted
+// with box and users can't be checked with a refresh token if
+// it's nil. Providers with the same name can't tell which
+// users are theirs so they're skipped.
+func ProviderCheckers(providers []*authruntime.Provider, box *secrets.Box) map[string]Checker {
+	checkers := map[string]Checker{}
+	names := map[string]int{}
+	for _, p := range providers {
+		names[p.Name]++
+	}
+	for _, p := range providers {
+		if p.CheckUser == nil {
+			continue
+		}
+		if names[p.Name] > 1 {
+			logrus.Warnf("more than one authentication provider is named '%s' - their users won't be checked by the lifecycle job", p.Name)
+			continue
+		}
+		checkers[p.Name] = providerChecker(p, box)
+	}
+	return checkers
+}
+
+func providerChecker(p *authruntime.Provider, box *secrets.Box) Checker {
+	return func(ctx context.Context, user *storage.User) (authruntime.UserStatus, error) {
+		var refresh *authsession.Refresh
+		if box != nil && len(user.RefreshToken) > 0 {
+			token, err := box.Open(user.RefreshToken, userName(user.Provider, user.Subject))
+			if err != nil {
+				return authruntime.UserUnknown, errors.Wrap(err, "failed to decrypt refresh token")
+			}
+			refresh = &authsession.Refresh{Provider: p.Type, Token: string(token)}
+		}
+
+		status, next, err := p.CheckUser(ctx, user.Subject, refresh)
+		if next != nil && box != nil && (refresh == nil || next.Token != refresh.Token) {
+			sealed, sealErr := box.Seal([]byte(next.Token), userName(user.Provider, user.Subject))
+			if sealErr != nil {
+				return authruntime.UserUnknown, errors.Wrap(sealErr, "failed to encrypt refresh token")
+			}
+			user.RefreshToken = sealed
+		}
+		return status, err
+	}
+}
@@ -0,0 +1,216 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/place1/wg-access-server/internal/audit"
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/internal/devices"
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-embed/pkg/wgembed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob(t *testing.T) {
+	require := require.New(t)
+
+	s, profiles := testDevices(t,
+		&storage.Device{OwnerProvider: "ldap", Owner: "alice", Name: "laptop"},
+		&storage.Device{OwnerProvider: "ldap", Owner: "alice", Name: "phone"},
+		&storage.Device{OwnerProvider: "ldap", Owner: "bob", Name: "laptop"},
+		&storage.Device{OwnerProvider: "ldap", Owner: "carol", Name: "laptop"},
+		&storage.Device{OwnerProvider: "ldap", Owner: "dave", Name: "laptop"},
+		&storage.Device{OwnerProvider: "ldap", Owner: "erin", Name: "laptop"},
+		&storage.Device{OwnerProvider: "apitoken", Owner: "ci", Name: "runner"},
+	)
+	// erin signed in recently
+	require.NoError(s.SaveUser(&storage.User{Provider: "ldap", Subject: "erin", LastSeen: time.Now()}))
+	require.NoError(s.SaveAPIToken(&storage.APIToken{ID: "1", Hash: "1", OwnerProvider: "ldap", Owner: "alice"}))
+	require.NoError(s.SaveAPIToken(&storage.APIToken{ID: "2", Hash: "2", OwnerProvider: "ldap", Owner: "bob"}))
+	// service accounts created by alice and bob
+	require.NoError(s.SaveAPIToken(&storage.APIToken{ID: "3", Hash: "3", ServiceAccount: true, Owner: "service-account:3", Scopes: "admin", CreatedByProvider: "ldap", CreatedBy: "alice"}))
+	require.NoError(s.SaveAPIToken(&storage.APIToken{ID: "4", Hash: "4", ServiceAccount: true, Owner: "service-account:4", Scopes: "scim", CreatedByProvider: "ldap", CreatedBy: "alice"}))
+	require.NoError(s.SaveAPIToken(&storage.APIToken{ID: "5", Hash: "5", ServiceAccount: true, Owner: "service-account:5", Scopes: "admin", CreatedByProvider: "ldap", CreatedBy: "bob"}))
+	require.NoError(s.SaveSession(&storage.Session{ID: "1", Provider: "ldap", Owner: "alice", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	deprovisioner, err := NewDeprovisioner(s, profiles, testAudit(t), ActionDisable)
+	require.NoError(err)
+	checked := map[string]bool{}
+	job := New(Opts{
+		Storage:       s,
+		Deprovisioner: deprovisioner,
+		Checkers: map[string]Checker{
+			"ldap": func(ctx context.Context, user *storage.User) (authruntime.UserStatus, error) {
+				checked[user.Subject] = true
+				switch user.Subject {
+				case "alice", "erin":
+					return authruntime.UserDeprovisioned, nil
+				case "bob":
+					return authruntime.UserActive, nil
+				case "carol":
+					return authruntime.UserUnknown, nil
+				}
+				return authruntime.UserDeprovisioned, errors.New("ldap server is down")
+			},
+		},
+	})
+	require.NoError(job.Run(context.Background()))
+
+	require.True(checked["alice"])
+	require.False(checked["erin"], "recently seen users aren't checked")
+
+	require.True(device(t, s, "alice", "laptop").Disabled)
+	require.True(device(t, s, "alice", "phone").Disabled)
+	for _, d := range [][2]string{{"bob", "laptop"}, {"carol", "laptop"}, {"dave", "laptop"}, {"erin", "laptop"}, {"ci", "runner"}} {
+		require.False(device(t, s, d[0], d[1]).Disabled, d[0])
+	}
+
+	user, err := s.GetUser("ldap", "alice")
+	require.NoError(err)
+	require.True(user.Deprovisioned)
+	require.NotNil(user.CheckedAt)
+	tokens, err := s.ListAPITokens("")
+	require.NoError(err)
+	ids := []string{}
+	for _, token := range tokens {
+		ids = append(ids, token.ID)
+	}
+	require.ElementsMatch([]string{"2", "4", "5"}, ids, "the api tokens and admin service accounts of deprovisioned users are revoked")
+	sessions, err := s.ListSessions("alice")
+	require.NoError(err)
+	require.Empty(sessions)
+	user, err = s.GetUser("ldap", "dave")
+	require.NoError(err)
+	require.False(user.Deprovisioned, "errors don't deprovision users")
+
+	// deprovisioned users aren't checked again
+	checked = map[string]bool{}
+	require.NoError(job.Run(context.Background()))
+	require.False(checked["alice"])
+
+	// and can't add devices
+	alice := &authsession.Identity{Provider: "ldap", Subject: "alice", Name: "Alice"}
+	_, err = profiles[""].AddDevice(alice, "tablet", "alice-tablet", 0)
+	require.Equal(devices.ErrUserDeprovisioned, err)
+
+	// the devices come back if the user can sign in again
+	recorder := NewRecorder(s, deprovisioner, nil)
+	require.NoError(recorder.RecordUser(&authsession.AuthSession{Identity: alice}))
+	require.False(device(t, s, "alice", "laptop").Disabled)
+	require.False(device(t, s, "alice", "phone").Disabled)
+	user, err = s.GetUser("ldap", "alice")
+	require.NoError(err)
+	require.False(user.Deprovisioned)
+	require.Equal("Alice", user.Name)
+}
+
+func TestJobDelete(t *testing.T) {
+	require := require.New(t)
+
+	s, profiles := testDevices(t,
+		&storage.Device{OwnerProvider: "basic", Owner: "alice", Name: "laptop"},
+		&storage.Device{OwnerProvider: "basic", Owner: "bob", Name: "laptop"},
+	)
+	deprovisioner, err := NewDeprovisioner(s, profiles, testAudit(t), ActionDelete)
+	require.NoError(err)
+	job := New(Opts{
+		Storage:       s,
+		Deprovisioner: deprovisioner,
+		Checkers: map[string]Checker{
+			"basic": func(ctx context.Context, user *storage.User) (authruntime.UserStatus, error) {
+				if user.Subject == "alice" {
+					return authruntime.UserDeprovisioned, nil
+				}
+				return authruntime.UserActive, nil
+			},
+		},
+	})
+	require.NoError(job.Run(context.Background()))
+
+	_, err = s.Get("alice", "laptop")
+	require.Error(err)
+	_, err = s.Get("bob", "laptop")
+	require.NoError(err)
+
+	_, err = NewDeprovisioner(s, profiles, nil, "explode")
+	require.Error(err)
+}
+
+func TestProviderCheckers(t *testing.T) {
+	require := require.New(t)
+
+	box, err := secrets.New("secret")
+	require.NoError(err)
+
+	var got *authsession.Refresh
+	checkUser := func(ctx context.Context, subject string, refresh *authsession.Refresh) (authruntime.UserStatus, *authsession.Refresh, error) {
+		got = refresh
+		return authruntime.UserActive, &authsession.Refresh{Provider: "OIDC", Token: "rotated"}, nil
+	}
+	checkers := ProviderCheckers([]*authruntime.Provider{
+		{Type: "OIDC", Name: "idp", CheckUser: checkUser},
+		{Type: "OIDC", Name: "", CheckUser: checkUser},
+		{Type: "Gitlab", Name: "", CheckUser: checkUser},
+		{Type: "Basic", Name: "basic"},
+	}, box)
+	require.Len(checkers, 1, "providers with the same name or without a check are skipped")
+
+	// the user's refresh token is decrypted and
+	// replaced by the rotated token
+	recorder := NewRecorder(storage.NewMemoryStorage(), nil, box)
+	s := recorder.storage
+	require.NoError(recorder.RecordUser(&authsession.AuthSession{
+		Identity: &authsession.Identity{Provider: "idp", Subject: "alice"},
+		Refresh:  &authsession.Refresh{Provider: "OIDC", Token: "original"},
+	}))
+	user, err := s.GetUser("idp", "alice")
+	require.NoError(err)
+	require.NotContains(string(user.RefreshToken), "original")
+
+	status, err := checkers["idp"](context.Background(), user)
+	require.NoError(err)
+	require.Equal(authruntime.UserActive, status)
+	require.Equal("original", got.Token)
+	token, err := box.Open(user.RefreshToken, "idp/alice")
+	require.NoError(err)
+	require.Equal("rotated", string(token))
+
+	// sessions are refreshed with the rotated token
+	require.NoError(s.SaveUser(user))
+	latest, err := recorder.RefreshToken("idp", "alice")
+	require.NoError(err)
+	require.Equal("rotated", latest)
+	latest, err = recorder.RefreshToken("idp", "bob")
+	require.NoError(err)
+	require.Empty(latest)
+}
+
+// testDevices saves devices in the default profile. Their public
+// key is "<owner>-<name>" so that they're unique.
+func testDevices(t *testing.T, list ...*storage.Device) (storage.Storage, devices.Profiles) {
+	s := storage.NewMemoryStorage()
+	for _, d := range list {
+		d.PublicKey = d.Owner + "-" + d.Name
+		require.NoError(t, s.Save(d))
+	}
+	profiles := devices.Profiles{"": devices.New(wgembed.NewNoOpInterface(), s, config.Profile{})}
+	return s, profiles
+}
+
+func device(t *testing.T, s storage.Storage, owner string, name string) *storage.Device {
+	d, err := s.Get(owner, name)
+	require.NoError(t, err)
+	return d
+}
+
+func testAudit(t *testing.T) *audit.Log {
+	log, err := audit.New("")
+	require.NoError(t, err)
+	return log
+}
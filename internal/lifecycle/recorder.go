@@ -0,0 +1,87 @@
+package lifecycle
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// Recorder saves the users that sign in so that the Job
+// can check them later. It implements authsession.UserRecorder.
+type Recorder struct {
+	storage       storage.Storage
+	deprovisioner *Deprovisioner
+	box           *secrets.Box
+	reactivate    func(identity *authsession.Identity) bool
+}
+
+// NewRecorder creates a Recorder. Refresh tokens are
+// encrypted with box and aren't saved if it's nil.
+func NewRecorder(s storage.Storage, deprovisioner *Deprovisioner, box *secrets.Box) *Recorder {
+	return &Recorder{
+		storage:       s,
+		deprovisioner: deprovisioner,
+		box:           box,
+	}
+}
+
+// SetReactivationCheck sets a check for deprovisioned users
+// that sign in again. They only get their access back if it
+// returns true. By default they always do.
+func (r *Recorder) SetReactivationCheck(check func(identity *authsession.Identity) bool) {
+	r.reactivate = check
+}
+
+// RecordUser saves a user that signed in or refreshed their
+// session. Users that were deprovisioned but can sign in
+// again get their disabled devices back.
+func (r *Recorder) RecordUser(s *authsession.AuthSession) error {
+	identity := s.Identity
+	user := &storage.User{Provider: identity.Provider, Subject: identity.Subject}
+	if stored, err := r.storage.GetUser(identity.Provider, identity.Subject); err == nil {
+		copy := *stored
+		user = &copy
+	}
+
+	user.Name = identity.Name
+	user.Email = identity.Email
+	user.EmailVerified = identity.EmailVerified
+	user.LastSeen = time.Now()
+	if s.Refresh != nil && r.box != nil {
+		sealed, err := r.box.Seal([]byte(s.Refresh.Token), userName(user.Provider, user.Subject))
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt refresh token")
+		}
+		user.RefreshToken = sealed
+	}
+
+	if err := r.storage.SaveUser(user); err != nil {
+		return errors.Wrap(err, "failed to save user")
+	}
+
+	if user.Deprovisioned && (r.reactivate == nil || r.reactivate(identity)) {
+		return r.deprovisioner.Reactivate(user.Provider, user.Subject, actor, "the user signed in again")
+	}
+	return nil
+}
+
+// RefreshToken returns the user's latest refresh token. It's empty
+// if the user has none or refresh tokens aren't saved.
+func (r *Recorder) RefreshToken(provider string, subject string) (string, error) {
+	if r.box == nil {
+		return "", nil
+	}
+	user, err := r.storage.GetUser(provider, subject)
+	if err != nil || len(user.RefreshToken) == 0 {
+		// the user hasn't been recorded yet
+		return "", nil
+	}
+	token, err := r.box.Open(user.RefreshToken, userName(provider, subject))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt refresh token")
+	}
+	return string(token), nil
+}
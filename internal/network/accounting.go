@@ -0,0 +1,171 @@
+package network
+
+import (
+	"net"
+	"sort"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/pkg/errors"
+)
+
+// TrafficCounter is the number of bytes a device has
+// exchanged with a destination network since the
+// accounting rules were created.
+type TrafficCounter struct {
+	Address       string
+	Destination   string
+	ReceiveBytes  uint64
+	TransmitBytes uint64
+}
+
+// ConfigureAccounting creates the chain that counts
+// device traffic per destination network for the VPN
+// profile with the given chain prefix.
+// It must be called after ConfigureForwarding.
+func ConfigureAccounting(chain string) error {
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	ipt.ClearChain("filter", chain+"_ACCOUNTING")
+	ipt.NewChain("filter", chain+"_ACCOUNTING")
+
+	// every forwarded packet passes through the accounting
+	// chain before any accept/reject rules are evaluated
+	if ok, _ := ipt.Exists("filter", chain+"_FORWARD", "-j", chain+"_ACCOUNTING"); !ok {
+		if err := ipt.Insert("filter", chain+"_FORWARD", 1, "-j", chain+"_ACCOUNTING"); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+	}
+
+	return nil
+}
+
+// SetDeviceAccounting adds counting rules for traffic between
+// a device and each destination network.
+// Traffic is only counted against the most specific
+// destination network that it matches.
+func SetDeviceAccounting(chain string, address string, destinations []string) error {
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	for _, dest := range sortBySpecificity(destinations) {
+		if err := ipt.AppendUnique("filter", chain+"_ACCOUNTING", "-s", address, "-d", dest, "-j", "RETURN"); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+		if err := ipt.AppendUnique("filter", chain+"_ACCOUNTING", "-s", dest, "-d", address, "-j", "RETURN"); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+	}
+
+	return nil
+}
+
+// RemoveDeviceAccounting removes the counting rules for a device.
+func RemoveDeviceAccounting(chain string, address string, destinations []string) error {
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	for _, dest := range destinations {
+		for _, rule := range [][]string{
+			{"-s", address, "-d", dest, "-j", "RETURN"},
+			{"-s", dest, "-d", address, "-j", "RETURN"},
+		} {
+			if ok, _ := ipt.Exists("filter", chain+"_ACCOUNTING", rule...); ok {
+				if err := ipt.Delete("filter", chain+"_ACCOUNTING", rule...); err != nil {
+					return errors.Wrap(err, "failed to remove ip tables rule")
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadAccounting returns the current value of all traffic counters.
+func ReadAccounting(chain string, destinations []string) ([]TrafficCounter, error) {
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init iptables")
+	}
+
+	stats, err := ipt.StructuredStats("filter", chain+"_ACCOUNTING")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ip tables counters")
+	}
+
+	return countTraffic(stats, destinations), nil
+}
+
+// countTraffic sums the byte counters of accounting rules by device
+// address and destination. Rules from a device to a destination
+// count transmitted bytes and rules in the other direction count
+// received bytes.
+func countTraffic(stats []iptables.Stat, destinations []string) []TrafficCounter {
+	counters := map[[2]string]*TrafficCounter{}
+	counter := func(address string, dest string) *TrafficCounter {
+		k := [2]string{address, dest}
+		if _, ok := counters[k]; !ok {
+			counters[k] = &TrafficCounter{
+				Address:     address,
+				Destination: dest,
+			}
+		}
+		return counters[k]
+	}
+
+	for _, stat := range stats {
+		if stat.Source == nil || stat.Destination == nil {
+			continue
+		}
+		src, dst := stat.Source.String(), stat.Destination.String()
+		if isHost(stat.Source) && containsCIDR(destinations, dst) {
+			counter(stat.Source.IP.String(), dst).TransmitBytes += stat.Bytes
+		} else if isHost(stat.Destination) && containsCIDR(destinations, src) {
+			counter(stat.Destination.IP.String(), src).ReceiveBytes += stat.Bytes
+		}
+	}
+
+	result := []TrafficCounter{}
+	for _, c := range counters {
+		result = append(result, *c)
+	}
+	return result
+}
+
+func sortBySpecificity(cidrs []string) []string {
+	sorted := make([]string, len(cidrs))
+	copy(sorted, cidrs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return prefixLength(sorted[i]) > prefixLength(sorted[j])
+	})
+	return sorted
+}
+
+func prefixLength(cidr string) int {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0
+	}
+	ones, _ := ipnet.Mask.Size()
+	return ones
+}
+
+func isHost(ipnet *net.IPNet) bool {
+	ones, bits := ipnet.Mask.Size()
+	return ones == bits
+}
+
+func containsCIDR(cidrs []string, target string) bool {
+	for _, cidr := range cidrs {
+		if _, ipnet, err := net.ParseCIDR(cidr); err == nil && ipnet.String() == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package network
+
+import (
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortBySpecificity(t *testing.T) {
+	require := require.New(t)
+
+	cidrs := []string{"0.0.0.0/0", "10.0.0.0/8", "invalid", "10.1.0.0/16", "192.168.0.0/16"}
+	require.Equal([]string{"10.1.0.0/16", "192.168.0.0/16", "10.0.0.0/8", "0.0.0.0/0", "invalid"}, sortBySpecificity(cidrs))
+	require.Equal("0.0.0.0/0", cidrs[0], "the input isn't modified")
+}
+
+func TestCountTraffic(t *testing.T) {
+	require := require.New(t)
+
+	cidr := func(s string) *net.IPNet {
+		_, ipnet, err := net.ParseCIDR(s)
+		require.NoError(err)
+		return ipnet
+	}
+	stats := []iptables.Stat{
+		{Source: cidr("10.44.0.2/32"), Destination: cidr("10.0.0.0/8"), Bytes: 100},
+		{Source: cidr("10.0.0.0/8"), Destination: cidr("10.44.0.2/32"), Bytes: 1000},
+		{Source: cidr("10.44.0.2/32"), Destination: cidr("0.0.0.0/0"), Bytes: 5},
+		{Source: cidr("0.0.0.0/0"), Destination: cidr("10.44.0.3/32"), Bytes: 50},
+		// rules that aren't accounting rules are ignored
+		{Source: cidr("10.44.0.2/32"), Destination: cidr("172.16.0.0/12"), Bytes: 7},
+		{Source: cidr("10.44.0.0/24"), Destination: cidr("10.0.0.0/8"), Bytes: 9},
+		{Source: nil, Destination: cidr("10.0.0.0/8"), Bytes: 11},
+	}
+
+	counters := countTraffic(stats, []string{"0.0.0.0/0", "10.0.0.0/8"})
+	sort.Slice(counters, func(i, j int) bool {
+		return counters[i].Address+counters[i].Destination < counters[j].Address+counters[j].Destination
+	})
+	require.Equal([]TrafficCounter{
+		{Address: "10.44.0.2", Destination: "0.0.0.0/0", TransmitBytes: 5},
+		{Address: "10.44.0.2", Destination: "10.0.0.0/8", ReceiveBytes: 1000, TransmitBytes: 100},
+		{Address: "10.44.0.3", Destination: "0.0.0.0/0", ReceiveBytes: 50},
+	}, counters)
+}
@@ -3,9 +3,11 @@ package network
 import (
 	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
 )
 
 func ServerVPNIP(cidr string) *net.IPNet {
@@ -14,7 +16,11 @@ func ServerVPNIP(cidr string) *net.IPNet {
 	return vpnsubnet
 }
 
-func ConfigureForwarding(wgIface string, gatewayIface string, cidr string, allowedIPs []string) error {
+// ConfigureForwarding sets up the iptables rules that forward
+// traffic from a VPN network. All rules are kept in chains that
+// start with the given prefix so that each VPN profile's rules
+// are independent of the others.
+func ConfigureForwarding(chain string, wgIface string, gatewayIface string, cidr string, allowedIPs []string) error {
 	// Networking configuration (iptables) configuration
 	// to ensure that traffic from clients the wireguard interface
 	// is sent to the provided network interface
@@ -23,39 +29,139 @@ func ConfigureForwarding(wgIface string, gatewayIface string, cidr string, allow
 		return errors.Wrap(err, "failed to init iptables")
 	}
 
+	forward := chain + "_FORWARD"
+	postrouting := chain + "_POSTROUTING"
+
 	// Cleanup our chains first so that we don't leak
 	// iptable rules when the network configuration changes.
-	ipt.ClearChain("filter", "WG_ACCESS_SERVER_FORWARD")
-	ipt.ClearChain("nat", "WG_ACCESS_SERVER_POSTROUTING")
+	ipt.ClearChain("filter", forward)
+	ipt.ClearChain("nat", postrouting)
 
 	// Create our own chain for forwarding rules
-	ipt.NewChain("filter", "WG_ACCESS_SERVER_FORWARD")
-	ipt.AppendUnique("filter", "FORWARD", "-j", "WG_ACCESS_SERVER_FORWARD")
+	ipt.NewChain("filter", forward)
+	ipt.AppendUnique("filter", "FORWARD", "-j", forward)
 
 	// Create our own chain for postrouting rules
-	ipt.NewChain("nat", "WG_ACCESS_SERVER_POSTROUTING")
-	ipt.AppendUnique("nat", "POSTROUTING", "-j", "WG_ACCESS_SERVER_POSTROUTING")
+	ipt.NewChain("nat", postrouting)
+	ipt.AppendUnique("nat", "POSTROUTING", "-j", postrouting)
 
 	// Accept client traffic for given allowed ips
 	for _, allowedCIDR := range allowedIPs {
-		if err := ipt.AppendUnique("filter", "WG_ACCESS_SERVER_FORWARD", "-s", cidr, "-d", allowedCIDR, "-j", "ACCEPT"); err != nil {
+		if err := ipt.AppendUnique("filter", forward, "-s", cidr, "-d", allowedCIDR, "-j", "ACCEPT"); err != nil {
 			return errors.Wrap(err, "failed to set ip tables rule")
 		}
 	}
 
 	if gatewayIface != "" {
-		if err := ipt.AppendUnique("nat", "WG_ACCESS_SERVER_POSTROUTING", "-s", cidr, "-o", gatewayIface, "-j", "MASQUERADE"); err != nil {
+		if err := ipt.AppendUnique("nat", postrouting, "-s", cidr, "-o", gatewayIface, "-j", "MASQUERADE"); err != nil {
 			return errors.Wrap(err, "failed to set ip tables rule")
 		}
 	}
 
-	if err := ipt.AppendUnique("filter", "WG_ACCESS_SERVER_FORWARD", "-s", cidr, "-j", "REJECT"); err != nil {
+	if err := ipt.AppendUnique("filter", forward, "-s", cidr, "-j", "REJECT"); err != nil {
 		return errors.Wrap(err, "failed to set ip tables rule")
 	}
 
 	return nil
 }
 
+// PortForward is a DNAT rule that sends traffic arriving
+// on a public port to a port on a VPN device's address.
+type PortForward struct {
+	Protocol   string
+	PublicPort int
+	Address    string
+	DevicePort int
+}
+
+func ConfigurePortForwarding(chain string, wgIface string, gatewayIface string, rules []PortForward) error {
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	prerouting := chain + "_PREROUTING"
+	inbound := chain + "_INBOUND"
+
+	// Cleanup our chains first so that rules for
+	// removed port forwards don't hang around.
+	ipt.ClearChain("nat", prerouting)
+	ipt.ClearChain("nat", inbound)
+	ipt.ClearChain("filter", inbound)
+
+	// Create our own chain for the DNAT rules
+	ipt.NewChain("nat", prerouting)
+	ipt.AppendUnique("nat", "PREROUTING", "-j", prerouting)
+
+	// Inbound traffic is masqueraded so that devices reply
+	// via the VPN even if they only route some networks
+	// through it.
+	ipt.NewChain("nat", inbound)
+	ipt.AppendUnique("nat", "POSTROUTING", "-j", inbound)
+
+	// The inbound chain must be evaluated before the
+	// forwarding chain because the forwarding chain
+	// rejects anything it doesn't explicitly allow.
+	ipt.NewChain("filter", inbound)
+	if ok, _ := ipt.Exists("filter", "FORWARD", "-j", inbound); !ok {
+		if err := ipt.Insert("filter", "FORWARD", 1, "-j", inbound); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+	}
+
+	// Only traffic from outside of the VPN is forwarded. Without
+	// a gateway interface that's anything that isn't from wgIface.
+	in := []string{"-i", gatewayIface}
+	out := []string{"-o", gatewayIface}
+	if gatewayIface == "" {
+		in = []string{"!", "-i", wgIface}
+		out = []string{"!", "-o", wgIface}
+	}
+
+	for _, rule := range rules {
+		publicPort := strconv.Itoa(rule.PublicPort)
+		devicePort := strconv.Itoa(rule.DevicePort)
+
+		// only traffic to one of the server's own addresses is
+		// forwarded, not traffic that's routed through the server
+		dnat := append(in, "-p", rule.Protocol, "-m", "addrtype", "--dst-type", "LOCAL", "--dport", publicPort, "-j", "DNAT", "--to-destination", net.JoinHostPort(rule.Address, devicePort))
+		if err := ipt.AppendUnique("nat", prerouting, dnat...); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+		if err := ipt.AppendUnique("nat", inbound, "-o", wgIface, "-p", rule.Protocol, "-d", rule.Address, "--dport", devicePort, "-j", "MASQUERADE"); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+		// accept new connections that were DNAT'd by the rule above
+		accept := append(append(in, "-o", wgIface), "-p", rule.Protocol, "-d", rule.Address, "--dport", devicePort, "-m", "conntrack", "--ctstate", "DNAT", "-j", "ACCEPT")
+		if err := ipt.AppendUnique("filter", inbound, accept...); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+		// and the device's replies to them
+		reply := append(append([]string{"-i", wgIface}, out...), "-p", rule.Protocol, "-s", rule.Address, "--sport", devicePort, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT")
+		if err := ipt.AppendUnique("filter", inbound, reply...); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+	}
+
+	return nil
+}
+
+// ConfigureMTU sets the MTU of a network interface.
+// An MTU of 0 leaves the interface's current MTU in place.
+func ConfigureMTU(iface string, mtu int) error {
+	if mtu == 0 {
+		return nil
+	}
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return errors.Wrap(err, "failed to find interface")
+	}
+	if err := netlink.LinkSetMTU(link, mtu); err != nil {
+		return errors.Wrapf(err, "failed to set mtu for interface %s", iface)
+	}
+	return nil
+}
+
 func MustParseCIDR(cidr string) (net.IP, *net.IPNet) {
 	ip, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
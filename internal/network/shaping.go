@@ -0,0 +1,269 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// the tc handle major number used for all of
+// wg-access-server's qdiscs, classes and filters
+const shapingHandle = 0xa5
+
+// the upper 16 bits of the packet mark are used to
+// classify device traffic. the lower 16 bits are left
+// untouched for other software on the host.
+const shapingMarkMask = 0xffff0000
+
+// BandwidthLimit caps the throughput of a single VPN device.
+// Rates are in bits per second and a rate of 0 means unlimited.
+type BandwidthLimit struct {
+	Address string
+	Ingress uint64
+	Egress  uint64
+}
+
+// tc minor numbers are allocated to devices as their limits
+// are applied. minor numbers are shared between all interfaces
+// because the gateway interface is shared by all VPN profiles.
+var minors = struct {
+	sync.Mutex
+	byAddress map[string]uint16
+}{byAddress: map[string]uint16{}}
+
+// ConfigureShaping resets the traffic shaping qdiscs
+// on the given wireguard and gateway interfaces.
+// Traffic that doesn't belong to a rate limited device
+// is sent without any shaping.
+// It must only be called once with all interfaces.
+func ConfigureShaping(ifaces []string) error {
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	ipt.ClearChain("mangle", "WG_ACCESS_SERVER_SHAPING")
+	ipt.NewChain("mangle", "WG_ACCESS_SERVER_SHAPING")
+	if err := ipt.AppendUnique("mangle", "FORWARD", "-j", "WG_ACCESS_SERVER_SHAPING"); err != nil {
+		return errors.Wrap(err, "failed to set ip tables rule")
+	}
+
+	configured := map[string]bool{}
+	for _, iface := range ifaces {
+		if iface == "" || configured[iface] {
+			continue
+		}
+		if err := resetRootQdisc(iface); err != nil {
+			return errors.Wrapf(err, "failed to configure traffic shaping on %s", iface)
+		}
+		configured[iface] = true
+	}
+
+	minors.Lock()
+	defer minors.Unlock()
+	minors.byAddress = map[string]uint16{}
+
+	return nil
+}
+
+// SetBandwidthLimit creates or updates the tc classes
+// and filters that limit a device's throughput.
+func SetBandwidthLimit(wgIface string, gatewayIface string, limit BandwidthLimit) error {
+	if limit.Ingress == 0 && limit.Egress == 0 {
+		return RemoveBandwidthLimit(wgIface, gatewayIface, limit.Address)
+	}
+
+	minor, err := allocateMinor(limit.Address)
+	if err != nil {
+		return err
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	mark := fmt.Sprintf("0x%x/0x%x", uint32(minor)<<16, uint32(shapingMarkMask))
+	for _, direction := range []string{"-s", "-d"} {
+		if err := ipt.AppendUnique("mangle", "WG_ACCESS_SERVER_SHAPING", direction, limit.Address, "-j", "MARK", "--set-xmark", mark); err != nil {
+			return errors.Wrap(err, "failed to set ip tables rule")
+		}
+	}
+
+	// traffic to the device leaves via the wireguard interface
+	if err := setClass(wgIface, minor, limit.Ingress); err != nil {
+		return errors.Wrap(err, "failed to set ingress limit")
+	}
+
+	// traffic from the device leaves via the gateway interface
+	if gatewayIface != "" {
+		if err := setClass(gatewayIface, minor, limit.Egress); err != nil {
+			return errors.Wrap(err, "failed to set egress limit")
+		}
+	}
+
+	return nil
+}
+
+// RemoveBandwidthLimit removes any throughput limits for a device.
+func RemoveBandwidthLimit(wgIface string, gatewayIface string, address string) error {
+	minors.Lock()
+	minor, ok := minors.byAddress[address]
+	minors.Unlock()
+	if !ok {
+		// the device was never limited
+		return nil
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to init iptables")
+	}
+
+	mark := fmt.Sprintf("0x%x/0x%x", uint32(minor)<<16, uint32(shapingMarkMask))
+	for _, direction := range []string{"-s", "-d"} {
+		if ok, _ := ipt.Exists("mangle", "WG_ACCESS_SERVER_SHAPING", direction, address, "-j", "MARK", "--set-xmark", mark); ok {
+			if err := ipt.Delete("mangle", "WG_ACCESS_SERVER_SHAPING", direction, address, "-j", "MARK", "--set-xmark", mark); err != nil {
+				return errors.Wrap(err, "failed to remove ip tables rule")
+			}
+		}
+	}
+
+	for _, iface := range shapingIfaces(wgIface, gatewayIface) {
+		if err := setClass(iface, minor, 0); err != nil {
+			return errors.Wrapf(err, "failed to remove bandwidth limit on %s", iface)
+		}
+	}
+
+	minors.Lock()
+	delete(minors.byAddress, address)
+	minors.Unlock()
+
+	return nil
+}
+
+func shapingIfaces(wgIface string, gatewayIface string) []string {
+	if gatewayIface == "" {
+		return []string{wgIface}
+	}
+	return []string{wgIface, gatewayIface}
+}
+
+func resetRootQdisc(iface string) error {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return errors.Wrap(err, "failed to find interface")
+	}
+
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return errors.Wrap(err, "failed to list qdiscs")
+	}
+
+	// remove our own qdisc (and therefore all of its classes)
+	// left behind by a previous run
+	for _, q := range qdiscs {
+		major, _ := netlink.MajorMinor(q.Attrs().Handle)
+		if q.Attrs().Parent == netlink.HANDLE_ROOT && major == shapingHandle {
+			if err := netlink.QdiscDel(q); err != nil {
+				return errors.Wrap(err, "failed to remove existing qdisc")
+			}
+		}
+	}
+
+	// Defcls is 0 so that unclassified traffic is not shaped
+	htb := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    netlink.MakeHandle(shapingHandle, 0),
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	htb.Defcls = 0
+	if err := netlink.QdiscReplace(htb); err != nil {
+		return errors.Wrap(err, "failed to create htb qdisc")
+	}
+
+	return nil
+}
+
+func setClass(iface string, minor uint16, rate uint64) error {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return errors.Wrap(err, "failed to find interface")
+	}
+
+	parent := netlink.MakeHandle(shapingHandle, 0)
+	classid := netlink.MakeHandle(shapingHandle, minor)
+
+	filter, err := netlink.NewFw(netlink.FilterAttrs{
+		LinkIndex: link.Attrs().Index,
+		Parent:    parent,
+		Handle:    uint32(minor) << 16,
+		Priority:  1,
+		Protocol:  unix.ETH_P_IP,
+	}, netlink.FilterFwAttrs{
+		ClassId: classid,
+		Mask:    shapingMarkMask,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create fw filter")
+	}
+
+	class := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: link.Attrs().Index,
+		Parent:    parent,
+		Handle:    classid,
+	}, netlink.HtbClassAttrs{
+		Rate: rate,
+		Ceil: rate,
+	})
+
+	if rate == 0 {
+		// errors are ignored because the filter
+		// and class may not exist
+		netlink.FilterDel(filter)
+		netlink.ClassDel(class)
+		return nil
+	}
+
+	if err := netlink.ClassReplace(class); err != nil {
+		return errors.Wrap(err, "failed to set htb class")
+	}
+	if err := netlink.FilterReplace(filter); err != nil {
+		return errors.Wrap(err, "failed to set fw filter")
+	}
+
+	return nil
+}
+
+// allocateMinor returns the tc minor number of a device,
+// allocating the lowest free number if it doesn't have one.
+func allocateMinor(address string) (uint16, error) {
+	if net.ParseIP(address).To4() == nil {
+		return 0, fmt.Errorf("address %s is not an ipv4 address", address)
+	}
+
+	minors.Lock()
+	defer minors.Unlock()
+
+	if minor, ok := minors.byAddress[address]; ok {
+		return minor, nil
+	}
+
+	used := map[uint16]bool{}
+	for _, minor := range minors.byAddress {
+		used[minor] = true
+	}
+	// minor 0 is the qdisc itself and 0xffff is reserved by tc
+	for minor := uint16(1); minor < 0xffff; minor++ {
+		if !used[minor] {
+			minors.byAddress[address] = minor
+			return minor, nil
+		}
+	}
+	return 0, fmt.Errorf("address %s cannot be bandwidth limited: too many limited devices", address)
+}
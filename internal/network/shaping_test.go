@@ -0,0 +1,42 @@
+package network
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateMinor(t *testing.T) {
+	require := require.New(t)
+
+	minors.byAddress = map[string]uint16{}
+	defer func() { minors.byAddress = map[string]uint16{} }()
+
+	allocate := func(address string) uint16 {
+		minor, err := allocateMinor(address)
+		require.NoError(err)
+		return minor
+	}
+	require.Equal(uint16(1), allocate("10.44.0.2"))
+	require.Equal(uint16(2), allocate("10.44.0.3"))
+	require.Equal(uint16(1), allocate("10.44.0.2"), "devices keep their minor")
+
+	// the lowest free minor is reused
+	delete(minors.byAddress, "10.44.0.2")
+	require.Equal(uint16(1), allocate("10.44.0.4"))
+	require.Equal(uint16(3), allocate("10.44.0.2"))
+
+	_, err := allocateMinor("fd00::2")
+	require.Error(err)
+	_, err = allocateMinor("invalid")
+	require.Error(err)
+
+	// minors 0 and 0xffff are reserved
+	minors.byAddress = map[string]uint16{}
+	for i := 1; i < 0xffff; i++ {
+		minors.byAddress[strconv.Itoa(i)] = uint16(i)
+	}
+	_, err = allocateMinor("10.44.0.5")
+	require.Error(err)
+}
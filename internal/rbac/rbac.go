@@ -0,0 +1,172 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// the roles that users can have
+const (
+	RoleAdmin    = "admin"
+	RoleAuditor  = "auditor"
+	RoleHelpdesk = "helpdesk"
+	RoleUser     = "user"
+)
+
+// RoleClaim is added to identities once for each of their roles
+const RoleClaim = "role"
+
+// Permission allows calling a set of RPCs
+type Permission string
+
+const (
+	// ManageOwnDevices allows adding, listing and
+	// deleting your own devices
+	ManageOwnDevices Permission = "devices:own"
+	// ReadAllDevices allows listing everyone's devices and traffic
+	ReadAllDevices Permission = "devices:read-all"
+	// DeleteAllDevices allows deleting anyone's devices
+	DeleteAllDevices Permission = "devices:delete-all"
+	// ManageAllDevices allows changing the settings of
+	// anyone's devices i.e. bandwidth limits
+	ManageAllDevices   Permission = "devices:manage-all"
+	ReadPortForwards   Permission = "port-forwards:read"
+	ManagePortForwards Permission = "port-forwards:manage"
+	ReadDNSQueries     Permission = "dns-queries:read"
+	ReadSessions       Permission = "sessions:read"
+	RevokeSessions     Permission = "sessions:revoke"
+	// ReadAPITokens allows listing everyone's api tokens
+	ReadAPITokens Permission = "api-tokens:read"
+	// ManageAPITokens allows revoking anyone's api tokens and
+	// creating admin and service account tokens
+	ManageAPITokens Permission = "api-tokens:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser: {
+		ManageOwnDevices,
+	},
+	RoleAuditor: {
+		ManageOwnDevices,
+		ReadAllDevices,
+		ReadPortForwards,
+		ReadDNSQueries,
+		ReadSessions,
+		ReadAPITokens,
+	},
+	RoleHelpdesk: {
+		ManageOwnDevices,
+		ReadAllDevices,
+		DeleteAllDevices,
+	},
+	RoleAdmin: {
+		ManageOwnDevices,
+		ReadAllDevices,
+		DeleteAllDevices,
+		ManageAllDevices,
+		ReadPortForwards,
+		ManagePortForwards,
+		ReadDNSQueries,
+		ReadSessions,
+		RevokeSessions,
+		ReadAPITokens,
+		ManageAPITokens,
+	},
+}
+
+// ValidateRoles checks that role assignments are valid
+func ValidateRoles(assignments []config.RoleAssignment) error {
+	for _, a := range assignments {
+		if _, ok := rolePermissions[a.Role]; !ok {
+			return fmt.Errorf("unknown role '%s' - must be %s, %s, %s or %s", a.Role, RoleAdmin, RoleAuditor, RoleHelpdesk, RoleUser)
+		}
+		if a.Subject == "" && a.Email == "" && a.Claim == "" {
+			return fmt.Errorf("the %s role must be assigned by subject, email or claim", a.Role)
+		}
+	}
+	return nil
+}
+
+// Assign adds a RoleClaim to a user for each of the
+// assignments that match them. The admin role also
+// adds the "admin" claim.
+func Assign(user *authsession.Identity, assignments []config.RoleAssignment) {
+	for _, a := range assignments {
+		if !matches(user, a) || user.Claims.Has(RoleClaim, a.Role) {
+			continue
+		}
+		user.Claims.Add(RoleClaim, a.Role)
+		if a.Role == RoleAdmin && !user.Claims.Contains("admin") {
+			user.Claims.Add("admin", "true")
+		}
+	}
+}
+
+func matches(user *authsession.Identity, a config.RoleAssignment) bool {
+	if a.Provider != "" && a.Provider != user.Provider {
+		return false
+	}
+	if a.Subject != "" && a.Subject != user.Subject {
+		return false
+	}
+	if a.Email != "" && (user.Email == "" || !strings.EqualFold(a.Email, user.Email)) {
+		return false
+	}
+	if a.Claim != "" {
+		if a.Value == "" && !user.Claims.Contains(a.Claim) {
+			return false
+		}
+		if a.Value != "" && !user.Claims.Has(a.Claim, a.Value) {
+			return false
+		}
+	}
+	return a.Subject != "" || a.Email != "" || a.Claim != ""
+}
+
+// Roles returns a user's roles. Everyone has the user role
+// and the "admin" claim grants the admin role.
+func Roles(user *authsession.Identity) []string {
+	roles := []string{RoleUser}
+	if user.Claims.Contains("admin") {
+		roles = append(roles, RoleAdmin)
+	}
+	for _, c := range user.Claims {
+		if c.Name != RoleClaim || c.Value == RoleUser || c.Value == RoleAdmin {
+			continue
+		}
+		if _, ok := rolePermissions[c.Value]; ok {
+			roles = append(roles, c.Value)
+		}
+	}
+	return roles
+}
+
+// Can reports if a user has a permission
+func Can(user *authsession.Identity, permission Permission) bool {
+	for _, role := range Roles(user) {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permissions returns all of a user's permissions
+func Permissions(user *authsession.Identity) []Permission {
+	seen := map[Permission]bool{}
+	permissions := []Permission{}
+	for _, role := range Roles(user) {
+		for _, p := range rolePermissions[role] {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	return permissions
+}
@@ -0,0 +1,54 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssign(t *testing.T) {
+	require := require.New(t)
+
+	assignments := []config.RoleAssignment{
+		{Role: RoleHelpdesk, Claim: "group", Value: "helpdesk"},
+		{Role: RoleAuditor, Email: "Audit@example.com"},
+		{Role: RoleAdmin, Provider: "ldap", Subject: "root"},
+	}
+	require.NoError(ValidateRoles(assignments))
+
+	helpdesk := &authsession.Identity{Provider: "oidc", Subject: "bob"}
+	helpdesk.Claims.Add("group", "helpdesk")
+	Assign(helpdesk, assignments)
+	require.Equal([]string{RoleUser, RoleHelpdesk}, Roles(helpdesk))
+	require.True(Can(helpdesk, DeleteAllDevices))
+	require.True(Can(helpdesk, ReadAllDevices))
+	require.False(Can(helpdesk, ManageAllDevices))
+	require.False(helpdesk.Claims.Contains("admin"))
+
+	auditor := &authsession.Identity{Provider: "oidc", Subject: "carol", Email: "audit@example.com"}
+	Assign(auditor, assignments)
+	require.True(Can(auditor, ReadDNSQueries))
+	require.False(Can(auditor, DeleteAllDevices))
+	require.False(Can(auditor, RevokeSessions))
+
+	// the provider must match too
+	root := &authsession.Identity{Provider: "basic", Subject: "root"}
+	Assign(root, assignments)
+	require.Equal([]string{RoleUser}, Roles(root))
+	require.Equal([]Permission{ManageOwnDevices}, Permissions(root))
+
+	root.Provider = "ldap"
+	Assign(root, assignments)
+	require.True(root.Claims.Contains("admin"))
+	require.True(Can(root, ManageAPITokens))
+
+	// role claims from elsewhere can't grant admin
+	user := &authsession.Identity{Subject: "mallory"}
+	user.Claims.Add(RoleClaim, RoleAdmin)
+	require.False(Can(user, ManagePortForwards))
+
+	require.Error(ValidateRoles([]config.RoleAssignment{{Role: "superuser", Subject: "alice"}}))
+	require.Error(ValidateRoles([]config.RoleAssignment{{Role: RoleAdmin}}))
+}
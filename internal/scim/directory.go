@@ -0,0 +1,194 @@
+package scim
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/lifecycle"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// the directory is read on every request so it's cached.
+// changes made by other replicas take this long to apply.
+const cacheTTL = 10 * time.Second
+
+// Directory is the local copy of the users and groups that an
+// identity provider pushed over scim. The users of the directory's
+// provider are matched with scim users by their subject or by their
+// email if the provider verified it. Otherwise anyone that can set
+// their own email at the provider could take over a scim user.
+type Directory struct {
+	storage  storage.Storage
+	provider string
+
+	lock   sync.Mutex
+	loaded time.Time
+	users  []*storage.SCIMUser
+	groups []*storage.SCIMGroup
+}
+
+func NewDirectory(s storage.Storage, provider string) *Directory {
+	return &Directory{
+		storage:  s,
+		provider: provider,
+	}
+}
+
+// Claims adds a "group" claim for each of the groups that a
+// user is a member of. It returns authsession.ErrUserDisabled
+// if the user was deactivated.
+func (d *Directory) Claims(identity *authsession.Identity) error {
+	if identity.Provider != d.provider {
+		return nil
+	}
+	user, groups, err := d.lookup(identity.Subject, verifiedEmail(identity.Email, identity.EmailVerified))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	if !user.Active {
+		return authsession.ErrUserDisabled
+	}
+	for _, group := range groups {
+		if isMember(group, user.ID) && !identity.Claims.Has("group", group.DisplayName) {
+			identity.Claims.Add("group", group.DisplayName)
+		}
+	}
+	return nil
+}
+
+// Allowed reports if a user may sign in. Users that
+// aren't in the directory are allowed.
+func (d *Directory) Allowed(identity *authsession.Identity) bool {
+	if identity.Provider != d.provider {
+		return true
+	}
+	user, _, err := d.lookup(identity.Subject, verifiedEmail(identity.Email, identity.EmailVerified))
+	return err != nil || user == nil || user.Active
+}
+
+// Checker checks users of the directory's provider for the
+// lifecycle job. Deactivated users are deprovisioned and
+// users that aren't in the directory are unknown.
+func (d *Directory) Checker() lifecycle.Checker {
+	return func(ctx context.Context, u *storage.User) (authruntime.UserStatus, error) {
+		user, _, err := d.lookup(u.Subject, verifiedEmail(u.Email, u.EmailVerified))
+		if err != nil {
+			return authruntime.UserUnknown, err
+		}
+		switch {
+		case user == nil:
+			return authruntime.UserUnknown, nil
+		case user.Active:
+			return authruntime.UserActive, nil
+		default:
+			return authruntime.UserDeprovisioned, nil
+		}
+	}
+}
+
+// subjects returns the subjects of the provider's users that own
+// devices or have signed in and that match a scim user. Devices
+// are only matched by subject because their owner's email
+// might not be verified.
+func (d *Directory) subjects(user *storage.SCIMUser) ([]string, error) {
+	seen := map[string]bool{}
+	subjects := []string{}
+	add := func(subject string) {
+		if !seen[subject] {
+			seen[subject] = true
+			subjects = append(subjects, subject)
+		}
+	}
+
+	devices, err := d.storage.List("")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list devices")
+	}
+	for _, device := range devices {
+		if device.OwnerProvider == d.provider && matches(user, device.Owner, "") {
+			add(device.Owner)
+		}
+	}
+
+	users, err := d.storage.ListUsers()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list users")
+	}
+	for _, u := range users {
+		if u.Provider == d.provider && matches(user, u.Subject, verifiedEmail(u.Email, u.EmailVerified)) {
+			add(u.Subject)
+		}
+	}
+	return subjects, nil
+}
+
+// lookup finds the scim user with a subject or email and the
+// directory's groups. A match by subject is preferred.
+func (d *Directory) lookup(subject string, email string) (*storage.SCIMUser, []*storage.SCIMGroup, error) {
+	users, groups, err := d.snapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+	var found *storage.SCIMUser
+	for _, user := range users {
+		if matches(user, subject, "") {
+			return user, groups, nil
+		}
+		if found == nil && matches(user, "", email) {
+			found = user
+		}
+	}
+	return found, groups, nil
+}
+
+func (d *Directory) snapshot() ([]*storage.SCIMUser, []*storage.SCIMGroup, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if time.Since(d.loaded) < cacheTTL {
+		return d.users, d.groups, nil
+	}
+	users, err := d.storage.ListSCIMUsers()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list scim users")
+	}
+	groups, err := d.storage.ListSCIMGroups()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list scim groups")
+	}
+	d.users, d.groups, d.loaded = users, groups, time.Now()
+	return users, groups, nil
+}
+
+// invalidate reloads the directory on the next read
+func (d *Directory) invalidate() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.loaded = time.Time{}
+}
+
+// verifiedEmail returns the email if it's verified
+// so that unverified emails are never matched
+func verifiedEmail(email string, verified bool) string {
+	if !verified {
+		return ""
+	}
+	return email
+}
+
+// matches reports if a user of the provider is a scim user.
+// Identity providers usually use the email address or the
+// provider's subject as the user name.
+func matches(user *storage.SCIMUser, subject string, email string) bool {
+	if subject != "" && (strings.EqualFold(user.UserName, subject) || user.ExternalID == subject) {
+		return true
+	}
+	return email != "" && (strings.EqualFold(user.Email, email) || strings.EqualFold(user.UserName, email))
+}
@@ -0,0 +1,304 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/place1/wg-access-server/internal/storage"
+)
+
+// scimError is returned to the client as a scim error response
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimError) Error() string {
+	return e.detail
+}
+
+func errorf(status int, scimType string, format string, args ...interface{}) *scimError {
+	return &scimError{status, scimType, fmt.Sprintf(format, args...)}
+}
+
+// only "eq" filters are supported. they're the only
+// filters that okta and azure ad use.
+var filterPattern = regexp.MustCompile(`(?i)^\s*([a-z.]+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// the attributes that users can be filtered by
+var userFilters = map[string]func(user *storage.SCIMUser, value string) bool{
+	"id":           func(u *storage.SCIMUser, v string) bool { return u.ID == v },
+	"username":     func(u *storage.SCIMUser, v string) bool { return strings.EqualFold(u.UserName, v) },
+	"externalid":   func(u *storage.SCIMUser, v string) bool { return u.ExternalID == v },
+	"displayname":  func(u *storage.SCIMUser, v string) bool { return strings.EqualFold(u.DisplayName, v) },
+	"emails.value": func(u *storage.SCIMUser, v string) bool { return strings.EqualFold(u.Email, v) },
+}
+
+// the attributes that groups can be filtered by
+var groupFilters = map[string]func(group *storage.SCIMGroup, value string) bool{
+	"id":            func(g *storage.SCIMGroup, v string) bool { return g.ID == v },
+	"displayname":   func(g *storage.SCIMGroup, v string) bool { return strings.EqualFold(g.DisplayName, v) },
+	"externalid":    func(g *storage.SCIMGroup, v string) bool { return g.ExternalID == v },
+	"members.value": isMember,
+}
+
+// parseFilter returns the lowercase attribute and the value
+// of an "eq" filter. The attribute is empty if filter is.
+func parseFilter(filter string) (string, string, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", "", nil
+	}
+	m := filterPattern.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", errorf(http.StatusBadRequest, "invalidFilter", "unsupported filter '%s' - only 'attribute eq \"value\"' is supported", filter)
+	}
+	value, err := strconv.Unquote(`"` + m[2] + `"`)
+	if err != nil {
+		return "", "", errorf(http.StatusBadRequest, "invalidFilter", "invalid filter value '%s'", m[2])
+	}
+	return strings.ToLower(m[1]), value, nil
+}
+
+func userFilter(filter string) (func(user *storage.SCIMUser) bool, error) {
+	attribute, value, err := parseFilter(filter)
+	if err != nil || attribute == "" {
+		return func(*storage.SCIMUser) bool { return true }, err
+	}
+	match, ok := userFilters[attribute]
+	if !ok {
+		return nil, errorf(http.StatusBadRequest, "invalidFilter", "users can't be filtered by '%s'", attribute)
+	}
+	return func(user *storage.SCIMUser) bool { return match(user, value) }, nil
+}
+
+func groupFilter(filter string) (func(group *storage.SCIMGroup) bool, error) {
+	attribute, value, err := parseFilter(filter)
+	if err != nil || attribute == "" {
+		return func(*storage.SCIMGroup) bool { return true }, err
+	}
+	match, ok := groupFilters[attribute]
+	if !ok {
+		return nil, errorf(http.StatusBadRequest, "invalidFilter", "groups can't be filtered by '%s'", attribute)
+	}
+	return func(group *storage.SCIMGroup) bool { return match(group, value) }, nil
+}
+
+type patchRequest struct {
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	// Op is "add", "replace" or "remove". Azure AD
+	// capitalises it i.e. "Replace".
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// matches `emails[type eq "work"].value`
+var emailValuePattern = regexp.MustCompile(`(?i)^emails\[.*\]\.value$`)
+
+// matches `members[value eq "id"]`
+var memberPattern = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+// patchUser applies patch operations to a user. Okta replaces
+// attributes without a path i.e. {"op": "replace", "value":
+// {"active": false}} while Azure AD uses paths and sends booleans
+// as strings i.e. {"op": "Replace", "path": "active", "value": "False"}.
+func patchUser(user *storage.SCIMUser, ops []patchOperation) error {
+	for _, op := range ops {
+		kind, err := opKind(op)
+		if err != nil {
+			return err
+		}
+		if op.Path == "" {
+			values := map[string]json.RawMessage{}
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return errorf(http.StatusBadRequest, "invalidValue", "the value of a patch without a path must be an object")
+			}
+			for path, value := range values {
+				if err := setUserAttribute(user, path, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if kind == "remove" {
+			op.Value = nil
+		}
+		if err := setUserAttribute(user, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUserAttribute sets or removes (if value is nil) an attribute.
+// Attributes that aren't stored i.e. phone numbers are ignored.
+func setUserAttribute(user *storage.SCIMUser, path string, value json.RawMessage) error {
+	path = strings.ToLower(strings.TrimPrefix(path, userSchema+":"))
+	var err error
+	switch {
+	case path == "username":
+		user.UserName, err = stringValue(value)
+		if err == nil && user.UserName == "" {
+			err = errorf(http.StatusBadRequest, "invalidValue", "userName is required")
+		}
+	case path == "externalid":
+		user.ExternalID, err = stringValue(value)
+	case path == "displayname":
+		user.DisplayName, err = stringValue(value)
+	case path == "name.givenname":
+		user.GivenName, err = stringValue(value)
+	case path == "name.familyname":
+		user.FamilyName, err = stringValue(value)
+	case path == "name":
+		n := name{}
+		if value != nil {
+			if jsonErr := json.Unmarshal(value, &n); jsonErr != nil {
+				err = errorf(http.StatusBadRequest, "invalidValue", "name must be an object")
+			}
+		}
+		user.GivenName, user.FamilyName = n.GivenName, n.FamilyName
+	case path == "emails":
+		emails := []multiValue{}
+		if value != nil {
+			if jsonErr := json.Unmarshal(value, &emails); jsonErr != nil {
+				err = errorf(http.StatusBadRequest, "invalidValue", "emails must be a list")
+			}
+		}
+		user.Email = primaryEmail(emails)
+	case path == "emails.value" || emailValuePattern.MatchString(path):
+		user.Email, err = stringValue(value)
+	case path == "active":
+		if value == nil {
+			return errorf(http.StatusBadRequest, "mutability", "active can't be removed")
+		}
+		user.Active, err = boolValue(value)
+	}
+	return err
+}
+
+// patchGroup applies patch operations to a group
+func patchGroup(group *storage.SCIMGroup, ops []patchOperation) error {
+	for _, op := range ops {
+		kind, err := opKind(op)
+		if err != nil {
+			return err
+		}
+		if op.Path == "" {
+			values := map[string]json.RawMessage{}
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return errorf(http.StatusBadRequest, "invalidValue", "the value of a patch without a path must be an object")
+			}
+			for path, value := range values {
+				if err := setGroupAttribute(group, kind, path, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := setGroupAttribute(group, kind, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setGroupAttribute(group *storage.SCIMGroup, kind string, path string, value json.RawMessage) error {
+	if m := memberPattern.FindStringSubmatch(path); m != nil {
+		if kind != "remove" {
+			return errorf(http.StatusBadRequest, "invalidPath", "members can only be removed with a filter")
+		}
+		setMembers(group, without(members(group), m[1]))
+		return nil
+	}
+
+	path = strings.ToLower(strings.TrimPrefix(path, groupSchema+":"))
+	if kind == "remove" && path != "members" {
+		value = nil
+	}
+	var err error
+	switch path {
+	case "displayname":
+		group.DisplayName, err = stringValue(value)
+		if err == nil && group.DisplayName == "" {
+			err = errorf(http.StatusBadRequest, "invalidValue", "displayName is required")
+		}
+	case "externalid":
+		group.ExternalID, err = stringValue(value)
+	case "members":
+		values := []multiValue{}
+		if value != nil {
+			if jsonErr := json.Unmarshal(value, &values); jsonErr != nil {
+				return errorf(http.StatusBadRequest, "invalidValue", "members must be a list")
+			}
+		}
+		switch {
+		case kind == "add":
+			setMembers(group, append(members(group), memberIDs(values)...))
+		case kind == "replace":
+			setMembers(group, memberIDs(values))
+		case len(values) == 0:
+			// removing members without a value removes all of them
+			setMembers(group, nil)
+		default:
+			setMembers(group, without(members(group), memberIDs(values)...))
+		}
+	}
+	return err
+}
+
+func opKind(op patchOperation) (string, error) {
+	kind := strings.ToLower(op.Op)
+	switch kind {
+	case "add", "replace", "remove":
+		return kind, nil
+	}
+	return "", errorf(http.StatusBadRequest, "invalidSyntax", "unknown patch operation '%s'", op.Op)
+}
+
+func stringValue(value json.RawMessage) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	s := ""
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", errorf(http.StatusBadRequest, "invalidValue", "expected a string but got %s", string(value))
+	}
+	return s, nil
+}
+
+// boolValue accepts booleans and strings like "True"
+func boolValue(value json.RawMessage) (bool, error) {
+	b := false
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	s := ""
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			return b, nil
+		}
+	}
+	return false, errorf(http.StatusBadRequest, "invalidValue", "expected a boolean but got %s", string(value))
+}
+
+func without(ids []string, remove ...string) []string {
+	removed := map[string]bool{}
+	for _, id := range remove {
+		removed[id] = true
+	}
+	kept := []string{}
+	for _, id := range ids {
+		if !removed[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,240 @@
+package scim
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/place1/wg-access-server/internal/storage"
+)
+
+const (
+	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	listSchema                  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+type meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// multiValue is an item of a multi-valued attribute
+// i.e. an email address or a member of a group
+type multiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type userResource struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []multiValue `json:"emails,omitempty"`
+	// Active is a pointer because users are
+	// active if it's missing from a request
+	Active *bool        `json:"active,omitempty"`
+	Groups []multiValue `json:"groups,omitempty"`
+	Meta   *meta        `json:"meta,omitempty"`
+}
+
+type groupResource struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []multiValue `json:"members,omitempty"`
+	Meta        *meta        `json:"meta,omitempty"`
+}
+
+type listResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// apply replaces the attributes of a user with a resource's
+func (r *userResource) apply(user *storage.SCIMUser) {
+	user.UserName = r.UserName
+	user.ExternalID = r.ExternalID
+	user.DisplayName = r.DisplayName
+	user.GivenName = ""
+	user.FamilyName = ""
+	if r.Name != nil {
+		user.GivenName = r.Name.GivenName
+		user.FamilyName = r.Name.FamilyName
+	}
+	user.Email = primaryEmail(r.Emails)
+	user.Active = r.Active == nil || *r.Active
+}
+
+func toUserResource(user *storage.SCIMUser, groups []*storage.SCIMGroup) *userResource {
+	active := user.Active
+	r := &userResource{
+		Schemas:     []string{userSchema},
+		ID:          user.ID,
+		ExternalID:  user.ExternalID,
+		UserName:    user.UserName,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     Prefix + "/Users/" + user.ID,
+		},
+	}
+	if user.GivenName != "" || user.FamilyName != "" {
+		r.Name = &name{
+			Formatted:  strings.TrimSpace(user.GivenName + " " + user.FamilyName),
+			GivenName:  user.GivenName,
+			FamilyName: user.FamilyName,
+		}
+	}
+	if user.Email != "" {
+		r.Emails = []multiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	for _, group := range groups {
+		if isMember(group, user.ID) {
+			r.Groups = append(r.Groups, multiValue{Value: group.ID, Display: group.DisplayName})
+		}
+	}
+	return r
+}
+
+// apply replaces the attributes of a group with a resource's
+func (r *groupResource) apply(group *storage.SCIMGroup) {
+	group.DisplayName = r.DisplayName
+	group.ExternalID = r.ExternalID
+	setMembers(group, memberIDs(r.Members))
+}
+
+// toGroupResource converts a group. users are used to
+// show the names of members and members are left out
+// if excludeMembers is true.
+func toGroupResource(group *storage.SCIMGroup, users map[string]*storage.SCIMUser, excludeMembers bool) *groupResource {
+	r := &groupResource{
+		Schemas:     []string{groupSchema},
+		ID:          group.ID,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Meta: &meta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     Prefix + "/Groups/" + group.ID,
+		},
+	}
+	if excludeMembers {
+		return r
+	}
+	for _, id := range members(group) {
+		member := multiValue{Value: id}
+		if user, ok := users[id]; ok {
+			member.Display = user.DisplayName
+			if member.Display == "" {
+				member.Display = user.UserName
+			}
+		}
+		r.Members = append(r.Members, member)
+	}
+	return r
+}
+
+// primaryEmail picks the primary email, then a work
+// email and then the first email.
+func primaryEmail(emails []multiValue) string {
+	if len(emails) == 0 {
+		return ""
+	}
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	for _, email := range emails {
+		if strings.EqualFold(email.Type, "work") {
+			return email.Value
+		}
+	}
+	return emails[0].Value
+}
+
+func members(group *storage.SCIMGroup) []string {
+	return strings.Fields(group.Members)
+}
+
+func isMember(group *storage.SCIMGroup, id string) bool {
+	for _, member := range members(group) {
+		if member == id {
+			return true
+		}
+	}
+	return false
+}
+
+// setMembers replaces the members of a group
+// without duplicates
+func setMembers(group *storage.SCIMGroup, ids []string) {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	group.Members = strings.Join(unique, " ")
+}
+
+func memberIDs(values []multiValue) []string {
+	ids := []string{}
+	for _, v := range values {
+		ids = append(ids, v.Value)
+	}
+	return ids
+}
+
+// sortUsers sorts users by creation time so
+// that pages of users are stable
+func sortUsers(users []*storage.SCIMUser) {
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].ID < users[j].ID
+		}
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+}
+
+func sortGroups(groups []*storage.SCIMGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].CreatedAt.Equal(groups[j].CreatedAt) {
+			return groups[i].ID < groups[j].ID
+		}
+		return groups[i].CreatedAt.Before(groups[j].CreatedAt)
+	})
+}
@@ -0,0 +1,577 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/lifecycle"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+// Prefix is the path that the scim endpoint is served at
+const Prefix = "/scim/v2"
+
+// the most resources that are returned by a list request
+const maxResults = 200
+
+// the actor of audit events
+const actor = "scim"
+
+// Handler is a scim 2.0 endpoint for users and groups. It requires
+// an api token with the scim scope. Deactivating or deleting a user
+// deprovisions them and reactivating them gives their access back.
+type Handler struct {
+	storage       storage.Storage
+	directory     *Directory
+	deprovisioner *lifecycle.Deprovisioner
+	router        *mux.Router
+
+	// requests read, modify and save resources so
+	// they're serialized to not lose each other's changes
+	lock sync.Mutex
+}
+
+func NewHandler(directory *Directory, deprovisioner *lifecycle.Deprovisioner) *Handler {
+	h := &Handler{
+		storage:       directory.storage,
+		directory:     directory,
+		deprovisioner: deprovisioner,
+	}
+
+	router := mux.NewRouter().PathPrefix(Prefix).Subrouter()
+	router.Handle("/ServiceProviderConfig", h.handle(h.serviceProviderConfig)).Methods(http.MethodGet)
+	router.Handle("/Users", h.handle(h.listUsers)).Methods(http.MethodGet)
+	router.Handle("/Users", h.handle(h.createUser)).Methods(http.MethodPost)
+	router.Handle("/Users/{id}", h.handle(h.getUser)).Methods(http.MethodGet)
+	router.Handle("/Users/{id}", h.handle(h.replaceUser)).Methods(http.MethodPut)
+	router.Handle("/Users/{id}", h.handle(h.patchUser)).Methods(http.MethodPatch)
+	router.Handle("/Users/{id}", h.handle(h.deleteUser)).Methods(http.MethodDelete)
+	router.Handle("/Groups", h.handle(h.listGroups)).Methods(http.MethodGet)
+	router.Handle("/Groups", h.handle(h.createGroup)).Methods(http.MethodPost)
+	router.Handle("/Groups/{id}", h.handle(h.getGroup)).Methods(http.MethodGet)
+	router.Handle("/Groups/{id}", h.handle(h.replaceGroup)).Methods(http.MethodPut)
+	router.Handle("/Groups/{id}", h.handle(h.patchGroup)).Methods(http.MethodPatch)
+	router.Handle("/Groups/{id}", h.handle(h.deleteGroup)).Methods(http.MethodDelete)
+	router.NotFoundHandler = h.handle(func(w http.ResponseWriter, r *http.Request) error {
+		return errorf(http.StatusNotFound, "", "unknown scim endpoint %s", r.URL.Path)
+	})
+	router.MethodNotAllowedHandler = h.handle(func(w http.ResponseWriter, r *http.Request) error {
+		return errorf(http.StatusMethodNotAllowed, "", "%s isn't supported by %s", r.Method, r.URL.Path)
+	})
+	h.router = router
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, err := authsession.CurrentUser(r.Context())
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, errorf(http.StatusUnauthorized, "", "an api token with the scim scope is required"))
+		return
+	}
+	if !apitokens.HasScope(user, apitokens.ScopeSCIM) {
+		writeError(w, errorf(http.StatusForbidden, "", "an api token with the scim scope is required"))
+		return
+	}
+	h.router.ServeHTTP(w, r)
+}
+
+// handle writes the errors of a handler as scim errors
+func (h *Handler) handle(fn func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+		if e, ok := err.(*scimError); ok {
+			writeError(w, e)
+			return
+		}
+		ctxlogrus.Extract(r.Context()).Error(errors.Wrapf(err, "scim %s %s failed", r.Method, r.URL.Path))
+		writeError(w, errorf(http.StatusInternalServerError, "", "internal server error"))
+	})
+}
+
+func (h *Handler) serviceProviderConfig(w http.ResponseWriter, r *http.Request) error {
+	supported := func(ok bool) map[string]interface{} {
+		return map[string]interface{}{"supported": ok}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{serviceProviderConfigSchema},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxResults},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "API Token",
+			"description": "A wg-access-server api token with the scim scope",
+		}},
+	})
+	return nil
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) error {
+	match, err := userFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		return err
+	}
+	users, groups, err := h.list()
+	if err != nil {
+		return err
+	}
+	sortUsers(users)
+	resources := []interface{}{}
+	for _, user := range users {
+		if match(user) {
+			resources = append(resources, toUserResource(user, groups))
+		}
+	}
+	writeList(w, r, resources)
+	return nil
+}
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := h.getSCIMUser(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	groups, err := h.storage.ListSCIMGroups()
+	if err != nil {
+		return errors.Wrap(err, "failed to list groups")
+	}
+	writeJSON(w, http.StatusOK, toUserResource(user, groups))
+	return nil
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) error {
+	resource := userResource{}
+	if err := decode(r, &resource); err != nil {
+		return err
+	}
+	if resource.UserName == "" {
+		return errorf(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.checkUserName("", resource.UserName); err != nil {
+		return err
+	}
+	user := &storage.SCIMUser{ID: uuid.New().String()}
+	resource.apply(user)
+	if err := h.saveUser(true, user); err != nil {
+		return err
+	}
+	w.Header().Set("Location", Prefix+"/Users/"+user.ID)
+	writeJSON(w, http.StatusCreated, toUserResource(user, nil))
+	return nil
+}
+
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request) error {
+	resource := userResource{}
+	if err := decode(r, &resource); err != nil {
+		return err
+	}
+	if resource.UserName == "" {
+		return errorf(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	user, err := h.getSCIMUser(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	wasActive := user.Active
+	if err := h.checkUserName(user.ID, resource.UserName); err != nil {
+		return err
+	}
+	resource.apply(user)
+	if err := h.saveUser(wasActive, user); err != nil {
+		return err
+	}
+	return h.writeUser(w, user)
+}
+
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request) error {
+	patch := patchRequest{}
+	if err := decode(r, &patch); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	user, err := h.getSCIMUser(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	wasActive := user.Active
+	if err := patchUser(user, patch.Operations); err != nil {
+		return err
+	}
+	if err := h.checkUserName(user.ID, user.UserName); err != nil {
+		return err
+	}
+	if err := h.saveUser(wasActive, user); err != nil {
+		return err
+	}
+	return h.writeUser(w, user)
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	user, err := h.getSCIMUser(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	groups, err := h.storage.ListSCIMGroups()
+	if err != nil {
+		return errors.Wrap(err, "failed to list groups")
+	}
+	for _, g := range groups {
+		if !isMember(g, user.ID) {
+			continue
+		}
+		group := *g
+		setMembers(&group, without(members(&group), user.ID))
+		if err := h.storage.SaveSCIMGroup(&group); err != nil {
+			return errors.Wrap(err, "failed to save group")
+		}
+	}
+	if err := h.storage.DeleteSCIMUser(user); err != nil {
+		return errors.Wrap(err, "failed to delete user")
+	}
+	h.directory.invalidate()
+
+	if err := h.deprovision(user, "the user was deleted by the identity provider"); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// saveUser saves a user and deprovisions or reactivates
+// them if they were deactivated or activated
+func (h *Handler) saveUser(wasActive bool, user *storage.SCIMUser) error {
+	if err := h.storage.SaveSCIMUser(user); err != nil {
+		return errors.Wrap(err, "failed to save user")
+	}
+	h.directory.invalidate()
+
+	switch {
+	case wasActive && !user.Active:
+		return h.deprovision(user, "the user was deactivated by the identity provider")
+	case !wasActive && user.Active:
+		subjects, err := h.directory.subjects(user)
+		if err != nil {
+			return err
+		}
+		for _, subject := range subjects {
+			if err := h.deprovisioner.Reactivate(h.directory.provider, subject, actor, "the user was activated by the identity provider"); err != nil {
+				return errors.Wrapf(err, "failed to reactivate %s", subject)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) deprovision(user *storage.SCIMUser, reason string) error {
+	subjects, err := h.directory.subjects(user)
+	if err != nil {
+		return err
+	}
+	for _, subject := range subjects {
+		if err := h.deprovisioner.Deprovision(h.directory.provider, subject, actor, reason); err != nil {
+			return errors.Wrapf(err, "failed to deprovision %s", subject)
+		}
+	}
+	return nil
+}
+
+// checkUserName checks that no other user has a user name
+func (h *Handler) checkUserName(id string, userName string) error {
+	users, err := h.storage.ListSCIMUsers()
+	if err != nil {
+		return errors.Wrap(err, "failed to list users")
+	}
+	for _, user := range users {
+		if user.ID != id && strings.EqualFold(user.UserName, userName) {
+			return errorf(http.StatusConflict, "uniqueness", "a user named '%s' already exists", userName)
+		}
+	}
+	return nil
+}
+
+// getSCIMUser returns a copy of a user that can be modified
+func (h *Handler) getSCIMUser(id string) (*storage.SCIMUser, error) {
+	user, err := h.storage.GetSCIMUser(id)
+	if err != nil {
+		return nil, errorf(http.StatusNotFound, "", "user %s not found", id)
+	}
+	copy := *user
+	return &copy, nil
+}
+
+func (h *Handler) writeUser(w http.ResponseWriter, user *storage.SCIMUser) error {
+	groups, err := h.storage.ListSCIMGroups()
+	if err != nil {
+		return errors.Wrap(err, "failed to list groups")
+	}
+	writeJSON(w, http.StatusOK, toUserResource(user, groups))
+	return nil
+}
+
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request) error {
+	match, err := groupFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		return err
+	}
+	users, groups, err := h.list()
+	if err != nil {
+		return err
+	}
+	sortGroups(groups)
+	byID := usersByID(users)
+	resources := []interface{}{}
+	for _, group := range groups {
+		if match(group) {
+			resources = append(resources, toGroupResource(group, byID, excludeMembers(r)))
+		}
+	}
+	writeList(w, r, resources)
+	return nil
+}
+
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request) error {
+	group, err := h.getSCIMGroup(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	return h.writeGroup(w, r, http.StatusOK, group)
+}
+
+func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request) error {
+	resource := groupResource{}
+	if err := decode(r, &resource); err != nil {
+		return err
+	}
+	if resource.DisplayName == "" {
+		return errorf(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.checkGroupName("", resource.DisplayName); err != nil {
+		return err
+	}
+	group := &storage.SCIMGroup{ID: uuid.New().String()}
+	resource.apply(group)
+	if err := h.saveGroup(group); err != nil {
+		return err
+	}
+	w.Header().Set("Location", Prefix+"/Groups/"+group.ID)
+	return h.writeGroup(w, r, http.StatusCreated, group)
+}
+
+func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request) error {
+	resource := groupResource{}
+	if err := decode(r, &resource); err != nil {
+		return err
+	}
+	if resource.DisplayName == "" {
+		return errorf(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	group, err := h.getSCIMGroup(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	if err := h.checkGroupName(group.ID, resource.DisplayName); err != nil {
+		return err
+	}
+	resource.apply(group)
+	if err := h.saveGroup(group); err != nil {
+		return err
+	}
+	return h.writeGroup(w, r, http.StatusOK, group)
+}
+
+func (h *Handler) patchGroup(w http.ResponseWriter, r *http.Request) error {
+	patch := patchRequest{}
+	if err := decode(r, &patch); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	group, err := h.getSCIMGroup(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	if err := patchGroup(group, patch.Operations); err != nil {
+		return err
+	}
+	if err := h.checkGroupName(group.ID, group.DisplayName); err != nil {
+		return err
+	}
+	if err := h.saveGroup(group); err != nil {
+		return err
+	}
+	return h.writeGroup(w, r, http.StatusOK, group)
+}
+
+func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	group, err := h.getSCIMGroup(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	if err := h.storage.DeleteSCIMGroup(group); err != nil {
+		return errors.Wrap(err, "failed to delete group")
+	}
+	h.directory.invalidate()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) saveGroup(group *storage.SCIMGroup) error {
+	if err := h.storage.SaveSCIMGroup(group); err != nil {
+		return errors.Wrap(err, "failed to save group")
+	}
+	h.directory.invalidate()
+	return nil
+}
+
+// checkGroupName checks that no other group has a display name
+func (h *Handler) checkGroupName(id string, displayName string) error {
+	groups, err := h.storage.ListSCIMGroups()
+	if err != nil {
+		return errors.Wrap(err, "failed to list groups")
+	}
+	for _, group := range groups {
+		if group.ID != id && strings.EqualFold(group.DisplayName, displayName) {
+			return errorf(http.StatusConflict, "uniqueness", "a group named '%s' already exists", displayName)
+		}
+	}
+	return nil
+}
+
+// getSCIMGroup returns a copy of a group that can be modified
+func (h *Handler) getSCIMGroup(id string) (*storage.SCIMGroup, error) {
+	group, err := h.storage.GetSCIMGroup(id)
+	if err != nil {
+		return nil, errorf(http.StatusNotFound, "", "group %s not found", id)
+	}
+	copy := *group
+	return &copy, nil
+}
+
+func (h *Handler) writeGroup(w http.ResponseWriter, r *http.Request, status int, group *storage.SCIMGroup) error {
+	users, err := h.storage.ListSCIMUsers()
+	if err != nil {
+		return errors.Wrap(err, "failed to list users")
+	}
+	writeJSON(w, status, toGroupResource(group, usersByID(users), excludeMembers(r)))
+	return nil
+}
+
+func (h *Handler) list() ([]*storage.SCIMUser, []*storage.SCIMGroup, error) {
+	users, err := h.storage.ListSCIMUsers()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list users")
+	}
+	groups, err := h.storage.ListSCIMGroups()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list groups")
+	}
+	return users, groups, nil
+}
+
+func usersByID(users []*storage.SCIMUser) map[string]*storage.SCIMUser {
+	byID := map[string]*storage.SCIMUser{}
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID
+}
+
+// excludeMembers reports if a request has excludedAttributes=members.
+// identity providers use it to not load the members of large groups.
+func excludeMembers(r *http.Request) bool {
+	for _, attribute := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "invalidSyntax", "invalid request body: %s", err)
+	}
+	return nil
+}
+
+// writeList writes a page of resources. startIndex is 1-based.
+func writeList(w http.ResponseWriter, r *http.Request, resources []interface{}) {
+	start, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || start < 1 {
+		start = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count > maxResults {
+		count = maxResults
+	}
+	if count < 0 {
+		count = 0
+	}
+
+	page := []interface{}{}
+	if start <= len(resources) {
+		end := start - 1 + count
+		if end > len(resources) {
+			end = len(resources)
+		}
+		page = resources[start-1 : end]
+	}
+	writeJSON(w, http.StatusOK, &listResponse{
+		Schemas:      []string{listSchema},
+		TotalResults: len(resources),
+		StartIndex:   start,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	})
+}
+
+func writeError(w http.ResponseWriter, e *scimError) {
+	writeJSON(w, e.status, &errorResponse{
+		Schemas:  []string{errorSchema},
+		Status:   strconv.Itoa(e.status),
+		ScimType: e.scimType,
+		Detail:   e.detail,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
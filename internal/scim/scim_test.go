@@ -0,0 +1,193 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/internal/devices"
+	"github.com/place1/wg-access-server/internal/lifecycle"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-embed/pkg/wgembed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers(t *testing.T) {
+	require := require.New(t)
+	s, h := testHandler(t)
+	require.NoError(s.Save(&storage.Device{OwnerProvider: "idp", Owner: "alice-subject", OwnerEmail: "alice@example.com", Name: "laptop", PublicKey: "alice-laptop"}))
+	require.NoError(s.Save(&storage.Device{OwnerProvider: "basic", Owner: "alice@example.com", Name: "laptop", PublicKey: "basic-laptop"}))
+	require.NoError(s.SaveUser(&storage.User{Provider: "idp", Subject: "alice-subject", Email: "alice@example.com", EmailVerified: true}))
+	// mallory set alice's email at the idp but it isn't verified
+	require.NoError(s.Save(&storage.Device{OwnerProvider: "idp", Owner: "mallory-subject", OwnerEmail: "alice@example.com", Name: "laptop", PublicKey: "mallory-laptop"}))
+	require.NoError(s.SaveUser(&storage.User{Provider: "idp", Subject: "mallory-subject", Email: "alice@example.com"}))
+
+	// an api token with the scim scope is required
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Prefix+"/Users", nil))
+	require.Equal(http.StatusUnauthorized, w.Code)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, withScope(httptest.NewRequest(http.MethodGet, Prefix+"/Users", nil), apitokens.ScopeAdmin))
+	require.Equal(http.StatusForbidden, w.Code)
+
+	created := userResource{}
+	do(t, h, http.MethodPost, "/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice@example.com",
+		"name": {"givenName": "Alice", "familyName": "Smith"},
+		"emails": [{"value": "alice@example.com", "type": "work", "primary": true}],
+		"active": true
+	}`, http.StatusCreated, &created)
+	require.NotEmpty(created.ID)
+	require.True(*created.Active)
+	do(t, h, http.MethodPost, "/Users", `{"userName": "ALICE@example.com"}`, http.StatusConflict, nil)
+	do(t, h, http.MethodPost, "/Users", `{"userName": "bob@example.com"}`, http.StatusCreated, nil)
+
+	list := listResponse{}
+	do(t, h, http.MethodGet, `/Users?filter=userName+eq+"Alice@Example.com"`, "", http.StatusOK, &list)
+	require.Equal(1, list.TotalResults)
+	do(t, h, http.MethodGet, "/Users?startIndex=2&count=5", "", http.StatusOK, &list)
+	require.Equal(2, list.TotalResults)
+	require.Equal(1, list.ItemsPerPage)
+	do(t, h, http.MethodGet, `/Users?filter=title+eq+"ceo"`, "", http.StatusBadRequest, nil)
+
+	// okta deactivates users without a path
+	do(t, h, http.MethodPatch, "/Users/"+created.ID, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "value": {"active": false}}]
+	}`, http.StatusOK, nil)
+	require.True(device(t, s, "alice-subject", "laptop").Disabled)
+	require.False(device(t, s, "alice@example.com", "laptop").Disabled, "users of other providers aren't affected")
+	require.False(device(t, s, "mallory-subject", "laptop").Disabled, "unverified emails aren't matched")
+	user, err := s.GetUser("idp", "alice-subject")
+	require.NoError(err)
+	require.True(user.Deprovisioned)
+
+	// azure ad sends booleans as strings
+	do(t, h, http.MethodPatch, "/Users/"+created.ID, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "True"},
+			{"op": "Replace", "path": "emails[type eq \"work\"].value", "value": "alice@example.org"}
+		]
+	}`, http.StatusOK, &created)
+	require.True(*created.Active)
+	require.Equal("alice@example.org", created.Emails[0].Value)
+	require.False(device(t, s, "alice-subject", "laptop").Disabled)
+
+	do(t, h, http.MethodDelete, "/Users/"+created.ID, "", http.StatusNoContent, nil)
+	do(t, h, http.MethodGet, "/Users/"+created.ID, "", http.StatusNotFound, nil)
+	require.True(device(t, s, "alice-subject", "laptop").Disabled)
+}
+
+func TestGroups(t *testing.T) {
+	require := require.New(t)
+	_, h := testHandler(t)
+
+	alice, bob := userResource{}, userResource{}
+	do(t, h, http.MethodPost, "/Users", `{"userName": "alice"}`, http.StatusCreated, &alice)
+	do(t, h, http.MethodPost, "/Users", `{"userName": "bob"}`, http.StatusCreated, &bob)
+
+	group := groupResource{}
+	do(t, h, http.MethodPost, "/Groups", `{
+		"displayName": "vpn-admins",
+		"members": [{"value": "`+alice.ID+`"}]
+	}`, http.StatusCreated, &group)
+	require.Len(group.Members, 1)
+	require.Equal("alice", group.Members[0].Display)
+
+	do(t, h, http.MethodPatch, "/Groups/"+group.ID, `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "`+bob.ID+`"}]},
+		{"op": "remove", "path": "members[value eq \"`+alice.ID+`\"]"}
+	]}`, http.StatusOK, &group)
+	require.Len(group.Members, 1)
+	require.Equal(bob.ID, group.Members[0].Value)
+
+	excluded := groupResource{}
+	do(t, h, http.MethodGet, "/Groups/"+group.ID+"?excludedAttributes=members", "", http.StatusOK, &excluded)
+	require.Equal("vpn-admins", excluded.DisplayName)
+	require.Empty(excluded.Members)
+
+	list := listResponse{}
+	do(t, h, http.MethodGet, `/Groups?filter=displayName+eq+"vpn-admins"`, "", http.StatusOK, &list)
+	require.Equal(1, list.TotalResults)
+
+	// members get a group claim and deactivated users can't sign in
+	identity := &authsession.Identity{Provider: "idp", Subject: "bob"}
+	require.NoError(h.directory.Claims(identity))
+	require.True(identity.Claims.Has("group", "vpn-admins"))
+	identity = &authsession.Identity{Provider: "basic", Subject: "bob"}
+	require.NoError(h.directory.Claims(identity))
+	require.False(identity.Claims.Contains("group"))
+
+	// users are only matched by email if it's verified
+	identity = &authsession.Identity{Provider: "idp", Subject: "00u2", Email: "bob"}
+	require.NoError(h.directory.Claims(identity))
+	require.False(identity.Claims.Contains("group"))
+	identity.EmailVerified = true
+	require.NoError(h.directory.Claims(identity))
+	require.True(identity.Claims.Has("group", "vpn-admins"))
+
+	do(t, h, http.MethodPatch, "/Users/"+bob.ID, `{"Operations": [{"op": "replace", "path": "active", "value": false}]}`, http.StatusOK, nil)
+	require.Equal(authsession.ErrUserDisabled, h.directory.Claims(&authsession.Identity{Provider: "idp", Subject: "bob"}))
+
+	// deleted users are removed from groups
+	do(t, h, http.MethodDelete, "/Users/"+bob.ID, "", http.StatusNoContent, nil)
+	remaining := groupResource{}
+	do(t, h, http.MethodGet, "/Groups/"+group.ID, "", http.StatusOK, &remaining)
+	require.Empty(remaining.Members)
+}
+
+func TestPatchUser(t *testing.T) {
+	require := require.New(t)
+
+	user := &storage.SCIMUser{UserName: "alice", Active: true}
+	require.NoError(patchUser(user, []patchOperation{
+		{Op: "replace", Value: json.RawMessage(`{"name": {"givenName": "Alice"}, "displayName": "Alice", "title": "ignored"}`)},
+		{Op: "add", Path: "urn:ietf:params:scim:schemas:core:2.0:User:externalId", Value: json.RawMessage(`"00u1"`)},
+	}))
+	require.Equal("Alice", user.GivenName)
+	require.Equal("Alice", user.DisplayName)
+	require.Equal("00u1", user.ExternalID)
+
+	require.Error(patchUser(user, []patchOperation{{Op: "remove", Path: "userName"}}))
+	require.Error(patchUser(user, []patchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}}))
+	require.Error(patchUser(user, []patchOperation{{Op: "move", Path: "active"}}))
+}
+
+func testHandler(t *testing.T) (storage.Storage, *Handler) {
+	s := storage.NewMemoryStorage()
+	profiles := devices.Profiles{"": devices.New(wgembed.NewNoOpInterface(), s, config.Profile{})}
+	deprovisioner, err := lifecycle.NewDeprovisioner(s, profiles, nil, lifecycle.ActionDisable)
+	require.NoError(t, err)
+	return s, NewHandler(NewDirectory(s, "idp"), deprovisioner)
+}
+
+// do makes a request with the scim scope and decodes the response into v
+func do(t *testing.T, h *Handler, method string, path string, body string, status int, v interface{}) {
+	r := withScope(httptest.NewRequest(method, Prefix+path, strings.NewReader(body)), apitokens.ScopeSCIM)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, status, w.Code, w.Body.String())
+	if v != nil {
+		require.Equal(t, "application/scim+json", w.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+}
+
+func withScope(r *http.Request, scope string) *http.Request {
+	identity := &authsession.Identity{Provider: "service-account", Subject: "service-account:okta"}
+	identity.Claims.Add(apitokens.ScopeClaim, scope)
+	return r.WithContext(authsession.SetIdentityCtx(r.Context(), &authsession.AuthSession{Identity: identity}))
+}
+
+func device(t *testing.T, s storage.Storage, owner string, name string) *storage.Device {
+	d, err := s.Get(owner, name)
+	require.NoError(t, err)
+	return d
+}
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Box encrypts secrets, i.e. oauth2 refresh tokens,
+// before they're written to the storage backend.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates a Box with a key that's derived from a secret.
+// The same secret must be used to open sealed values.
+func New(secret string) (*Box, error) {
+	if secret == "" {
+		return nil, errors.New("a secret is required")
+	}
+	// a separate purpose so that the key differs from
+	// other keys derived from the same secret
+	key := sha256.Sum256([]byte("wg-access-server secrets\x00" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts a value. context is authenticated but not
+// encrypted and must be the same when the value is opened,
+// i.e. the id of the record so values can't be swapped.
+func (b *Box) Seal(value []byte, context string) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to create nonce")
+	}
+	return b.aead.Seal(nonce, nonce, value, []byte(context)), nil
+}
+
+// Open decrypts a value that was encrypted by Seal
+func (b *Box) Open(sealed []byte, context string) ([]byte, error) {
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("sealed value is too short")
+	}
+	value, err := b.aead.Open(nil, sealed[:size], sealed[size:], []byte(context))
+	if err != nil {
+		return nil, errors.New("failed to decrypt value - was the secret changed?")
+	}
+	return value, nil
+}
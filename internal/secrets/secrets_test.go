@@ -0,0 +1,34 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBox(t *testing.T) {
+	require := require.New(t)
+
+	box, err := New("secret")
+	require.NoError(err)
+
+	sealed, err := box.Seal([]byte("refresh-token"), "oidc/alice")
+	require.NoError(err)
+	require.NotContains(string(sealed), "refresh-token")
+
+	value, err := box.Open(sealed, "oidc/alice")
+	require.NoError(err)
+	require.Equal("refresh-token", string(value))
+
+	// values are bound to their context
+	_, err = box.Open(sealed, "oidc/bob")
+	require.Error(err)
+
+	other, err := New("other")
+	require.NoError(err)
+	_, err = other.Open(sealed, "oidc/alice")
+	require.Error(err)
+
+	_, err = New("")
+	require.Error(err)
+}
@@ -10,8 +10,11 @@ import (
 
 	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/place1/wg-access-server/internal/apitokens"
 	"github.com/place1/wg-access-server/internal/config"
 	"github.com/place1/wg-access-server/internal/devices"
+	"github.com/place1/wg-access-server/internal/dnsproxy"
+	"github.com/place1/wg-access-server/internal/storage"
 	"github.com/place1/wg-access-server/internal/traces"
 	"github.com/place1/wg-access-server/proto/proto"
 	"google.golang.org/grpc"
@@ -20,26 +23,29 @@ import (
 type ApiServices struct {
 	Config        *config.AppConfig
 	DeviceManager *devices.DeviceManager
+	Profiles      devices.Profiles
 	Wg            wgembed.WireGuardInterface
+	QueryLog      *dnsproxy.QueryLog
+	// nil if server-side sessions are disabled
+	Sessions storage.Storage
+	Tokens   *apitokens.Tokens
 }
 
 func ApiRouter(deps *ApiServices) http.Handler {
 	// Native GRPC server
 	server := grpc.NewServer([]grpc.ServerOption{
 		grpc.MaxRecvMsgSize(int(1 * math.Pow(2, 20))), // 1MB
-		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-			return grpc_logrus.UnaryServerInterceptor(traces.Logger(ctx))(ctx, req, info, handler)
-		}),
+		grpc.ChainUnaryInterceptor(
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+				return grpc_logrus.UnaryServerInterceptor(traces.Logger(ctx))(ctx, req, info, handler)
+			},
+			ApiTokenScopes,
+			Authorization,
+		),
 	}...)
 
 	// Register GRPC services
-	proto.RegisterServerServer(server, &ServerService{
-		Config: deps.Config,
-		Wg:     deps.Wg,
-	})
-	proto.RegisterDevicesServer(server, &DeviceService{
-		DeviceManager: deps.DeviceManager,
-	})
+	registerServices(server, deps)
 
 	// Grpc Web in process proxy (wrapper)
 	grpcServer := grpcweb.WrapServer(server,
@@ -57,3 +63,33 @@ func ApiRouter(deps *ApiServices) http.Handler {
 		return
 	})
 }
+
+// registerServices registers the GRPC services. Every rpc
+// must be listed in rpcPermissions and rpcScopes.
+func registerServices(server *grpc.Server, deps *ApiServices) {
+	proto.RegisterServerServer(server, &ServerService{
+		Config:   deps.Config,
+		Profiles: deps.Profiles,
+		Wg:       deps.Wg,
+	})
+	proto.RegisterDevicesServer(server, &DeviceService{
+		DeviceManager: deps.DeviceManager,
+		Profiles:      deps.Profiles,
+	})
+	proto.RegisterPortForwardsServer(server, &PortForwardService{
+		DeviceManager: deps.DeviceManager,
+		Profiles:      deps.Profiles,
+	})
+
+	proto.RegisterDNSServer(server, &DNSService{
+		QueryLog: deps.QueryLog,
+	})
+
+	proto.RegisterSessionsServer(server, &SessionService{
+		Storage: deps.Sessions,
+	})
+
+	proto.RegisterApiTokensServer(server, &ApiTokenService{
+		Tokens: deps.Tokens,
+	})
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/rbac"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-access-server/proto/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ApiTokenService struct {
+	Tokens *apitokens.Tokens
+}
+
+func (a *ApiTokenService) CreateApiToken(ctx context.Context, req *proto.CreateApiTokenReq) (*proto.CreateApiTokenRes, error) {
+	user, err := authsession.CurrentUser(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "not authenticated")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "name is required")
+	}
+	if err := apitokens.ValidateScopes(req.GetScopes()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	opts := apitokens.CreateOpts{
+		Name:           req.GetName(),
+		Scopes:         req.GetScopes(),
+		ServiceAccount: req.GetServiceAccount(),
+	}
+	if req.GetExpiresAt() != nil {
+		expires, err := ptypes.Timestamp(req.GetExpiresAt())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid expiry time")
+		}
+		opts.ExpiresAt = &expires
+	}
+
+	token, record, err := a.Tokens.Create(user, opts)
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to create api token")
+	}
+	ctxlogrus.Extract(ctx).Infof("created api token %s (%s) for %s", record.ID, record.Scopes, record.Owner)
+
+	return &proto.CreateApiTokenRes{
+		Token:    token,
+		ApiToken: mapApiToken(record),
+	}, nil
+}
+
+func (a *ApiTokenService) ListApiTokens(ctx context.Context, req *proto.ListApiTokensReq) (*proto.ListApiTokensRes, error) {
+	user, err := authsession.CurrentUser(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "not authenticated")
+	}
+
+	owner := user.Subject
+	if req.GetAll() {
+		owner = ""
+	}
+
+	tokens, err := a.Tokens.List(owner)
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to retrieve api tokens")
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+
+	items := []*proto.ApiToken{}
+	for _, token := range tokens {
+		items = append(items, mapApiToken(token))
+	}
+
+	return &proto.ListApiTokensRes{
+		Items: items,
+	}, nil
+}
+
+func (a *ApiTokenService) RevokeApiToken(ctx context.Context, req *proto.RevokeApiTokenReq) (*empty.Empty, error) {
+	user, err := authsession.CurrentUser(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "not authenticated")
+	}
+
+	token, err := a.Tokens.Get(req.GetId())
+	if err != nil || (token.Owner != user.Subject && !rbac.Can(user, rbac.ManageAPITokens)) {
+		return nil, status.Errorf(codes.NotFound, "api token doesn't exist")
+	}
+
+	if err := a.Tokens.Revoke(token); err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to revoke api token")
+	}
+	ctxlogrus.Extract(ctx).Infof("revoked api token %s of %s", token.ID, token.Owner)
+
+	return &empty.Empty{}, nil
+}
+
+func mapApiToken(token *storage.APIToken) *proto.ApiToken {
+	return &proto.ApiToken{
+		Id:             token.ID,
+		Name:           token.Name,
+		Owner:          token.Owner,
+		OwnerName:      token.OwnerName,
+		ServiceAccount: token.ServiceAccount,
+		Scopes:         apitokens.Scopes(token),
+		CreatedAt:      TimeToTimestamp(&token.CreatedAt),
+		ExpiresAt:      TimeToTimestamp(token.ExpiresAt),
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/rbac"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-access-server/proto/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// permissionRule returns the permission that's
+// required to make a request
+type permissionRule func(user *authsession.Identity, req interface{}) rbac.Permission
+
+func always(permission rbac.Permission) permissionRule {
+	return func(user *authsession.Identity, req interface{}) rbac.Permission {
+		return permission
+	}
+}
+
+// rpcPermissions is the permission that's required to call each rpc.
+// RPCs that aren't listed can't be called by anyone.
+var rpcPermissions = map[string]permissionRule{
+	"/proto.Server/Info":                always(rbac.ManageOwnDevices),
+	"/proto.Devices/AddDevice":          always(rbac.ManageOwnDevices),
+	"/proto.Devices/ListDevices":        always(rbac.ManageOwnDevices),
+	"/proto.Devices/ListAllDevices":     always(rbac.ReadAllDevices),
+	"/proto.Devices/SetDeviceBandwidth": always(rbac.ManageAllDevices),
+	"/proto.Devices/DeleteDevice": func(user *authsession.Identity, req interface{}) rbac.Permission {
+		if r, ok := req.(*proto.DeleteDeviceReq); ok && r.Owner != nil && r.Owner.Value != user.Subject {
+			return rbac.DeleteAllDevices
+		}
+		return rbac.ManageOwnDevices
+	},
+	"/proto.Devices/ListDeviceTraffic": func(user *authsession.Identity, req interface{}) rbac.Permission {
+		if r, ok := req.(*proto.ListDeviceTrafficReq); ok && r.Owner != nil && r.Owner.Value != user.Subject {
+			return rbac.ReadAllDevices
+		}
+		return rbac.ManageOwnDevices
+	},
+	"/proto.PortForwards/ListPortForwards":  always(rbac.ReadPortForwards),
+	"/proto.PortForwards/AddPortForward":    always(rbac.ManagePortForwards),
+	"/proto.PortForwards/DeletePortForward": always(rbac.ManagePortForwards),
+	"/proto.DNS/SearchQueries":              always(rbac.ReadDNSQueries),
+	"/proto.Sessions/ListSessions":          always(rbac.ReadSessions),
+	"/proto.Sessions/RevokeSessions":        always(rbac.RevokeSessions),
+	"/proto.ApiTokens/CreateApiToken": func(user *authsession.Identity, req interface{}) rbac.Permission {
+		if r, ok := req.(*proto.CreateApiTokenReq); ok {
+			if r.GetServiceAccount() {
+				return rbac.ManageAPITokens
+			}
+			for _, scope := range r.GetScopes() {
+				if scope == apitokens.ScopeAdmin || scope == apitokens.ScopeSCIM {
+					return rbac.ManageAPITokens
+				}
+			}
+		}
+		return rbac.ManageOwnDevices
+	},
+	"/proto.ApiTokens/ListApiTokens": func(user *authsession.Identity, req interface{}) rbac.Permission {
+		if r, ok := req.(*proto.ListApiTokensReq); ok && r.GetAll() {
+			return rbac.ReadAPITokens
+		}
+		return rbac.ManageOwnDevices
+	},
+	// revoking someone else's token is checked by the
+	// service because the owner isn't in the request
+	"/proto.ApiTokens/RevokeApiToken": always(rbac.ManageOwnDevices),
+}
+
+// Authorization is a grpc interceptor that checks that
+// the user's roles allow them to make a request.
+func Authorization(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := authsession.CurrentUser(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "not authenticated")
+	}
+
+	rule, ok := rpcPermissions[info.FullMethod]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "%s isn't allowed", info.FullMethod)
+	}
+	if permission := rule(user, req); !rbac.Can(user, permission) {
+		return nil, status.Errorf(codes.PermissionDenied, "missing the %s permission", permission)
+	}
+
+	return handler(ctx, req)
+}
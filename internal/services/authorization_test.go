@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/internal/rbac"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-access-server/proto/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRPCTables(t *testing.T) {
+	require := require.New(t)
+
+	server := grpc.NewServer()
+	registerServices(server, &ApiServices{})
+	methods := 0
+	for service, info := range server.GetServiceInfo() {
+		for _, method := range info.Methods {
+			name := "/" + service + "/" + method.Name
+			require.Contains(rpcPermissions, name)
+			require.Contains(rpcScopes, name)
+			methods++
+		}
+	}
+	require.Len(rpcPermissions, methods, "rpcPermissions has rpcs that don't exist")
+	require.Len(rpcScopes, methods, "rpcScopes has rpcs that don't exist")
+}
+
+func TestAuthorization(t *testing.T) {
+	require := require.New(t)
+
+	roles := map[string]*authsession.Identity{
+		rbac.RoleUser:     testIdentity(),
+		rbac.RoleAuditor:  testIdentity(rbac.RoleClaim, rbac.RoleAuditor),
+		rbac.RoleHelpdesk: testIdentity(rbac.RoleClaim, rbac.RoleHelpdesk),
+		rbac.RoleAdmin:    testIdentity("admin", "true"),
+	}
+	own := &wrappers.StringValue{Value: "alice"}
+	other := &wrappers.StringValue{Value: "bob"}
+
+	tests := []struct {
+		method  string
+		req     interface{}
+		allowed []string
+	}{
+		{"/proto.Devices/DeleteDevice", &proto.DeleteDeviceReq{Name: "laptop"}, []string{rbac.RoleUser, rbac.RoleAuditor, rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.Devices/DeleteDevice", &proto.DeleteDeviceReq{Name: "laptop", Owner: own}, []string{rbac.RoleUser, rbac.RoleAuditor, rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.Devices/DeleteDevice", &proto.DeleteDeviceReq{Name: "laptop", Owner: other}, []string{rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.Devices/ListDeviceTraffic", &proto.ListDeviceTrafficReq{}, []string{rbac.RoleUser, rbac.RoleAuditor, rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.Devices/ListDeviceTraffic", &proto.ListDeviceTrafficReq{Owner: own}, []string{rbac.RoleUser, rbac.RoleAuditor, rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.Devices/ListDeviceTraffic", &proto.ListDeviceTrafficReq{Owner: other}, []string{rbac.RoleAuditor, rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.ApiTokens/CreateApiToken", &proto.CreateApiTokenReq{Scopes: []string{apitokens.ScopeDevicesRead}}, []string{rbac.RoleUser, rbac.RoleAuditor, rbac.RoleHelpdesk, rbac.RoleAdmin}},
+		{"/proto.ApiTokens/CreateApiToken", &proto.CreateApiTokenReq{Scopes: []string{apitokens.ScopeDevicesRead, apitokens.ScopeAdmin}}, []string{rbac.RoleAdmin}},
+		{"/proto.ApiTokens/CreateApiToken", &proto.CreateApiTokenReq{Scopes: []string{apitokens.ScopeSCIM}}, []string{rbac.RoleAdmin}},
+		{"/proto.ApiTokens/CreateApiToken", &proto.CreateApiTokenReq{Scopes: []string{apitokens.ScopeDevicesRead}, ServiceAccount: true}, []string{rbac.RoleAdmin}},
+		{"/proto.Devices/SetDeviceBandwidth", &proto.SetDeviceBandwidthReq{}, []string{rbac.RoleAdmin}},
+		{"/proto.Unknown/Call", nil, []string{}},
+	}
+
+	for _, test := range tests {
+		for role, user := range roles {
+			_, err := callRPC(Authorization, user, test.method, test.req)
+			if contains(test.allowed, role) {
+				require.NoError(err, "%s %s %v", role, test.method, test.req)
+			} else {
+				require.Equal(codes.PermissionDenied, status.Code(err), "%s %s %v", role, test.method, test.req)
+			}
+		}
+	}
+}
+
+func TestApiTokenScopes(t *testing.T) {
+	require := require.New(t)
+
+	read := testIdentity(apitokens.ScopeClaim, apitokens.ScopeDevicesRead)
+	admin := testIdentity(apitokens.ScopeClaim, apitokens.ScopeAdmin, "admin", "true")
+
+	_, err := callRPC(ApiTokenScopes, read, "/proto.Devices/ListDevices", nil)
+	require.NoError(err)
+	_, err = callRPC(ApiTokenScopes, read, "/proto.Devices/AddDevice", nil)
+	require.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = callRPC(ApiTokenScopes, admin, "/proto.Sessions/RevokeSessions", nil)
+	require.NoError(err)
+	_, err = callRPC(ApiTokenScopes, admin, "/proto.ApiTokens/CreateApiToken", nil)
+	require.Equal(codes.PermissionDenied, status.Code(err), "tokens can't create tokens")
+	_, err = callRPC(ApiTokenScopes, admin, "/proto.Unknown/Call", nil)
+	require.Equal(codes.PermissionDenied, status.Code(err))
+
+	// sessions aren't limited by scopes
+	_, err = callRPC(ApiTokenScopes, testIdentity(), "/proto.ApiTokens/CreateApiToken", nil)
+	require.NoError(err)
+}
+
+// testIdentity creates alice with claims
+// given as pairs of names and values
+func testIdentity(claims ...string) *authsession.Identity {
+	identity := &authsession.Identity{Provider: "basic", Subject: "alice"}
+	for i := 0; i+1 < len(claims); i += 2 {
+		identity.Claims.Add(claims[i], claims[i+1])
+	}
+	return identity
+}
+
+func callRPC(interceptor grpc.UnaryServerInterceptor, user *authsession.Identity, method string, req interface{}) (interface{}, error) {
+	ctx := authsession.SetIdentityCtx(context.Background(), &authsession.AuthSession{Identity: user})
+	return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/place1/wg-access-server/pkg/authnz/authsession"
 
 	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/pkg/errors"
 	"github.com/place1/wg-access-server/internal/devices"
 	"github.com/place1/wg-access-server/internal/storage"
 	"github.com/place1/wg-access-server/proto/proto"
@@ -17,6 +18,7 @@ import (
 
 type DeviceService struct {
 	DeviceManager *devices.DeviceManager
+	Profiles      devices.Profiles
 }
 
 func (d *DeviceService) AddDevice(ctx context.Context, req *proto.AddDeviceReq) (*proto.Device, error) {
@@ -25,7 +27,18 @@ func (d *DeviceService) AddDevice(ctx context.Context, req *proto.AddDeviceReq)
 		return nil, status.Errorf(codes.PermissionDenied, "not authenticated")
 	}
 
-	device, err := d.DeviceManager.AddDevice(user, req.GetName(), req.GetPublicKey())
+	profile, err := d.Profiles.Get(req.GetProfile())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "vpn profile doesn't exist")
+	}
+	if !profile.Allows(user) {
+		return nil, status.Errorf(codes.PermissionDenied, "not allowed to use vpn profile")
+	}
+
+	device, err := profile.AddDevice(user, req.GetName(), req.GetPublicKey(), int(req.GetPersistentKeepalive()))
+	if errors.Cause(err) == devices.ErrUserDeprovisioned {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
 	if err != nil {
 		ctxlogrus.Extract(ctx).Error(err)
 		return nil, status.Errorf(codes.Internal, "failed to add device")
@@ -57,16 +70,17 @@ func (d *DeviceService) DeleteDevice(ctx context.Context, req *proto.DeleteDevic
 	}
 
 	deviceOwner := user.Subject
-
 	if req.Owner != nil {
-		if user.Claims.Contains("admin") {
-			deviceOwner = req.Owner.Value
-		} else {
-			return nil, status.Errorf(codes.PermissionDenied, "must be an admin")
-		}
+		deviceOwner = req.Owner.Value
+	}
+
+	profile, err := d.Profiles.ForDevice(deviceOwner, req.GetName())
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to delete device")
 	}
 
-	if err := d.DeviceManager.DeleteDevice(deviceOwner, req.GetName()); err != nil {
+	if err := profile.DeleteDevice(deviceOwner, req.GetName()); err != nil {
 		ctxlogrus.Extract(ctx).Error(err)
 		return nil, status.Errorf(codes.Internal, "failed to delete device")
 	}
@@ -75,26 +89,71 @@ func (d *DeviceService) DeleteDevice(ctx context.Context, req *proto.DeleteDevic
 }
 
 func (d *DeviceService) ListAllDevices(ctx context.Context, req *proto.ListAllDevicesReq) (*proto.ListAllDevicesRes, error) {
+	devices, err := d.DeviceManager.ListAllDevices()
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to retrieve devices")
+	}
+
+	return &proto.ListAllDevicesRes{
+		Items: mapDevices(devices),
+	}, nil
+}
+
+func (d *DeviceService) ListDeviceTraffic(ctx context.Context, req *proto.ListDeviceTrafficReq) (*proto.ListDeviceTrafficRes, error) {
 	user, err := authsession.CurrentUser(ctx)
 	if err != nil {
 		return nil, status.Errorf(codes.PermissionDenied, "not authenticated")
 	}
 
-	if !user.Claims.Contains("admin") {
-		return nil, status.Errorf(codes.PermissionDenied, "must be an admin")
+	owner := user.Subject
+	if req.Owner != nil {
+		owner = req.Owner.Value
 	}
 
-	devices, err := d.DeviceManager.ListAllDevices()
+	traffic, err := d.DeviceManager.ListDeviceTraffic(owner)
 	if err != nil {
 		ctxlogrus.Extract(ctx).Error(err)
-		return nil, status.Errorf(codes.Internal, "failed to retrieve devices")
+		return nil, status.Errorf(codes.Internal, "failed to retrieve device traffic")
 	}
 
-	return &proto.ListAllDevicesRes{
-		Items: mapDevices(devices),
+	items := []*proto.DeviceTraffic{}
+	for _, t := range traffic {
+		items = append(items, &proto.DeviceTraffic{
+			Owner:         t.Owner,
+			Name:          t.Name,
+			Destination:   t.Destination,
+			ReceiveBytes:  t.ReceiveBytes,
+			TransmitBytes: t.TransmitBytes,
+			UpdatedAt:     TimeToTimestamp(&t.UpdatedAt),
+		})
+	}
+
+	return &proto.ListDeviceTrafficRes{
+		Items: items,
 	}, nil
 }
 
+func (d *DeviceService) SetDeviceBandwidth(ctx context.Context, req *proto.SetDeviceBandwidthReq) (*proto.Device, error) {
+	// the limit is applied by the device's profile
+	profile, err := d.Profiles.ForDevice(req.GetOwner(), req.GetName())
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.NotFound, "device doesn't exist")
+	}
+
+	device, err := profile.SetBandwidthLimit(req.GetOwner(), req.GetName(), req.GetIngressLimit(), req.GetEgressLimit())
+	if errors.Cause(err) == devices.ErrInvalidBandwidthLimit {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to set device bandwidth")
+	}
+
+	return mapDevice(device), nil
+}
+
 func mapDevice(d *storage.Device) *proto.Device {
 	return &proto.Device{
 		Name:              d.Name,
@@ -109,6 +168,12 @@ func mapDevice(d *storage.Device) *proto.Device {
 		ReceiveBytes:      d.ReceiveBytes,
 		TransmitBytes:     d.TransmitBytes,
 		Endpoint:          d.Endpoint,
+		IngressLimit:      d.IngressLimit,
+		EgressLimit:       d.EgressLimit,
+
+		PersistentKeepalive: int32(d.PersistentKeepalive),
+		Profile:             d.Profile,
+		Disabled:            d.Disabled,
 		/**
 		 * Wireguard is a connectionless UDP protocol - data is only
 		 * sent over the wire when the client is sending real traffic.
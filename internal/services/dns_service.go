@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+
+	"github.com/place1/wg-access-server/internal/dnsproxy"
+	"github.com/place1/wg-access-server/proto/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the number of queries returned by
+// SearchQueries if the request has no limit
+const defaultQueryLimit = 100
+
+type DNSService struct {
+	// nil if query logging is disabled
+	QueryLog *dnsproxy.QueryLog
+}
+
+func (d *DNSService) SearchQueries(ctx context.Context, req *proto.SearchQueriesReq) (*proto.SearchQueriesRes, error) {
+	if d.QueryLog == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "dns query logging is disabled")
+	}
+
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+
+	entries := d.QueryLog.Search(dnsproxy.QueryLogFilter{
+		Owner:  req.GetOwner(),
+		Device: req.GetDeviceName(),
+		Domain: req.GetDomain(),
+		Limit:  limit,
+	})
+
+	items := []*proto.DNSQuery{}
+	for _, entry := range entries {
+		items = append(items, mapDNSQuery(entry))
+	}
+
+	return &proto.SearchQueriesRes{
+		Items: items,
+	}, nil
+}
+
+func mapDNSQuery(entry dnsproxy.QueryLogEntry) *proto.DNSQuery {
+	return &proto.DNSQuery{
+		Time:       TimeToTimestamp(&entry.Time),
+		ClientIp:   entry.ClientIP,
+		Owner:      entry.Owner,
+		DeviceName: entry.Device,
+		Name:       entry.Name,
+		Type:       entry.Type,
+		Rcode:      entry.Rcode,
+		AnsweredBy: entry.AnsweredBy,
+		BlockedBy:  entry.BlockedBy,
+		DurationMs: entry.Duration.Milliseconds(),
+	}
+}
@@ -5,9 +5,34 @@ import (
 	"net/http"
 )
 
-func HealthEndpoint() http.Handler {
+// HealthCheck reports the status of a subsystem.
+// Check returns nil if the subsystem is healthy.
+type HealthCheck struct {
+	Name  string
+	Check func() error
+}
+
+func HealthEndpoint(checks ...HealthCheck) http.Handler {
 	return http.HandlerFunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(200)
-		fmt.Fprintf(w, "ok")
+		status := http.StatusOK
+		results := []string{}
+		for _, check := range checks {
+			if err := check.Check(); err != nil {
+				status = http.StatusServiceUnavailable
+				results = append(results, fmt.Sprintf("%s: %s", check.Name, err))
+			} else {
+				results = append(results, fmt.Sprintf("%s: ok", check.Name))
+			}
+		}
+
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			fmt.Fprintf(w, "ok")
+		} else {
+			fmt.Fprintf(w, "unhealthy")
+		}
+		for _, result := range results {
+			fmt.Fprintf(w, "\n%s", result)
+		}
 	}))
 }
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/devices"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/proto/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PortForwardService struct {
+	DeviceManager *devices.DeviceManager
+	Profiles      devices.Profiles
+}
+
+func (p *PortForwardService) AddPortForward(ctx context.Context, req *proto.AddPortForwardReq) (*proto.PortForward, error) {
+	pf := &storage.PortForward{
+		Protocol:   req.GetProtocol(),
+		PublicPort: int(req.GetPublicPort()),
+		Owner:      req.GetOwner(),
+		DeviceName: req.GetDeviceName(),
+		DevicePort: int(req.GetDevicePort()),
+	}
+
+	// the rule is applied by the device's profile
+	profile, err := p.Profiles.ForDevice(pf.Owner, pf.DeviceName)
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.NotFound, "device doesn't exist")
+	}
+
+	if err := profile.AddPortForward(pf); err != nil {
+		switch errors.Cause(err) {
+		case devices.ErrInvalidPortForward:
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case devices.ErrPortForwardExists:
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to add port forward")
+	}
+
+	return mapPortForward(pf), nil
+}
+
+func (p *PortForwardService) ListPortForwards(ctx context.Context, req *proto.ListPortForwardsReq) (*proto.ListPortForwardsRes, error) {
+	pfs, err := p.DeviceManager.ListPortForwards()
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to retrieve port forwards")
+	}
+
+	items := []*proto.PortForward{}
+	for _, pf := range pfs {
+		items = append(items, mapPortForward(pf))
+	}
+
+	return &proto.ListPortForwardsRes{
+		Items: items,
+	}, nil
+}
+
+func (p *PortForwardService) DeletePortForward(ctx context.Context, req *proto.DeletePortForwardReq) (*empty.Empty, error) {
+	if err := p.profileFor(req.GetProtocol(), int(req.GetPublicPort())).DeletePortForward(req.GetProtocol(), int(req.GetPublicPort())); err != nil {
+		if errors.Cause(err) == devices.ErrPortForwardNotFound {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to delete port forward")
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// profileFor returns the DeviceManager that applies a port forward.
+// The default profile is returned if the rule or its device doesn't exist.
+func (p *PortForwardService) profileFor(protocol string, publicPort int) *devices.DeviceManager {
+	pfs, err := p.DeviceManager.ListPortForwards()
+	if err != nil {
+		return p.DeviceManager
+	}
+	for _, pf := range pfs {
+		if pf.Protocol == strings.ToLower(protocol) && pf.PublicPort == publicPort {
+			if profile, err := p.Profiles.ForDevice(pf.Owner, pf.DeviceName); err == nil {
+				return profile
+			}
+		}
+	}
+	return p.DeviceManager
+}
+
+func mapPortForward(pf *storage.PortForward) *proto.PortForward {
+	return &proto.PortForward{
+		Protocol:   pf.Protocol,
+		PublicPort: int32(pf.PublicPort),
+		Owner:      pf.Owner,
+		DeviceName: pf.DeviceName,
+		DevicePort: int32(pf.DevicePort),
+		CreatedAt:  TimeToTimestamp(&pf.CreatedAt),
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+
+	"github.com/place1/wg-access-server/internal/apitokens"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rpcScopes is the api token scope that's required to call each rpc.
+// RPCs without a scope can't be called with an api token.
+var rpcScopes = map[string]string{
+	"/proto.Server/Info":                    apitokens.ScopeDevicesRead,
+	"/proto.Devices/ListDevices":            apitokens.ScopeDevicesRead,
+	"/proto.Devices/ListDeviceTraffic":      apitokens.ScopeDevicesRead,
+	"/proto.Devices/AddDevice":              apitokens.ScopeDevicesWrite,
+	"/proto.Devices/DeleteDevice":           apitokens.ScopeDevicesWrite,
+	"/proto.Devices/ListAllDevices":         apitokens.ScopeAdmin,
+	"/proto.Devices/SetDeviceBandwidth":     apitokens.ScopeAdmin,
+	"/proto.PortForwards/AddPortForward":    apitokens.ScopeAdmin,
+	"/proto.PortForwards/ListPortForwards":  apitokens.ScopeAdmin,
+	"/proto.PortForwards/DeletePortForward": apitokens.ScopeAdmin,
+	"/proto.DNS/SearchQueries":              apitokens.ScopeAdmin,
+	"/proto.Sessions/ListSessions":          apitokens.ScopeAdmin,
+	"/proto.Sessions/RevokeSessions":        apitokens.ScopeAdmin,
+	// tokens can't be used to create other tokens
+	"/proto.ApiTokens/CreateApiToken": "",
+	"/proto.ApiTokens/ListApiTokens":  "",
+	"/proto.ApiTokens/RevokeApiToken": "",
+}
+
+// ApiTokenScopes is a grpc interceptor that only allows requests
+// authenticated with an api token to call the RPCs that are
+// allowed by the token's scopes.
+func ApiTokenScopes(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := authsession.CurrentUser(ctx)
+	if err != nil || !apitokens.IsToken(user) {
+		return handler(ctx, req)
+	}
+
+	scope, ok := rpcScopes[info.FullMethod]
+	if !ok || scope == "" {
+		return nil, status.Errorf(codes.PermissionDenied, "api tokens can't call %s", info.FullMethod)
+	}
+	if !apitokens.HasScope(user, scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "api token is missing the %s scope", scope)
+	}
+
+	return handler(ctx, req)
+}
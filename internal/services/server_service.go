@@ -2,12 +2,16 @@ package services
 
 import (
 	"context"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
 	"github.com/place1/wg-access-server/internal/network"
 
 	"github.com/place1/wg-access-server/internal/config"
+	"github.com/place1/wg-access-server/internal/devices"
+	"github.com/place1/wg-access-server/internal/rbac"
 	"github.com/place1/wg-access-server/pkg/authnz/authsession"
 	"github.com/place1/wg-access-server/proto/proto"
 	"github.com/place1/wg-embed/pkg/wgembed"
@@ -16,8 +20,9 @@ import (
 )
 
 type ServerService struct {
-	Config *config.AppConfig
-	Wg     wgembed.WireGuardInterface
+	Config   *config.AppConfig
+	Profiles devices.Profiles
+	Wg       wgembed.WireGuardInterface
 }
 
 func (s *ServerService) Info(ctx context.Context, req *proto.InfoReq) (*proto.InfoRes, error) {
@@ -42,9 +47,63 @@ func (s *ServerService) Info(ctx context.Context, req *proto.InfoReq) (*proto.In
 		AllowedIps:      allowedIPs(s.Config),
 		DnsEnabled:      s.Config.DNS.Enabled,
 		DnsAddress:      network.ServerVPNIP(s.Config.VPN.CIDR).IP.String(),
+
+		Mtu:                 int32(s.Config.WireGuard.MTU),
+		PersistentKeepalive: int32(s.Config.WireGuard.PersistentKeepalive),
+		Endpoint:            s.Config.WireGuard.Endpoint,
+		Profiles:            s.profiles(user),
+		Permissions:         permissions(user),
 	}, nil
 }
 
+// profiles returns the additional vpn profiles that a user may use
+func (s *ServerService) profiles(user *authsession.Identity) []*proto.VPNProfile {
+	items := []*proto.VPNProfile{}
+	for _, d := range s.Profiles.Allowed(user) {
+		p := d.ProfileConfig()
+		if p.Name == "" {
+			continue
+		}
+		vpnip := network.ServerVPNIP(p.CIDR).IP.String()
+		profile := &proto.VPNProfile{
+			Name:       p.Name,
+			Port:       int32(p.Port),
+			HostVpnIp:  vpnip,
+			AllowedIps: strings.Join(p.AllowedIPs, ", "),
+			DnsEnabled: s.Config.DNS.Enabled,
+			DnsAddress: vpnip,
+			Endpoint:   profileEndpoint(s.Config, p),
+		}
+		if len(p.DNS.Servers) > 0 {
+			profile.DnsEnabled = true
+			profile.DnsAddress = strings.Join(p.DNS.Servers, ", ")
+		}
+		items = append(items, profile)
+	}
+	return items
+}
+
+// profileEndpoint returns the host:port that clients of a
+// profile connect to. An empty string means clients should
+// use the web ui's host and the profile's port.
+func profileEndpoint(conf *config.AppConfig, p config.Profile) string {
+	if p.Endpoint != "" {
+		return p.Endpoint
+	}
+	if host, _, err := net.SplitHostPort(conf.WireGuard.Endpoint); err == nil {
+		return net.JoinHostPort(host, strconv.Itoa(p.Port))
+	}
+	return ""
+}
+
+func permissions(user *authsession.Identity) []string {
+	items := []string{}
+	for _, p := range rbac.Permissions(user) {
+		items = append(items, string(p))
+	}
+	return items
+}
+
 func allowedIPs(config *config.AppConfig) string {
 	return strings.Join(config.VPN.AllowedIPs, ", ")
 }
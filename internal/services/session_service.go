@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/place1/wg-access-server/proto/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type SessionService struct {
+	// nil if server-side sessions are disabled
+	Storage storage.Storage
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, req *proto.ListSessionsReq) (*proto.ListSessionsRes, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.Storage.ListSessions(req.GetOwner())
+	if err != nil {
+		ctxlogrus.Extract(ctx).Error(err)
+		return nil, status.Errorf(codes.Internal, "failed to retrieve sessions")
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	now := time.Now()
+	items := []*proto.Session{}
+	for _, session := range sessions {
+		// sessions without an owner haven't finished signing in
+		if session.Owner == "" || !now.Before(session.ExpiresAt) {
+			continue
+		}
+		items = append(items, mapSession(session))
+	}
+
+	return &proto.ListSessionsRes{
+		Items: items,
+	}, nil
+}
+
+func (s *SessionService) RevokeSessions(ctx context.Context, req *proto.RevokeSessionsReq) (*empty.Empty, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.GetOwner() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "owner is required")
+	}
+
+	sessions := []*storage.Session{}
+	if req.GetId() != "" {
+		session, err := s.Storage.GetSession(req.GetId())
+		if err != nil || session.Owner != req.GetOwner() {
+			return nil, status.Errorf(codes.NotFound, "session doesn't exist")
+		}
+		sessions = append(sessions, session)
+	} else {
+		owned, err := s.Storage.ListSessions(req.GetOwner())
+		if err != nil {
+			ctxlogrus.Extract(ctx).Error(err)
+			return nil, status.Errorf(codes.Internal, "failed to retrieve sessions")
+		}
+		sessions = owned
+	}
+
+	for _, session := range sessions {
+		if err := s.Storage.DeleteSession(session); err != nil {
+			ctxlogrus.Extract(ctx).Error(err)
+			return nil, status.Errorf(codes.Internal, "failed to revoke session")
+		}
+	}
+	ctxlogrus.Extract(ctx).Infof("revoked %d session(s) of %s", len(sessions), req.GetOwner())
+
+	return &empty.Empty{}, nil
+}
+
+func (s *SessionService) check(ctx context.Context) error {
+	if s.Storage == nil {
+		return status.Errorf(codes.FailedPrecondition, "server-side sessions are disabled")
+	}
+	return nil
+}
+
+func mapSession(session *storage.Session) *proto.Session {
+	return &proto.Session{
+		Id:        session.ID,
+		Owner:     session.Owner,
+		OwnerName: session.OwnerName,
+		Provider:  session.Provider,
+		CreatedAt: TimeToTimestamp(&session.CreatedAt),
+		ExpiresAt: TimeToTimestamp(&session.ExpiresAt),
+	}
+}
@@ -12,10 +12,44 @@ import (
 type Storage interface {
 	Watcher
 	Save(device *Device) error
+	// SaveDeviceMetadata only writes the metadata fields of a
+	// device so that it doesn't overwrite changes made since
+	// the device was read i.e. disabling it
+	SaveDeviceMetadata(device *Device) error
 	List(owner string) ([]*Device, error)
 	Get(owner string, name string) (*Device, error)
 	GetByPublicKey(publicKey string) (*Device, error)
 	Delete(device *Device) error
+	SavePortForward(pf *PortForward) error
+	ListPortForwards() ([]*PortForward, error)
+	DeletePortForward(pf *PortForward) error
+	SaveDeviceTraffic(t *DeviceTraffic) error
+	ListDeviceTraffic(owner string) ([]*DeviceTraffic, error)
+	DeleteDeviceTraffic(device *Device) error
+	SaveSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	ListSessions(owner string) ([]*Session, error)
+	DeleteSession(session *Session) error
+	DeleteExpiredSessions(now time.Time) error
+	SaveAPIToken(token *APIToken) error
+	GetAPIToken(id string) (*APIToken, error)
+	GetAPITokenByHash(hash string) (*APIToken, error)
+	ListAPITokens(owner string) ([]*APIToken, error)
+	DeleteAPIToken(token *APIToken) error
+	SaveUser(user *User) error
+	GetUser(provider string, subject string) (*User, error)
+	ListUsers() ([]*User, error)
+	SaveSCIMUser(user *SCIMUser) error
+	GetSCIMUser(id string) (*SCIMUser, error)
+	ListSCIMUsers() ([]*SCIMUser, error)
+	DeleteSCIMUser(user *SCIMUser) error
+	SaveSCIMGroup(group *SCIMGroup) error
+	GetSCIMGroup(id string) (*SCIMGroup, error)
+	ListSCIMGroups() ([]*SCIMGroup, error)
+	DeleteSCIMGroup(group *SCIMGroup) error
+	SaveSecondFactor(factor *SecondFactor) error
+	GetSecondFactor(provider string, subject string) (*SecondFactor, error)
+	DeleteSecondFactor(factor *SecondFactor) error
 	Close() error
 	Open() error
 }
@@ -40,6 +74,27 @@ type Device struct {
 	Address       string    `json:"address"`
 	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
 
+	// the name of the VPN profile that the device
+	// belongs to. empty for the default profile.
+	Profile string `json:"profile" gorm:"type:varchar(100)"`
+
+	// keepalive interval in seconds for the device's
+	// config file. 0 uses the server's default.
+	PersistentKeepalive int `json:"persistent_keepalive"`
+
+	// bandwidth limits in bits per second (0 is unlimited)
+	IngressLimit uint64 `json:"ingress_limit"`
+	EgressLimit  uint64 `json:"egress_limit"`
+
+	// DNS queries from the device bypass the
+	// DNS blocklists. Decided by the owner's
+	// claims when the device is added.
+	DNSFilterExempt bool `json:"dns_filter_exempt"`
+
+	// Disabled devices aren't peers of the wireguard
+	// interface i.e. because their owner was deprovisioned
+	Disabled bool `json:"disabled"`
+
 	/**
 	 * Metadata fields below.
 	 * All metadata tracking can be disabled
@@ -53,6 +108,139 @@ type Device struct {
 	Endpoint          string     `json:"endpoint"`
 }
 
+// PortForward forwards traffic arriving on a public port
+// of the server to a port on a VPN device.
+type PortForward struct {
+	Protocol   string    `json:"protocol" gorm:"type:varchar(10);primary_key"`
+	PublicPort int       `json:"public_port" gorm:"primary_key;AUTO_INCREMENT:false"`
+	Owner      string    `json:"owner" gorm:"type:varchar(100)"`
+	DeviceName string    `json:"device_name" gorm:"type:varchar(100)"`
+	DevicePort int       `json:"device_port"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// DeviceTraffic is the total amount of traffic a device
+// has exchanged with one of the VPN's allowed networks.
+type DeviceTraffic struct {
+	Owner         string    `json:"owner" gorm:"type:varchar(100);primary_key"`
+	Name          string    `json:"name" gorm:"type:varchar(100);primary_key"`
+	Destination   string    `json:"destination" gorm:"type:varchar(100);primary_key"`
+	ReceiveBytes  int64     `json:"receive_bytes"`
+	TransmitBytes int64     `json:"transmit_bytes"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// Session is a login session that's stored server-side.
+// Sessions without an owner are logins that are in progress.
+type Session struct {
+	// a hash of the session's cookie
+	ID        string    `json:"id" gorm:"type:varchar(64);primary_key"`
+	Owner     string    `json:"owner" gorm:"type:varchar(100);index"`
+	OwnerName string    `json:"owner_name"`
+	Provider  string    `json:"provider"`
+	Data      []byte    `json:"data"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// APIToken authenticates scripts with the API.
+// Only a hash of the token is stored.
+type APIToken struct {
+	ID   string `json:"id" gorm:"type:varchar(36);primary_key"`
+	Hash string `json:"hash" gorm:"type:varchar(64);unique_index"`
+	Name string `json:"name"`
+	// the user that the token acts as
+	Owner         string `json:"owner" gorm:"type:varchar(100);index"`
+	OwnerName     string `json:"owner_name"`
+	OwnerEmail    string `json:"owner_email"`
+	OwnerProvider string `json:"owner_provider"`
+	// OwnerEmailVerified is copied from the owner's identity
+	OwnerEmailVerified bool `json:"owner_email_verified"`
+	// service account tokens aren't owned by a person
+	ServiceAccount bool `json:"service_account"`
+	// the user that created the token
+	CreatedBy         string `json:"created_by" gorm:"type:varchar(100);index"`
+	CreatedByProvider string `json:"created_by_provider"`
+	// space separated
+	Scopes    string     `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// User is someone that has signed in. Users are recorded so
+// that their devices can be deactivated when they're removed
+// from their identity provider.
+type User struct {
+	Provider string `json:"provider" gorm:"type:varchar(100);primary_key"`
+	Subject  string `json:"subject" gorm:"type:varchar(100);primary_key"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	// EmailVerified is true if the identity provider
+	// verified the email address
+	EmailVerified bool `json:"email_verified"`
+	// the encrypted refresh token of the user's latest session
+	RefreshToken []byte `json:"refresh_token"`
+	// the last time the user signed in or refreshed their session
+	LastSeen time.Time `json:"last_seen"`
+	// the last time the user was checked with their identity provider
+	CheckedAt *time.Time `json:"checked_at"`
+	// set when the identity provider reports that
+	// the user has been disabled or deleted
+	Deprovisioned bool `json:"deprovisioned"`
+}
+
+// SCIMUser is a user that an identity provider
+// provisioned with SCIM
+type SCIMUser struct {
+	ID         string `json:"id" gorm:"type:varchar(36);primary_key"`
+	UserName   string `json:"user_name" gorm:"type:varchar(255);index"`
+	ExternalID string `json:"external_id"`
+	// DisplayName, GivenName and FamilyName may be empty
+	DisplayName string    `json:"display_name"`
+	GivenName   string    `json:"given_name"`
+	FamilyName  string    `json:"family_name"`
+	Email       string    `json:"email"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// SCIMGroup is a group that an identity provider
+// provisioned with SCIM
+type SCIMGroup struct {
+	ID          string `json:"id" gorm:"type:varchar(36);primary_key"`
+	DisplayName string `json:"display_name" gorm:"type:varchar(255);index"`
+	ExternalID  string `json:"external_id"`
+	// the ids of the group's users, space separated
+	Members   string    `json:"members" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// ErrNotFound is returned by GetSecondFactor if a user has no
+// second factor so that it can be told apart from storage errors
+var ErrNotFound = errors.New("not found")
+
+// SecondFactor is the TOTP second factor of a user
+type SecondFactor struct {
+	Provider string `json:"provider" gorm:"type:varchar(100);primary_key"`
+	Subject  string `json:"subject" gorm:"type:varchar(100);primary_key"`
+	// the TOTP secret encrypted with the session secret
+	Secret []byte `json:"secret"`
+	// Confirmed is false until the user enters
+	// a code from their authenticator app
+	Confirmed bool `json:"confirmed"`
+	// sha256 hashes of the unused recovery codes, space separated
+	RecoveryCodes string `json:"recovery_codes" gorm:"type:text"`
+	// the time step of the last accepted code
+	// so that codes can't be used twice
+	LastStep int64 `json:"last_step"`
+	// wrong codes since the last accepted code
+	Failures    int        `json:"failures"`
+	LockedUntil *time.Time `json:"locked_until"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
 func NewStorage(uri string) (Storage, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
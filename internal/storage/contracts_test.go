@@ -2,6 +2,7 @@ package storage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -59,3 +60,37 @@ func TestUnknownStorage(t *testing.T) {
 	require.Error(err)
 	require.Equal(err.Error(), "unknown storage backend foo")
 }
+
+func TestSaveDeviceMetadata(t *testing.T) {
+	require := require.New(t)
+
+	s := NewMemoryStorage()
+	require.NoError(s.Save(&Device{Owner: "alice", Name: "laptop", PublicKey: "key"}))
+	read, err := s.Get("alice", "laptop")
+	require.NoError(err)
+	stale := *read
+
+	// the device is disabled after the metadata sync read it
+	disabled := *read
+	disabled.Disabled = true
+	disabled.IngressLimit = 1000
+	require.NoError(s.Save(&disabled))
+
+	handshake := time.Now()
+	stale.Endpoint = "1.2.3.4"
+	stale.ReceiveBytes = 10
+	stale.TransmitBytes = 20
+	stale.LastHandshakeTime = &handshake
+	require.NoError(s.SaveDeviceMetadata(&stale))
+
+	device, err := s.Get("alice", "laptop")
+	require.NoError(err)
+	require.True(device.Disabled)
+	require.Equal(uint64(1000), device.IngressLimit)
+	require.Equal("1.2.3.4", device.Endpoint)
+	require.Equal(int64(10), device.ReceiveBytes)
+	require.Equal(int64(20), device.TransmitBytes)
+	require.Equal(&handshake, device.LastHandshakeTime)
+
+	require.Error(s.SaveDeviceMetadata(&Device{Owner: "bob", Name: "laptop"}))
+}
@@ -3,12 +3,37 @@ package storage
 import (
 	"errors"
 	"strings"
+	"sync"
+	"time"
 )
 
 // implements Storage interface
 type InMemoryStorage struct {
 	*InProcessWatcher
-	db map[string]*Device
+	db           map[string]*Device
+	portForwards map[string]*PortForward
+	traffic      map[string]*DeviceTraffic
+
+	// sessions are saved concurrently by http handlers
+	sessionsLock sync.Mutex
+	sessions     map[string]*Session
+
+	// api tokens are read concurrently by http handlers
+	tokensLock sync.Mutex
+	tokens     map[string]*APIToken
+
+	// users are saved concurrently by http handlers
+	usersLock sync.Mutex
+	users     map[string]*User
+
+	// scim users and groups are written by the scim endpoint
+	// and read by http handlers
+	scimLock   sync.Mutex
+	scimUsers  map[string]*SCIMUser
+	scimGroups map[string]*SCIMGroup
+
+	factorsLock sync.Mutex
+	factors     map[string]*SecondFactor
 }
 
 func NewMemoryStorage() *InMemoryStorage {
@@ -16,6 +41,14 @@ func NewMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
 		InProcessWatcher: NewInProcessWatcher(),
 		db:               db,
+		portForwards:     make(map[string]*PortForward),
+		traffic:          make(map[string]*DeviceTraffic),
+		sessions:         make(map[string]*Session),
+		tokens:           make(map[string]*APIToken),
+		users:            make(map[string]*User),
+		scimUsers:        make(map[string]*SCIMUser),
+		scimGroups:       make(map[string]*SCIMGroup),
+		factors:          make(map[string]*SecondFactor),
 	}
 }
 
@@ -33,6 +66,20 @@ func (s *InMemoryStorage) Save(device *Device) error {
 	return nil
 }
 
+func (s *InMemoryStorage) SaveDeviceMetadata(device *Device) error {
+	stored, ok := s.db[key(device)]
+	if !ok {
+		return errors.New("device doesn't exist")
+	}
+	updated := *stored
+	updated.LastHandshakeTime = device.LastHandshakeTime
+	updated.ReceiveBytes = device.ReceiveBytes
+	updated.TransmitBytes = device.TransmitBytes
+	updated.Endpoint = device.Endpoint
+	s.db[key(device)] = &updated
+	return nil
+}
+
 func (s *InMemoryStorage) List(username string) ([]*Device, error) {
 	devices := []*Device{}
 	prefix := func() string {
@@ -75,3 +122,274 @@ func (s *InMemoryStorage) Delete(device *Device) error {
 	s.EmitDelete(device)
 	return nil
 }
+
+func (s *InMemoryStorage) SavePortForward(pf *PortForward) error {
+	s.portForwards[portForwardKey(pf)] = pf
+	return nil
+}
+
+func (s *InMemoryStorage) ListPortForwards() ([]*PortForward, error) {
+	pfs := []*PortForward{}
+	for _, pf := range s.portForwards {
+		pfs = append(pfs, pf)
+	}
+	return pfs, nil
+}
+
+func (s *InMemoryStorage) DeletePortForward(pf *PortForward) error {
+	delete(s.portForwards, portForwardKey(pf))
+	return nil
+}
+
+func (s *InMemoryStorage) SaveDeviceTraffic(t *DeviceTraffic) error {
+	s.traffic[deviceTrafficKey(t)] = t
+	return nil
+}
+
+func (s *InMemoryStorage) ListDeviceTraffic(owner string) ([]*DeviceTraffic, error) {
+	items := []*DeviceTraffic{}
+	for _, t := range s.traffic {
+		if owner == "" || t.Owner == owner {
+			items = append(items, t)
+		}
+	}
+	return items, nil
+}
+
+func (s *InMemoryStorage) DeleteDeviceTraffic(device *Device) error {
+	for k, t := range s.traffic {
+		if t.Owner == device.Owner && t.Name == device.Name {
+			delete(s.traffic, k)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) SaveSession(session *Session) error {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *InMemoryStorage) GetSession(id string) (*Session, error) {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, errors.New("session doesn't exist")
+	}
+	return session, nil
+}
+
+func (s *InMemoryStorage) ListSessions(owner string) ([]*Session, error) {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	items := []*Session{}
+	for _, session := range s.sessions {
+		if owner == "" || session.Owner == owner {
+			items = append(items, session)
+		}
+	}
+	return items, nil
+}
+
+func (s *InMemoryStorage) DeleteSession(session *Session) error {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	delete(s.sessions, session.ID)
+	return nil
+}
+
+func (s *InMemoryStorage) DeleteExpiredSessions(now time.Time) error {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	for id, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) SaveAPIToken(token *APIToken) error {
+	s.tokensLock.Lock()
+	defer s.tokensLock.Unlock()
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	s.tokens[token.ID] = token
+	return nil
+}
+
+func (s *InMemoryStorage) GetAPIToken(id string) (*APIToken, error) {
+	s.tokensLock.Lock()
+	defer s.tokensLock.Unlock()
+	token, ok := s.tokens[id]
+	if !ok {
+		return nil, errors.New("api token doesn't exist")
+	}
+	return token, nil
+}
+
+func (s *InMemoryStorage) GetAPITokenByHash(hash string) (*APIToken, error) {
+	s.tokensLock.Lock()
+	defer s.tokensLock.Unlock()
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return nil, errors.New("api token doesn't exist")
+}
+
+func (s *InMemoryStorage) ListAPITokens(owner string) ([]*APIToken, error) {
+	s.tokensLock.Lock()
+	defer s.tokensLock.Unlock()
+	items := []*APIToken{}
+	for _, token := range s.tokens {
+		if owner == "" || token.Owner == owner {
+			items = append(items, token)
+		}
+	}
+	return items, nil
+}
+
+func (s *InMemoryStorage) DeleteAPIToken(token *APIToken) error {
+	s.tokensLock.Lock()
+	defer s.tokensLock.Unlock()
+	delete(s.tokens, token.ID)
+	return nil
+}
+
+func (s *InMemoryStorage) SaveUser(user *User) error {
+	s.usersLock.Lock()
+	defer s.usersLock.Unlock()
+	s.users[keyStr(user.Provider, user.Subject)] = user
+	return nil
+}
+
+func (s *InMemoryStorage) GetUser(provider string, subject string) (*User, error) {
+	s.usersLock.Lock()
+	defer s.usersLock.Unlock()
+	user, ok := s.users[keyStr(provider, subject)]
+	if !ok {
+		return nil, errors.New("user doesn't exist")
+	}
+	return user, nil
+}
+
+func (s *InMemoryStorage) ListUsers() ([]*User, error) {
+	s.usersLock.Lock()
+	defer s.usersLock.Unlock()
+	items := []*User{}
+	for _, user := range s.users {
+		items = append(items, user)
+	}
+	return items, nil
+}
+
+func (s *InMemoryStorage) SaveSCIMUser(user *SCIMUser) error {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	s.scimUsers[user.ID] = user
+	return nil
+}
+
+func (s *InMemoryStorage) GetSCIMUser(id string) (*SCIMUser, error) {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	user, ok := s.scimUsers[id]
+	if !ok {
+		return nil, errors.New("scim user doesn't exist")
+	}
+	return user, nil
+}
+
+func (s *InMemoryStorage) ListSCIMUsers() ([]*SCIMUser, error) {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	items := []*SCIMUser{}
+	for _, user := range s.scimUsers {
+		items = append(items, user)
+	}
+	return items, nil
+}
+
+func (s *InMemoryStorage) DeleteSCIMUser(user *SCIMUser) error {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	delete(s.scimUsers, user.ID)
+	return nil
+}
+
+func (s *InMemoryStorage) SaveSCIMGroup(group *SCIMGroup) error {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	now := time.Now()
+	if group.CreatedAt.IsZero() {
+		group.CreatedAt = now
+	}
+	group.UpdatedAt = now
+	s.scimGroups[group.ID] = group
+	return nil
+}
+
+func (s *InMemoryStorage) GetSCIMGroup(id string) (*SCIMGroup, error) {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	group, ok := s.scimGroups[id]
+	if !ok {
+		return nil, errors.New("scim group doesn't exist")
+	}
+	return group, nil
+}
+
+func (s *InMemoryStorage) ListSCIMGroups() ([]*SCIMGroup, error) {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	items := []*SCIMGroup{}
+	for _, group := range s.scimGroups {
+		items = append(items, group)
+	}
+	return items, nil
+}
+
+func (s *InMemoryStorage) DeleteSCIMGroup(group *SCIMGroup) error {
+	s.scimLock.Lock()
+	defer s.scimLock.Unlock()
+	delete(s.scimGroups, group.ID)
+	return nil
+}
+
+func (s *InMemoryStorage) SaveSecondFactor(factor *SecondFactor) error {
+	s.factorsLock.Lock()
+	defer s.factorsLock.Unlock()
+	s.factors[keyStr(factor.Provider, factor.Subject)] = factor
+	return nil
+}
+
+func (s *InMemoryStorage) GetSecondFactor(provider string, subject string) (*SecondFactor, error) {
+	s.factorsLock.Lock()
+	defer s.factorsLock.Unlock()
+	factor, ok := s.factors[keyStr(provider, subject)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return factor, nil
+}
+
+func (s *InMemoryStorage) DeleteSecondFactor(factor *SecondFactor) error {
+	s.factorsLock.Lock()
+	defer s.factorsLock.Unlock()
+	delete(s.factors, keyStr(factor.Provider, factor.Subject))
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/sirupsen/logrus"
+)
+
+// how often expired sessions are deleted
+const sessionCleanupInterval = time.Hour
+
+// SessionBackend stores login sessions in a storage backend
+// so that they survive restarts and are shared by replicas.
+// Sessions are encrypted because they contain the users'
+// refresh tokens.
+type SessionBackend struct {
+	storage Storage
+	box     *secrets.Box
+}
+
+// NewSessionBackend creates a SessionBackend and
+// periodically deletes expired sessions.
+func NewSessionBackend(s Storage, box *secrets.Box) *SessionBackend {
+	go func() {
+		for range time.Tick(sessionCleanupInterval) {
+			if err := s.DeleteExpiredSessions(time.Now()); err != nil {
+				logrus.Warn(err)
+			}
+		}
+	}()
+	return &SessionBackend{storage: s, box: box}
+}
+
+func (b *SessionBackend) SaveSession(id string, s *authsession.AuthSession, expires time.Time) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal session")
+	}
+	sealed, err := b.box.Seal(data, sessionContext(id))
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt session")
+	}
+	session := &Session{
+		ID:        id,
+		Data:      sealed,
+		ExpiresAt: expires,
+	}
+	// the session is saved again when it's refreshed
+	if existing, err := b.storage.GetSession(id); err == nil {
+		session.CreatedAt = existing.CreatedAt
+	}
+	if s.Identity != nil {
+		session.Owner = s.Identity.Subject
+		session.OwnerName = s.Identity.Name
+		session.Provider = s.Identity.Provider
+	}
+	return b.storage.SaveSession(session)
+}
+
+func (b *SessionBackend) LoadSession(id string) (*authsession.AuthSession, error) {
+	session, err := b.storage.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, errors.New("session expired")
+	}
+	data, err := b.box.Open(session.Data, sessionContext(id))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt session")
+	}
+	s := &authsession.AuthSession{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrap(err, "failed to parse session")
+	}
+	return s, nil
+}
+
+func (b *SessionBackend) DeleteSession(id string) error {
+	return b.storage.DeleteSession(&Session{ID: id})
+}
+
+func sessionContext(id string) string {
+	return "session/" + id
+}
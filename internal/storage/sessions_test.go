@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionBackend(t *testing.T) {
+	require := require.New(t)
+
+	s := NewMemoryStorage()
+	box, err := secrets.New("secret")
+	require.NoError(err)
+	backend := NewSessionBackend(s, box)
+
+	require.NoError(backend.SaveSession("a", &authsession.AuthSession{
+		Identity: &authsession.Identity{Subject: "alice", Name: "Alice", Provider: "ldap"},
+		Refresh:  &authsession.Refresh{Provider: "OIDC", Token: "refresh-token"},
+	}, time.Now().Add(time.Hour)))
+	require.NoError(backend.SaveSession("b", &authsession.AuthSession{
+		Identity: &authsession.Identity{Subject: "bob"},
+	}, time.Now().Add(-time.Second)))
+
+	session, err := backend.LoadSession("a")
+	require.NoError(err)
+	require.Equal("alice", session.Identity.Subject)
+	require.Equal("refresh-token", session.Refresh.Token)
+
+	// sessions are encrypted
+	stored, err := s.GetSession("a")
+	require.NoError(err)
+	require.NotContains(string(stored.Data), "refresh-token")
+	created := stored.CreatedAt
+
+	// and keep their creation time when they're refreshed
+	time.Sleep(time.Millisecond)
+	require.NoError(backend.SaveSession("a", session, time.Now().Add(time.Hour)))
+	stored, err = s.GetSession("a")
+	require.NoError(err)
+	require.Equal(created, stored.CreatedAt)
+
+	sessions, err := s.ListSessions("alice")
+	require.NoError(err)
+	require.Len(sessions, 1)
+	require.Equal("Alice", sessions[0].OwnerName)
+	require.Equal("ldap", sessions[0].Provider)
+
+	// expired sessions can't be used
+	_, err = backend.LoadSession("b")
+	require.Error(err)
+	require.NoError(s.DeleteExpiredSessions(time.Now()))
+	sessions, err = s.ListSessions("")
+	require.NoError(err)
+	require.Len(sessions, 1)
+
+	require.NoError(backend.DeleteSession("a"))
+	_, err = backend.LoadSession("a")
+	require.Error(err)
+}
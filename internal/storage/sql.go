@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
@@ -116,7 +117,7 @@ func (s *SQLStorage) Open() error {
 	db.LogMode(true)
 
 	// Migrate the schema
-	s.db.AutoMigrate(&Device{})
+	s.db.AutoMigrate(&Device{}, &PortForward{}, &DeviceTraffic{}, &Session{}, &APIToken{}, &User{}, &SCIMUser{}, &SCIMGroup{}, &SecondFactor{})
 
 	if s.sqlType == "postgres" {
 		watcher, err := NewPgWatcher(s.connectionString, db.NewScope(&Device{}).TableName())
@@ -147,6 +148,19 @@ func (s *SQLStorage) Save(device *Device) error {
 	return nil
 }
 
+func (s *SQLStorage) SaveDeviceMetadata(device *Device) error {
+	err := s.db.Model(&Device{}).Where("owner = ? AND name = ?", device.Owner, device.Name).UpdateColumns(map[string]interface{}{
+		"last_handshake_time": device.LastHandshakeTime,
+		"receive_bytes":       device.ReceiveBytes,
+		"transmit_bytes":      device.TransmitBytes,
+		"endpoint":            device.Endpoint,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to write device metadata")
+	}
+	return nil
+}
+
 func (s *SQLStorage) List(username string) ([]*Device, error) {
 	var err error
 	devices := []*Device{}
@@ -186,3 +200,249 @@ func (s *SQLStorage) Delete(device *Device) error {
 	s.Watcher.EmitDelete(device)
 	return nil
 }
+
+func (s *SQLStorage) SavePortForward(pf *PortForward) error {
+	logrus.Debugf("saving port forward %s", portForwardKey(pf))
+	if err := s.db.Save(&pf).Error; err != nil {
+		return errors.Wrap(err, "failed to write port forward")
+	}
+	return nil
+}
+
+func (s *SQLStorage) ListPortForwards() ([]*PortForward, error) {
+	pfs := []*PortForward{}
+	if err := s.db.Find(&pfs).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read port forwards from sql")
+	}
+	return pfs, nil
+}
+
+func (s *SQLStorage) DeletePortForward(pf *PortForward) error {
+	if err := s.db.Delete(&pf).Error; err != nil {
+		return errors.Wrap(err, "failed to delete port forward")
+	}
+	return nil
+}
+
+func (s *SQLStorage) SaveDeviceTraffic(t *DeviceTraffic) error {
+	if err := s.db.Save(&t).Error; err != nil {
+		return errors.Wrap(err, "failed to write device traffic")
+	}
+	return nil
+}
+
+func (s *SQLStorage) ListDeviceTraffic(owner string) ([]*DeviceTraffic, error) {
+	var err error
+	items := []*DeviceTraffic{}
+	if owner != "" {
+		err = s.db.Where("owner = ?", owner).Find(&items).Error
+	} else {
+		err = s.db.Find(&items).Error
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read device traffic from sql")
+	}
+	return items, nil
+}
+
+func (s *SQLStorage) DeleteDeviceTraffic(device *Device) error {
+	if err := s.db.Where("owner = ? AND name = ?", device.Owner, device.Name).Delete(&DeviceTraffic{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete device traffic")
+	}
+	return nil
+}
+
+func (s *SQLStorage) SaveSession(session *Session) error {
+	if err := s.db.Save(&session).Error; err != nil {
+		return errors.Wrap(err, "failed to write session")
+	}
+	return nil
+}
+
+func (s *SQLStorage) GetSession(id string) (*Session, error) {
+	session := &Session{}
+	if err := s.db.Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read session")
+	}
+	return session, nil
+}
+
+func (s *SQLStorage) ListSessions(owner string) ([]*Session, error) {
+	var err error
+	sessions := []*Session{}
+	if owner != "" {
+		err = s.db.Where("owner = ?", owner).Find(&sessions).Error
+	} else {
+		err = s.db.Find(&sessions).Error
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sessions from sql")
+	}
+	return sessions, nil
+}
+
+func (s *SQLStorage) DeleteSession(session *Session) error {
+	if err := s.db.Where("id = ?", session.ID).Delete(&Session{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete session")
+	}
+	return nil
+}
+
+func (s *SQLStorage) DeleteExpiredSessions(now time.Time) error {
+	if err := s.db.Where("expires_at <= ?", now).Delete(&Session{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete expired sessions")
+	}
+	return nil
+}
+
+func (s *SQLStorage) SaveAPIToken(token *APIToken) error {
+	if err := s.db.Save(&token).Error; err != nil {
+		return errors.Wrap(err, "failed to write api token")
+	}
+	return nil
+}
+
+func (s *SQLStorage) GetAPIToken(id string) (*APIToken, error) {
+	token := &APIToken{}
+	if err := s.db.Where("id = ?", id).First(&token).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read api token")
+	}
+	return token, nil
+}
+
+func (s *SQLStorage) GetAPITokenByHash(hash string) (*APIToken, error) {
+	token := &APIToken{}
+	if err := s.db.Where("hash = ?", hash).First(&token).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read api token")
+	}
+	return token, nil
+}
+
+func (s *SQLStorage) ListAPITokens(owner string) ([]*APIToken, error) {
+	var err error
+	tokens := []*APIToken{}
+	if owner != "" {
+		err = s.db.Where("owner = ?", owner).Find(&tokens).Error
+	} else {
+		err = s.db.Find(&tokens).Error
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read api tokens from sql")
+	}
+	return tokens, nil
+}
+
+func (s *SQLStorage) DeleteAPIToken(token *APIToken) error {
+	if err := s.db.Where("id = ?", token.ID).Delete(&APIToken{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete api token")
+	}
+	return nil
+}
+
+func (s *SQLStorage) SaveUser(user *User) error {
+	if err := s.db.Save(&user).Error; err != nil {
+		return errors.Wrap(err, "failed to write user")
+	}
+	return nil
+}
+
+func (s *SQLStorage) GetUser(provider string, subject string) (*User, error) {
+	user := &User{}
+	if err := s.db.Where("provider = ? AND subject = ?", provider, subject).First(&user).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read user")
+	}
+	return user, nil
+}
+
+func (s *SQLStorage) ListUsers() ([]*User, error) {
+	users := []*User{}
+	if err := s.db.Find(&users).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read users from sql")
+	}
+	return users, nil
+}
+
+func (s *SQLStorage) SaveSCIMUser(user *SCIMUser) error {
+	if err := s.db.Save(&user).Error; err != nil {
+		return errors.Wrap(err, "failed to write scim user")
+	}
+	return nil
+}
+
+func (s *SQLStorage) GetSCIMUser(id string) (*SCIMUser, error) {
+	user := &SCIMUser{}
+	if err := s.db.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read scim user")
+	}
+	return user, nil
+}
+
+func (s *SQLStorage) ListSCIMUsers() ([]*SCIMUser, error) {
+	users := []*SCIMUser{}
+	if err := s.db.Find(&users).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read scim users from sql")
+	}
+	return users, nil
+}
+
+func (s *SQLStorage) DeleteSCIMUser(user *SCIMUser) error {
+	if err := s.db.Where("id = ?", user.ID).Delete(&SCIMUser{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete scim user")
+	}
+	return nil
+}
+
+func (s *SQLStorage) SaveSCIMGroup(group *SCIMGroup) error {
+	if err := s.db.Save(&group).Error; err != nil {
+		return errors.Wrap(err, "failed to write scim group")
+	}
+	return nil
+}
+
+func (s *SQLStorage) GetSCIMGroup(id string) (*SCIMGroup, error) {
+	group := &SCIMGroup{}
+	if err := s.db.Where("id = ?", id).First(&group).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read scim group")
+	}
+	return group, nil
+}
+
+func (s *SQLStorage) ListSCIMGroups() ([]*SCIMGroup, error) {
+	groups := []*SCIMGroup{}
+	if err := s.db.Find(&groups).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read scim groups from sql")
+	}
+	return groups, nil
+}
+
+func (s *SQLStorage) DeleteSCIMGroup(group *SCIMGroup) error {
+	if err := s.db.Where("id = ?", group.ID).Delete(&SCIMGroup{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete scim group")
+	}
+	return nil
+}
+
+func (s *SQLStorage) SaveSecondFactor(factor *SecondFactor) error {
+	if err := s.db.Save(&factor).Error; err != nil {
+		return errors.Wrap(err, "failed to write second factor")
+	}
+	return nil
+}
+
+func (s *SQLStorage) GetSecondFactor(provider string, subject string) (*SecondFactor, error) {
+	factor := &SecondFactor{}
+	if err := s.db.Where("provider = ? AND subject = ?", provider, subject).First(&factor).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrap(err, "failed to read second factor")
+	}
+	return factor, nil
+}
+
+func (s *SQLStorage) DeleteSecondFactor(factor *SecondFactor) error {
+	if err := s.db.Where("provider = ? AND subject = ?", factor.Provider, factor.Subject).Delete(&SecondFactor{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete second factor")
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"path/filepath"
 )
 
@@ -11,3 +12,11 @@ func keyStr(owner string, name string) string {
 func key(device *Device) string {
 	return keyStr(device.Owner, device.Name)
 }
+
+func portForwardKey(pf *PortForward) string {
+	return fmt.Sprintf("%s/%d", pf.Protocol, pf.PublicPort)
+}
+
+func deviceTrafficKey(t *DeviceTraffic) string {
+	return filepath.Join(keyStr(t.Owner, t.Name), t.Destination)
+}
@@ -0,0 +1,247 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/internal/storage"
+)
+
+const (
+	// codes are 6 digits that change every 30s (RFC 6238)
+	period = 30
+	digits = 6
+	// codes from the previous and next period are
+	// accepted because clocks aren't perfectly in sync
+	skew = 1
+	// users are locked out after too many wrong codes
+	maxFailures = 5
+	lockout     = 5 * time.Minute
+	// the number of recovery codes a user gets
+	recoveryCodes = 10
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Store keeps TOTP second factors in storage with their secrets
+// encrypted. It implements authsession.SecondFactors.
+type Store struct {
+	storage storage.Storage
+	box     *secrets.Box
+	now     func() time.Time
+
+	// verifying a code reads, modifies and saves
+	// the second factor so that codes are used once
+	lock sync.Mutex
+}
+
+func New(s storage.Storage, box *secrets.Box) *Store {
+	return &Store{
+		storage: s,
+		box:     box,
+		now:     time.Now,
+	}
+}
+
+func (s *Store) Enrolled(provider string, subject string) (bool, error) {
+	factor, err := s.storage.GetSecondFactor(provider, subject)
+	if errors.Cause(err) == storage.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read second factor")
+	}
+	return factor.Confirmed, nil
+}
+
+func (s *Store) Enroll(provider string, subject string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	factor, err := s.storage.GetSecondFactor(provider, subject)
+	if err != nil && errors.Cause(err) != storage.ErrNotFound {
+		return "", errors.Wrap(err, "failed to read second factor")
+	}
+	if err == nil {
+		if factor.Confirmed {
+			return "", errors.New("a second factor is already enrolled")
+		}
+		secret, err := s.box.Open(factor.Secret, sealContext(provider, subject))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to decrypt totp secret")
+		}
+		return encoding.EncodeToString(secret), nil
+	}
+
+	secret := securecookie.GenerateRandomKey(20)
+	sealed, err := s.box.Seal(secret, sealContext(provider, subject))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encrypt totp secret")
+	}
+	factor = &storage.SecondFactor{
+		Provider:  provider,
+		Subject:   subject,
+		Secret:    sealed,
+		CreatedAt: s.now(),
+	}
+	if err := s.storage.SaveSecondFactor(factor); err != nil {
+		return "", errors.Wrap(err, "failed to save second factor")
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+func (s *Store) Confirm(provider string, subject string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, err := s.storage.GetSecondFactor(provider, subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read second factor")
+	}
+	if stored.Confirmed {
+		return nil, errors.New("the second factor is already confirmed")
+	}
+	factor := *stored
+
+	codes := []string{}
+	hashes := []string{}
+	for i := 0; i < recoveryCodes; i++ {
+		code := strings.ToLower(encoding.EncodeToString(securecookie.GenerateRandomKey(10))[:10])
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hash(code))
+	}
+	factor.Confirmed = true
+	factor.RecoveryCodes = strings.Join(hashes, " ")
+	if err := s.storage.SaveSecondFactor(&factor); err != nil {
+		return nil, errors.Wrap(err, "failed to save second factor")
+	}
+	return codes, nil
+}
+
+func (s *Store) Remove(provider string, subject string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	factor, err := s.storage.GetSecondFactor(provider, subject)
+	if errors.Cause(err) == storage.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read second factor")
+	}
+	return s.storage.DeleteSecondFactor(factor)
+}
+
+// Verify checks a TOTP code or a recovery code. Users are
+// locked out for a while after too many wrong codes.
+func (s *Store) Verify(provider string, subject string, code string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, err := s.storage.GetSecondFactor(provider, subject)
+	if errors.Cause(err) == storage.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read second factor")
+	}
+	factor := *stored
+	now := s.now()
+	if factor.LockedUntil != nil && now.Before(*factor.LockedUntil) {
+		return false, nil
+	}
+
+	ok, err := s.check(&factor, normalize(code), now)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		factor.Failures = 0
+		factor.LockedUntil = nil
+	} else {
+		factor.Failures++
+		if factor.Failures >= maxFailures {
+			until := now.Add(lockout)
+			factor.Failures = 0
+			factor.LockedUntil = &until
+		}
+	}
+	if err := s.storage.SaveSecondFactor(&factor); err != nil {
+		return false, errors.Wrap(err, "failed to save second factor")
+	}
+	return ok, nil
+}
+
+func (s *Store) check(factor *storage.SecondFactor, code string, now time.Time) (bool, error) {
+	if len(code) == digits {
+		secret, err := s.box.Open(factor.Secret, sealContext(factor.Provider, factor.Subject))
+		if err != nil {
+			return false, errors.Wrap(err, "failed to decrypt totp secret")
+		}
+		step := now.Unix() / period
+		for i := int64(-skew); i <= skew; i++ {
+			if step+i > factor.LastStep && subtle.ConstantTimeCompare([]byte(generate(secret, step+i)), []byte(code)) == 1 {
+				factor.LastStep = step + i
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// recovery codes can only be used once the user
+	// has seen them i.e. they've confirmed the secret
+	if !factor.Confirmed || code == "" {
+		return false, nil
+	}
+	remaining := []string{}
+	found := false
+	for _, h := range strings.Fields(factor.RecoveryCodes) {
+		if !found && subtle.ConstantTimeCompare([]byte(h), []byte(hash(code))) == 1 {
+			found = true
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	factor.RecoveryCodes = strings.Join(remaining, " ")
+	return found, nil
+}
+
+// generate returns the code of a secret for a time step (RFC 4226)
+func generate(secret []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1000000)
+}
+
+// normalize removes spaces and dashes that users type
+// i.e. "123 456" or "ABCDE-FGHIJ"
+func normalize(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// recovery codes are random so they don't need a slow hash
+func hash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func sealContext(provider string, subject string) string {
+	return "totp/" + provider + "/" + subject
+}
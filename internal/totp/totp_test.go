@@ -0,0 +1,94 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/place1/wg-access-server/internal/secrets"
+	"github.com/place1/wg-access-server/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	// the sha1 test vectors of RFC 6238 truncated to 6 digits
+	secret := []byte("12345678901234567890")
+	require.Equal(t, "287082", generate(secret, 59/period))
+	require.Equal(t, "081804", generate(secret, 1111111109/period))
+	require.Equal(t, "005924", generate(secret, 1234567890/period))
+}
+
+func TestStore(t *testing.T) {
+	require := require.New(t)
+
+	box, err := secrets.New("secret")
+	require.NoError(err)
+	s := New(storage.NewMemoryStorage(), box)
+	now := time.Unix(1600000000, 0)
+	s.now = func() time.Time { return now }
+
+	enrolled, err := s.Enrolled("basic", "admin")
+	require.NoError(err)
+	require.False(enrolled)
+
+	encoded, err := s.Enroll("basic", "admin")
+	require.NoError(err)
+	again, err := s.Enroll("basic", "admin")
+	require.NoError(err)
+	require.Equal(encoded, again, "the unconfirmed secret is shown again")
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(encoded)
+	require.NoError(err)
+
+	// unconfirmed secrets don't require a second factor
+	ok, err := s.Verify("basic", "admin", generate(secret, now.Unix()/period))
+	require.NoError(err)
+	require.True(ok)
+	enrolled, err = s.Enrolled("basic", "admin")
+	require.NoError(err)
+	require.False(enrolled)
+
+	codes, err := s.Confirm("basic", "admin")
+	require.NoError(err)
+	require.Len(codes, recoveryCodes)
+	enrolled, err = s.Enrolled("basic", "admin")
+	require.NoError(err)
+	require.True(enrolled)
+	_, err = s.Enroll("basic", "admin")
+	require.Error(err)
+
+	// codes can't be used twice
+	ok, err = s.Verify("basic", "admin", generate(secret, now.Unix()/period))
+	require.NoError(err)
+	require.False(ok)
+	now = now.Add(period * time.Second)
+	ok, err = s.Verify("basic", "admin", generate(secret, now.Unix()/period))
+	require.NoError(err)
+	require.True(ok)
+
+	// and neither can recovery codes
+	ok, err = s.Verify("basic", "admin", " "+codes[0]+" ")
+	require.NoError(err)
+	require.True(ok)
+	ok, err = s.Verify("basic", "admin", codes[0])
+	require.NoError(err)
+	require.False(ok)
+
+	// users are locked out after too many wrong codes
+	for i := 0; i < maxFailures; i++ {
+		ok, err = s.Verify("basic", "admin", "000000")
+		require.NoError(err)
+		require.False(ok)
+	}
+	ok, err = s.Verify("basic", "admin", codes[1])
+	require.NoError(err)
+	require.False(ok, "locked out")
+	now = now.Add(lockout)
+	ok, err = s.Verify("basic", "admin", codes[1])
+	require.NoError(err)
+	require.True(ok)
+
+	require.NoError(s.Remove("basic", "admin"))
+	enrolled, err = s.Enrolled("basic", "admin")
+	require.NoError(err)
+	require.False(enrolled)
+}
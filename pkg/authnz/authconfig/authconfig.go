@@ -8,10 +8,15 @@ type AuthConfig struct {
 	OIDC   *OIDCConfig      `yaml:"oidc"`
 	Gitlab *GitlabConfig    `yaml:"gitlab"`
 	Basic  *BasicAuthConfig `yaml:"basic"`
+	LDAP   *LDAPConfig      `yaml:"ldap"`
+	// Sessions configures how users stay signed in
+	Sessions SessionConfig `yaml:"sessions"`
+	// Throttle limits password logins
+	Throttle ThrottleConfig `yaml:"throttle"`
 }
 
 func (c *AuthConfig) IsEnabled() bool {
-	return c.OIDC != nil || c.Gitlab != nil || c.Basic != nil
+	return c.OIDC != nil || c.Gitlab != nil || c.Basic != nil || c.LDAP != nil
 }
 
 func (c *AuthConfig) Providers() []*authruntime.Provider {
@@ -29,5 +34,9 @@ func (c *AuthConfig) Providers() []*authruntime.Provider {
 		providers = append(providers, c.Basic.Provider())
 	}
 
+	if c.LDAP != nil {
+		providers = append(providers, c.LDAP.Provider())
+	}
+
 	return providers
 }
@@ -1,6 +1,7 @@
 package authconfig
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -16,14 +17,26 @@ type BasicAuthConfig struct {
 	// example: "htpasswd -nB <username>"
 	// copy the result into your user's array
 	Users []string `yaml:"users"`
+	// TOTP lets users enroll a TOTP second factor at
+	// /account/totp. It requires a session secret.
+	TOTP bool `yaml:"totp"`
 }
 
 func (c *BasicAuthConfig) Provider() *authruntime.Provider {
 	return &authruntime.Provider{
-		Type: "Basic",
+		Type:         "Basic",
+		Name:         "basic",
+		SecondFactor: c.TOTP,
 		Invoke: func(w http.ResponseWriter, r *http.Request, runtime *authruntime.ProviderRuntime) {
 			basicAuthLogin(c, runtime)(w, r)
 		},
+		CheckUser: func(ctx context.Context, subject string, refresh *authsession.Refresh) (authruntime.UserStatus, *authsession.Refresh, error) {
+			// users are removed by deleting them from the config file
+			if hasUser(c.Users, subject) {
+				return authruntime.UserActive, nil, nil
+			}
+			return authruntime.UserDeprovisioned, nil, nil
+		},
 	}
 }
 
@@ -37,19 +50,31 @@ func basicAuthLogin(c *BasicAuthConfig, runtime *authruntime.ProviderRuntime) ht
 			return
 		}
 
+		attempt := runtime.StartLogin(w, r, "basic", u)
+		if attempt == nil {
+			return
+		}
+
 		if ok := checkCreds(c.Users, u, p); ok {
+			attempt.Succeeded()
+			identity := &authsession.Identity{
+				Provider: "basic",
+				Subject:  u,
+				Name:     u,
+				Email:    "", // basic auth has no email
+			}
+			if c.TOTP {
+				runtime.SecondFactor(w, r, identity)
+				return
+			}
 			runtime.SetSession(w, r, &authsession.AuthSession{
-				Identity: &authsession.Identity{
-					Provider: "basic",
-					Subject:  u,
-					Name:     u,
-					Email:    "", // basic auth has no email
-				},
+				Identity: identity,
 			})
 			runtime.Done(w, r)
 			return
 		}
 
+		attempt.Failed()
 		w.Header().Set("WWW-Authenticate", `Basic realm="site"`)
 		w.WriteHeader(http.StatusUnauthorized)
 		fmt.Fprintln(w, "unauthorized")
@@ -68,6 +93,15 @@ func checkCreds(users []string, username string, password string) bool {
 	return false
 }
 
+func hasUser(users []string, username string) bool {
+	for _, user := range users {
+		if u, _, ok := parsehtpassword(user); ok && u == username {
+			return true
+		}
+	}
+	return false
+}
+
 func parsehtpassword(user string) (string, string, bool) {
 	segments := strings.SplitN(user, ":", 2)
 	if len(segments) >= 1 {
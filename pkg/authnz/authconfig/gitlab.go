@@ -20,8 +20,7 @@ func (c *GitlabConfig) Provider() *authruntime.Provider {
 		RedirectURL:  c.RedirectURL,
 		Scopes:       []string{"openid"},
 		EmailDomains: c.EmailDomains,
+		providerType: "Gitlab",
 	}
-	p := o.Provider()
-	p.Type = "Gitlab"
-	return p
+	return o.Provider()
 }
@@ -0,0 +1,321 @@
+package authconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/sirupsen/logrus"
+)
+
+// the timeout for connecting to and querying the ldap server
+const ldapTimeout = 10 * time.Second
+
+var errInvalidCredentials = errors.New("invalid username or password")
+
+type LDAPConfig struct {
+	Name string `yaml:"name"`
+	// URL of the ldap server i.e. ldaps://ldap.example.com:636
+	// or ldap://ldap.example.com:389
+	URL string `yaml:"url"`
+	// StartTLS upgrades an ldap:// connection to TLS
+	StartTLS bool `yaml:"startTLS"`
+	// CACert is a path to a PEM encoded CA certificate that's used
+	// to verify the server's certificate instead of the system's CAs
+	CACert             string `yaml:"caCert"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	// BindDN and BindPassword are the service account that's used
+	// to search for users. Searches are anonymous if BindDN is empty.
+	BindDN       string `yaml:"bindDN"`
+	BindPassword string `yaml:"bindPassword"`
+	// BaseDN is where users are searched for
+	BaseDN string `yaml:"baseDN"`
+	// UsernameAttribute is the attribute that users sign in with.
+	// Defaults to "uid". Active Directory uses "sAMAccountName".
+	UsernameAttribute string `yaml:"usernameAttribute"`
+	// UserFilter finds the user that's signing in. %s is replaced
+	// by the username. Defaults to "(<usernameAttribute>=%s)"
+	UserFilter string `yaml:"userFilter"`
+	// NameAttribute defaults to "cn"
+	NameAttribute string `yaml:"nameAttribute"`
+	// EmailAttribute defaults to "mail"
+	EmailAttribute string `yaml:"emailAttribute"`
+	// GroupAttribute lists the DNs of the user's groups.
+	// Defaults to "memberOf"
+	GroupAttribute string `yaml:"groupAttribute"`
+	// GroupClaims grants a claim to members of a group
+	// i.e. admin: "cn=vpn-admins,ou=groups,dc=example,dc=com"
+	GroupClaims map[string]string `yaml:"groupClaims"`
+}
+
+func (c *LDAPConfig) Provider() *authruntime.Provider {
+	if c.Name == "" {
+		c.Name = "ldap"
+	}
+	if c.UsernameAttribute == "" {
+		c.UsernameAttribute = "uid"
+	}
+	if c.UserFilter == "" {
+		c.UserFilter = fmt.Sprintf("(%s=%%s)", c.UsernameAttribute)
+	}
+	if c.NameAttribute == "" {
+		c.NameAttribute = "cn"
+	}
+	if c.EmailAttribute == "" {
+		c.EmailAttribute = "mail"
+	}
+	if c.GroupAttribute == "" {
+		c.GroupAttribute = "memberOf"
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		logrus.Fatal(errors.Wrap(err, "failed to create ldap provider"))
+	}
+
+	return &authruntime.Provider{
+		Type:         "LDAP",
+		Name:         c.Name,
+		PasswordForm: true,
+		Invoke: func(w http.ResponseWriter, r *http.Request, runtime *authruntime.ProviderRuntime) {
+			c.loginHandler(runtime, tlsConfig)(w, r)
+		},
+		CheckUser: func(ctx context.Context, subject string, refresh *authsession.Refresh) (authruntime.UserStatus, *authsession.Refresh, error) {
+			status, err := c.checkUser(tlsConfig, subject)
+			return status, nil, err
+		},
+	}
+}
+
+func (c *LDAPConfig) tlsConfig() (*tls.Config, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ldap url '%s'", c.URL)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported ldap url scheme '%s' - must be ldap or ldaps", u.Scheme)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CACert != "" {
+		pem, err := ioutil.ReadFile(c.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ldap ca certificate")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ldap ca certificate %s", c.CACert)
+		}
+	}
+	return tlsConfig, nil
+}
+
+func (c *LDAPConfig) loginHandler(runtime *authruntime.ProviderRuntime, tlsConfig *tls.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			runtime.Restart(w, r)
+			return
+		}
+
+		username := r.PostFormValue("username")
+		attempt := runtime.StartLogin(w, r, c.Name, username)
+		if attempt == nil {
+			return
+		}
+		// server errors don't count as failed logins
+		defer attempt.Cancel()
+
+		identity, err := c.authenticate(tlsConfig, username, r.PostFormValue("password"))
+		if err == errInvalidCredentials {
+			attempt.Failed()
+			runtime.LoginFailed(w, r)
+			return
+		}
+		if err != nil {
+			logrus.Error(errors.Wrap(err, "ldap login failed"))
+			http.Error(w, "ldap server error", http.StatusBadGateway)
+			return
+		}
+		attempt.Succeeded()
+
+		runtime.SetSession(w, r, &authsession.AuthSession{
+			Identity: identity,
+		})
+		runtime.Done(w, r)
+	}
+}
+
+// authenticate finds the user using the service account
+// and then checks their password by binding as the user.
+func (c *LDAPConfig) authenticate(tlsConfig *tls.Config, username string, password string) (*authsession.Identity, error) {
+	// an empty password is an unauthenticated bind (RFC 4513)
+	// which many servers accept without checking anything
+	if username == "" || password == "" {
+		return nil, errInvalidCredentials
+	}
+
+	conn, err := c.dial(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.BindDN != "" {
+		if err := conn.Bind(c.BindDN, c.BindPassword); err != nil {
+			return nil, errors.Wrap(err, "failed to bind with the ldap service account")
+		}
+	}
+
+	entries, err := c.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, errInvalidCredentials
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errInvalidCredentials
+		}
+		return nil, errors.Wrap(err, "failed to bind as the ldap user")
+	}
+
+	subject := entry.GetEqualFoldAttributeValue(c.UsernameAttribute)
+	if subject == "" {
+		subject = username
+	}
+	name := entry.GetEqualFoldAttributeValue(c.NameAttribute)
+	if name == "" {
+		name = subject
+	}
+
+	// the email comes from the directory so it's trusted
+	email := entry.GetEqualFoldAttributeValue(c.EmailAttribute)
+	return &authsession.Identity{
+		Provider:      c.Name,
+		Subject:       subject,
+		Name:          name,
+		Email:         email,
+		EmailVerified: email != "",
+		Claims:        c.groupClaims(entry.GetEqualFoldAttributeValues(c.GroupAttribute)),
+	}, nil
+}
+
+// findUser searches for the entries that match the user filter.
+// The connection must be bound as the service account.
+func (c *LDAPConfig) findUser(conn *ldap.Conn, username string) ([]*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		int(ldapTimeout/time.Second),
+		false,
+		strings.Replace(c.UserFilter, "%s", ldap.EscapeFilter(username), -1),
+		[]string{c.UsernameAttribute, c.NameAttribute, c.EmailAttribute, c.GroupAttribute},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, errors.Wrap(err, "failed to search for the ldap user")
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return result.Entries, nil
+}
+
+// checkUser checks that a user can still be found with the user
+// filter. Filters that exclude disabled accounts i.e. in
+// Active Directory also deprovision disabled users.
+func (c *LDAPConfig) checkUser(tlsConfig *tls.Config, subject string) (authruntime.UserStatus, error) {
+	conn, err := c.dial(tlsConfig)
+	if err != nil {
+		return authruntime.UserUnknown, err
+	}
+	defer conn.Close()
+
+	if c.BindDN != "" {
+		if err := conn.Bind(c.BindDN, c.BindPassword); err != nil {
+			return authruntime.UserUnknown, errors.Wrap(err, "failed to bind with the ldap service account")
+		}
+	}
+
+	entries, err := c.findUser(conn, subject)
+	if err != nil {
+		return authruntime.UserUnknown, err
+	}
+	switch len(entries) {
+	case 0:
+		return authruntime.UserDeprovisioned, nil
+	case 1:
+		return authruntime.UserActive, nil
+	}
+	return authruntime.UserUnknown, fmt.Errorf("more than one ldap user matches %s", subject)
+}
+
+func (c *LDAPConfig) dial(tlsConfig *tls.Config) (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(c.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the ldap server")
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if c.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to start tls with the ldap server")
+		}
+	}
+	return conn, nil
+}
+
+// groupClaims adds a "group" claim for each of the user's
+// groups and the configured claims for the groups they're in.
+func (c *LDAPConfig) groupClaims(groups []string) authsession.Claims {
+	claims := authsession.Claims{}
+	for _, group := range groups {
+		claims.Add("group", group)
+	}
+	for claim, group := range c.GroupClaims {
+		for _, g := range groups {
+			if sameDN(group, g) {
+				claims.Add(claim, "true")
+				break
+			}
+		}
+	}
+	return claims
+}
+
+// sameDN compares DNs ignoring case and whitespace between RDNs
+func sameDN(a string, b string) bool {
+	parsedA, err := ldap.ParseDN(a)
+	if err != nil {
+		return strings.EqualFold(a, b)
+	}
+	parsedB, err := ldap.ParseDN(b)
+	if err != nil {
+		return strings.EqualFold(a, b)
+	}
+	return parsedA.EqualFold(parsedB)
+}
@@ -0,0 +1,385 @@
+package authconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/gorilla/sessions"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	ldapBindDN     = "cn=svc,dc=example,dc=com"
+	ldapBindSecret = "svc-password"
+	ldapAdminGroup = "cn=vpn-admins,ou=groups,dc=example,dc=com"
+)
+
+func TestLDAPLogin(t *testing.T) {
+	require := require.New(t)
+
+	server := newTestLDAPServer(t, nil)
+	defer server.Close()
+
+	c, tlsConfig := testLDAPConfig(t, &LDAPConfig{URL: "ldap://" + server.Addr()})
+
+	identity, err := c.authenticate(tlsConfig, "alice", "alice-password")
+	require.NoError(err)
+	require.Equal("ldap", identity.Provider)
+	require.Equal("alice", identity.Subject)
+	require.Equal("Alice Smith", identity.Name)
+	require.Equal("alice@example.com", identity.Email)
+	require.True(identity.Claims.Has("group", ldapAdminGroup))
+	require.True(identity.Claims.Has("admin", "true"))
+
+	identity, err = c.authenticate(tlsConfig, "bob", "bob-password")
+	require.NoError(err)
+	require.Equal("bob", identity.Name)
+	require.False(identity.Claims.Contains("admin"))
+
+	for _, creds := range [][2]string{
+		{"alice", "wrong"},
+		{"alice", ""},
+		{"", ""},
+		{"nobody", "password"},
+		// the username is escaped in the user filter
+		{"*", "alice-password"},
+	} {
+		_, err := c.authenticate(tlsConfig, creds[0], creds[1])
+		require.Equal(errInvalidCredentials, err, "%s:%s", creds[0], creds[1])
+	}
+
+	// a wrong service account password is a server error
+	c.BindPassword = "wrong"
+	_, err = c.authenticate(tlsConfig, "alice", "alice-password")
+	require.Error(err)
+	require.NotEqual(errInvalidCredentials, err)
+}
+
+func TestLDAPCheckUser(t *testing.T) {
+	require := require.New(t)
+
+	server := newTestLDAPServer(t, nil)
+	defer server.Close()
+
+	c, tlsConfig := testLDAPConfig(t, &LDAPConfig{URL: "ldap://" + server.Addr()})
+
+	status, err := c.checkUser(tlsConfig, "alice")
+	require.NoError(err)
+	require.Equal(authruntime.UserActive, status)
+
+	status, err = c.checkUser(tlsConfig, "nobody")
+	require.NoError(err)
+	require.Equal(authruntime.UserDeprovisioned, status)
+
+	// server errors don't deprovision anyone
+	c.BindPassword = "wrong"
+	status, err = c.checkUser(tlsConfig, "alice")
+	require.Error(err)
+	require.Equal(authruntime.UserUnknown, status)
+}
+
+func TestLDAPTLS(t *testing.T) {
+	require := require.New(t)
+
+	cert, caFile := testCertificate(t)
+	defer os.RemoveAll(filepath.Dir(caFile))
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	// StartTLS
+	server := newTestLDAPServer(t, serverTLS)
+	defer server.Close()
+	c, tlsConfig := testLDAPConfig(t, &LDAPConfig{
+		URL:      "ldap://" + server.Addr(),
+		StartTLS: true,
+		CACert:   caFile,
+	})
+	_, err := c.authenticate(tlsConfig, "alice", "alice-password")
+	require.NoError(err)
+	require.True(server.UsedTLS())
+
+	// ldaps
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	require.NoError(err)
+	server = serveTestLDAP(t, listener, nil)
+	defer server.Close()
+	c, tlsConfig = testLDAPConfig(t, &LDAPConfig{
+		URL:    "ldaps://" + server.Addr(),
+		CACert: caFile,
+	})
+	_, err = c.authenticate(tlsConfig, "alice", "alice-password")
+	require.NoError(err)
+
+	// the server's certificate is verified
+	c, tlsConfig = testLDAPConfig(t, &LDAPConfig{
+		URL: "ldaps://" + server.Addr(),
+	})
+	_, err = c.authenticate(tlsConfig, "alice", "alice-password")
+	require.Error(err)
+}
+
+func TestLDAPLoginForm(t *testing.T) {
+	require := require.New(t)
+
+	server := newTestLDAPServer(t, nil)
+	defer server.Close()
+
+	c := &LDAPConfig{
+		URL:          "ldap://" + server.Addr(),
+		BindDN:       ldapBindDN,
+		BindPassword: ldapBindSecret,
+		BaseDN:       "dc=example,dc=com",
+	}
+	provider := c.Provider()
+	require.True(provider.PasswordForm)
+	runtime := authruntime.NewProviderRuntime(sessions.NewCookieStore([]byte("test")), nil, nil, nil)
+
+	login := func(username string, password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}}
+		r := httptest.NewRequest(http.MethodPost, "/signin/0", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		provider.Invoke(w, r, runtime)
+		return w
+	}
+
+	w := login("alice", "alice-password")
+	require.Equal(http.StatusSeeOther, w.Code)
+	require.Equal("/", w.Header().Get("Location"))
+	require.NotEmpty(w.Header().Get("Set-Cookie"))
+
+	w = login("alice", "wrong")
+	require.Equal(http.StatusSeeOther, w.Code)
+	require.Equal("/signin?failed=true", w.Header().Get("Location"))
+	require.Empty(w.Header().Get("Set-Cookie"))
+}
+
+func testLDAPConfig(t *testing.T, c *LDAPConfig) (*LDAPConfig, *tls.Config) {
+	c.BindDN = ldapBindDN
+	c.BindPassword = ldapBindSecret
+	c.BaseDN = "dc=example,dc=com"
+	c.GroupClaims = map[string]string{
+		"admin": "CN=VPN-Admins, OU=Groups, DC=example, DC=com",
+	}
+	c.Provider()
+	tlsConfig, err := c.tlsConfig()
+	require.NoError(t, err)
+	return c, tlsConfig
+}
+
+type testLDAPEntry struct {
+	password   string
+	attributes map[string][]string
+}
+
+var testLDAPDirectory = map[string]testLDAPEntry{
+	ldapBindDN: {password: ldapBindSecret},
+	"uid=alice,ou=people,dc=example,dc=com": {
+		password: "alice-password",
+		attributes: map[string][]string{
+			"uid":      {"alice"},
+			"cn":       {"Alice Smith"},
+			"mail":     {"alice@example.com"},
+			"memberOf": {ldapAdminGroup, "cn=staff,ou=groups,dc=example,dc=com"},
+		},
+	},
+	"uid=bob,ou=people,dc=example,dc=com": {
+		password: "bob-password",
+		attributes: map[string][]string{
+			"uid": {"bob"},
+		},
+	},
+}
+
+// testLDAPServer is a minimal ldap server that supports
+// simple binds, equality searches and StartTLS.
+type testLDAPServer struct {
+	t         *testing.T
+	listener  net.Listener
+	tlsConfig *tls.Config
+	usedTLS   chan struct{}
+}
+
+func newTestLDAPServer(t *testing.T, tlsConfig *tls.Config) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return serveTestLDAP(t, listener, tlsConfig)
+}
+
+func serveTestLDAP(t *testing.T, listener net.Listener, tlsConfig *tls.Config) *testLDAPServer {
+	s := &testLDAPServer{
+		t:         t,
+		listener:  listener,
+		tlsConfig: tlsConfig,
+		usedTLS:   make(chan struct{}, 100),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testLDAPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testLDAPServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testLDAPServer) UsedTLS() bool {
+	select {
+	case <-s.usedTLS:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	bound := ""
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			entry, ok := testLDAPDirectory[dn]
+			switch {
+			case dn == "" && password == "":
+				bound = ""
+				s.write(conn, ldapResult(id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess))
+			case ok && entry.password == password:
+				bound = dn
+				s.write(conn, ldapResult(id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess))
+			default:
+				s.write(conn, ldapResult(id, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials))
+			}
+
+		case ldap.ApplicationSearchRequest:
+			if bound != ldapBindDN {
+				s.write(conn, ldapResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				continue
+			}
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			require.NoError(s.t, err)
+			for dn, entry := range testLDAPDirectory {
+				for _, uid := range entry.attributes["uid"] {
+					if filter == fmt.Sprintf("(uid=%s)", uid) {
+						s.write(conn, ldapEntry(id, dn, entry.attributes))
+					}
+				}
+			}
+			s.write(conn, ldapResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationExtendedRequest:
+			if s.tlsConfig == nil || op.Children[0].Data.String() != "1.3.6.1.4.1.1466.20037" {
+				s.write(conn, ldapResult(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError))
+				continue
+			}
+			s.write(conn, ldapResult(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			conn = tls.Server(conn, s.tlsConfig)
+			s.usedTLS <- struct{}{}
+
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *testLDAPServer) write(conn net.Conn, packet *ber.Packet) {
+	conn.Write(packet.Bytes())
+}
+
+func ldapMessage(id int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func ldapResult(id int64, tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return ldapMessage(id, op)
+}
+
+func ldapEntry(id int64, dn string, attributes map[string][]string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range attributes {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return ldapMessage(id, op)
+}
+
+// testCertificate creates a self-signed certificate for 127.0.0.1
+// and returns it with the path of a PEM file containing it.
+func testCertificate(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap test"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "ldap-test")
+	require.NoError(t, err)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
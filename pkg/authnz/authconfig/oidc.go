@@ -2,6 +2,10 @@ package authconfig
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -10,6 +14,7 @@ import (
 
 	"github.com/coreos/go-oidc"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
 	"github.com/pkg/errors"
 	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
 	"github.com/place1/wg-access-server/pkg/authnz/authsession"
@@ -20,6 +25,12 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// how often sessions are refreshed by default
+const defaultRefreshInterval = 15 * time.Minute
+
+// how soon a refresh is retried if the oidc provider can't be reached
+const refreshRetry = time.Minute
+
 type OIDCConfig struct {
 	Name         string                    `yaml:"name"`
 	Issuer       string                    `yaml:"issuer"`
@@ -29,11 +40,32 @@ type OIDCConfig struct {
 	RedirectURL  string                    `yaml:"redirectURL"`
 	EmailDomains []string                  `yaml:"emailDomains"`
 	ClaimMapping map[string]ruleExpression `yaml:"claimMapping"`
+	// GroupsClaim is the ID token or userinfo claim that
+	// lists the user's groups. Defaults to "groups"
+	GroupsClaim string `yaml:"groupsClaim"`
+	// AllowedGroups restricts access to members of at
+	// least one of the groups. All users are allowed if empty.
+	AllowedGroups []string `yaml:"allowedGroups"`
+	// AdminGroups grants the admin claim to members of the groups
+	AdminGroups []string `yaml:"adminGroups"`
+	// RefreshInterval is how often users are re-checked with the
+	// oidc provider using their refresh token. Defaults to 15m
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+	// providerType defaults to "OIDC"
+	providerType string
+}
+
+// loginRejected is returned when a user isn't allowed to sign in
+type loginRejected string
+
+func (e loginRejected) Error() string {
+	return string(e)
 }
 
 func (c *OIDCConfig) Provider() *authruntime.Provider {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	// the provider keeps the context to fetch signing keys
+	// so it can't be cancelled. the client's timeout is used instead.
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: 15 * time.Second})
 	provider, err := oidc.NewProvider(ctx, c.Issuer)
 	if err != nil {
 		logrus.Fatal(errors.Wrap(err, "failed to create oidc provider"))
@@ -42,6 +74,18 @@ func (c *OIDCConfig) Provider() *authruntime.Provider {
 	if c.Scopes == nil {
 		c.Scopes = []string{"openid"}
 	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if c.providerType == "" {
+		c.providerType = "OIDC"
+	}
+	if (len(c.AllowedGroups) > 0 || len(c.AdminGroups) > 0) && !hasScope(c.Scopes, "offline_access") {
+		logrus.Warnf("oidc provider '%s' has allowedGroups or adminGroups but doesn't request the offline_access scope - most providers won't return a refresh token without it and group changes will only be seen when users sign in again", c.Name)
+	}
 
 	oauthConfig := &oauth2.Config{
 		RedirectURL:  c.RedirectURL,
@@ -57,7 +101,8 @@ func (c *OIDCConfig) Provider() *authruntime.Provider {
 	}
 
 	return &authruntime.Provider{
-		Type: "OIDC",
+		Type: c.providerType,
+		Name: c.Name,
 		Invoke: func(w http.ResponseWriter, r *http.Request, runtime *authruntime.ProviderRuntime) {
 			c.loginHandler(runtime, oauthConfig)(w, r)
 		},
@@ -65,16 +110,30 @@ func (c *OIDCConfig) Provider() *authruntime.Provider {
 			router.HandleFunc(redirectURL.Path, c.callbackHandler(runtime, oauthConfig, provider))
 			return nil
 		},
+		Refresh: func(ctx context.Context, s *authsession.AuthSession) (*authsession.AuthSession, error) {
+			return c.refresh(ctx, s, oauthConfig, provider)
+		},
+		CheckUser: func(ctx context.Context, subject string, refresh *authsession.Refresh) (authruntime.UserStatus, *authsession.Refresh, error) {
+			return c.checkUser(ctx, subject, refresh, oauthConfig, provider)
+		},
 	}
 }
 
 func (c *OIDCConfig) loginHandler(runtime *authruntime.ProviderRuntime, oauthConfig *oauth2.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// the state doubles as the id token nonce.
+		// both are random values bound to the session.
 		oauthStateString := authutil.RandomString(32)
+		verifier := pkceVerifier()
 		runtime.SetSession(w, r, &authsession.AuthSession{
-			Nonce: &oauthStateString,
+			Nonce:        &oauthStateString,
+			CodeVerifier: verifier,
 		})
-		url := oauthConfig.AuthCodeURL(oauthStateString)
+		url := oauthConfig.AuthCodeURL(oauthStateString,
+			oidc.Nonce(oauthStateString),
+			oauth2.SetAuthURLParam("code_challenge", pkceChallenge(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 	}
 }
@@ -93,51 +152,297 @@ func (c *OIDCConfig) callbackHandler(runtime *authruntime.ProviderRuntime, oauth
 			return
 		}
 
+		if msg := r.FormValue("error"); msg != "" {
+			http.Error(w, fmt.Sprintf("oidc login failed: %s %s", msg, r.FormValue("error_description")), http.StatusForbidden)
+			return
+		}
+
 		code := r.FormValue("code")
-		token, _ := oauthConfig.Exchange(r.Context(), code)
-		info, err := provider.UserInfo(r.Context(), oauthConfig.TokenSource(r.Context(), token))
+		token, err := oauthConfig.Exchange(r.Context(), code, oauth2.SetAuthURLParam("code_verifier", s.CodeVerifier))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logrus.Warn(errors.Wrap(err, "failed to exchange oidc authorization code"))
+			http.Error(w, "failed to exchange authorization code", http.StatusBadRequest)
 			return
 		}
 
-		if msg, valid := verifyEmailDomain(c.EmailDomains, info.Email); !valid {
-			http.Error(w, msg, http.StatusForbidden)
+		identity, err := c.identity(r.Context(), token, oauthConfig, provider, *s.Nonce)
+		if rejected, ok := err.(loginRejected); ok {
+			http.Error(w, string(rejected), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			logrus.Warn(errors.Wrap(err, "oidc login failed"))
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		oidcProfileData := make(map[string]interface{})
-		info.Claims(&oidcProfileData)
+		runtime.SetSession(w, r, c.session(identity, token))
+		runtime.Done(w, r)
+	}
+}
 
-		claims := &authsession.Claims{}
-		for claimName, rule := range c.ClaimMapping {
-			result, err := rule.Evaluate(oidcProfileData)
+// refresh gets a new token with the session's refresh
+// token and checks that the user is still allowed in.
+func (c *OIDCConfig) refresh(ctx context.Context, s *authsession.AuthSession, oauthConfig *oauth2.Config, provider *oidc.Provider) (*authsession.AuthSession, error) {
+	token, err := oauthConfig.TokenSource(ctx, &oauth2.Token{RefreshToken: s.Refresh.Token}).Token()
+	if retrieveErr, ok := err.(*oauth2.RetrieveError); ok && oauthErrorCode(retrieveErr) == "invalid_grant" {
+		// the provider rejected the refresh token i.e.
+		// the user was disabled or their session was revoked
+		return nil, errors.Wrap(err, "oidc provider rejected the refresh token")
+	}
+	if err != nil {
+		// the provider is down or failing so the
+		// session is kept and refreshed again later
+		logrus.Warn(errors.Wrap(err, "failed to refresh oidc session"))
+		return retrySession(s, s.Refresh.Token), nil
+	}
 
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			
-			// If result is 'false' or an empty string then don't include the Claim
-			if val, ok := result.(bool); ok && val {
-				claims.Add(claimName, strconv.FormatBool(val))
-			} else if val, ok := result.(string); ok && len(val) > 0 {
-				claims.Add(claimName, val)
-			}
+	identity, err := c.identity(ctx, token, oauthConfig, provider, "")
+	if _, ok := err.(loginRejected); ok {
+		return nil, err
+	}
+	if err != nil {
+		// i.e. the userinfo endpoint or the signing keys
+		// can't be reached. the refresh token may have
+		// been rotated so the new one is kept.
+		logrus.Warn(errors.Wrap(err, "failed to refresh oidc session"))
+		next := s.Refresh.Token
+		if token.RefreshToken != "" {
+			next = token.RefreshToken
 		}
+		return retrySession(s, next), nil
+	}
+	if s.Identity == nil || identity.Subject != s.Identity.Subject {
+		return nil, errors.New("refreshed oidc session belongs to another user")
+	}
+	return c.session(identity, token), nil
+}
 
-		runtime.SetSession(w, r, &authsession.AuthSession{
-			Identity: &authsession.Identity{
-				Provider: c.Name,
-				Subject:  info.Subject,
-				Email:    info.Email,
-				Name:     oidcProfileData["name"].(string),
-				Claims:   *claims,
-			},
-		})
+// retrySession keeps a session that couldn't be refreshed
+// and refreshes it again soon with refreshToken
+func retrySession(s *authsession.AuthSession, refreshToken string) *authsession.AuthSession {
+	retry := *s
+	retry.Refresh = &authsession.Refresh{
+		Provider: s.Refresh.Provider,
+		Token:    refreshToken,
+		At:       time.Now().Add(refreshRetry),
+	}
+	return &retry
+}
 
-		runtime.Done(w, r)
+// checkUser uses the user's last refresh token to check
+// that they still exist and are allowed in.
+func (c *OIDCConfig) checkUser(ctx context.Context, subject string, refresh *authsession.Refresh, oauthConfig *oauth2.Config, provider *oidc.Provider) (authruntime.UserStatus, *authsession.Refresh, error) {
+	if refresh == nil || refresh.Token == "" {
+		return authruntime.UserUnknown, nil, nil
+	}
+
+	token, err := oauthConfig.TokenSource(ctx, &oauth2.Token{RefreshToken: refresh.Token}).Token()
+	if retrieveErr, ok := err.(*oauth2.RetrieveError); ok && oauthErrorCode(retrieveErr) == "invalid_grant" {
+		// the user may have been removed or disabled but refresh
+		// tokens also expire or are revoked when a user signs out
+		// so it's not a reason to deprovision them
+		return authruntime.UserUnknown, nil, errors.New("the oidc provider rejected the refresh token - the user can't be checked until they sign in again")
+	}
+	if err != nil {
+		return authruntime.UserUnknown, nil, errors.Wrap(err, "failed to refresh oidc token")
+	}
+
+	// providers that rotate refresh tokens
+	// have invalidated the old one
+	next := &authsession.Refresh{
+		Provider: c.providerType,
+		Token:    refresh.Token,
+	}
+	if token.RefreshToken != "" {
+		next.Token = token.RefreshToken
+	}
+
+	identity, err := c.identity(ctx, token, oauthConfig, provider, "")
+	if _, ok := err.(loginRejected); ok {
+		return authruntime.UserDeprovisioned, next, nil
+	}
+	if err != nil {
+		return authruntime.UserUnknown, next, err
+	}
+	if identity.Subject != subject {
+		return authruntime.UserUnknown, next, errors.New("refreshed oidc token belongs to another user")
+	}
+	return authruntime.UserActive, next, nil
+}
+
+// oauthErrorCode returns the error code of a token endpoint
+// error response i.e. "invalid_grant"
+func oauthErrorCode(err *oauth2.RetrieveError) string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(err.Body, &body) == nil {
+		return body.Error
+	}
+	// some providers respond with a form
+	if values, parseErr := url.ParseQuery(string(err.Body)); parseErr == nil {
+		return values.Get("error")
+	}
+	return ""
+}
+
+func (c *OIDCConfig) session(identity *authsession.Identity, token *oauth2.Token) *authsession.AuthSession {
+	s := &authsession.AuthSession{
+		Identity: identity,
+	}
+	if token.RefreshToken != "" {
+		s.Refresh = &authsession.Refresh{
+			Provider: c.providerType,
+			Token:    token.RefreshToken,
+			At:       time.Now().Add(c.RefreshInterval),
+		}
+	} else {
+		logrus.Infof("oidc provider didn't return a refresh token - %s won't be re-checked until they sign in again", identity.Subject)
+	}
+	return s
+}
+
+// identity creates the identity of a user and checks that they're
+// allowed to sign in. At login the token must have an id token with
+// the login's nonce. Refreshed tokens don't always have an id token
+// so nonce is empty when a session is refreshed.
+func (c *OIDCConfig) identity(ctx context.Context, token *oauth2.Token, oauthConfig *oauth2.Config, provider *oidc.Provider, nonce string) (*authsession.Identity, error) {
+	oidcProfileData := make(map[string]interface{})
+	subject := ""
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok && nonce != "" {
+		return nil, errors.New("oidc provider didn't return an id token")
+	}
+	if ok {
+		// checks the signature, issuer, audience and expiry
+		idToken, err := provider.Verifier(&oidc.Config{ClientID: c.ClientID}).Verify(ctx, rawIDToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid id token")
+		}
+		if nonce != "" && idToken.Nonce != nonce {
+			return nil, errors.New("invalid id token: nonce doesn't match")
+		}
+		if err := idToken.Claims(&oidcProfileData); err != nil {
+			return nil, errors.Wrap(err, "invalid id token claims")
+		}
+		subject = idToken.Subject
+	}
+
+	if hasUserInfo(provider) {
+		info, err := provider.UserInfo(ctx, oauthConfig.TokenSource(ctx, token))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get oidc userinfo")
+		}
+		// the userinfo must be for the id token's subject
+		if subject != "" && info.Subject != subject {
+			return nil, errors.New("oidc userinfo subject doesn't match the id token")
+		}
+		if err := info.Claims(&oidcProfileData); err != nil {
+			return nil, errors.Wrap(err, "invalid oidc userinfo claims")
+		}
+		subject = info.Subject
+	}
+	if subject == "" {
+		return nil, errors.New("oidc provider didn't return a subject")
+	}
+
+	email := stringClaim(oidcProfileData, "email")
+	if msg, valid := verifyEmailDomain(c.EmailDomains, email); !valid {
+		return nil, loginRejected(msg)
+	}
+
+	groups := groupsClaim(oidcProfileData[c.GroupsClaim])
+	if msg, valid := verifyGroups(c.AllowedGroups, groups); !valid {
+		return nil, loginRejected(msg)
+	}
+
+	claims := &authsession.Claims{}
+	for claimName, rule := range c.ClaimMapping {
+		result, err := rule.Evaluate(oidcProfileData)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// If result is 'false' or an empty string then don't include the Claim
+		if val, ok := result.(bool); ok && val {
+			claims.Add(claimName, strconv.FormatBool(val))
+		} else if val, ok := result.(string); ok && len(val) > 0 {
+			claims.Add(claimName, val)
+		}
+	}
+	for _, group := range groups {
+		claims.Add("group", group)
+	}
+	if len(c.AdminGroups) > 0 && !claims.Contains("admin") {
+		if _, admin := verifyGroups(c.AdminGroups, groups); admin {
+			claims.Add("admin", "true")
+		}
+	}
+
+	// name is optional so fall back to other claims
+	name := subject
+	for _, claim := range []string{"name", "preferred_username", "email"} {
+		if value := stringClaim(oidcProfileData, claim); value != "" {
+			name = value
+			break
+		}
 	}
+
+	return &authsession.Identity{
+		Provider:      c.Name,
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: boolClaim(oidcProfileData, "email_verified"),
+		Name:          name,
+		Claims:        *claims,
+	}, nil
+}
+
+// hasUserInfo reports if the provider has a userinfo endpoint
+func hasUserInfo(provider *oidc.Provider) bool {
+	var metadata struct {
+		UserInfoURL string `json:"userinfo_endpoint"`
+	}
+	return provider.Claims(&metadata) == nil && metadata.UserInfoURL != ""
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim reports if a claim is true. Some providers
+// (i.e. aws cognito) send booleans as strings.
+func boolClaim(claims map[string]interface{}, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(value, "true")
+	}
+	return false
+}
+
+// pkceVerifier creates a PKCE code verifier (RFC 7636)
+func pkceVerifier() string {
+	return base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
 }
 
 func verifyEmailDomain(allowedDomains []string, email string) (string, bool) {
@@ -162,6 +467,40 @@ func verifyEmailDomain(allowedDomains []string, email string) (string, bool) {
 	return "email domain not authorized", false
 }
 
+// verifyGroups checks that the user is in at least
+// one of the allowed groups if there are any.
+func verifyGroups(allowedGroups []string, groups []string) (string, bool) {
+	if len(allowedGroups) == 0 {
+		return "", true
+	}
+	for _, allowed := range allowedGroups {
+		for _, group := range groups {
+			if allowed == group {
+				return "", true
+			}
+		}
+	}
+	return "not a member of an authorized group", false
+}
+
+// groupsClaim reads a claim that's a list of groups or a single group
+func groupsClaim(value interface{}) []string {
+	groups := []string{}
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			groups = append(groups, v)
+		}
+	case []interface{}:
+		for _, group := range v {
+			if g, ok := group.(string); ok {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
 type ruleExpression struct {
 	*govaluate.EvaluableExpression
 }
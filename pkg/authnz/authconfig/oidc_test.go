@@ -0,0 +1,441 @@
+package authconfig
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestOIDCGroups(t *testing.T) {
+	require := require.New(t)
+
+	idp := newTestIDP(t)
+	defer idp.Close()
+	idp.users["alice"] = &testIDPUser{Name: "Alice", Email: "alice@example.com", Groups: []string{"vpn", "vpn-admins"}}
+	idp.users["bob"] = &testIDPUser{Name: "Bob", Email: "bob@example.com", Groups: []string{"sales"}}
+
+	c := &OIDCConfig{
+		Name:          "idp",
+		Issuer:        idp.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://wg-access-server/callback",
+		AllowedGroups: []string{"vpn"},
+		AdminGroups:   []string{"vpn-admins"},
+	}
+	provider := c.Provider()
+	runtime, handler := testProviderRuntime(provider)
+
+	cookie, status := oidcLogin(t, idp, runtime, provider, handler, "alice")
+	require.Equal(http.StatusSeeOther, status)
+	s := testSession(t, runtime, cookie)
+	require.Equal("alice", s.Identity.Subject)
+	require.Equal("Alice", s.Identity.Name)
+	require.True(s.Identity.Claims.Has("group", "vpn"))
+	require.True(s.Identity.Claims.Contains("admin"))
+	require.NotNil(s.Refresh)
+	require.Equal("OIDC", s.Refresh.Provider)
+
+	// users outside the allowed groups can't sign in
+	_, status = oidcLogin(t, idp, runtime, provider, handler, "bob")
+	require.Equal(http.StatusForbidden, status)
+
+	// group changes are picked up when the session is refreshed
+	idp.setGroups("alice", []string{"vpn"})
+	s, err := provider.Refresh(context.Background(), s)
+	require.NoError(err)
+	require.False(s.Identity.Claims.Contains("admin"))
+
+	idp.setGroups("alice", []string{"sales"})
+	_, err = provider.Refresh(context.Background(), s)
+	require.Error(err)
+
+	// sessions are kept and retried later when the provider is failing
+	idp.setGroups("alice", []string{"vpn"})
+	idp.unavailable = true
+	retry, err := provider.Refresh(context.Background(), s)
+	require.NoError(err)
+	require.Equal(s.Identity, retry.Identity)
+	require.Equal(s.Refresh.Token, retry.Refresh.Token)
+	require.True(retry.Refresh.At.After(time.Now()))
+	idp.unavailable = false
+
+	idp.userinfoUnavailable = true
+	retry, err = provider.Refresh(context.Background(), retry)
+	require.NoError(err)
+	require.Equal(s.Identity, retry.Identity)
+	require.True(retry.Refresh.At.Before(time.Now().Add(time.Hour)))
+	idp.userinfoUnavailable = false
+
+	// disabled users can't refresh their session
+	s, err = provider.Refresh(context.Background(), retry)
+	require.NoError(err)
+	idp.disable("alice")
+	_, err = provider.Refresh(context.Background(), s)
+	require.Error(err)
+}
+
+func TestOIDCCheckUser(t *testing.T) {
+	require := require.New(t)
+
+	idp := newTestIDP(t)
+	defer idp.Close()
+	idp.users["alice"] = &testIDPUser{Name: "Alice", Groups: []string{"vpn"}}
+
+	c := &OIDCConfig{
+		Issuer:        idp.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://wg-access-server/callback",
+		AllowedGroups: []string{"vpn"},
+	}
+	provider := c.Provider()
+	ctx := context.Background()
+	refresh := &authsession.Refresh{Provider: "OIDC", Token: "refresh:alice"}
+
+	status, next, err := provider.CheckUser(ctx, "alice", refresh)
+	require.NoError(err)
+	require.Equal(authruntime.UserActive, status)
+	require.Equal("refresh:alice", next.Token)
+
+	// users without a refresh token can't be checked
+	status, _, err = provider.CheckUser(ctx, "alice", nil)
+	require.NoError(err)
+	require.Equal(authruntime.UserUnknown, status)
+
+	// users that left the allowed groups are deprovisioned
+	idp.setGroups("alice", []string{"sales"})
+	status, _, err = provider.CheckUser(ctx, "alice", refresh)
+	require.NoError(err)
+	require.Equal(authruntime.UserDeprovisioned, status)
+
+	// a rejected refresh token may have just expired
+	// so the user isn't deprovisioned
+	idp.setGroups("alice", []string{"vpn"})
+	idp.disable("alice")
+	status, next, err = provider.CheckUser(ctx, "alice", refresh)
+	require.Error(err)
+	require.Equal(authruntime.UserUnknown, status)
+	require.Nil(next)
+
+	// an unreachable provider isn't a reason to deprovision anyone
+	idp.Close()
+	status, _, err = provider.CheckUser(ctx, "alice", refresh)
+	require.Error(err)
+	require.Equal(authruntime.UserUnknown, status)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	require := require.New(t)
+
+	idp := newTestIDP(t)
+	defer idp.Close()
+	idp.users["alice"] = &testIDPUser{Email: "alice@example.com"}
+
+	c := &OIDCConfig{
+		Name:         "idp",
+		Issuer:       idp.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://wg-access-server/callback",
+	}
+	provider := c.Provider()
+	runtime, handler := testProviderRuntime(provider)
+
+	// the name claim is optional
+	cookie, status := oidcLogin(t, idp, runtime, provider, handler, "alice")
+	require.Equal(http.StatusSeeOther, status)
+	s := testSession(t, runtime, cookie)
+	require.Equal("alice@example.com", s.Identity.Name)
+	require.False(s.Identity.EmailVerified)
+
+	// some providers send email_verified as a string
+	for _, verified := range []interface{}{true, "true"} {
+		idp.users["bob"] = &testIDPUser{Email: "bob@example.com", EmailVerified: verified}
+		cookie, _ := oidcLogin(t, idp, runtime, provider, handler, "bob")
+		require.True(testSession(t, runtime, cookie).Identity.EmailVerified, verified)
+	}
+
+	callback := func(params url.Values) int {
+		w := httptest.NewRecorder()
+		provider.Invoke(w, httptest.NewRequest(http.MethodGet, "/signin/0", nil), runtime)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(err)
+		query := location.Query()
+		require.Equal("S256", query.Get("code_challenge_method"))
+		for k, v := range params {
+			query[k] = v
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/callback?"+url.Values{
+			"state": {query.Get("state")},
+			"code":  {idp.authorize("alice", query)},
+		}.Encode(), nil)
+		r.AddCookie(w.Result().Cookies()[0])
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// the code can only be exchanged with the PKCE verifier
+	require.Equal(http.StatusBadRequest, callback(url.Values{"code_challenge": {"other"}}))
+
+	// the id token must be for this login
+	require.Equal(http.StatusBadRequest, callback(url.Values{"nonce": {"other"}}))
+
+	// an id token is required to sign in but not to refresh
+	idp.noIDToken = true
+	require.Equal(http.StatusBadRequest, callback(nil))
+	_, err := provider.Refresh(context.Background(), s)
+	require.NoError(err)
+}
+
+func TestGroupsClaim(t *testing.T) {
+	require := require.New(t)
+	require.Equal([]string{"a", "b"}, groupsClaim([]interface{}{"a", "b", 1}))
+	require.Equal([]string{"a"}, groupsClaim("a"))
+	require.Empty(groupsClaim(nil))
+
+	_, ok := verifyGroups(nil, nil)
+	require.True(ok)
+	_, ok = verifyGroups([]string{"a"}, []string{"b"})
+	require.False(ok)
+}
+
+// oidcLogin signs in as a user and returns the session cookie
+// and the status code of the callback
+func oidcLogin(t *testing.T, idp *testIDP, runtime *authruntime.ProviderRuntime, provider *authruntime.Provider, handler http.Handler, user string) (*http.Cookie, int) {
+	w := httptest.NewRecorder()
+	provider.Invoke(w, httptest.NewRequest(http.MethodGet, "/signin/0", nil), runtime)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	cookie := w.Result().Cookies()[0]
+
+	callback := url.Values{
+		"state": {location.Query().Get("state")},
+		"code":  {idp.authorize(user, location.Query())},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/callback?"+callback.Encode(), nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		cookie = cookies[0]
+	}
+	return cookie, w.Code
+}
+
+func testProviderRuntime(provider *authruntime.Provider) (*authruntime.ProviderRuntime, http.Handler) {
+	hashKey, blockKey := authsession.SessionKeys("secret")
+	runtime := authruntime.NewProviderRuntime(sessions.NewCookieStore(hashKey, blockKey), nil, nil, nil)
+	router := mux.NewRouter()
+	provider.RegisterRoutes(router, runtime)
+	return runtime, router
+}
+
+func testSession(t *testing.T, runtime *authruntime.ProviderRuntime, cookie *http.Cookie) *authsession.AuthSession {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	s, err := runtime.GetSession(r)
+	require.NoError(t, err)
+	return s
+}
+
+type testIDPLogin struct {
+	user      string
+	nonce     string
+	challenge string
+}
+
+type testIDPUser struct {
+	Name  string
+	Email string
+	// EmailVerified is sent as the email_verified
+	// claim of the id token if it's set
+	EmailVerified interface{}
+	Groups        []string
+	Disabled      bool
+}
+
+// testIDP is a minimal oidc provider.
+// Tokens are "<kind>:<username>".
+type testIDP struct {
+	*httptest.Server
+	t      *testing.T
+	key    *rsa.PrivateKey
+	lock   sync.Mutex
+	users  map[string]*testIDPUser
+	codes  map[string]testIDPLogin
+	signer jose.Signer
+	// noIDToken stops id tokens being returned
+	noIDToken bool
+	// unavailable makes the token endpoint respond with 503
+	unavailable bool
+	// userinfoUnavailable makes the userinfo endpoint respond with 503
+	userinfoUnavailable bool
+}
+
+func newTestIDP(t *testing.T) *testIDP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "test"}}, nil)
+	require.NoError(t, err)
+
+	idp := &testIDP{t: t, key: key, users: map[string]*testIDPUser{}, codes: map[string]testIDPLogin{}, signer: signer}
+	router := mux.NewRouter()
+	router.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	router.HandleFunc("/keys", idp.keys)
+	router.HandleFunc("/token", idp.token)
+	router.HandleFunc("/userinfo", idp.userinfo)
+	idp.Server = httptest.NewServer(router)
+	return idp
+}
+
+// authorize signs in a user with the parameters of an
+// authorization request and returns the authorization code
+func (idp *testIDP) authorize(user string, params url.Values) string {
+	idp.lock.Lock()
+	defer idp.lock.Unlock()
+	code := fmt.Sprintf("code-%d", len(idp.codes))
+	idp.codes[code] = testIDPLogin{
+		user:      user,
+		nonce:     params.Get("nonce"),
+		challenge: params.Get("code_challenge"),
+	}
+	return code
+}
+
+func (idp *testIDP) setGroups(user string, groups []string) {
+	idp.lock.Lock()
+	defer idp.lock.Unlock()
+	idp.users[user].Groups = groups
+}
+
+func (idp *testIDP) disable(user string) {
+	idp.lock.Lock()
+	defer idp.lock.Unlock()
+	idp.users[user].Disabled = true
+}
+
+func (idp *testIDP) user(name string) (*testIDPUser, bool) {
+	idp.lock.Lock()
+	defer idp.lock.Unlock()
+	u, ok := idp.users[name]
+	if !ok || u.Disabled {
+		return nil, false
+	}
+	copy := *u
+	return &copy, true
+}
+
+func (idp *testIDP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                idp.URL,
+		"authorization_endpoint":                idp.URL + "/authorize",
+		"token_endpoint":                        idp.URL + "/token",
+		"userinfo_endpoint":                     idp.URL + "/userinfo",
+		"jwks_uri":                              idp.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *testIDP) keys(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &idp.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+	}})
+}
+
+func (idp *testIDP) token(w http.ResponseWriter, r *http.Request) {
+	if idp.unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var name, nonce string
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		idp.lock.Lock()
+		login, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.lock.Unlock()
+		if ok && login.challenge == pkceChallenge(r.PostFormValue("code_verifier")) {
+			name = login.user
+			nonce = login.nonce
+		}
+	case "refresh_token":
+		name = strings.TrimPrefix(r.PostFormValue("refresh_token"), "refresh:")
+	}
+	user, ok := idp.user(name)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	res := map[string]interface{}{
+		"access_token":  "access:" + name,
+		"refresh_token": "refresh:" + name,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	}
+	if !idp.noIDToken {
+		claims := map[string]interface{}{
+			"iss":    idp.URL,
+			"sub":    name,
+			"aud":    "client",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"iat":    time.Now().Unix(),
+			"groups": user.Groups,
+		}
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		if user.EmailVerified != nil {
+			claims["email_verified"] = user.EmailVerified
+		}
+		idToken, err := jwt.Signed(idp.signer).Claims(claims).CompactSerialize()
+		require.NoError(idp.t, err)
+		res["id_token"] = idToken
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (idp *testIDP) userinfo(w http.ResponseWriter, r *http.Request) {
+	if idp.userinfoUnavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	name := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer access:")
+	user, ok := idp.user(name)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	info := map[string]interface{}{
+		"sub":   name,
+		"email": user.Email,
+	}
+	if user.Name != "" {
+		info["name"] = user.Name
+	}
+	json.NewEncoder(w).Encode(info)
+}
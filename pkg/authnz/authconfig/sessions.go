@@ -0,0 +1,26 @@
+package authconfig
+
+import "time"
+
+// the default lifetime of a login session
+const defaultSessionTTL = 7 * 24 * time.Hour
+
+type SessionConfig struct {
+	// Secret signs and encrypts session cookies.
+	// Set it to keep users signed in across restarts
+	// and replicas. A random secret is used if it's empty.
+	Secret string `yaml:"secret"`
+	// TTL is how long users stay signed in.
+	// Defaults to 7 days
+	TTL time.Duration `yaml:"ttl"`
+	// ServerSide stores sessions in the storage backend
+	// so that they can be listed and revoked by admins.
+	ServerSide bool `yaml:"serverSide"`
+}
+
+func (c SessionConfig) SessionTTL() time.Duration {
+	if c.TTL <= 0 {
+		return defaultSessionTTL
+	}
+	return c.TTL
+}
@@ -0,0 +1,76 @@
+package authconfig
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+)
+
+// the default limits of password logins
+const (
+	defaultMaxFailures = 5
+	defaultLockout     = 15 * time.Minute
+)
+
+// ThrottleConfig limits password logins i.e. basic and ldap
+type ThrottleConfig struct {
+	// MaxFailures is how many wrong passwords in a row
+	// lock a username out. Defaults to 5
+	MaxFailures int `yaml:"maxFailures"`
+	// MaxAddressFailures is how many failed logins from an IP
+	// address lock it out. Addresses aren't limited by default.
+	MaxAddressFailures int `yaml:"maxAddressFailures"`
+	// Lockout is how long usernames and addresses are
+	// locked out for. Defaults to 15 minutes
+	Lockout time.Duration `yaml:"lockout"`
+	// TrustedProxies are the IP addresses or CIDRs of reverse
+	// proxies whose X-Forwarded-For header is trusted
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+func (c ThrottleConfig) Limits() authruntime.ThrottleLimits {
+	limits := authruntime.ThrottleLimits{
+		MaxFailures:        c.MaxFailures,
+		MaxAddressFailures: c.MaxAddressFailures,
+		Lockout:            c.Lockout,
+	}
+	if limits.MaxFailures <= 0 {
+		limits.MaxFailures = defaultMaxFailures
+	}
+	if limits.MaxAddressFailures < 0 {
+		limits.MaxAddressFailures = 0
+	}
+	if limits.Lockout <= 0 {
+		limits.Lockout = defaultLockout
+	}
+	return limits
+}
+
+// Proxies parses the trusted proxies
+func (c ThrottleConfig) Proxies() ([]*net.IPNet, error) {
+	proxies := []*net.IPNet{}
+	for _, proxy := range c.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s' - must be an ip address or cidr", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s' - must be an ip address or cidr", proxy)
+		}
+		proxies = append(proxies, cidr)
+	}
+	return proxies, nil
+}
@@ -0,0 +1,28 @@
+package authconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleConfig(t *testing.T) {
+	require := require.New(t)
+
+	limits := ThrottleConfig{}.Limits()
+	require.Equal(defaultMaxFailures, limits.MaxFailures)
+	require.Zero(limits.MaxAddressFailures, "addresses aren't limited by default")
+	require.Equal(defaultLockout, limits.Lockout)
+
+	proxies, err := ThrottleConfig{TrustedProxies: []string{"10.0.0.1", "172.16.0.0/12", "fd00::1"}}.Proxies()
+	require.NoError(err)
+	require.Len(proxies, 3)
+	require.Equal("10.0.0.1/32", proxies[0].String())
+	require.Equal("172.16.0.0/12", proxies[1].String())
+	require.Equal("fd00::1/128", proxies[2].String())
+
+	_, err = ThrottleConfig{TrustedProxies: []string{"proxy.local"}}.Proxies()
+	require.Error(err)
+	_, err = ThrottleConfig{TrustedProxies: []string{"10.0.0.0/33"}}.Proxies()
+	require.Error(err)
+}
@@ -1,29 +1,99 @@
 package authruntime
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
+	"github.com/pkg/errors"
 	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/sirupsen/logrus"
 )
 
+// UserStatus is the result of checking a user with their provider
+type UserStatus int
+
+const (
+	// UserUnknown means the provider couldn't tell
+	// i.e. it was unreachable
+	UserUnknown UserStatus = iota
+	UserActive
+	// UserDeprovisioned means the user was removed or
+	// disabled and shouldn't have access anymore
+	UserDeprovisioned
+)
+
+func (s UserStatus) String() string {
+	switch s {
+	case UserActive:
+		return "active"
+	case UserDeprovisioned:
+		return "deprovisioned"
+	}
+	return "unknown"
+}
+
 type Provider struct {
-	Type           string
+	Type string
+	// Name is the Provider of the identities that
+	// this provider creates i.e. "basic"
+	Name string
+	// PasswordForm providers are rendered as a username/password
+	// form on the login page that is POSTed to Invoke.
+	PasswordForm bool
+	// SecondFactor is true if the provider's users can
+	// enroll a TOTP second factor
+	SecondFactor   bool
 	Invoke         func(http.ResponseWriter, *http.Request, *ProviderRuntime)
 	RegisterRoutes func(*mux.Router, *ProviderRuntime) error
+	// Refresh re-checks the user of a session that has
+	// Refresh state from this provider. It returns the updated
+	// session or an error if the user must sign in again.
+	Refresh func(context.Context, *authsession.AuthSession) (*authsession.AuthSession, error)
+	// CheckUser checks if a user still exists with the provider
+	// when they aren't signed in. refresh is the user's last
+	// Refresh state and may be nil. Providers that rotate
+	// refresh tokens return the new state.
+	CheckUser func(ctx context.Context, subject string, refresh *authsession.Refresh) (UserStatus, *authsession.Refresh, error)
 }
 
+// how long a user has to enter their second factor
+// after signing in with their password
+const secondFactorTimeout = 5 * time.Minute
+
 type ProviderRuntime struct {
-	store sessions.Store
+	store         sessions.Store
+	users         authsession.UserRecorder
+	secondFactors authsession.SecondFactors
+	throttle      *Throttle
 }
 
-func NewProviderRuntime(store sessions.Store) *ProviderRuntime {
-	return &ProviderRuntime{store}
+// NewProviderRuntime creates a ProviderRuntime. Users that sign in
+// or refresh their session are recorded by users if it's not nil.
+// Second factors are disabled if secondFactors is nil and password
+// logins aren't rate limited if throttle is nil.
+func NewProviderRuntime(store sessions.Store, users authsession.UserRecorder, secondFactors authsession.SecondFactors, throttle *Throttle) *ProviderRuntime {
+	return &ProviderRuntime{store, users, secondFactors, throttle}
+}
+
+// SecondFactors returns nil if second factors are disabled
+func (p *ProviderRuntime) SecondFactors() authsession.SecondFactors {
+	return p.secondFactors
 }
 
 func (p *ProviderRuntime) SetSession(w http.ResponseWriter, r *http.Request, s *authsession.AuthSession) error {
-	return authsession.SetSession(p.store, r, w, s)
+	if err := authsession.SetSession(p.store, r, w, s); err != nil {
+		return err
+	}
+	if s.Identity != nil && p.users != nil {
+		// the user is signed in even if they can't be recorded
+		if err := p.users.RecordUser(s); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to record user"))
+		}
+	}
+	return nil
 }
 
 func (p *ProviderRuntime) GetSession(r *http.Request) (*authsession.AuthSession, error) {
@@ -38,6 +108,40 @@ func (p *ProviderRuntime) Restart(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/signin", http.StatusTemporaryRedirect)
 }
 
+// LoginFailed sends the user back to the login page
+// with an "invalid username or password" message.
+func (p *ProviderRuntime) LoginFailed(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/signin?failed=true", http.StatusSeeOther)
+}
+
+// SecondFactor signs in a user that authenticated with their
+// password. Users that enrolled a second factor are sent to
+// the second factor step of the login first.
+func (p *ProviderRuntime) SecondFactor(w http.ResponseWriter, r *http.Request, identity *authsession.Identity) {
+	if p.secondFactors != nil {
+		enrolled, err := p.secondFactors.Enrolled(identity.Provider, identity.Subject)
+		if err != nil {
+			logrus.Error(errors.Wrap(err, "failed to check second factor"))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if enrolled {
+			p.SetSession(w, r, &authsession.AuthSession{
+				SecondFactor: &authsession.SecondFactorStep{
+					Identity: identity,
+					Expires:  time.Now().Add(secondFactorTimeout),
+				},
+			})
+			http.Redirect(w, r, "/signin/totp", http.StatusSeeOther)
+			return
+		}
+	}
+	p.SetSession(w, r, &authsession.AuthSession{Identity: identity})
+	p.Done(w, r)
+}
+
 func (p *ProviderRuntime) Done(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+	// 303 so that the browser follows the redirect
+	// with a GET after a login form is POSTed
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
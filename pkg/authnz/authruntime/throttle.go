@@ -0,0 +1,334 @@
+package authruntime
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+)
+
+const (
+	// the first retry after a failed login waits 1s and
+	// every failure after that doubles the wait up to 30s
+	backoffBase = time.Second
+	backoffMax  = 30 * time.Second
+	// attempts that are never finished i.e. because of a
+	// provider bug stop counting against the limits
+	attemptTimeout = time.Minute
+	pruneInterval  = time.Minute
+	// how long an address that a user signed in from isn't
+	// affected by the lockout of their username
+	trustedAddressTTL = 30 * 24 * time.Hour
+)
+
+// ThrottleLimits are the limits of password logins
+type ThrottleLimits struct {
+	// MaxFailures is how many wrong passwords in a row
+	// lock a username out
+	MaxFailures int
+	// MaxAddressFailures is how many failed logins from an
+	// address lock it out. Addresses aren't limited if it's 0.
+	MaxAddressFailures int
+	// Lockout is how long usernames and addresses are locked
+	// out for. Failures are forgotten after this long too.
+	Lockout time.Duration
+}
+
+// Throttle rate limits password logins by username and by the
+// source address of the request. Retries after a failed login
+// are delayed with an exponential backoff and usernames and
+// addresses are locked out after too many failures. Addresses
+// that a user signed in from before have their own limits for
+// the user so that others can't lock them out.
+type Throttle struct {
+	limits  ThrottleLimits
+	auditor authsession.LoginAuditor
+	now     func() time.Time
+	proxies []*net.IPNet
+
+	lock      sync.Mutex
+	attempts  map[string]*attempts
+	trusted   map[string]time.Time
+	lastPrune time.Time
+}
+
+type attempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+	// logins that are being checked right now count
+	// against the limits so that an attacker can't send
+	// many guesses at once before the first one fails
+	pending   int
+	lastStart time.Time
+}
+
+// NewThrottle creates a Throttle. Failed logins are
+// recorded by auditor if it's not nil.
+func NewThrottle(limits ThrottleLimits, auditor authsession.LoginAuditor) *Throttle {
+	return &Throttle{
+		limits:   limits,
+		auditor:  auditor,
+		now:      time.Now,
+		attempts: map[string]*attempts{},
+		trusted:  map[string]time.Time{},
+	}
+}
+
+// SetTrustedProxies sets the reverse proxies whose
+// X-Forwarded-For header is used as the source address
+// of a login. By default no proxies are trusted.
+func (t *Throttle) SetTrustedProxies(proxies []*net.IPNet) {
+	t.proxies = proxies
+}
+
+// LoginAttempt is a password login that the throttle allowed.
+// Providers must call Failed or Succeeded once they've checked the
+// password, or Cancel if they couldn't i.e. the server is down.
+type LoginAttempt struct {
+	throttle *Throttle
+	provider string
+	username string
+	address  string
+	keys     map[string]int
+	done     bool
+}
+
+// Start starts a login attempt. It returns an error that can
+// be shown to the user and how long they have to wait if the
+// username or the address is throttled.
+func (t *Throttle) Start(provider string, username string, address string) (*LoginAttempt, time.Duration, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	t.prune(now)
+	keys := t.keys(provider, username, address)
+	wait := time.Duration(0)
+	for key, max := range keys {
+		if w := t.get(key, now).wait(now, max); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return nil, wait, fmt.Errorf("too many failed logins - try again in %s", wait.Round(time.Second))
+	}
+	for key := range keys {
+		a := t.get(key, now)
+		a.pending++
+		a.lastStart = now
+	}
+	return &LoginAttempt{
+		throttle: t,
+		provider: provider,
+		username: username,
+		address:  address,
+		keys:     keys,
+	}, 0, nil
+}
+
+// Failed records a wrong password
+func (a *LoginAttempt) Failed() {
+	if a.finish() {
+		return
+	}
+	t := a.throttle
+	t.lock.Lock()
+	now := t.now()
+	locked := false
+	for key, max := range a.keys {
+		e := t.get(key, now)
+		e.failures++
+		e.lastFailure = now
+		if e.failures >= max {
+			e.failures = 0
+			e.lockedUntil = now.Add(t.limits.Lockout)
+			locked = true
+		}
+	}
+	t.lock.Unlock()
+
+	if t.auditor != nil {
+		t.auditor.LoginFailed(a.provider, a.username, a.address, locked)
+	}
+}
+
+// Succeeded forgets the failed logins of the username
+// and trusts the address for the username
+func (a *LoginAttempt) Succeeded() {
+	if a.finish() {
+		return
+	}
+	t := a.throttle
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := userKey(a.provider, a.username)
+	delete(t.attempts, key)
+	delete(t.attempts, key+"@"+a.address)
+	t.trusted[key+"@"+a.address] = t.now().Add(trustedAddressTTL)
+}
+
+// Cancel ends an attempt without a result. It does
+// nothing if Failed or Succeeded were called.
+func (a *LoginAttempt) Cancel() {
+	a.finish()
+}
+
+// finish stops the attempt from counting as pending.
+// It returns true if the attempt was already finished.
+func (a *LoginAttempt) finish() bool {
+	if a.done {
+		return true
+	}
+	a.done = true
+	t := a.throttle
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for key := range a.keys {
+		if e, ok := t.attempts[key]; ok && e.pending > 0 {
+			e.pending--
+		}
+	}
+	return false
+}
+
+// keys returns the keys of an attempt and their limits
+func (t *Throttle) keys(provider string, username string, address string) map[string]int {
+	user := userKey(provider, username)
+	if expires, ok := t.trusted[user+"@"+address]; ok && t.now().Before(expires) {
+		// failures from other addresses can't lock the
+		// user out of an address they signed in from
+		user += "@" + address
+	}
+	keys := map[string]int{
+		user: t.limits.MaxFailures,
+	}
+	if t.limits.MaxAddressFailures > 0 {
+		keys["address/"+address] = t.limits.MaxAddressFailures
+	}
+	return keys
+}
+
+func (t *Throttle) get(key string, now time.Time) *attempts {
+	a, ok := t.attempts[key]
+	if !ok {
+		a = &attempts{}
+		t.attempts[key] = a
+	}
+	if a.failures > 0 && !now.Before(a.lastFailure.Add(t.limits.Lockout)) {
+		a.failures = 0
+	}
+	if a.pending > 0 && !now.Before(a.lastStart.Add(attemptTimeout)) {
+		a.pending = 0
+	}
+	return a
+}
+
+// prune forgets usernames and addresses that
+// haven't failed to log in for a while
+func (t *Throttle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < pruneInterval {
+		return
+	}
+	t.lastPrune = now
+	for key, expires := range t.trusted {
+		if !now.Before(expires) {
+			delete(t.trusted, key)
+		}
+	}
+	for key := range t.attempts {
+		a := t.get(key, now)
+		if a.failures == 0 && a.pending == 0 && !now.Before(a.lockedUntil) {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// wait returns how long until the next attempt is allowed
+func (a *attempts) wait(now time.Time, max int) time.Duration {
+	if now.Before(a.lockedUntil) {
+		return a.lockedUntil.Sub(now)
+	}
+	if a.failures+a.pending >= max {
+		// the pending attempts will finish soon
+		return backoffBase
+	}
+	if a.failures > 0 {
+		backoff := backoffBase * time.Duration(math.Pow(2, float64(a.failures-1)))
+		if backoff > backoffMax {
+			backoff = backoffMax
+		}
+		if next := a.lastFailure.Add(backoff); now.Before(next) {
+			return next.Sub(now)
+		}
+	}
+	return 0
+}
+
+func userKey(provider string, username string) string {
+	return "user/" + provider + "/" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// StartLogin starts a password login for username. It writes a 429
+// response and returns nil if the username or the source address
+// of the request is throttled. Logins aren't throttled if the
+// runtime has no throttle.
+func (p *ProviderRuntime) StartLogin(w http.ResponseWriter, r *http.Request, provider string, username string) *LoginAttempt {
+	if p.throttle == nil {
+		return &LoginAttempt{done: true}
+	}
+	address := p.throttle.sourceAddress(r)
+	attempt, wait, err := p.throttle.Start(provider, username, address)
+	if err != nil {
+		ctxlogrus.Extract(r.Context()).Infof("throttled login of %s/%s from %s", provider, username, address)
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return nil
+	}
+	return attempt
+}
+
+// sourceAddress returns the address that a request comes from.
+// Requests from trusted proxies come from the last address in
+// their X-Forwarded-For header that isn't a trusted proxy.
+func (t *Throttle) sourceAddress(r *http.Request) string {
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+	if !t.trustedProxy(address) {
+		return address
+	}
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// the rest of the header can't be trusted
+			break
+		}
+		address = ip.String()
+		if !t.trustedProxy(address) {
+			break
+		}
+	}
+	return address
+}
+
+func (t *Throttle) trustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range t.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package authruntime
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuditor struct {
+	failed []string
+	locked int
+}
+
+func (a *fakeAuditor) LoginFailed(provider string, username string, address string, locked bool) {
+	a.failed = append(a.failed, provider+"/"+username+"@"+address)
+	if locked {
+		a.locked++
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	require := require.New(t)
+
+	auditor := &fakeAuditor{}
+	throttle := NewThrottle(ThrottleLimits{MaxFailures: 3, MaxAddressFailures: 5, Lockout: time.Hour}, auditor)
+	now := time.Unix(1600000000, 0)
+	throttle.now = func() time.Time { return now }
+
+	start := func(username string, address string) (*LoginAttempt, time.Duration) {
+		attempt, wait, err := throttle.Start("basic", username, address)
+		if err != nil {
+			require.Nil(attempt)
+			require.True(wait > 0)
+		}
+		return attempt, wait
+	}
+
+	// retries after a failure are delayed with an exponential backoff
+	attempt, _ := start("admin", "10.0.0.1")
+	attempt.Failed()
+	_, wait := start("admin", "10.0.0.1")
+	require.Equal(time.Second, wait)
+	now = now.Add(time.Second)
+	attempt, _ = start("Admin", "10.0.0.2")
+	require.NotNil(attempt, "usernames aren't case sensitive")
+	attempt.Failed()
+	now = now.Add(time.Second)
+	_, wait = start("admin", "10.0.0.1")
+	require.Equal(time.Second, wait)
+	now = now.Add(time.Second)
+
+	// usernames are locked out after too many failures
+	attempt, _ = start("admin", "10.0.0.3")
+	attempt.Failed()
+	require.Len(auditor.failed, 3)
+	require.Equal("basic/admin@10.0.0.3", auditor.failed[2])
+	require.Equal(1, auditor.locked)
+	now = now.Add(time.Minute)
+	_, wait = start("admin", "10.0.0.4")
+	require.Equal(time.Hour-time.Minute, wait)
+	now = now.Add(time.Hour)
+	attempt, _ = start("admin", "10.0.0.4")
+	require.NotNil(attempt)
+
+	// a successful login forgets the failures of the username
+	attempt.Succeeded()
+	attempt, _ = start("admin", "10.0.0.4")
+	attempt.Failed()
+	now = now.Add(time.Second)
+	attempt, _ = start("admin", "10.0.0.4")
+	attempt.Succeeded()
+	attempt, _ = start("admin", "10.0.0.4")
+	require.NotNil(attempt, "no backoff after a successful login")
+	attempt.Cancel()
+	attempt.Failed()
+	require.Len(auditor.failed, 4, "cancelled attempts don't count")
+
+	// guesses that are sent at once count against the limit
+	attempts := []*LoginAttempt{}
+	for i := 0; i < 3; i++ {
+		attempt, _ := start("bob", "10.0.0.5")
+		require.NotNil(attempt)
+		attempts = append(attempts, attempt)
+	}
+	_, wait = start("bob", "10.0.0.5")
+	require.NotZero(wait)
+	for _, attempt := range attempts {
+		attempt.Cancel()
+	}
+
+	// addresses are locked out after too many failures of any username
+	for _, username := range []string{"a", "b", "c", "d", "e"} {
+		now = now.Add(backoffMax)
+		attempt, _ := start(username, "10.0.0.6")
+		require.NotNil(attempt)
+		attempt.Failed()
+	}
+	_, wait = start("f", "10.0.0.6")
+	require.Equal(time.Hour, wait)
+	attempt, _ = start("f", "10.0.0.7")
+	require.NotNil(attempt)
+	attempt.Cancel()
+
+	// the runtime responds with 429 to throttled logins
+	runtime := NewProviderRuntime(nil, nil, nil, throttle)
+	r := httptest.NewRequest(http.MethodPost, "/signin/0", nil)
+	r.RemoteAddr = "10.0.0.6:1234"
+	w := httptest.NewRecorder()
+	require.Nil(runtime.StartLogin(w, r, "basic", "f"))
+	require.Equal(http.StatusTooManyRequests, w.Code)
+	require.Equal("3600", w.Header().Get("Retry-After"))
+}
+
+func TestThrottleTrustedAddress(t *testing.T) {
+	require := require.New(t)
+
+	throttle := NewThrottle(ThrottleLimits{MaxFailures: 2, Lockout: time.Hour}, nil)
+	now := time.Unix(1600000000, 0)
+	throttle.now = func() time.Time { return now }
+
+	attempt, _, err := throttle.Start("basic", "admin", "10.0.0.1")
+	require.NoError(err)
+	attempt.Succeeded()
+
+	// someone else locks the username out
+	for i := 0; i < 2; i++ {
+		now = now.Add(backoffMax)
+		attempt, _, err := throttle.Start("basic", "admin", "10.0.0.2")
+		require.NoError(err)
+		attempt.Failed()
+	}
+	_, wait, _ := throttle.Start("basic", "admin", "10.0.0.3")
+	require.Equal(time.Hour, wait)
+
+	// but the user can still sign in from their address
+	attempt, _, err = throttle.Start("basic", "Admin", "10.0.0.1")
+	require.NoError(err)
+	attempt.Failed()
+	now = now.Add(time.Second)
+	attempt, _, err = throttle.Start("basic", "admin", "10.0.0.1")
+	require.NoError(err)
+	attempt.Failed()
+	_, wait, _ = throttle.Start("basic", "admin", "10.0.0.1")
+	require.Equal(time.Hour, wait, "trusted addresses have their own limit")
+
+	// addresses are only trusted for a while
+	now = now.Add(trustedAddressTTL)
+	_, _, err = throttle.Start("basic", "admin", "10.0.0.1")
+	require.NoError(err)
+	require.NotContains(throttle.trusted, "user/basic/admin@10.0.0.1")
+}
+
+func TestSourceAddress(t *testing.T) {
+	require := require.New(t)
+
+	throttle := NewThrottle(ThrottleLimits{MaxFailures: 5}, nil)
+	_, proxies, err := net.ParseCIDR("10.0.0.0/24")
+	require.NoError(err)
+	throttle.SetTrustedProxies([]*net.IPNet{proxies})
+
+	address := func(remote string, forwarded ...string) string {
+		r := httptest.NewRequest(http.MethodPost, "/signin/0", nil)
+		r.RemoteAddr = remote
+		for _, f := range forwarded {
+			r.Header.Add("X-Forwarded-For", f)
+		}
+		return throttle.sourceAddress(r)
+	}
+
+	require.Equal("1.2.3.4", address("1.2.3.4:1234", "5.6.7.8"), "only proxies are trusted")
+	require.Equal("10.0.0.1", address("10.0.0.1:1234"))
+	require.Equal("5.6.7.8", address("10.0.0.1:1234", "5.6.7.8"))
+	require.Equal("5.6.7.8", address("10.0.0.1:1234", "9.9.9.9, 5.6.7.8, 10.0.0.2"), "clients can add their own hops")
+	require.Equal("5.6.7.8", address("10.0.0.1:1234", "9.9.9.9", "5.6.7.8"))
+	require.Equal("10.0.0.2", address("10.0.0.1:1234", "bogus, 10.0.0.2"))
+}
@@ -12,6 +12,9 @@ type Identity struct {
 	// Email is the email address of the person this Identity refers to.
 	// It may be empty.
 	Email string
+	// EmailVerified is true if the provider verified that
+	// the person owns the email address.
+	EmailVerified bool
 	// Claims are any additional claims that middleware have
 	// added to this Identity.
 	Claims Claims
@@ -1,3 +1,50 @@
 package authsession
 
+import "github.com/pkg/errors"
+
+// ErrUserDisabled is returned by a ClaimsMiddleware
+// to sign out a user that isn't allowed to sign in.
+var ErrUserDisabled = errors.New("user is disabled")
+
 type ClaimsMiddleware func(user *Identity) error
+
+// TokenVerifier authenticates requests that have
+// an "Authorization: Bearer <token>" header.
+type TokenVerifier interface {
+	VerifyToken(token string) (*Identity, error)
+}
+
+// UserRecorder is told about users when they sign in
+// or their session is refreshed.
+type UserRecorder interface {
+	RecordUser(s *AuthSession) error
+	// RefreshToken returns the user's latest refresh token or an
+	// empty string if it isn't known. Sessions are refreshed with it
+	// because providers that rotate refresh tokens may have issued
+	// a new one to another replica or a background check.
+	RefreshToken(provider string, subject string) (string, error)
+}
+
+// SecondFactors stores the TOTP second factors of users.
+// A second factor is enrolled once it's confirmed.
+type SecondFactors interface {
+	// Enrolled reports if a user has confirmed a second factor
+	Enrolled(provider string, subject string) (bool, error)
+	// Verify checks a TOTP code or, once the second factor is
+	// confirmed, a recovery code. Codes can only be used once.
+	Verify(provider string, subject string, code string) (bool, error)
+	// Enroll returns the user's unconfirmed TOTP secret, base32
+	// encoded, or creates one. It fails if they're already enrolled.
+	Enroll(provider string, subject string) (string, error)
+	// Confirm enrolls the user's unconfirmed secret after they
+	// entered a valid code. It returns their recovery codes.
+	Confirm(provider string, subject string) ([]string, error)
+	Remove(provider string, subject string) error
+}
+
+// LoginAuditor records failed password logins i.e. in an audit log
+type LoginAuditor interface {
+	// LoginFailed is called after a wrong password. locked is
+	// true if the username or the address is now locked out.
+	LoginFailed(provider string, username string, address string, locked bool)
+}
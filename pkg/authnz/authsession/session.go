@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/pkg/errors"
@@ -13,6 +14,32 @@ import (
 type AuthSession struct {
 	Nonce    *string
 	Identity *Identity
+	// CodeVerifier is the PKCE code verifier of an oauth2 login
+	CodeVerifier string `json:",omitempty"`
+	// Refresh is set by providers that periodically
+	// re-check users with the identity provider
+	Refresh *Refresh `json:",omitempty"`
+	// SecondFactor is set when a user signed in with
+	// their password but hasn't entered their second factor
+	SecondFactor *SecondFactorStep `json:",omitempty"`
+}
+
+// SecondFactorStep is a login that's waiting for a second factor
+type SecondFactorStep struct {
+	Identity *Identity
+	Expires  time.Time
+}
+
+// Refresh is the state that a provider needs
+// to refresh a session.
+type Refresh struct {
+	// Provider is the type of the provider that
+	// created the session i.e. "OIDC"
+	Provider string
+	// Token is i.e. an oauth2 refresh token
+	Token string
+	// At is when the session is next refreshed
+	At time.Time
 }
 
 type authSessionKey string
@@ -0,0 +1,160 @@
+package authsession
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/pkg/errors"
+)
+
+// SessionBackend persists sessions server-side.
+// Sessions are identified by a hash of their cookie
+// so the backend never stores usable session tokens.
+type SessionBackend interface {
+	SaveSession(id string, s *AuthSession, expires time.Time) error
+	// LoadSession returns an error if the session doesn't
+	// exist, has expired or has been revoked
+	LoadSession(id string) (*AuthSession, error)
+	DeleteSession(id string) error
+}
+
+// SessionKeys derives the keys that sign and encrypt
+// session cookies from a secret.
+func SessionKeys(secret string) (hashKey []byte, blockKey []byte) {
+	sum := sha512.Sum512([]byte(secret))
+	return sum[:32], sum[32:]
+}
+
+// SessionID is the id of a session in a SessionBackend
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ServerStore is a sessions.Store that keeps sessions in a
+// SessionBackend. The session cookie only contains a random token
+// so sessions can be listed and revoked server-side.
+type ServerStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+	backend SessionBackend
+}
+
+func NewServerStore(backend SessionBackend, ttl time.Duration, keyPairs ...[]byte) *ServerStore {
+	s := &ServerStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		// not HttpOnly because the web ui checks
+		// for the cookie to show the logout button
+		Options: &sessions.Options{
+			Path: "/",
+		},
+		backend: backend,
+	}
+	s.MaxAge(int(ttl / time.Second))
+	return s
+}
+
+// MaxAge sets the lifetime of new sessions in seconds
+func (s *ServerStore) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+func (s *ServerStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *ServerStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+		return session, err
+	}
+	stored, err := s.backend.LoadSession(SessionID(session.ID))
+	if err != nil {
+		return session, err
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return session, errors.Wrap(err, "failed to marshal session")
+	}
+	session.Values[string(sessionKey)] = data
+	session.Values[loadedIdentityKey] = identityOf(stored)
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores a session and sets its cookie. The session
+// is deleted if its MaxAge is less than or equal to 0.
+func (s *ServerStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.backend.DeleteSession(SessionID(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	stored := &AuthSession{}
+	if data, ok := session.Values[string(sessionKey)].([]byte); ok {
+		if err := json.Unmarshal(data, stored); err != nil {
+			return errors.Wrap(err, "failed to parse session")
+		}
+	}
+
+	// sessions get a new token when someone signs in to
+	// prevent session fixation. refreshed sessions keep their
+	// token so that concurrent requests aren't signed out.
+	if loaded, _ := session.Values[loadedIdentityKey].(string); session.ID == "" || loaded == "" || loaded != identityOf(stored) {
+		if session.ID != "" {
+			if err := s.backend.DeleteSession(SessionID(session.ID)); err != nil {
+				return err
+			}
+		}
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	expires := time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second)
+	if err := s.backend.SaveSession(SessionID(session.ID), stored, expires); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	session.Values[loadedIdentityKey] = identityOf(stored)
+	return nil
+}
+
+// the session value that records who was signed in
+// when the session was loaded
+const loadedIdentityKey = "loaded-identity"
+
+func identityOf(s *AuthSession) string {
+	if s.Identity == nil {
+		return ""
+	}
+	return s.Identity.Provider + "/" + s.Identity.Subject
+}
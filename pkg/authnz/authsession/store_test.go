@@ -0,0 +1,136 @@
+package authsession
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStore(t *testing.T) {
+	require := require.New(t)
+
+	backend := &testBackend{sessions: map[string]*AuthSession{}}
+	hashKey, blockKey := SessionKeys("secret")
+	store := NewServerStore(backend, time.Hour, hashKey, blockKey)
+
+	// sign in
+	w := httptest.NewRecorder()
+	require.NoError(SetSession(store, httptest.NewRequest(http.MethodGet, "/", nil), w, &AuthSession{
+		Identity: &Identity{Subject: "alice"},
+	}))
+	cookie := w.Result().Cookies()[0]
+	require.Equal(3600, cookie.MaxAge)
+	require.Len(backend.sessions, 1)
+	for id := range backend.sessions {
+		require.NotContains(cookie.Value, id)
+	}
+
+	request := func(cookie *http.Cookie) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(cookie)
+		return r
+	}
+
+	s, err := GetSession(store, request(cookie))
+	require.NoError(err)
+	require.Equal("alice", s.Identity.Subject)
+
+	// refreshing the session keeps its token
+	w = httptest.NewRecorder()
+	require.NoError(SetSession(store, request(cookie), w, &AuthSession{
+		Identity: &Identity{Subject: "alice", Name: "Alice"},
+	}))
+	refreshed := w.Result().Cookies()[0]
+	require.Len(backend.sessions, 1)
+	s, err = GetSession(store, request(cookie))
+	require.NoError(err)
+	require.Equal("Alice", s.Identity.Name)
+	_, err = GetSession(store, request(refreshed))
+	require.NoError(err)
+
+	// a new token is issued when someone else signs in
+	w = httptest.NewRecorder()
+	require.NoError(SetSession(store, request(cookie), w, &AuthSession{
+		Identity: &Identity{Subject: "mallory"},
+	}))
+	rotated := w.Result().Cookies()[0]
+	require.NotEqual(cookie.Value, rotated.Value)
+	require.Len(backend.sessions, 1)
+	_, err = GetSession(store, request(cookie))
+	require.Error(err)
+	_, err = GetSession(store, request(rotated))
+	require.NoError(err)
+
+	// and when an anonymous session signs in
+	w = httptest.NewRecorder()
+	nonce := "nonce"
+	require.NoError(SetSession(store, httptest.NewRequest(http.MethodGet, "/", nil), w, &AuthSession{
+		Nonce: &nonce,
+	}))
+	anonymous := w.Result().Cookies()[0]
+	w = httptest.NewRecorder()
+	require.NoError(SetSession(store, request(anonymous), w, &AuthSession{
+		Identity: &Identity{Subject: "alice"},
+	}))
+	require.NotEqual(anonymous.Value, w.Result().Cookies()[0].Value)
+	_, err = GetSession(store, request(anonymous))
+	require.Error(err)
+
+	// revoked sessions are signed out
+	w = httptest.NewRecorder()
+	require.NoError(SetSession(store, httptest.NewRequest(http.MethodGet, "/", nil), w, &AuthSession{
+		Identity: &Identity{Subject: "alice"},
+	}))
+	cookie = w.Result().Cookies()[0]
+	backend.sessions = map[string]*AuthSession{}
+	_, err = GetSession(store, request(cookie))
+	require.Error(err)
+
+	// cookies signed with another secret are rejected
+	w = httptest.NewRecorder()
+	require.NoError(SetSession(store, httptest.NewRequest(http.MethodGet, "/", nil), w, &AuthSession{
+		Identity: &Identity{Subject: "bob"},
+	}))
+	cookie = w.Result().Cookies()[0]
+	hashKey, blockKey = SessionKeys("other")
+	_, err = GetSession(NewServerStore(backend, time.Hour, hashKey, blockKey), request(cookie))
+	require.Error(err)
+
+	// signing out deletes the session
+	require.NoError(ClearSession(store, request(cookie), httptest.NewRecorder()))
+	require.Empty(backend.sessions)
+}
+
+type testBackend struct {
+	lock     sync.Mutex
+	sessions map[string]*AuthSession
+}
+
+func (b *testBackend) SaveSession(id string, s *AuthSession, expires time.Time) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.sessions[id] = s
+	return nil
+}
+
+func (b *testBackend) LoadSession(id string) (*AuthSession, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	s, ok := b.sessions[id]
+	if !ok {
+		return nil, errors.New("session doesn't exist")
+	}
+	return s, nil
+}
+
+func (b *testBackend) DeleteSession(id string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.sessions, id)
+	return nil
+}
@@ -9,6 +9,8 @@ import (
 
 type LoginPage struct {
 	Providers []*authruntime.Provider
+	// Failed is true after a failed login with a username and password
+	Failed bool
 }
 
 func RenderLoginPage(w io.Writer, data LoginPage) error {
@@ -19,7 +21,8 @@ func RenderLoginPage(w io.Writer, data LoginPage) error {
 	return tpl.Execute(w, data)
 }
 
-const loginPage string = `
+// style is shared by the pages of the login flow
+const style string = `
 <style>
 	* {
 		font-family: monospace;
@@ -66,6 +69,15 @@ const loginPage string = `
 		display: block;
 	}
 
+	.form form > * {
+		margin: 0 0 10px;
+	}
+
+	.form .error {
+		color: #d9534f;
+		text-align: center;
+	}
+
 	.form > * {
 		margin: 0 0 20px;
 	}
@@ -114,24 +126,29 @@ const loginPage string = `
 		border-radius: 50%;
 	}
 </style>
+`
 
+const loginPage string = style + `
 <section class="form">
   <h2>Sign In</h2>
 
-	{{range $i, $p := .Providers}}
-		<a href="/signin/{{$i}}">
-			<button>{{$p.Type}}</button>
-		</a>
+	{{if .Failed}}
+		<p class="error">Invalid username or password</p>
 	{{end}}
 
-	<!--
-	<form autocomplete="off">
-		<input placeholder="Username" type="text" id="username"></input>
-		<input placeholder="Password" type="password" id="password"></input>
-		<button id="submit">Login</button>
-	</form>
-	<hr />
-	-->
+	{{range $i, $p := .Providers}}
+		{{if $p.PasswordForm}}
+			<form method="POST" action="/signin/{{$i}}" autocomplete="off">
+				<input placeholder="Username" type="text" name="username"></input>
+				<input placeholder="Password" type="password" name="password"></input>
+				<button type="submit">{{$p.Type}}</button>
+			</form>
+		{{else}}
+			<a href="/signin/{{$i}}">
+				<button>{{$p.Type}}</button>
+			</a>
+		{{end}}
+	{{end}}
 
 </section>
 `
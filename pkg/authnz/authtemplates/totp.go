@@ -0,0 +1,92 @@
+package authtemplates
+
+import (
+	"html/template"
+	"io"
+)
+
+type SecondFactorPage struct {
+	// Failed is true after a wrong code
+	Failed bool
+}
+
+func RenderSecondFactorPage(w io.Writer, data SecondFactorPage) error {
+	tpl, err := template.New("second-factor-page").Parse(secondFactorPage)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(w, data)
+}
+
+type EnrollPage struct {
+	// Enrolled is true if the user has a second factor
+	Enrolled bool
+	// Secret and URI are shown to users that are enrolling
+	Secret string
+	URI    template.URL
+	// RecoveryCodes are shown once when a user enrolls
+	RecoveryCodes []string
+	// Removed is true after a user removed their second factor
+	Removed bool
+	// Failed is true after a wrong code
+	Failed bool
+}
+
+func RenderEnrollPage(w io.Writer, data EnrollPage) error {
+	tpl, err := template.New("enroll-page").Parse(enrollPage)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(w, data)
+}
+
+const secondFactorPage string = style + `
+<section class="form">
+	<h2>Two-Factor Authentication</h2>
+
+	{{if .Failed}}
+		<p class="error">Invalid code</p>
+	{{end}}
+
+	<form method="POST" action="/signin/totp" autocomplete="off">
+		<input placeholder="Code or recovery code" type="text" name="code" inputmode="numeric" autofocus></input>
+		<button type="submit">Verify</button>
+	</form>
+	<a href="/signin">Cancel</a>
+</section>
+`
+
+const enrollPage string = style + `
+<section class="form">
+	<h2>Two-Factor Authentication</h2>
+
+	{{if .Failed}}
+		<p class="error">Invalid code</p>
+	{{end}}
+
+	{{if .RecoveryCodes}}
+		<p>Two-factor authentication is enabled. Save these recovery codes somewhere safe. Each of them can be used once instead of a code if you lose your authenticator app. They won't be shown again.</p>
+		<pre>{{range .RecoveryCodes}}{{.}}
+{{end}}</pre>
+		<a href="/">Done</a>
+	{{else if .Removed}}
+		<p>Two-factor authentication is disabled.</p>
+		<a href="/">Done</a>
+	{{else if .Enrolled}}
+		<p>Two-factor authentication is enabled. Enter a code to disable it.</p>
+		<form method="POST" action="/account/totp" autocomplete="off">
+			<input type="hidden" name="action" value="remove"></input>
+			<input placeholder="Code or recovery code" type="text" name="code"></input>
+			<button type="submit">Disable</button>
+		</form>
+	{{else}}
+		<p>Add this key to your authenticator app or <a href="{{.URI}}">open it</a> on your phone, then enter a code to enable two-factor authentication.</p>
+		<pre>{{.Secret}}</pre>
+		<form method="POST" action="/account/totp" autocomplete="off">
+			<input type="hidden" name="action" value="confirm"></input>
+			<input placeholder="Code" type="text" name="code" inputmode="numeric"></input>
+			<button type="submit">Enable</button>
+		</form>
+	{{end}}
+</section>
+`
@@ -0,0 +1,142 @@
+package authnz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/sirupsen/logrus"
+)
+
+// how long the result of a refresh is reused for
+// requests that still have the session's old cookie
+const refreshReuse = time.Minute
+
+// the timeout for refreshing a session with a provider
+const refreshTimeout = 15 * time.Second
+
+// refresher refreshes sessions with their provider.
+// Concurrent requests for the same session share one refresh
+// because providers may only allow a refresh token to be used once.
+type refresher struct {
+	providers map[string]*authruntime.Provider
+	users     authsession.UserRecorder
+	lock      sync.Mutex
+	calls     map[string]*refreshCall
+}
+
+type refreshCall struct {
+	done    chan struct{}
+	session *authsession.AuthSession
+	err     error
+	// zero until the refresh is done
+	expires time.Time
+}
+
+// newRefresher creates a refresher. Sessions are refreshed
+// with the latest refresh token from users if it's not nil.
+func newRefresher(providers []*authruntime.Provider, users authsession.UserRecorder) *refresher {
+	r := &refresher{
+		providers: map[string]*authruntime.Provider{},
+		users:     users,
+		calls:     map[string]*refreshCall{},
+	}
+	for _, p := range providers {
+		if p.Refresh != nil {
+			r.providers[p.Type] = p
+		}
+	}
+	return r
+}
+
+func (r *refresher) refresh(s *authsession.AuthSession) (*authsession.AuthSession, error) {
+	provider, ok := r.providers[s.Refresh.Provider]
+	if !ok {
+		return nil, fmt.Errorf("session was created by an unknown provider '%s'", s.Refresh.Provider)
+	}
+
+	key := authsession.SessionID(s.Refresh.Provider + "/" + s.Refresh.Token)
+	now := time.Now()
+
+	r.lock.Lock()
+	for k, c := range r.calls {
+		if !c.expires.IsZero() && now.After(c.expires) {
+			delete(r.calls, k)
+		}
+	}
+	call, ok := r.calls[key]
+	if !ok {
+		call = &refreshCall{done: make(chan struct{})}
+		r.calls[key] = call
+	}
+	r.lock.Unlock()
+
+	if ok {
+		<-call.done
+	} else {
+		// not the request's context so that a cancelled request
+		// doesn't fail the refresh for the requests waiting on it
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		used := r.latest(s)
+		call.session, call.err = provider.Refresh(ctx, used)
+		if call.err != nil {
+			// the token may have been rotated while it was used
+			if latest := r.latest(s); latest.Refresh.Token != used.Refresh.Token {
+				call.session, call.err = provider.Refresh(ctx, latest)
+			}
+		}
+		cancel()
+		r.lock.Lock()
+		call.expires = time.Now().Add(refreshReuse)
+		r.lock.Unlock()
+		close(call.done)
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return copySession(call.session)
+}
+
+// latest returns a copy of the session with the user's latest
+// refresh token if the token was rotated since the session was
+// saved i.e. by the lifecycle job. Otherwise it returns s.
+func (r *refresher) latest(s *authsession.AuthSession) *authsession.AuthSession {
+	if r.users == nil || s.Identity == nil {
+		return s
+	}
+	token, err := r.users.RefreshToken(s.Identity.Provider, s.Identity.Subject)
+	if err != nil {
+		logrus.Warn(errors.Wrap(err, "failed to get the latest refresh token"))
+		return s
+	}
+	if token == "" || token == s.Refresh.Token {
+		return s
+	}
+	latest := *s
+	latest.Refresh = &authsession.Refresh{
+		Provider: s.Refresh.Provider,
+		Token:    token,
+		At:       s.Refresh.At,
+	}
+	return &latest
+}
+
+// copySession copies a session so that requests
+// sharing a refresh can't modify each other's claims
+func copySession(s *authsession.AuthSession) (*authsession.AuthSession, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal session")
+	}
+	c := &authsession.AuthSession{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, errors.Wrap(err, "failed to parse session")
+	}
+	return c, nil
+}
@@ -0,0 +1,137 @@
+package authnz
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresher(t *testing.T) {
+	require := require.New(t)
+
+	var calls int32
+	r := newRefresher([]*authruntime.Provider{{
+		Type: "OIDC",
+		Refresh: func(ctx context.Context, s *authsession.AuthSession) (*authsession.AuthSession, error) {
+			atomic.AddInt32(&calls, 1)
+			if s.Refresh.Token == "revoked" {
+				return nil, errors.New("revoked")
+			}
+			time.Sleep(10 * time.Millisecond)
+			return &authsession.AuthSession{
+				Identity: &authsession.Identity{Subject: "alice"},
+				Refresh:  &authsession.Refresh{Provider: "OIDC", Token: "new"},
+			}, nil
+		},
+	}}, nil)
+
+	// concurrent requests share a refresh
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := r.refresh(&authsession.AuthSession{
+				Refresh: &authsession.Refresh{Provider: "OIDC", Token: "old"},
+			})
+			require.NoError(err)
+			require.Equal("new", s.Refresh.Token)
+			s.Identity.Claims.Add("admin", "true")
+		}()
+	}
+	wg.Wait()
+	require.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	// and can't change each other's claims
+	s, err := r.refresh(&authsession.AuthSession{
+		Refresh: &authsession.Refresh{Provider: "OIDC", Token: "old"},
+	})
+	require.NoError(err)
+	require.Empty(s.Identity.Claims)
+
+	_, err = r.refresh(&authsession.AuthSession{
+		Refresh: &authsession.Refresh{Provider: "OIDC", Token: "revoked"},
+	})
+	require.Error(err)
+	_, err = r.refresh(&authsession.AuthSession{
+		Refresh: &authsession.Refresh{Provider: "LDAP", Token: "old"},
+	})
+	require.Error(err)
+}
+
+type fakeRecorder struct {
+	lock   sync.Mutex
+	tokens map[string]string
+}
+
+func (f *fakeRecorder) RecordUser(s *authsession.AuthSession) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.tokens[s.Identity.Subject] = s.Refresh.Token
+	return nil
+}
+
+func (f *fakeRecorder) RefreshToken(provider string, subject string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.tokens[subject], nil
+}
+
+func TestRefresherRotation(t *testing.T) {
+	require := require.New(t)
+
+	// the provider rotates refresh tokens and only
+	// accepts the latest one like okta and auth0
+	lock := sync.Mutex{}
+	valid := "1"
+	rotate := func(token string) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if token != valid {
+			return "", errors.New("invalid_grant")
+		}
+		valid = valid + "1"
+		return valid, nil
+	}
+	users := &fakeRecorder{tokens: map[string]string{}}
+	r := newRefresher([]*authruntime.Provider{{
+		Type: "OIDC",
+		Refresh: func(ctx context.Context, s *authsession.AuthSession) (*authsession.AuthSession, error) {
+			next, err := rotate(s.Refresh.Token)
+			if err != nil {
+				return nil, err
+			}
+			return &authsession.AuthSession{
+				Identity: s.Identity,
+				Refresh:  &authsession.Refresh{Provider: "OIDC", Token: next},
+			}, nil
+		},
+	}}, users)
+
+	session := &authsession.AuthSession{
+		Identity: &authsession.Identity{Provider: "idp", Subject: "alice"},
+		Refresh:  &authsession.Refresh{Provider: "OIDC", Token: "1"},
+	}
+	require.NoError(users.RecordUser(session))
+
+	// the lifecycle job rotates the token that the session has
+	checked, err := rotate("1")
+	require.NoError(err)
+	require.NoError(users.RecordUser(&authsession.AuthSession{
+		Identity: session.Identity,
+		Refresh:  &authsession.Refresh{Provider: "OIDC", Token: checked},
+	}))
+
+	// the session is refreshed with the rotated token
+	s, err := r.refresh(session)
+	require.NoError(err)
+	require.Equal("111", s.Refresh.Token)
+	require.Equal("1", session.Refresh.Token, "the session isn't modified")
+}
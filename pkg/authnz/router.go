@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/place1/wg-access-server/pkg/authnz/authconfig"
 	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
@@ -21,14 +24,29 @@ import (
 type AuthMiddleware struct {
 	config           authconfig.AuthConfig
 	claimsMiddleware authsession.ClaimsMiddleware
+	tokens           authsession.TokenVerifier
 	router           *mux.Router
 	runtime          *authruntime.ProviderRuntime
+	refresher        *refresher
+	providers        []*authruntime.Provider
 }
 
-func New(config authconfig.AuthConfig, claimsMiddleware authsession.ClaimsMiddleware) *AuthMiddleware {
+// New creates the auth middleware. Sessions are stored
+// in the backend if server-side sessions are enabled.
+// Bearer tokens are verified by tokens if it's not nil.
+// Users that sign in are recorded by users if it's not nil.
+// Users of providers that support second factors can enroll one
+// if secondFactors isn't nil. Failed password logins are recorded
+// by logins if it's not nil.
+func New(config authconfig.AuthConfig, claimsMiddleware authsession.ClaimsMiddleware, backend authsession.SessionBackend, tokens authsession.TokenVerifier, users authsession.UserRecorder, secondFactors authsession.SecondFactors, logins authsession.LoginAuditor) *AuthMiddleware {
 	router := mux.NewRouter()
-	store := sessions.NewCookieStore([]byte(authutil.RandomString(32)))
-	runtime := authruntime.NewProviderRuntime(store)
+	throttle := authruntime.NewThrottle(config.Throttle.Limits(), logins)
+	proxies, err := config.Throttle.Proxies()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	throttle.SetTrustedProxies(proxies)
+	runtime := authruntime.NewProviderRuntime(sessionStore(config.Sessions, backend), users, secondFactors, throttle)
 	providers := config.Providers()
 
 	for _, p := range providers {
@@ -41,9 +59,15 @@ func New(config authconfig.AuthConfig, claimsMiddleware authsession.ClaimsMiddle
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, authtemplates.RenderLoginPage(w, authtemplates.LoginPage{
 			Providers: providers,
+			Failed:    r.URL.Query().Get("failed") != "",
 		}))
 	})
 
+	if secondFactors != nil {
+		router.HandleFunc("/signin/totp", secondFactorStep(runtime))
+		router.HandleFunc("/account/totp", enrollSecondFactor(runtime, providers))
+	}
+
 	router.HandleFunc("/signin/{index}", func(w http.ResponseWriter, r *http.Request) {
 		index, err := strconv.Atoi(mux.Vars(r)["index"])
 		if err != nil || index < 0 || len(providers) <= index {
@@ -63,13 +87,42 @@ func New(config authconfig.AuthConfig, claimsMiddleware authsession.ClaimsMiddle
 	return &AuthMiddleware{
 		config,
 		claimsMiddleware,
+		tokens,
 		router,
 		runtime,
+		newRefresher(providers, users),
+		providers,
 	}
 }
 
-func NewMiddleware(config authconfig.AuthConfig, claimsMiddleware authsession.ClaimsMiddleware) mux.MiddlewareFunc {
-	return New(config, claimsMiddleware).Middleware
+func NewMiddleware(config authconfig.AuthConfig, claimsMiddleware authsession.ClaimsMiddleware, backend authsession.SessionBackend, tokens authsession.TokenVerifier, users authsession.UserRecorder, secondFactors authsession.SecondFactors, logins authsession.LoginAuditor) mux.MiddlewareFunc {
+	return New(config, claimsMiddleware, backend, tokens, users, secondFactors, logins).Middleware
+}
+
+// Providers returns the configured authentication providers
+func (m *AuthMiddleware) Providers() []*authruntime.Provider {
+	return m.providers
+}
+
+func sessionStore(config authconfig.SessionConfig, backend authsession.SessionBackend) sessions.Store {
+	secret := config.Secret
+	if secret == "" {
+		logrus.Info("no session secret is configured - users will be signed out when the server restarts")
+		secret = authutil.RandomString(32)
+	}
+	hashKey, blockKey := authsession.SessionKeys(secret)
+	ttl := config.SessionTTL()
+
+	if config.ServerSide {
+		if backend == nil {
+			logrus.Fatal("server-side sessions require a session backend")
+		}
+		return authsession.NewServerStore(backend, ttl, hashKey, blockKey)
+	}
+
+	store := sessions.NewCookieStore(hashKey, blockKey)
+	store.MaxAge(int(ttl / time.Second))
+	return store
 }
 
 func (m *AuthMiddleware) Middleware(next http.Handler) http.Handler {
@@ -82,12 +135,61 @@ func (m *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// requests with a bearer token are authenticated by the
+		// token alone. the claims middleware adds the owner's
+		// current claims like it does for sessions.
+		if token, ok := bearerToken(r); ok && m.tokens != nil {
+			identity, err := m.tokens.VerifyToken(token)
+			if err != nil {
+				ctxlogrus.Extract(r.Context()).Info(errors.Wrap(err, "bearer token rejected"))
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			if m.claimsMiddleware != nil {
+				if err := m.claimsMiddleware(identity); err != nil {
+					if errors.Cause(err) == authsession.ErrUserDisabled {
+						ctxlogrus.Extract(r.Context()).Infof("rejecting api token of disabled user %s", identity.Subject)
+						http.Error(w, "your account has been disabled", http.StatusForbidden)
+						return
+					}
+					ctxlogrus.Extract(r.Context()).Error(errors.Wrap(err, "authz middleware failure"))
+					http.Error(w, "internal server error", http.StatusInternalServerError)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(authsession.SetIdentityCtx(r.Context(), &authsession.AuthSession{
+				Identity: identity,
+			})))
+			return
+		}
+
 		// otherwise we apply the standard middleware
 		// functionality i.e. annotate the request context
 		// with the request user (identity)
 		if s, err := m.runtime.GetSession(r); err == nil {
-			if m.claimsMiddleware != nil {
+			if s.Refresh != nil && !time.Now().Before(s.Refresh.At) {
+				refreshed, err := m.refresher.refresh(s)
+				if err != nil {
+					ctxlogrus.Extract(r.Context()).Info(errors.Wrap(err, "session refresh failed - signing out"))
+					m.runtime.ClearSession(w, r)
+					next.ServeHTTP(w, r)
+					return
+				}
+				if err := m.runtime.SetSession(w, r, refreshed); err != nil {
+					ctxlogrus.Extract(r.Context()).Error(errors.Wrap(err, "failed to save refreshed session"))
+				}
+				s = refreshed
+			}
+			// sessions of logins that haven't finished
+			// i.e. the second factor step have no identity
+			if m.claimsMiddleware != nil && s.Identity != nil {
 				if err := m.claimsMiddleware(s.Identity); err != nil {
+					if errors.Cause(err) == authsession.ErrUserDisabled {
+						ctxlogrus.Extract(r.Context()).Infof("signing out disabled user %s", s.Identity.Subject)
+						m.runtime.ClearSession(w, r)
+						http.Error(w, "your account has been disabled", http.StatusForbidden)
+						return
+					}
 					ctxlogrus.Extract(r.Context()).Error(errors.Wrap(err, "authz middleware failure"))
 					http.Error(w, "internal server error", http.StatusInternalServerError)
 					return
@@ -100,6 +202,14 @@ func (m *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:]), true
+	}
+	return "", false
+}
+
 func RequireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if authsession.Authenticated(r.Context()) {
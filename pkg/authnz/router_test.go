@@ -0,0 +1,65 @@
+package authnz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/place1/wg-access-server/pkg/authnz/authconfig"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokens map[string]string
+
+func (f fakeTokens) VerifyToken(token string) (*authsession.Identity, error) {
+	subject, ok := f[token]
+	if !ok {
+		return nil, errors.New("invalid api token")
+	}
+	return &authsession.Identity{Provider: "basic", Subject: subject}, nil
+}
+
+func TestBearerTokenClaims(t *testing.T) {
+	require := require.New(t)
+
+	// the claims middleware sees the current state of token owners
+	claims := func(user *authsession.Identity) error {
+		if user.Subject == "disabled" {
+			return errors.Wrap(authsession.ErrUserDisabled, "scim user is inactive")
+		}
+		user.Claims.Add("group", "engineering")
+		return nil
+	}
+	auth := New(authconfig.AuthConfig{
+		Basic:    &authconfig.BasicAuthConfig{},
+		Sessions: authconfig.SessionConfig{Secret: "secret"},
+	}, claims, nil, fakeTokens{"alice-token": "alice", "disabled-token": "disabled"}, nil, nil, nil)
+
+	var user *authsession.Identity
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = authsession.CurrentUser(r.Context())
+	}))
+	do := func(token string) *httptest.ResponseRecorder {
+		user = nil
+		r := httptest.NewRequest(http.MethodGet, "/api", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("alice-token")
+	require.Equal(http.StatusOK, w.Code)
+	require.Equal("alice", user.Subject)
+	require.True(user.Claims.Has("group", "engineering"))
+
+	w = do("disabled-token")
+	require.Equal(http.StatusForbidden, w.Code)
+	require.Nil(user)
+
+	w = do("invalid")
+	require.Equal(http.StatusUnauthorized, w.Code)
+	require.Nil(user)
+}
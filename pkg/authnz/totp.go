@@ -0,0 +1,139 @@
+package authnz
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/pkg/errors"
+
+	"github.com/place1/wg-access-server/pkg/authnz/authruntime"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/place1/wg-access-server/pkg/authnz/authtemplates"
+)
+
+// the issuer that authenticator apps show next to codes
+const totpIssuer = "wg-access-server"
+
+// secondFactorStep is the step of a password login where
+// users that enrolled a second factor enter a code
+func secondFactorStep(runtime *authruntime.ProviderRuntime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := runtime.GetSession(r)
+		if err != nil || s.SecondFactor == nil || !time.Now().Before(s.SecondFactor.Expires) {
+			runtime.Restart(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			authtemplates.RenderSecondFactorPage(w, authtemplates.SecondFactorPage{})
+			return
+		}
+
+		identity := s.SecondFactor.Identity
+		ok, err := runtime.SecondFactors().Verify(identity.Provider, identity.Subject, r.PostFormValue("code"))
+		if err != nil {
+			ctxlogrus.Extract(r.Context()).Error(errors.Wrap(err, "failed to verify second factor"))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			ctxlogrus.Extract(r.Context()).Infof("wrong second factor code for %s/%s", identity.Provider, identity.Subject)
+			w.WriteHeader(http.StatusUnauthorized)
+			authtemplates.RenderSecondFactorPage(w, authtemplates.SecondFactorPage{Failed: true})
+			return
+		}
+		runtime.SetSession(w, r, &authsession.AuthSession{Identity: identity})
+		runtime.Done(w, r)
+	}
+}
+
+// enrollSecondFactor lets signed in users of providers
+// that support second factors enroll or remove one
+func enrollSecondFactor(runtime *authruntime.ProviderRuntime, providers []*authruntime.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := runtime.GetSession(r)
+		if err != nil || s.Identity == nil {
+			runtime.Restart(w, r)
+			return
+		}
+		identity := s.Identity
+		if !supportsSecondFactor(providers, identity.Provider) {
+			http.Error(w, "two-factor authentication isn't available for your account", http.StatusNotFound)
+			return
+		}
+
+		page, err := enroll(runtime.SecondFactors(), identity, r)
+		if err != nil {
+			ctxlogrus.Extract(r.Context()).Error(errors.Wrap(err, "failed to enroll second factor"))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if page.Failed {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		authtemplates.RenderEnrollPage(w, *page)
+	}
+}
+
+func enroll(factors authsession.SecondFactors, identity *authsession.Identity, r *http.Request) (*authtemplates.EnrollPage, error) {
+	enrolled, err := factors.Enrolled(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	page := &authtemplates.EnrollPage{Enrolled: enrolled}
+
+	if r.Method == http.MethodPost {
+		ok, err := factors.Verify(identity.Provider, identity.Subject, r.PostFormValue("code"))
+		if err != nil {
+			return nil, err
+		}
+		action := r.PostFormValue("action")
+		switch {
+		case !ok:
+			page.Failed = true
+		case action == "confirm" && !enrolled:
+			if page.RecoveryCodes, err = factors.Confirm(identity.Provider, identity.Subject); err != nil {
+				return nil, err
+			}
+			ctxlogrus.Extract(r.Context()).Infof("%s/%s enrolled a second factor", identity.Provider, identity.Subject)
+			return page, nil
+		case action == "remove" && enrolled:
+			if err := factors.Remove(identity.Provider, identity.Subject); err != nil {
+				return nil, err
+			}
+			ctxlogrus.Extract(r.Context()).Infof("%s/%s removed their second factor", identity.Provider, identity.Subject)
+			page.Removed = true
+			return page, nil
+		}
+	}
+
+	if !enrolled {
+		secret, err := factors.Enroll(identity.Provider, identity.Subject)
+		if err != nil {
+			return nil, err
+		}
+		page.Secret = secret
+		page.URI = template.URL(otpauthURI(identity.Subject, secret))
+	}
+	return page, nil
+}
+
+func supportsSecondFactor(providers []*authruntime.Provider, name string) bool {
+	for _, p := range providers {
+		if p.Name == name && p.SecondFactor {
+			return true
+		}
+	}
+	return false
+}
+
+// otpauthURI is the key uri that authenticator apps import
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func otpauthURI(subject string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	return "otpauth://totp/" + url.PathEscape(totpIssuer+":"+subject) + "?" + query.Encode()
+}
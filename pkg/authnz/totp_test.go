@@ -0,0 +1,113 @@
+package authnz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/place1/wg-access-server/pkg/authnz/authconfig"
+	"github.com/place1/wg-access-server/pkg/authnz/authsession"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeSecondFactors accepts the code "123456" for enrolled users
+type fakeSecondFactors struct {
+	enrolled map[string]bool
+}
+
+func (f *fakeSecondFactors) Enrolled(provider string, subject string) (bool, error) {
+	return f.enrolled[provider+"/"+subject], nil
+}
+
+func (f *fakeSecondFactors) Verify(provider string, subject string, code string) (bool, error) {
+	return code == "123456", nil
+}
+
+func (f *fakeSecondFactors) Enroll(provider string, subject string) (string, error) {
+	return "SECRET", nil
+}
+
+func (f *fakeSecondFactors) Confirm(provider string, subject string) ([]string, error) {
+	f.enrolled[provider+"/"+subject] = true
+	return []string{"recovery"}, nil
+}
+
+func (f *fakeSecondFactors) Remove(provider string, subject string) error {
+	delete(f.enrolled, provider+"/"+subject)
+	return nil
+}
+
+func TestSecondFactorStep(t *testing.T) {
+	require := require.New(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(err)
+	factors := &fakeSecondFactors{enrolled: map[string]bool{"basic/admin": true}}
+	auth := New(authconfig.AuthConfig{
+		Basic: &authconfig.BasicAuthConfig{
+			Users: []string{"admin:" + string(hash), "bob:" + string(hash)},
+			TOTP:  true,
+		},
+		Sessions: authconfig.SessionConfig{Secret: "secret"},
+	}, nil, nil, nil, nil, factors, nil)
+
+	var signedIn *authsession.Identity
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signedIn, _ = authsession.CurrentUser(r.Context())
+	}))
+	do := func(method string, path string, cookies []*http.Cookie, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		if path == "/signin/0" {
+			r.SetBasicAuth("admin", "password")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// the password alone doesn't sign in enrolled users
+	w := do(http.MethodGet, "/signin/0", nil, nil)
+	require.Equal(http.StatusSeeOther, w.Code)
+	require.Equal("/signin/totp", w.Header().Get("Location"))
+	pending := w.Result().Cookies()
+	do(http.MethodGet, "/", pending, nil)
+	require.Nil(signedIn)
+
+	w = do(http.MethodPost, "/signin/totp", pending, url.Values{"code": {"000000"}})
+	require.Equal(http.StatusUnauthorized, w.Code)
+	require.Contains(w.Body.String(), "Invalid code")
+
+	w = do(http.MethodPost, "/signin/totp", pending, url.Values{"code": {"123456"}})
+	require.Equal(http.StatusSeeOther, w.Code)
+	require.Equal("/", w.Header().Get("Location"))
+	do(http.MethodGet, "/", w.Result().Cookies(), nil)
+	require.NotNil(signedIn)
+	require.Equal("admin", signedIn.Subject)
+
+	// the second factor step can't be used without a password
+	w = do(http.MethodPost, "/signin/totp", nil, url.Values{"code": {"123456"}})
+	require.Equal(http.StatusTemporaryRedirect, w.Code)
+	require.Equal("/signin", w.Header().Get("Location"))
+
+	// enrolled users can remove their second factor
+	w = do(http.MethodPost, "/signin/totp", pending, url.Values{"code": {"123456"}})
+	session := w.Result().Cookies()
+	w = do(http.MethodPost, "/account/totp", session, url.Values{"action": {"remove"}, "code": {"123456"}})
+	require.Equal(http.StatusOK, w.Code)
+	require.False(factors.enrolled["basic/admin"])
+
+	// and enroll again
+	w = do(http.MethodGet, "/account/totp", session, nil)
+	require.Contains(w.Body.String(), "SECRET")
+	require.Contains(w.Body.String(), "otpauth://totp/wg-access-server:admin?issuer=wg-access-server&amp;secret=SECRET")
+	w = do(http.MethodPost, "/account/totp", session, url.Values{"action": {"confirm"}, "code": {"123456"}})
+	require.Contains(w.Body.String(), "recovery")
+	require.True(factors.enrolled["basic/admin"])
+}
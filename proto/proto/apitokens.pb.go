@@ -0,0 +1,553 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: apitokens.proto
+
+package proto
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type ApiToken struct {
+	Id             string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name           string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Owner          string `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	OwnerName      string `protobuf:"bytes,4,opt,name=owner_name,json=ownerName,proto3" json:"owner_name,omitempty"`
+	ServiceAccount bool   `protobuf:"varint,5,opt,name=service_account,json=serviceAccount,proto3" json:"service_account,omitempty"`
+	// "devices:read", "devices:write" or "admin"
+	Scopes               []string             `protobuf:"bytes,6,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ExpiresAt            *timestamp.Timestamp `protobuf:"bytes,8,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ApiToken) Reset()         { *m = ApiToken{} }
+func (m *ApiToken) String() string { return proto.CompactTextString(m) }
+func (*ApiToken) ProtoMessage()    {}
+func (*ApiToken) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37e70f504b8d547e, []int{0}
+}
+
+func (m *ApiToken) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ApiToken.Unmarshal(m, b)
+}
+func (m *ApiToken) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ApiToken.Marshal(b, m, deterministic)
+}
+func (m *ApiToken) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ApiToken.Merge(m, src)
+}
+func (m *ApiToken) XXX_Size() int {
+	return xxx_messageInfo_ApiToken.Size(m)
+}
+func (m *ApiToken) XXX_DiscardUnknown() {
+	xxx_messageInfo_ApiToken.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ApiToken proto.InternalMessageInfo
+
+func (m *ApiToken) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ApiToken) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ApiToken) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *ApiToken) GetOwnerName() string {
+	if m != nil {
+		return m.OwnerName
+	}
+	return ""
+}
+
+func (m *ApiToken) GetServiceAccount() bool {
+	if m != nil {
+		return m.ServiceAccount
+	}
+	return false
+}
+
+func (m *ApiToken) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *ApiToken) GetCreatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+func (m *ApiToken) GetExpiresAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+type CreateApiTokenReq struct {
+	Name   string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Scopes []string `protobuf:"bytes,2,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	// creates a token for a service account named
+	// after the token instead of the current user.
+	// admin only.
+	ServiceAccount bool `protobuf:"varint,3,opt,name=service_account,json=serviceAccount,proto3" json:"service_account,omitempty"`
+	// optional
+	ExpiresAt            *timestamp.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *CreateApiTokenReq) Reset()         { *m = CreateApiTokenReq{} }
+func (m *CreateApiTokenReq) String() string { return proto.CompactTextString(m) }
+func (*CreateApiTokenReq) ProtoMessage()    {}
+func (*CreateApiTokenReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37e70f504b8d547e, []int{1}
+}
+
+func (m *CreateApiTokenReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateApiTokenReq.Unmarshal(m, b)
+}
+func (m *CreateApiTokenReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateApiTokenReq.Marshal(b, m, deterministic)
+}
+func (m *CreateApiTokenReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateApiTokenReq.Merge(m, src)
+}
+func (m *CreateApiTokenReq) XXX_Size() int {
+	return xxx_messageInfo_CreateApiTokenReq.Size(m)
+}
+func (m *CreateApiTokenReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateApiTokenReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateApiTokenReq proto.InternalMessageInfo
+
+func (m *CreateApiTokenReq) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateApiTokenReq) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *CreateApiTokenReq) GetServiceAccount() bool {
+	if m != nil {
+		return m.ServiceAccount
+	}
+	return false
+}
+
+func (m *CreateApiTokenReq) GetExpiresAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+type CreateApiTokenRes struct {
+	// the token is only returned once.
+	// use it as an "Authorization: Bearer <token>" header.
+	Token                string    `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	ApiToken             *ApiToken `protobuf:"bytes,2,opt,name=api_token,json=apiToken,proto3" json:"api_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *CreateApiTokenRes) Reset()         { *m = CreateApiTokenRes{} }
+func (m *CreateApiTokenRes) String() string { return proto.CompactTextString(m) }
+func (*CreateApiTokenRes) ProtoMessage()    {}
+func (*CreateApiTokenRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37e70f504b8d547e, []int{2}
+}
+
+func (m *CreateApiTokenRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateApiTokenRes.Unmarshal(m, b)
+}
+func (m *CreateApiTokenRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateApiTokenRes.Marshal(b, m, deterministic)
+}
+func (m *CreateApiTokenRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateApiTokenRes.Merge(m, src)
+}
+func (m *CreateApiTokenRes) XXX_Size() int {
+	return xxx_messageInfo_CreateApiTokenRes.Size(m)
+}
+func (m *CreateApiTokenRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateApiTokenRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateApiTokenRes proto.InternalMessageInfo
+
+func (m *CreateApiTokenRes) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *CreateApiTokenRes) GetApiToken() *ApiToken {
+	if m != nil {
+		return m.ApiToken
+	}
+	return nil
+}
+
+type ListApiTokensReq struct {
+	// lists the tokens of all users. admin only.
+	All                  bool     `protobuf:"varint,1,opt,name=all,proto3" json:"all,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListApiTokensReq) Reset()         { *m = ListApiTokensReq{} }
+func (m *ListApiTokensReq) String() string { return proto.CompactTextString(m) }
+func (*ListApiTokensReq) ProtoMessage()    {}
+func (*ListApiTokensReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37e70f504b8d547e, []int{3}
+}
+
+func (m *ListApiTokensReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListApiTokensReq.Unmarshal(m, b)
+}
+func (m *ListApiTokensReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListApiTokensReq.Marshal(b, m, deterministic)
+}
+func (m *ListApiTokensReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListApiTokensReq.Merge(m, src)
+}
+func (m *ListApiTokensReq) XXX_Size() int {
+	return xxx_messageInfo_ListApiTokensReq.Size(m)
+}
+func (m *ListApiTokensReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListApiTokensReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListApiTokensReq proto.InternalMessageInfo
+
+func (m *ListApiTokensReq) GetAll() bool {
+	if m != nil {
+		return m.All
+	}
+	return false
+}
+
+type ListApiTokensRes struct {
+	Items                []*ApiToken `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *ListApiTokensRes) Reset()         { *m = ListApiTokensRes{} }
+func (m *ListApiTokensRes) String() string { return proto.CompactTextString(m) }
+func (*ListApiTokensRes) ProtoMessage()    {}
+func (*ListApiTokensRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37e70f504b8d547e, []int{4}
+}
+
+func (m *ListApiTokensRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListApiTokensRes.Unmarshal(m, b)
+}
+func (m *ListApiTokensRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListApiTokensRes.Marshal(b, m, deterministic)
+}
+func (m *ListApiTokensRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListApiTokensRes.Merge(m, src)
+}
+func (m *ListApiTokensRes) XXX_Size() int {
+	return xxx_messageInfo_ListApiTokensRes.Size(m)
+}
+func (m *ListApiTokensRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListApiTokensRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListApiTokensRes proto.InternalMessageInfo
+
+func (m *ListApiTokensRes) GetItems() []*ApiToken {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+type RevokeApiTokenReq struct {
+	// admins can revoke any token
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeApiTokenReq) Reset()         { *m = RevokeApiTokenReq{} }
+func (m *RevokeApiTokenReq) String() string { return proto.CompactTextString(m) }
+func (*RevokeApiTokenReq) ProtoMessage()    {}
+func (*RevokeApiTokenReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37e70f504b8d547e, []int{5}
+}
+
+func (m *RevokeApiTokenReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeApiTokenReq.Unmarshal(m, b)
+}
+func (m *RevokeApiTokenReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeApiTokenReq.Marshal(b, m, deterministic)
+}
+func (m *RevokeApiTokenReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeApiTokenReq.Merge(m, src)
+}
+func (m *RevokeApiTokenReq) XXX_Size() int {
+	return xxx_messageInfo_RevokeApiTokenReq.Size(m)
+}
+func (m *RevokeApiTokenReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeApiTokenReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeApiTokenReq proto.InternalMessageInfo
+
+func (m *RevokeApiTokenReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*ApiToken)(nil), "proto.ApiToken")
+	proto.RegisterType((*CreateApiTokenReq)(nil), "proto.CreateApiTokenReq")
+	proto.RegisterType((*CreateApiTokenRes)(nil), "proto.CreateApiTokenRes")
+	proto.RegisterType((*ListApiTokensReq)(nil), "proto.ListApiTokensReq")
+	proto.RegisterType((*ListApiTokensRes)(nil), "proto.ListApiTokensRes")
+	proto.RegisterType((*RevokeApiTokenReq)(nil), "proto.RevokeApiTokenReq")
+}
+
+func init() { proto.RegisterFile("apitokens.proto", fileDescriptor_37e70f504b8d547e) }
+
+var fileDescriptor_37e70f504b8d547e = []byte{
+	// 435 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x53, 0x5d, 0x6f, 0xd3, 0x30,
+	0x14, 0x9d, 0x93, 0xb6, 0x24, 0x17, 0xd1, 0x6e, 0x16, 0x1a, 0x56, 0x10, 0x22, 0x0a, 0x20, 0xfa,
+	0x80, 0x32, 0x69, 0x3c, 0xed, 0xb1, 0x1a, 0xf0, 0x84, 0x78, 0x88, 0x26, 0xf1, 0x58, 0x79, 0xe9,
+	0x65, 0xb2, 0xd6, 0xd4, 0x26, 0xf6, 0x06, 0xfc, 0x15, 0x5e, 0xf9, 0x5f, 0xfc, 0x16, 0xe4, 0x8f,
+	0x06, 0xd2, 0x74, 0x42, 0x7b, 0xca, 0xfd, 0x38, 0xf7, 0xf8, 0x9c, 0x6b, 0x07, 0x66, 0x5c, 0x09,
+	0x23, 0xaf, 0x71, 0xa3, 0x4b, 0xd5, 0x4a, 0x23, 0xe9, 0xd8, 0x7d, 0xb2, 0xe7, 0x57, 0x52, 0x5e,
+	0xad, 0xf1, 0xc4, 0x65, 0x97, 0x37, 0x5f, 0x4e, 0x8c, 0x68, 0x50, 0x1b, 0xde, 0x28, 0x8f, 0xcb,
+	0x9e, 0xee, 0x02, 0xb0, 0x51, 0xe6, 0x87, 0x6f, 0x16, 0x3f, 0x23, 0x48, 0x16, 0x4a, 0x5c, 0x58,
+	0x62, 0x3a, 0x85, 0x48, 0xac, 0x18, 0xc9, 0xc9, 0x3c, 0xad, 0x22, 0xb1, 0xa2, 0x14, 0x46, 0x1b,
+	0xde, 0x20, 0x8b, 0x5c, 0xc5, 0xc5, 0xf4, 0x31, 0x8c, 0xe5, 0xb7, 0x0d, 0xb6, 0x2c, 0x76, 0x45,
+	0x9f, 0xd0, 0x67, 0x00, 0x2e, 0x58, 0x3a, 0xfc, 0xc8, 0xb5, 0x52, 0x57, 0xf9, 0x64, 0x87, 0x5e,
+	0xc3, 0x4c, 0x63, 0x7b, 0x2b, 0x6a, 0x5c, 0xf2, 0xba, 0x96, 0x37, 0x1b, 0xc3, 0xc6, 0x39, 0x99,
+	0x27, 0xd5, 0x34, 0x94, 0x17, 0xbe, 0x4a, 0x8f, 0x61, 0xa2, 0x6b, 0xa9, 0x50, 0xb3, 0x49, 0x1e,
+	0xcf, 0xd3, 0x2a, 0x64, 0xf4, 0x0c, 0xa0, 0x6e, 0x91, 0x1b, 0x5c, 0x2d, 0xb9, 0x61, 0x0f, 0x72,
+	0x32, 0x7f, 0x78, 0x9a, 0x95, 0xde, 0x58, 0xb9, 0x35, 0x56, 0x5e, 0x6c, 0x9d, 0x57, 0x69, 0x40,
+	0x2f, 0x8c, 0x1d, 0xc5, 0xef, 0x4a, 0xb4, 0xa8, 0xed, 0x68, 0xf2, 0xff, 0xd1, 0x80, 0x5e, 0x98,
+	0xe2, 0x17, 0x81, 0xa3, 0x73, 0x47, 0xb4, 0x5d, 0x51, 0x85, 0x5f, 0xbb, 0xad, 0x90, 0x7f, 0xb6,
+	0xf2, 0x57, 0x77, 0xd4, 0xd3, 0xbd, 0xc7, 0x78, 0xbc, 0xd7, 0x78, 0x5f, 0xe5, 0xe8, 0x3e, 0x2a,
+	0x3f, 0x0f, 0x45, 0x6a, 0x7b, 0x4d, 0xee, 0xb1, 0x04, 0x95, 0x3e, 0xa1, 0x6f, 0x20, 0xe5, 0x4a,
+	0x2c, 0x7d, 0x27, 0x72, 0x87, 0xcc, 0x3c, 0x7b, 0xd9, 0x0d, 0x27, 0x3c, 0x44, 0xc5, 0x4b, 0x38,
+	0xfc, 0x28, 0xb4, 0xd9, 0x76, 0xb4, 0x35, 0x7f, 0x08, 0x31, 0x5f, 0xaf, 0x1d, 0x6b, 0x52, 0xd9,
+	0xb0, 0x38, 0x1b, 0xa0, 0x34, 0x7d, 0x05, 0x63, 0x61, 0xb0, 0xd1, 0x8c, 0xe4, 0xf1, 0xbe, 0x33,
+	0x7c, 0xb7, 0x78, 0x01, 0x47, 0x15, 0xde, 0xca, 0xeb, 0xde, 0x7a, 0x77, 0x1e, 0xe1, 0xe9, 0x6f,
+	0x02, 0x69, 0x47, 0x4e, 0x3f, 0xc0, 0xb4, 0x6f, 0x96, 0xb2, 0x40, 0x3e, 0xb8, 0xa8, 0xec, 0xae,
+	0x8e, 0x2e, 0x0e, 0xe8, 0x39, 0x3c, 0xea, 0xa9, 0xa6, 0x4f, 0x02, 0x78, 0xd7, 0x71, 0x76, 0x47,
+	0xc3, 0x92, 0xbc, 0x83, 0x69, 0x5f, 0x7f, 0x27, 0x66, 0x60, 0x2b, 0x3b, 0x1e, 0x5c, 0xe6, 0x7b,
+	0xfb, 0x1b, 0x16, 0x07, 0x97, 0x13, 0x57, 0x79, 0xfb, 0x67, 0x00, 0x2d, 0x21, 0x81, 0x7f, 0xe1,
+	0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// ApiTokensClient is the client API for ApiTokens service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ApiTokensClient interface {
+	CreateApiToken(ctx context.Context, in *CreateApiTokenReq, opts ...grpc.CallOption) (*CreateApiTokenRes, error)
+	ListApiTokens(ctx context.Context, in *ListApiTokensReq, opts ...grpc.CallOption) (*ListApiTokensRes, error)
+	RevokeApiToken(ctx context.Context, in *RevokeApiTokenReq, opts ...grpc.CallOption) (*empty.Empty, error)
+}
+
+type apiTokensClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewApiTokensClient(cc grpc.ClientConnInterface) ApiTokensClient {
+	return &apiTokensClient{cc}
+}
+
+func (c *apiTokensClient) CreateApiToken(ctx context.Context, in *CreateApiTokenReq, opts ...grpc.CallOption) (*CreateApiTokenRes, error) {
+	out := new(CreateApiTokenRes)
+	err := c.cc.Invoke(ctx, "/proto.ApiTokens/CreateApiToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiTokensClient) ListApiTokens(ctx context.Context, in *ListApiTokensReq, opts ...grpc.CallOption) (*ListApiTokensRes, error) {
+	out := new(ListApiTokensRes)
+	err := c.cc.Invoke(ctx, "/proto.ApiTokens/ListApiTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiTokensClient) RevokeApiToken(ctx context.Context, in *RevokeApiTokenReq, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/proto.ApiTokens/RevokeApiToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ApiTokensServer is the server API for ApiTokens service.
+type ApiTokensServer interface {
+	CreateApiToken(context.Context, *CreateApiTokenReq) (*CreateApiTokenRes, error)
+	ListApiTokens(context.Context, *ListApiTokensReq) (*ListApiTokensRes, error)
+	RevokeApiToken(context.Context, *RevokeApiTokenReq) (*empty.Empty, error)
+}
+
+// UnimplementedApiTokensServer can be embedded to have forward compatible implementations.
+type UnimplementedApiTokensServer struct {
+}
+
+func (*UnimplementedApiTokensServer) CreateApiToken(ctx context.Context, req *CreateApiTokenReq) (*CreateApiTokenRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateApiToken not implemented")
+}
+func (*UnimplementedApiTokensServer) ListApiTokens(ctx context.Context, req *ListApiTokensReq) (*ListApiTokensRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListApiTokens not implemented")
+}
+func (*UnimplementedApiTokensServer) RevokeApiToken(ctx context.Context, req *RevokeApiTokenReq) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeApiToken not implemented")
+}
+
+func RegisterApiTokensServer(s *grpc.Server, srv ApiTokensServer) {
+	s.RegisterService(&_ApiTokens_serviceDesc, srv)
+}
+
+func _ApiTokens_CreateApiToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateApiTokenReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiTokensServer).CreateApiToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.ApiTokens/CreateApiToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiTokensServer).CreateApiToken(ctx, req.(*CreateApiTokenReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ApiTokens_ListApiTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListApiTokensReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiTokensServer).ListApiTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.ApiTokens/ListApiTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiTokensServer).ListApiTokens(ctx, req.(*ListApiTokensReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ApiTokens_RevokeApiToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeApiTokenReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiTokensServer).RevokeApiToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.ApiTokens/RevokeApiToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiTokensServer).RevokeApiToken(ctx, req.(*RevokeApiTokenReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ApiTokens_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.ApiTokens",
+	HandlerType: (*ApiTokensServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateApiToken",
+			Handler:    _ApiTokens_CreateApiToken_Handler,
+		},
+		{
+			MethodName: "ListApiTokens",
+			Handler:    _ApiTokens_ListApiTokens_Handler,
+		},
+		{
+			MethodName: "RevokeApiToken",
+			Handler:    _ApiTokens_RevokeApiToken_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "apitokens.proto",
+}
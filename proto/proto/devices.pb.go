@@ -28,22 +28,35 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type Device struct {
-	Name                 string               `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Owner                string               `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
-	PublicKey            string               `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
-	Address              string               `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
-	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
-	Connected            bool                 `protobuf:"varint,6,opt,name=connected,proto3" json:"connected,omitempty"`
-	LastHandshakeTime    *timestamp.Timestamp `protobuf:"bytes,7,opt,name=last_handshake_time,json=lastHandshakeTime,proto3" json:"last_handshake_time,omitempty"`
-	ReceiveBytes         int64                `protobuf:"varint,8,opt,name=receive_bytes,json=receiveBytes,proto3" json:"receive_bytes,omitempty"`
-	TransmitBytes        int64                `protobuf:"varint,9,opt,name=transmit_bytes,json=transmitBytes,proto3" json:"transmit_bytes,omitempty"`
-	Endpoint             string               `protobuf:"bytes,10,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
-	OwnerName            string               `protobuf:"bytes,11,opt,name=owner_name,json=ownerName,proto3" json:"owner_name,omitempty"`
-	OwnerEmail           string               `protobuf:"bytes,12,opt,name=owner_email,json=ownerEmail,proto3" json:"owner_email,omitempty"`
-	OwnerProvider        string               `protobuf:"bytes,13,opt,name=owner_provider,json=ownerProvider,proto3" json:"owner_provider,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+	Name              string               `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Owner             string               `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
+	PublicKey         string               `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Address           string               `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
+	CreatedAt         *timestamp.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Connected         bool                 `protobuf:"varint,6,opt,name=connected,proto3" json:"connected,omitempty"`
+	LastHandshakeTime *timestamp.Timestamp `protobuf:"bytes,7,opt,name=last_handshake_time,json=lastHandshakeTime,proto3" json:"last_handshake_time,omitempty"`
+	ReceiveBytes      int64                `protobuf:"varint,8,opt,name=receive_bytes,json=receiveBytes,proto3" json:"receive_bytes,omitempty"`
+	TransmitBytes     int64                `protobuf:"varint,9,opt,name=transmit_bytes,json=transmitBytes,proto3" json:"transmit_bytes,omitempty"`
+	Endpoint          string               `protobuf:"bytes,10,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	OwnerName         string               `protobuf:"bytes,11,opt,name=owner_name,json=ownerName,proto3" json:"owner_name,omitempty"`
+	OwnerEmail        string               `protobuf:"bytes,12,opt,name=owner_email,json=ownerEmail,proto3" json:"owner_email,omitempty"`
+	OwnerProvider     string               `protobuf:"bytes,13,opt,name=owner_provider,json=ownerProvider,proto3" json:"owner_provider,omitempty"`
+	// bandwidth limits in bits per second
+	// a limit of 0 means unlimited
+	IngressLimit uint64 `protobuf:"varint,14,opt,name=ingress_limit,json=ingressLimit,proto3" json:"ingress_limit,omitempty"`
+	EgressLimit  uint64 `protobuf:"varint,15,opt,name=egress_limit,json=egressLimit,proto3" json:"egress_limit,omitempty"`
+	// overrides the server's default keepalive
+	// interval (seconds) if not 0.
+	PersistentKeepalive int32 `protobuf:"varint,16,opt,name=persistent_keepalive,json=persistentKeepalive,proto3" json:"persistent_keepalive,omitempty"`
+	// the vpn profile that the device belongs to.
+	// empty for the default profile.
+	Profile string `protobuf:"bytes,17,opt,name=profile,proto3" json:"profile,omitempty"`
+	// disabled devices can't connect i.e. because
+	// their owner was deprovisioned
+	Disabled             bool     `protobuf:"varint,18,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *Device) Reset()         { *m = Device{} }
@@ -162,9 +175,50 @@ func (m *Device) GetOwnerProvider() string {
 	return ""
 }
 
+func (m *Device) GetIngressLimit() uint64 {
+	if m != nil {
+		return m.IngressLimit
+	}
+	return 0
+}
+
+func (m *Device) GetEgressLimit() uint64 {
+	if m != nil {
+		return m.EgressLimit
+	}
+	return 0
+}
+
+func (m *Device) GetPersistentKeepalive() int32 {
+	if m != nil {
+		return m.PersistentKeepalive
+	}
+	return 0
+}
+
+func (m *Device) GetProfile() string {
+	if m != nil {
+		return m.Profile
+	}
+	return ""
+}
+
+func (m *Device) GetDisabled() bool {
+	if m != nil {
+		return m.Disabled
+	}
+	return false
+}
+
 type AddDeviceReq struct {
-	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	PublicKey            string   `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Name      string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	PublicKey string `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// optionally override the server's default keepalive
+	// interval (seconds) for this device.
+	PersistentKeepalive int32 `protobuf:"varint,3,opt,name=persistent_keepalive,json=persistentKeepalive,proto3" json:"persistent_keepalive,omitempty"`
+	// the vpn profile to add the device to.
+	// empty for the default profile.
+	Profile              string   `protobuf:"bytes,4,opt,name=profile,proto3" json:"profile,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -209,6 +263,20 @@ func (m *AddDeviceReq) GetPublicKey() string {
 	return ""
 }
 
+func (m *AddDeviceReq) GetPersistentKeepalive() int32 {
+	if m != nil {
+		return m.PersistentKeepalive
+	}
+	return 0
+}
+
+func (m *AddDeviceReq) GetProfile() string {
+	if m != nil {
+		return m.Profile
+	}
+	return ""
+}
+
 type ListDevicesReq struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -399,6 +467,233 @@ func (m *ListAllDevicesRes) GetItems() []*Device {
 	return nil
 }
 
+type SetDeviceBandwidthReq struct {
+	Owner string `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// limits in bits per second (0 is unlimited)
+	IngressLimit         uint64   `protobuf:"varint,3,opt,name=ingress_limit,json=ingressLimit,proto3" json:"ingress_limit,omitempty"`
+	EgressLimit          uint64   `protobuf:"varint,4,opt,name=egress_limit,json=egressLimit,proto3" json:"egress_limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetDeviceBandwidthReq) Reset()         { *m = SetDeviceBandwidthReq{} }
+func (m *SetDeviceBandwidthReq) String() string { return proto.CompactTextString(m) }
+func (*SetDeviceBandwidthReq) ProtoMessage()    {}
+func (*SetDeviceBandwidthReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6d27ec3f2c0e2043, []int{7}
+}
+
+func (m *SetDeviceBandwidthReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetDeviceBandwidthReq.Unmarshal(m, b)
+}
+func (m *SetDeviceBandwidthReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetDeviceBandwidthReq.Marshal(b, m, deterministic)
+}
+func (m *SetDeviceBandwidthReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetDeviceBandwidthReq.Merge(m, src)
+}
+func (m *SetDeviceBandwidthReq) XXX_Size() int {
+	return xxx_messageInfo_SetDeviceBandwidthReq.Size(m)
+}
+func (m *SetDeviceBandwidthReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetDeviceBandwidthReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetDeviceBandwidthReq proto.InternalMessageInfo
+
+func (m *SetDeviceBandwidthReq) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *SetDeviceBandwidthReq) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SetDeviceBandwidthReq) GetIngressLimit() uint64 {
+	if m != nil {
+		return m.IngressLimit
+	}
+	return 0
+}
+
+func (m *SetDeviceBandwidthReq) GetEgressLimit() uint64 {
+	if m != nil {
+		return m.EgressLimit
+	}
+	return 0
+}
+
+type DeviceTraffic struct {
+	Owner string `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// the allowed network (cidr) that traffic
+	// was exchanged with
+	Destination          string               `protobuf:"bytes,3,opt,name=destination,proto3" json:"destination,omitempty"`
+	ReceiveBytes         int64                `protobuf:"varint,4,opt,name=receive_bytes,json=receiveBytes,proto3" json:"receive_bytes,omitempty"`
+	TransmitBytes        int64                `protobuf:"varint,5,opt,name=transmit_bytes,json=transmitBytes,proto3" json:"transmit_bytes,omitempty"`
+	UpdatedAt            *timestamp.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *DeviceTraffic) Reset()         { *m = DeviceTraffic{} }
+func (m *DeviceTraffic) String() string { return proto.CompactTextString(m) }
+func (*DeviceTraffic) ProtoMessage()    {}
+func (*DeviceTraffic) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6d27ec3f2c0e2043, []int{8}
+}
+
+func (m *DeviceTraffic) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeviceTraffic.Unmarshal(m, b)
+}
+func (m *DeviceTraffic) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeviceTraffic.Marshal(b, m, deterministic)
+}
+func (m *DeviceTraffic) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeviceTraffic.Merge(m, src)
+}
+func (m *DeviceTraffic) XXX_Size() int {
+	return xxx_messageInfo_DeviceTraffic.Size(m)
+}
+func (m *DeviceTraffic) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeviceTraffic.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeviceTraffic proto.InternalMessageInfo
+
+func (m *DeviceTraffic) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *DeviceTraffic) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DeviceTraffic) GetDestination() string {
+	if m != nil {
+		return m.Destination
+	}
+	return ""
+}
+
+func (m *DeviceTraffic) GetReceiveBytes() int64 {
+	if m != nil {
+		return m.ReceiveBytes
+	}
+	return 0
+}
+
+func (m *DeviceTraffic) GetTransmitBytes() int64 {
+	if m != nil {
+		return m.TransmitBytes
+	}
+	return 0
+}
+
+func (m *DeviceTraffic) GetUpdatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return nil
+}
+
+type ListDeviceTrafficReq struct {
+	// admin's may list traffic for devices owned
+	// by someone other than the current user.
+	// if empty, defaults to the current user.
+	// admin's can set an empty value to list traffic for all users.
+	Owner                *wrappers.StringValue `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *ListDeviceTrafficReq) Reset()         { *m = ListDeviceTrafficReq{} }
+func (m *ListDeviceTrafficReq) String() string { return proto.CompactTextString(m) }
+func (*ListDeviceTrafficReq) ProtoMessage()    {}
+func (*ListDeviceTrafficReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6d27ec3f2c0e2043, []int{9}
+}
+
+func (m *ListDeviceTrafficReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListDeviceTrafficReq.Unmarshal(m, b)
+}
+func (m *ListDeviceTrafficReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListDeviceTrafficReq.Marshal(b, m, deterministic)
+}
+func (m *ListDeviceTrafficReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListDeviceTrafficReq.Merge(m, src)
+}
+func (m *ListDeviceTrafficReq) XXX_Size() int {
+	return xxx_messageInfo_ListDeviceTrafficReq.Size(m)
+}
+func (m *ListDeviceTrafficReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListDeviceTrafficReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListDeviceTrafficReq proto.InternalMessageInfo
+
+func (m *ListDeviceTrafficReq) GetOwner() *wrappers.StringValue {
+	if m != nil {
+		return m.Owner
+	}
+	return nil
+}
+
+type ListDeviceTrafficRes struct {
+	Items                []*DeviceTraffic `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *ListDeviceTrafficRes) Reset()         { *m = ListDeviceTrafficRes{} }
+func (m *ListDeviceTrafficRes) String() string { return proto.CompactTextString(m) }
+func (*ListDeviceTrafficRes) ProtoMessage()    {}
+func (*ListDeviceTrafficRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6d27ec3f2c0e2043, []int{10}
+}
+
+func (m *ListDeviceTrafficRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListDeviceTrafficRes.Unmarshal(m, b)
+}
+func (m *ListDeviceTrafficRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListDeviceTrafficRes.Marshal(b, m, deterministic)
+}
+func (m *ListDeviceTrafficRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListDeviceTrafficRes.Merge(m, src)
+}
+func (m *ListDeviceTrafficRes) XXX_Size() int {
+	return xxx_messageInfo_ListDeviceTrafficRes.Size(m)
+}
+func (m *ListDeviceTrafficRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListDeviceTrafficRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListDeviceTrafficRes proto.InternalMessageInfo
+
+func (m *ListDeviceTrafficRes) GetItems() []*DeviceTraffic {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Device)(nil), "proto.Device")
 	proto.RegisterType((*AddDeviceReq)(nil), "proto.AddDeviceReq")
@@ -407,47 +702,66 @@ func init() {
 	proto.RegisterType((*DeleteDeviceReq)(nil), "proto.DeleteDeviceReq")
 	proto.RegisterType((*ListAllDevicesReq)(nil), "proto.ListAllDevicesReq")
 	proto.RegisterType((*ListAllDevicesRes)(nil), "proto.ListAllDevicesRes")
+	proto.RegisterType((*SetDeviceBandwidthReq)(nil), "proto.SetDeviceBandwidthReq")
+	proto.RegisterType((*DeviceTraffic)(nil), "proto.DeviceTraffic")
+	proto.RegisterType((*ListDeviceTrafficReq)(nil), "proto.ListDeviceTrafficReq")
+	proto.RegisterType((*ListDeviceTrafficRes)(nil), "proto.ListDeviceTrafficRes")
 }
 
 func init() { proto.RegisterFile("devices.proto", fileDescriptor_6d27ec3f2c0e2043) }
 
 var fileDescriptor_6d27ec3f2c0e2043 = []byte{
-	// 548 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xdb, 0x6e, 0xd3, 0x4e,
-	0x10, 0xc6, 0xe3, 0xa6, 0x39, 0x78, 0x1c, 0xf7, 0xff, 0xef, 0x06, 0xaa, 0x95, 0x29, 0x34, 0x72,
-	0x85, 0x94, 0xab, 0x54, 0x04, 0x21, 0xc1, 0x05, 0x12, 0x41, 0x2d, 0x42, 0x80, 0x10, 0x32, 0x08,
-	0x89, 0x2b, 0x6b, 0x63, 0x0f, 0xa9, 0x55, 0x9f, 0xba, 0xbb, 0x49, 0x95, 0x07, 0xe0, 0x39, 0x79,
-	0x15, 0xe4, 0xdd, 0x4d, 0xea, 0x24, 0xe5, 0x70, 0x95, 0xec, 0xf7, 0x7d, 0xb3, 0x33, 0xfe, 0x8d,
-	0x0d, 0x6e, 0x8c, 0x8b, 0x24, 0x42, 0x31, 0x2a, 0x79, 0x21, 0x0b, 0xd2, 0x52, 0x3f, 0xde, 0xa3,
-	0x59, 0x51, 0xcc, 0x52, 0x3c, 0x53, 0xa7, 0xe9, 0xfc, 0xfb, 0xd9, 0x0d, 0x67, 0x65, 0x89, 0xdc,
-	0xc4, 0xbc, 0x93, 0x6d, 0x5f, 0x26, 0x19, 0x0a, 0xc9, 0xb2, 0xd2, 0x04, 0x1e, 0x6c, 0x07, 0x30,
-	0x2b, 0xe5, 0x52, 0x9b, 0xfe, 0xcf, 0x26, 0xb4, 0xcf, 0x55, 0x5b, 0x42, 0x60, 0x3f, 0x67, 0x19,
-	0x52, 0x6b, 0x60, 0x0d, 0xed, 0x40, 0xfd, 0x27, 0xf7, 0xa0, 0x55, 0xdc, 0xe4, 0xc8, 0xe9, 0x9e,
-	0x12, 0xf5, 0x81, 0x3c, 0x04, 0x28, 0xe7, 0xd3, 0x34, 0x89, 0xc2, 0x2b, 0x5c, 0xd2, 0xa6, 0xb2,
-	0x6c, 0xad, 0xbc, 0xc7, 0x25, 0xa1, 0xd0, 0x61, 0x71, 0xcc, 0x51, 0x08, 0xba, 0xaf, 0xbc, 0xd5,
-	0x91, 0xbc, 0x00, 0x88, 0x38, 0x32, 0x89, 0x71, 0xc8, 0x24, 0x6d, 0x0d, 0xac, 0xa1, 0x33, 0xf6,
-	0x46, 0x7a, 0xbe, 0xd1, 0x6a, 0xbe, 0xd1, 0x97, 0xd5, 0x03, 0x04, 0xb6, 0x49, 0x4f, 0x24, 0x39,
-	0x06, 0x3b, 0x2a, 0xf2, 0x1c, 0x23, 0x89, 0x31, 0x6d, 0x0f, 0xac, 0x61, 0x37, 0xb8, 0x15, 0xc8,
-	0x3b, 0xe8, 0xa7, 0x4c, 0xc8, 0xf0, 0x92, 0xe5, 0xb1, 0xb8, 0x64, 0x57, 0x18, 0x56, 0x14, 0x68,
-	0xe7, 0xaf, 0x1d, 0x0e, 0xab, 0xb2, 0xb7, 0xab, 0xaa, 0x4a, 0x27, 0xa7, 0xe0, 0x72, 0x8c, 0x30,
-	0x59, 0x60, 0x38, 0x5d, 0x4a, 0x14, 0xb4, 0x3b, 0xb0, 0x86, 0xcd, 0xa0, 0x67, 0xc4, 0xd7, 0x95,
-	0x46, 0x1e, 0xc3, 0x81, 0xe4, 0x2c, 0x17, 0x59, 0x22, 0x4d, 0xca, 0x56, 0x29, 0x77, 0xa5, 0xea,
-	0x98, 0x07, 0x5d, 0xcc, 0xe3, 0xb2, 0x48, 0x72, 0x49, 0x41, 0xb1, 0x58, 0x9f, 0x2b, 0x8a, 0x0a,
-	0x67, 0xa8, 0xa8, 0x3b, 0x9a, 0xa2, 0x52, 0x3e, 0x56, 0xe8, 0x4f, 0xc0, 0xd1, 0x36, 0x66, 0x2c,
-	0x49, 0x69, 0x4f, 0xf9, 0xba, 0xe2, 0xa2, 0x52, 0xaa, 0x11, 0x74, 0xa0, 0xe4, 0xc5, 0x22, 0x89,
-	0x91, 0x53, 0x57, 0x65, 0x5c, 0xa5, 0x7e, 0x32, 0xa2, 0x3f, 0x81, 0xde, 0x24, 0x8e, 0xf5, 0x8e,
-	0x03, 0xbc, 0xbe, 0x73, 0xcd, 0x9b, 0x0b, 0xdd, 0xdb, 0x5a, 0xa8, 0xff, 0x3f, 0x1c, 0x7c, 0x48,
-	0x84, 0xd4, 0x77, 0x88, 0x00, 0xaf, 0xfd, 0x67, 0x5b, 0x8a, 0x20, 0xa7, 0xd0, 0x4a, 0x24, 0x66,
-	0x82, 0x5a, 0x83, 0xe6, 0xd0, 0x19, 0xbb, 0x1a, 0xf6, 0xc8, 0xf4, 0xd5, 0x9e, 0xff, 0x0d, 0xfe,
-	0x3b, 0xc7, 0x14, 0x25, 0xfe, 0x79, 0x9c, 0x71, 0xfd, 0xad, 0x73, 0xc6, 0xc7, 0x3b, 0xfb, 0xfb,
-	0x2c, 0x79, 0x92, 0xcf, 0xbe, 0xb2, 0x74, 0x8e, 0xe6, 0x9d, 0xf4, 0xfb, 0x70, 0x58, 0x4d, 0x34,
-	0x49, 0xd3, 0xda, 0x98, 0xcf, 0x77, 0xc5, 0x7f, 0x9b, 0x74, 0xfc, 0x63, 0x0f, 0x3a, 0xa6, 0x86,
-	0x3c, 0x01, 0x7b, 0x4d, 0x90, 0xf4, 0x4d, 0xbc, 0xce, 0xd4, 0xdb, 0xbc, 0xc3, 0x6f, 0x90, 0x97,
-	0xe0, 0xd4, 0xf8, 0x90, 0xfb, 0xc6, 0xdf, 0xa4, 0xe8, 0xdd, 0x29, 0x0b, 0xbf, 0x41, 0x5e, 0x41,
-	0xaf, 0xce, 0x89, 0x1c, 0xad, 0xef, 0xdf, 0x80, 0xe7, 0x1d, 0xed, 0x90, 0xb9, 0xa8, 0xbe, 0x6d,
-	0xbf, 0x41, 0xde, 0xe8, 0x05, 0xdd, 0x3e, 0x39, 0xa1, 0xb5, 0x66, 0x1b, 0x94, 0xbc, 0xdf, 0x39,
-	0xc2, 0x6f, 0x4c, 0xdb, 0xca, 0x7a, 0xfa, 0x2b, 0x00, 0x00, 0xff, 0xff, 0x66, 0x8c, 0x07, 0x7b,
-	0x9c, 0x04, 0x00, 0x00,
+	// 799 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0x4d, 0x6f, 0xdb, 0x46,
+	0x10, 0x35, 0x2d, 0xc9, 0xb6, 0x86, 0x92, 0x13, 0xaf, 0x9d, 0x60, 0xc1, 0xb8, 0x0d, 0xcb, 0xa0,
+	0x80, 0xd0, 0x83, 0x82, 0xa8, 0x28, 0xd0, 0x1c, 0x0a, 0xd4, 0x6e, 0x52, 0x14, 0x49, 0xd0, 0x16,
+	0x4c, 0x50, 0xa0, 0x27, 0x62, 0xa5, 0x1d, 0xdb, 0x0b, 0xf3, 0x2b, 0xdc, 0xb5, 0x0d, 0xff, 0x84,
+	0x5e, 0x8a, 0xde, 0xfb, 0xef, 0xfa, 0x4b, 0x8a, 0xfd, 0xa0, 0x4c, 0x8a, 0x4a, 0xa2, 0x9c, 0xec,
+	0x79, 0xf3, 0x96, 0x7c, 0x33, 0x7c, 0x6f, 0x05, 0x63, 0x8e, 0xd7, 0x62, 0x81, 0x72, 0x5a, 0x56,
+	0x85, 0x2a, 0xc8, 0xc0, 0xfc, 0x09, 0xbe, 0x3c, 0x2f, 0x8a, 0xf3, 0x14, 0x9f, 0x9a, 0x6a, 0x7e,
+	0x75, 0xf6, 0xf4, 0xa6, 0x62, 0x65, 0x89, 0x95, 0xa3, 0x05, 0x8f, 0x57, 0xfb, 0x4a, 0x64, 0x28,
+	0x15, 0xcb, 0x4a, 0x47, 0x78, 0xb4, 0x4a, 0xc0, 0xac, 0x54, 0xb7, 0xb6, 0x19, 0xfd, 0x33, 0x80,
+	0x9d, 0x17, 0xe6, 0xb5, 0x84, 0x40, 0x3f, 0x67, 0x19, 0x52, 0x2f, 0xf4, 0x26, 0xc3, 0xd8, 0xfc,
+	0x4f, 0x8e, 0x60, 0x50, 0xdc, 0xe4, 0x58, 0xd1, 0x6d, 0x03, 0xda, 0x82, 0x7c, 0x01, 0x50, 0x5e,
+	0xcd, 0x53, 0xb1, 0x48, 0x2e, 0xf1, 0x96, 0xf6, 0x4c, 0x6b, 0x68, 0x91, 0xd7, 0x78, 0x4b, 0x28,
+	0xec, 0x32, 0xce, 0x2b, 0x94, 0x92, 0xf6, 0x4d, 0xaf, 0x2e, 0xc9, 0x73, 0x80, 0x45, 0x85, 0x4c,
+	0x21, 0x4f, 0x98, 0xa2, 0x83, 0xd0, 0x9b, 0xf8, 0xb3, 0x60, 0x6a, 0xf5, 0x4d, 0x6b, 0x7d, 0xd3,
+	0x77, 0xf5, 0x00, 0xf1, 0xd0, 0xb1, 0x4f, 0x14, 0x39, 0x86, 0xe1, 0xa2, 0xc8, 0x73, 0x5c, 0x28,
+	0xe4, 0x74, 0x27, 0xf4, 0x26, 0x7b, 0xf1, 0x1d, 0x40, 0x5e, 0xc1, 0x61, 0xca, 0xa4, 0x4a, 0x2e,
+	0x58, 0xce, 0xe5, 0x05, 0xbb, 0xc4, 0x44, 0x6f, 0x81, 0xee, 0x7e, 0xf2, 0x0d, 0x07, 0xfa, 0xd8,
+	0x2f, 0xf5, 0x29, 0x8d, 0x93, 0x27, 0x30, 0xae, 0x70, 0x81, 0xe2, 0x1a, 0x93, 0xf9, 0xad, 0x42,
+	0x49, 0xf7, 0x42, 0x6f, 0xd2, 0x8b, 0x47, 0x0e, 0x3c, 0xd5, 0x18, 0xf9, 0x1a, 0xf6, 0x55, 0xc5,
+	0x72, 0x99, 0x09, 0xe5, 0x58, 0x43, 0xc3, 0x1a, 0xd7, 0xa8, 0xa5, 0x05, 0xb0, 0x87, 0x39, 0x2f,
+	0x0b, 0x91, 0x2b, 0x0a, 0x66, 0x17, 0xcb, 0x5a, 0x6f, 0xd1, 0xac, 0x33, 0x31, 0x5b, 0xf7, 0xed,
+	0x16, 0x0d, 0xf2, 0xab, 0x5e, 0xfd, 0x63, 0xf0, 0x6d, 0x1b, 0x33, 0x26, 0x52, 0x3a, 0x32, 0x7d,
+	0x7b, 0xe2, 0xa5, 0x46, 0xb4, 0x04, 0x4b, 0x28, 0xab, 0xe2, 0x5a, 0x70, 0xac, 0xe8, 0xd8, 0x70,
+	0xc6, 0x06, 0xfd, 0xdd, 0x81, 0x7a, 0x1c, 0x91, 0x9f, 0xeb, 0xf5, 0x27, 0xa9, 0xc8, 0x84, 0xa2,
+	0xfb, 0xa1, 0x37, 0xe9, 0xc7, 0x23, 0x07, 0xbe, 0xd1, 0x18, 0xf9, 0x0a, 0x46, 0xd8, 0xe4, 0xdc,
+	0x33, 0x1c, 0x1f, 0x1b, 0x94, 0x67, 0x70, 0xa4, 0x5d, 0x27, 0xa4, 0xc2, 0x5c, 0x25, 0x97, 0x88,
+	0x25, 0x4b, 0xc5, 0x35, 0xd2, 0xfb, 0xa1, 0x37, 0x19, 0xc4, 0x87, 0x77, 0xbd, 0xd7, 0x75, 0x4b,
+	0x1b, 0xa1, 0xac, 0x8a, 0x33, 0x91, 0x22, 0x3d, 0xb0, 0x46, 0x70, 0xa5, 0xde, 0x0b, 0x17, 0x92,
+	0xcd, 0x53, 0xe4, 0x94, 0x98, 0x8f, 0xb9, 0xac, 0xa3, 0xbf, 0x3d, 0x18, 0x9d, 0x70, 0x6e, 0x5d,
+	0x19, 0xe3, 0xfb, 0xb5, 0xc6, 0x6c, 0x5b, 0x70, 0x7b, 0xd5, 0x82, 0x1f, 0x12, 0xdb, 0xdb, 0x48,
+	0x6c, 0xbf, 0x25, 0x36, 0xba, 0x0f, 0xfb, 0x6f, 0x84, 0x54, 0x56, 0x90, 0x8c, 0xf1, 0x7d, 0xf4,
+	0xdd, 0x0a, 0x22, 0xc9, 0x13, 0x18, 0x08, 0x85, 0x99, 0xa4, 0x5e, 0xd8, 0x9b, 0xf8, 0xb3, 0xb1,
+	0xf5, 0xda, 0xd4, 0x0d, 0x61, 0x7b, 0xd1, 0x9f, 0x70, 0xef, 0x05, 0xa6, 0xa8, 0xf0, 0xe3, 0xb3,
+	0xcd, 0x9a, 0xa1, 0xf3, 0x67, 0xc7, 0x1d, 0xfb, 0xbe, 0x55, 0x95, 0xc8, 0xcf, 0xff, 0x60, 0xe9,
+	0x15, 0xba, 0x48, 0x46, 0x87, 0x70, 0xa0, 0x15, 0x9d, 0xa4, 0x69, 0x43, 0xe6, 0xf7, 0x5d, 0x70,
+	0x43, 0xa5, 0x7f, 0x79, 0xf0, 0xe0, 0x2d, 0xba, 0x01, 0x4f, 0x59, 0xce, 0x6f, 0x04, 0x57, 0x17,
+	0x5a, 0xf0, 0xf2, 0x46, 0xf0, 0x9a, 0x37, 0x42, 0x3d, 0xc6, 0x76, 0x63, 0x8c, 0x8e, 0xf1, 0x7a,
+	0x1b, 0x18, 0xaf, 0xdf, 0x31, 0x5e, 0xf4, 0x9f, 0x07, 0x63, 0x2b, 0xe4, 0x5d, 0xc5, 0xce, 0xce,
+	0xc4, 0xe2, 0x33, 0x34, 0x84, 0xe0, 0x73, 0x94, 0x4a, 0xe4, 0x4c, 0x89, 0x22, 0x77, 0x57, 0x55,
+	0x13, 0xea, 0xa6, 0xbd, 0xbf, 0x51, 0xda, 0x07, 0xeb, 0xd2, 0xfe, 0x1c, 0xe0, 0xaa, 0xe4, 0xf5,
+	0xf5, 0xb6, 0xf3, 0xe9, 0xeb, 0xcd, 0xb1, 0x4f, 0x54, 0xf4, 0x0a, 0x8e, 0xee, 0x1c, 0xe5, 0xe6,
+	0xd4, 0xeb, 0x9e, 0x35, 0x47, 0xdd, 0xd0, 0x0b, 0xa7, 0x6b, 0x9f, 0x25, 0xc9, 0x37, 0xed, 0x2f,
+	0x7f, 0xd4, 0xfa, 0xf2, 0x35, 0xcf, 0x52, 0x66, 0xff, 0xf6, 0x60, 0xd7, 0x36, 0x24, 0x79, 0x06,
+	0xc3, 0x65, 0x1e, 0xc9, 0xa1, 0x3b, 0xd5, 0x4c, 0x68, 0xd0, 0x36, 0x51, 0xb4, 0x45, 0x7e, 0x00,
+	0xbf, 0x11, 0x10, 0xf2, 0xc0, 0xf5, 0xdb, 0x31, 0x0a, 0xd6, 0xc2, 0x32, 0xda, 0x22, 0x3f, 0xc2,
+	0xa8, 0x19, 0x14, 0xf2, 0x70, 0xf9, 0xfc, 0x56, 0x7a, 0x82, 0x87, 0x9d, 0x75, 0xbc, 0xd4, 0xbf,
+	0x6d, 0xd1, 0x16, 0xf9, 0xcd, 0x5a, 0xbf, 0xed, 0x9b, 0x47, 0x9d, 0xf7, 0xdd, 0x6d, 0x3a, 0xf8,
+	0x48, 0x53, 0x4b, 0xfa, 0x19, 0xf6, 0xdb, 0x59, 0x22, 0xb4, 0x71, 0xa0, 0x95, 0xbb, 0xe0, 0x43,
+	0x1d, 0xfd, 0x9c, 0x9f, 0x80, 0x74, 0x83, 0x45, 0x8e, 0xdd, 0x89, 0xb5, 0x99, 0xeb, 0xac, 0x77,
+	0xbe, 0x63, 0xea, 0x6f, 0xff, 0x1f, 0x00, 0x4b, 0xc9, 0xe6, 0x44, 0x32, 0x08, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -465,8 +779,10 @@ type DevicesClient interface {
 	AddDevice(ctx context.Context, in *AddDeviceReq, opts ...grpc.CallOption) (*Device, error)
 	ListDevices(ctx context.Context, in *ListDevicesReq, opts ...grpc.CallOption) (*ListDevicesRes, error)
 	DeleteDevice(ctx context.Context, in *DeleteDeviceReq, opts ...grpc.CallOption) (*empty.Empty, error)
+	ListDeviceTraffic(ctx context.Context, in *ListDeviceTrafficReq, opts ...grpc.CallOption) (*ListDeviceTrafficRes, error)
 	// admin only
 	ListAllDevices(ctx context.Context, in *ListAllDevicesReq, opts ...grpc.CallOption) (*ListAllDevicesRes, error)
+	SetDeviceBandwidth(ctx context.Context, in *SetDeviceBandwidthReq, opts ...grpc.CallOption) (*Device, error)
 }
 
 type devicesClient struct {
@@ -504,6 +820,15 @@ func (c *devicesClient) DeleteDevice(ctx context.Context, in *DeleteDeviceReq, o
 	return out, nil
 }
 
+func (c *devicesClient) ListDeviceTraffic(ctx context.Context, in *ListDeviceTrafficReq, opts ...grpc.CallOption) (*ListDeviceTrafficRes, error) {
+	out := new(ListDeviceTrafficRes)
+	err := c.cc.Invoke(ctx, "/proto.Devices/ListDeviceTraffic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *devicesClient) ListAllDevices(ctx context.Context, in *ListAllDevicesReq, opts ...grpc.CallOption) (*ListAllDevicesRes, error) {
 	out := new(ListAllDevicesRes)
 	err := c.cc.Invoke(ctx, "/proto.Devices/ListAllDevices", in, out, opts...)
@@ -513,13 +838,24 @@ func (c *devicesClient) ListAllDevices(ctx context.Context, in *ListAllDevicesRe
 	return out, nil
 }
 
+func (c *devicesClient) SetDeviceBandwidth(ctx context.Context, in *SetDeviceBandwidthReq, opts ...grpc.CallOption) (*Device, error) {
+	out := new(Device)
+	err := c.cc.Invoke(ctx, "/proto.Devices/SetDeviceBandwidth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DevicesServer is the server API for Devices service.
 type DevicesServer interface {
 	AddDevice(context.Context, *AddDeviceReq) (*Device, error)
 	ListDevices(context.Context, *ListDevicesReq) (*ListDevicesRes, error)
 	DeleteDevice(context.Context, *DeleteDeviceReq) (*empty.Empty, error)
+	ListDeviceTraffic(context.Context, *ListDeviceTrafficReq) (*ListDeviceTrafficRes, error)
 	// admin only
 	ListAllDevices(context.Context, *ListAllDevicesReq) (*ListAllDevicesRes, error)
+	SetDeviceBandwidth(context.Context, *SetDeviceBandwidthReq) (*Device, error)
 }
 
 // UnimplementedDevicesServer can be embedded to have forward compatible implementations.
@@ -535,9 +871,15 @@ func (*UnimplementedDevicesServer) ListDevices(ctx context.Context, req *ListDev
 func (*UnimplementedDevicesServer) DeleteDevice(ctx context.Context, req *DeleteDeviceReq) (*empty.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteDevice not implemented")
 }
+func (*UnimplementedDevicesServer) ListDeviceTraffic(ctx context.Context, req *ListDeviceTrafficReq) (*ListDeviceTrafficRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeviceTraffic not implemented")
+}
 func (*UnimplementedDevicesServer) ListAllDevices(ctx context.Context, req *ListAllDevicesReq) (*ListAllDevicesRes, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListAllDevices not implemented")
 }
+func (*UnimplementedDevicesServer) SetDeviceBandwidth(ctx context.Context, req *SetDeviceBandwidthReq) (*Device, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetDeviceBandwidth not implemented")
+}
 
 func RegisterDevicesServer(s *grpc.Server, srv DevicesServer) {
 	s.RegisterService(&_Devices_serviceDesc, srv)
@@ -597,6 +939,24 @@ func _Devices_DeleteDevice_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Devices_ListDeviceTraffic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeviceTrafficReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).ListDeviceTraffic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Devices/ListDeviceTraffic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).ListDeviceTraffic(ctx, req.(*ListDeviceTrafficReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Devices_ListAllDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListAllDevicesReq)
 	if err := dec(in); err != nil {
@@ -615,6 +975,24 @@ func _Devices_ListAllDevices_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Devices_SetDeviceBandwidth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDeviceBandwidthReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).SetDeviceBandwidth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Devices/SetDeviceBandwidth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).SetDeviceBandwidth(ctx, req.(*SetDeviceBandwidthReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Devices_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.Devices",
 	HandlerType: (*DevicesServer)(nil),
@@ -631,10 +1009,18 @@ var _Devices_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteDevice",
 			Handler:    _Devices_DeleteDevice_Handler,
 		},
+		{
+			MethodName: "ListDeviceTraffic",
+			Handler:    _Devices_ListDeviceTraffic_Handler,
+		},
 		{
 			MethodName: "ListAllDevices",
 			Handler:    _Devices_ListAllDevices_Handler,
 		},
+		{
+			MethodName: "SetDeviceBandwidth",
+			Handler:    _Devices_SetDeviceBandwidth_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "devices.proto",
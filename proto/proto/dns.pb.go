@@ -0,0 +1,363 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: dns.proto
+
+package proto
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type DNSQuery struct {
+	Time     *timestamp.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	ClientIp string               `protobuf:"bytes,2,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	// the device that sent the query.
+	// empty if the client isn't a vpn device.
+	Owner      string `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	DeviceName string `protobuf:"bytes,4,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`
+	Name       string `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	Type       string `protobuf:"bytes,6,opt,name=type,proto3" json:"type,omitempty"`
+	Rcode      string `protobuf:"bytes,7,opt,name=rcode,proto3" json:"rcode,omitempty"`
+	// one of "records", "zone", "blocklist", "cache" or "upstream"
+	AnsweredBy string `protobuf:"bytes,8,opt,name=answered_by,json=answeredBy,proto3" json:"answered_by,omitempty"`
+	// the blocklist that blocked the query
+	BlockedBy            string   `protobuf:"bytes,9,opt,name=blocked_by,json=blockedBy,proto3" json:"blocked_by,omitempty"`
+	DurationMs           int64    `protobuf:"varint,10,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DNSQuery) Reset()         { *m = DNSQuery{} }
+func (m *DNSQuery) String() string { return proto.CompactTextString(m) }
+func (*DNSQuery) ProtoMessage()    {}
+func (*DNSQuery) Descriptor() ([]byte, []int) {
+	return fileDescriptor_638ff8d8aaf3d8ae, []int{0}
+}
+
+func (m *DNSQuery) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DNSQuery.Unmarshal(m, b)
+}
+func (m *DNSQuery) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DNSQuery.Marshal(b, m, deterministic)
+}
+func (m *DNSQuery) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DNSQuery.Merge(m, src)
+}
+func (m *DNSQuery) XXX_Size() int {
+	return xxx_messageInfo_DNSQuery.Size(m)
+}
+func (m *DNSQuery) XXX_DiscardUnknown() {
+	xxx_messageInfo_DNSQuery.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DNSQuery proto.InternalMessageInfo
+
+func (m *DNSQuery) GetTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *DNSQuery) GetClientIp() string {
+	if m != nil {
+		return m.ClientIp
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetDeviceName() string {
+	if m != nil {
+		return m.DeviceName
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetRcode() string {
+	if m != nil {
+		return m.Rcode
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetAnsweredBy() string {
+	if m != nil {
+		return m.AnsweredBy
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetBlockedBy() string {
+	if m != nil {
+		return m.BlockedBy
+	}
+	return ""
+}
+
+func (m *DNSQuery) GetDurationMs() int64 {
+	if m != nil {
+		return m.DurationMs
+	}
+	return 0
+}
+
+type SearchQueriesReq struct {
+	// empty fields match all queries
+	Owner      string `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	DeviceName string `protobuf:"bytes,2,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`
+	// matches the domain and its subdomains
+	Domain string `protobuf:"bytes,3,opt,name=domain,proto3" json:"domain,omitempty"`
+	// the maximum number of queries to return.
+	// defaults to 100.
+	Limit                int32    `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SearchQueriesReq) Reset()         { *m = SearchQueriesReq{} }
+func (m *SearchQueriesReq) String() string { return proto.CompactTextString(m) }
+func (*SearchQueriesReq) ProtoMessage()    {}
+func (*SearchQueriesReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_638ff8d8aaf3d8ae, []int{1}
+}
+
+func (m *SearchQueriesReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchQueriesReq.Unmarshal(m, b)
+}
+func (m *SearchQueriesReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchQueriesReq.Marshal(b, m, deterministic)
+}
+func (m *SearchQueriesReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchQueriesReq.Merge(m, src)
+}
+func (m *SearchQueriesReq) XXX_Size() int {
+	return xxx_messageInfo_SearchQueriesReq.Size(m)
+}
+func (m *SearchQueriesReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchQueriesReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchQueriesReq proto.InternalMessageInfo
+
+func (m *SearchQueriesReq) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *SearchQueriesReq) GetDeviceName() string {
+	if m != nil {
+		return m.DeviceName
+	}
+	return ""
+}
+
+func (m *SearchQueriesReq) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *SearchQueriesReq) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type SearchQueriesRes struct {
+	// newest first
+	Items                []*DNSQuery `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *SearchQueriesRes) Reset()         { *m = SearchQueriesRes{} }
+func (m *SearchQueriesRes) String() string { return proto.CompactTextString(m) }
+func (*SearchQueriesRes) ProtoMessage()    {}
+func (*SearchQueriesRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_638ff8d8aaf3d8ae, []int{2}
+}
+
+func (m *SearchQueriesRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchQueriesRes.Unmarshal(m, b)
+}
+func (m *SearchQueriesRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchQueriesRes.Marshal(b, m, deterministic)
+}
+func (m *SearchQueriesRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchQueriesRes.Merge(m, src)
+}
+func (m *SearchQueriesRes) XXX_Size() int {
+	return xxx_messageInfo_SearchQueriesRes.Size(m)
+}
+func (m *SearchQueriesRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchQueriesRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchQueriesRes proto.InternalMessageInfo
+
+func (m *SearchQueriesRes) GetItems() []*DNSQuery {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*DNSQuery)(nil), "proto.DNSQuery")
+	proto.RegisterType((*SearchQueriesReq)(nil), "proto.SearchQueriesReq")
+	proto.RegisterType((*SearchQueriesRes)(nil), "proto.SearchQueriesRes")
+}
+
+func init() { proto.RegisterFile("dns.proto", fileDescriptor_638ff8d8aaf3d8ae) }
+
+var fileDescriptor_638ff8d8aaf3d8ae = []byte{
+	// 355 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x91, 0x4f, 0x6b, 0xdb, 0x40,
+	0x10, 0xc5, 0x2b, 0x4b, 0x72, 0xad, 0x31, 0xa5, 0x65, 0x29, 0xed, 0xe2, 0x52, 0x2c, 0x04, 0x05,
+	0x9d, 0x64, 0x70, 0x4f, 0xbd, 0xba, 0xbe, 0x24, 0x10, 0x43, 0xe4, 0xdc, 0x85, 0xfe, 0x4c, 0x9c,
+	0x25, 0xda, 0x5d, 0x45, 0xbb, 0x8e, 0xd1, 0x87, 0xca, 0x77, 0x0c, 0xda, 0x95, 0x08, 0x89, 0x49,
+	0x4e, 0xd2, 0x7b, 0xbf, 0x99, 0x27, 0xcd, 0x0c, 0x04, 0x95, 0x50, 0x49, 0xd3, 0x4a, 0x2d, 0x89,
+	0x6f, 0x1e, 0x8b, 0xe5, 0x41, 0xca, 0x43, 0x8d, 0x2b, 0xa3, 0x8a, 0xe3, 0xed, 0x4a, 0x33, 0x8e,
+	0x4a, 0xe7, 0xbc, 0xb1, 0x75, 0xd1, 0xd3, 0x04, 0x66, 0xdb, 0xdd, 0xfe, 0xfa, 0x88, 0x6d, 0x47,
+	0x12, 0xf0, 0x7a, 0x4e, 0x9d, 0xd0, 0x89, 0xe7, 0xeb, 0x45, 0x62, 0x9b, 0x93, 0xb1, 0x39, 0xb9,
+	0x19, 0x9b, 0x53, 0x53, 0x47, 0x7e, 0x41, 0x50, 0xd6, 0x0c, 0x85, 0xce, 0x58, 0x43, 0x27, 0xa1,
+	0x13, 0x07, 0xe9, 0xcc, 0x1a, 0x17, 0x0d, 0xf9, 0x0e, 0xbe, 0x3c, 0x09, 0x6c, 0xa9, 0x6b, 0x80,
+	0x15, 0x64, 0x09, 0xf3, 0x0a, 0x1f, 0x59, 0x89, 0x99, 0xc8, 0x39, 0x52, 0xcf, 0x30, 0xb0, 0xd6,
+	0x2e, 0xe7, 0x48, 0x08, 0x78, 0x86, 0xf8, 0x86, 0x78, 0x62, 0xf0, 0x74, 0xd7, 0x20, 0x9d, 0x5a,
+	0xaf, 0x7f, 0xef, 0xe3, 0xdb, 0x52, 0x56, 0x48, 0x3f, 0xdb, 0x78, 0x23, 0xfa, 0xf8, 0x5c, 0xa8,
+	0x13, 0xb6, 0x58, 0x65, 0x45, 0x47, 0x67, 0x36, 0x7e, 0xb4, 0x36, 0x1d, 0xf9, 0x0d, 0x50, 0xd4,
+	0xb2, 0xbc, 0xb7, 0x3c, 0x30, 0x3c, 0x18, 0x9c, 0x4d, 0x67, 0x7e, 0xef, 0xd8, 0xe6, 0x9a, 0x49,
+	0x91, 0x71, 0x45, 0x21, 0x74, 0x62, 0x37, 0x85, 0xd1, 0xba, 0x52, 0xd1, 0x09, 0xbe, 0xed, 0x31,
+	0x6f, 0xcb, 0xbb, 0x7e, 0x63, 0x0c, 0x55, 0x8a, 0x0f, 0x2f, 0x93, 0x3a, 0x1f, 0x4c, 0x3a, 0x39,
+	0x9b, 0xf4, 0x07, 0x4c, 0x2b, 0xc9, 0x73, 0x26, 0x86, 0x0d, 0x0d, 0xaa, 0x8f, 0xab, 0x19, 0x67,
+	0xda, 0x2c, 0xc7, 0x4f, 0xad, 0x88, 0xfe, 0x9d, 0x7d, 0x58, 0x91, 0x3f, 0xe0, 0x33, 0x8d, 0x5c,
+	0x51, 0x27, 0x74, 0xe3, 0xf9, 0xfa, 0xab, 0xbd, 0x54, 0x32, 0xde, 0x33, 0xb5, 0x74, 0x7d, 0x09,
+	0xee, 0x76, 0xb7, 0x27, 0xff, 0xe1, 0xcb, 0xab, 0x04, 0xf2, 0x73, 0xa8, 0x7f, 0x3b, 0xd0, 0xe2,
+	0x1d, 0xa0, 0xa2, 0x4f, 0xc5, 0xd4, 0x90, 0xbf, 0xcf, 0x03, 0x00, 0x0c, 0x67, 0xb3, 0x91, 0x6b,
+	0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// DNSClient is the client API for DNS service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type DNSClient interface {
+	SearchQueries(ctx context.Context, in *SearchQueriesReq, opts ...grpc.CallOption) (*SearchQueriesRes, error)
+}
+
+type dNSClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDNSClient(cc grpc.ClientConnInterface) DNSClient {
+	return &dNSClient{cc}
+}
+
+func (c *dNSClient) SearchQueries(ctx context.Context, in *SearchQueriesReq, opts ...grpc.CallOption) (*SearchQueriesRes, error) {
+	out := new(SearchQueriesRes)
+	err := c.cc.Invoke(ctx, "/proto.DNS/SearchQueries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DNSServer is the server API for DNS service.
+type DNSServer interface {
+	SearchQueries(context.Context, *SearchQueriesReq) (*SearchQueriesRes, error)
+}
+
+// UnimplementedDNSServer can be embedded to have forward compatible implementations.
+type UnimplementedDNSServer struct {
+}
+
+func (*UnimplementedDNSServer) SearchQueries(ctx context.Context, req *SearchQueriesReq) (*SearchQueriesRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchQueries not implemented")
+}
+
+func RegisterDNSServer(s *grpc.Server, srv DNSServer) {
+	s.RegisterService(&_DNS_serviceDesc, srv)
+}
+
+func _DNS_SearchQueries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchQueriesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DNSServer).SearchQueries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.DNS/SearchQueries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DNSServer).SearchQueries(ctx, req.(*SearchQueriesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _DNS_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.DNS",
+	HandlerType: (*DNSServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SearchQueries",
+			Handler:    _DNS_SearchQueries_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dns.proto",
+}
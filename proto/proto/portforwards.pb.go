@@ -0,0 +1,485 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: portforwards.proto
+
+package proto
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type PortForward struct {
+	Protocol             string               `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	PublicPort           int32                `protobuf:"varint,2,opt,name=public_port,json=publicPort,proto3" json:"public_port,omitempty"`
+	Owner                string               `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	DeviceName           string               `protobuf:"bytes,4,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`
+	DevicePort           int32                `protobuf:"varint,5,opt,name=device_port,json=devicePort,proto3" json:"device_port,omitempty"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *PortForward) Reset()         { *m = PortForward{} }
+func (m *PortForward) String() string { return proto.CompactTextString(m) }
+func (*PortForward) ProtoMessage()    {}
+func (*PortForward) Descriptor() ([]byte, []int) {
+	return fileDescriptor_62c530431f989db7, []int{0}
+}
+
+func (m *PortForward) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PortForward.Unmarshal(m, b)
+}
+func (m *PortForward) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PortForward.Marshal(b, m, deterministic)
+}
+func (m *PortForward) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PortForward.Merge(m, src)
+}
+func (m *PortForward) XXX_Size() int {
+	return xxx_messageInfo_PortForward.Size(m)
+}
+func (m *PortForward) XXX_DiscardUnknown() {
+	xxx_messageInfo_PortForward.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PortForward proto.InternalMessageInfo
+
+func (m *PortForward) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *PortForward) GetPublicPort() int32 {
+	if m != nil {
+		return m.PublicPort
+	}
+	return 0
+}
+
+func (m *PortForward) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *PortForward) GetDeviceName() string {
+	if m != nil {
+		return m.DeviceName
+	}
+	return ""
+}
+
+func (m *PortForward) GetDevicePort() int32 {
+	if m != nil {
+		return m.DevicePort
+	}
+	return 0
+}
+
+func (m *PortForward) GetCreatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+type AddPortForwardReq struct {
+	// either "tcp" or "udp"
+	Protocol   string `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	PublicPort int32  `protobuf:"varint,2,opt,name=public_port,json=publicPort,proto3" json:"public_port,omitempty"`
+	// the device that traffic will be forwarded to
+	Owner                string   `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	DeviceName           string   `protobuf:"bytes,4,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`
+	DevicePort           int32    `protobuf:"varint,5,opt,name=device_port,json=devicePort,proto3" json:"device_port,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddPortForwardReq) Reset()         { *m = AddPortForwardReq{} }
+func (m *AddPortForwardReq) String() string { return proto.CompactTextString(m) }
+func (*AddPortForwardReq) ProtoMessage()    {}
+func (*AddPortForwardReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_62c530431f989db7, []int{1}
+}
+
+func (m *AddPortForwardReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddPortForwardReq.Unmarshal(m, b)
+}
+func (m *AddPortForwardReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddPortForwardReq.Marshal(b, m, deterministic)
+}
+func (m *AddPortForwardReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddPortForwardReq.Merge(m, src)
+}
+func (m *AddPortForwardReq) XXX_Size() int {
+	return xxx_messageInfo_AddPortForwardReq.Size(m)
+}
+func (m *AddPortForwardReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddPortForwardReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddPortForwardReq proto.InternalMessageInfo
+
+func (m *AddPortForwardReq) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *AddPortForwardReq) GetPublicPort() int32 {
+	if m != nil {
+		return m.PublicPort
+	}
+	return 0
+}
+
+func (m *AddPortForwardReq) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *AddPortForwardReq) GetDeviceName() string {
+	if m != nil {
+		return m.DeviceName
+	}
+	return ""
+}
+
+func (m *AddPortForwardReq) GetDevicePort() int32 {
+	if m != nil {
+		return m.DevicePort
+	}
+	return 0
+}
+
+type ListPortForwardsReq struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPortForwardsReq) Reset()         { *m = ListPortForwardsReq{} }
+func (m *ListPortForwardsReq) String() string { return proto.CompactTextString(m) }
+func (*ListPortForwardsReq) ProtoMessage()    {}
+func (*ListPortForwardsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_62c530431f989db7, []int{2}
+}
+
+func (m *ListPortForwardsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPortForwardsReq.Unmarshal(m, b)
+}
+func (m *ListPortForwardsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPortForwardsReq.Marshal(b, m, deterministic)
+}
+func (m *ListPortForwardsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPortForwardsReq.Merge(m, src)
+}
+func (m *ListPortForwardsReq) XXX_Size() int {
+	return xxx_messageInfo_ListPortForwardsReq.Size(m)
+}
+func (m *ListPortForwardsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPortForwardsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPortForwardsReq proto.InternalMessageInfo
+
+type ListPortForwardsRes struct {
+	Items                []*PortForward `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ListPortForwardsRes) Reset()         { *m = ListPortForwardsRes{} }
+func (m *ListPortForwardsRes) String() string { return proto.CompactTextString(m) }
+func (*ListPortForwardsRes) ProtoMessage()    {}
+func (*ListPortForwardsRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_62c530431f989db7, []int{3}
+}
+
+func (m *ListPortForwardsRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPortForwardsRes.Unmarshal(m, b)
+}
+func (m *ListPortForwardsRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPortForwardsRes.Marshal(b, m, deterministic)
+}
+func (m *ListPortForwardsRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPortForwardsRes.Merge(m, src)
+}
+func (m *ListPortForwardsRes) XXX_Size() int {
+	return xxx_messageInfo_ListPortForwardsRes.Size(m)
+}
+func (m *ListPortForwardsRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPortForwardsRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPortForwardsRes proto.InternalMessageInfo
+
+func (m *ListPortForwardsRes) GetItems() []*PortForward {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+type DeletePortForwardReq struct {
+	Protocol             string   `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	PublicPort           int32    `protobuf:"varint,2,opt,name=public_port,json=publicPort,proto3" json:"public_port,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeletePortForwardReq) Reset()         { *m = DeletePortForwardReq{} }
+func (m *DeletePortForwardReq) String() string { return proto.CompactTextString(m) }
+func (*DeletePortForwardReq) ProtoMessage()    {}
+func (*DeletePortForwardReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_62c530431f989db7, []int{4}
+}
+
+func (m *DeletePortForwardReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeletePortForwardReq.Unmarshal(m, b)
+}
+func (m *DeletePortForwardReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeletePortForwardReq.Marshal(b, m, deterministic)
+}
+func (m *DeletePortForwardReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeletePortForwardReq.Merge(m, src)
+}
+func (m *DeletePortForwardReq) XXX_Size() int {
+	return xxx_messageInfo_DeletePortForwardReq.Size(m)
+}
+func (m *DeletePortForwardReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeletePortForwardReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeletePortForwardReq proto.InternalMessageInfo
+
+func (m *DeletePortForwardReq) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *DeletePortForwardReq) GetPublicPort() int32 {
+	if m != nil {
+		return m.PublicPort
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*PortForward)(nil), "proto.PortForward")
+	proto.RegisterType((*AddPortForwardReq)(nil), "proto.AddPortForwardReq")
+	proto.RegisterType((*ListPortForwardsReq)(nil), "proto.ListPortForwardsReq")
+	proto.RegisterType((*ListPortForwardsRes)(nil), "proto.ListPortForwardsRes")
+	proto.RegisterType((*DeletePortForwardReq)(nil), "proto.DeletePortForwardReq")
+}
+
+func init() { proto.RegisterFile("portforwards.proto", fileDescriptor_62c530431f989db7) }
+
+var fileDescriptor_62c530431f989db7 = []byte{
+	// 355 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x53, 0xc1, 0x4a, 0xeb, 0x40,
+	0x14, 0xed, 0xbc, 0xbe, 0x94, 0xd7, 0x9b, 0x87, 0xd8, 0xb1, 0x4a, 0x98, 0x2e, 0x1a, 0x66, 0x95,
+	0x55, 0x0a, 0x75, 0xe5, 0x4a, 0x0b, 0xea, 0x42, 0x8a, 0x48, 0x74, 0x5f, 0xd2, 0xe4, 0xb6, 0x04,
+	0x92, 0x4e, 0x9a, 0x99, 0x5a, 0xfc, 0x23, 0x7f, 0xca, 0x1f, 0xf0, 0x2b, 0x24, 0x33, 0xa9, 0xc4,
+	0x36, 0xee, 0x5c, 0xb8, 0x0a, 0xf7, 0xdc, 0x73, 0x0f, 0xe7, 0x1c, 0x32, 0x40, 0x73, 0x51, 0xa8,
+	0x85, 0x28, 0xb6, 0x61, 0x11, 0x4b, 0x3f, 0x2f, 0x84, 0x12, 0xd4, 0xd2, 0x1f, 0x36, 0x5c, 0x0a,
+	0xb1, 0x4c, 0x71, 0xa4, 0xa7, 0xf9, 0x66, 0x31, 0x52, 0x49, 0x86, 0x52, 0x85, 0x59, 0x6e, 0x78,
+	0x6c, 0xb0, 0x4f, 0xc0, 0x2c, 0x57, 0x2f, 0x66, 0xc9, 0xdf, 0x08, 0xd8, 0x0f, 0xa2, 0x50, 0xb7,
+	0x46, 0x9b, 0x32, 0xf8, 0xa7, 0x17, 0x91, 0x48, 0x1d, 0xe2, 0x12, 0xaf, 0x1b, 0x7c, 0xce, 0x74,
+	0x08, 0x76, 0xbe, 0x99, 0xa7, 0x49, 0x34, 0x2b, 0xdd, 0x38, 0x7f, 0x5c, 0xe2, 0x59, 0x01, 0x18,
+	0xa8, 0xd4, 0xa0, 0x7d, 0xb0, 0xc4, 0x76, 0x85, 0x85, 0xd3, 0xd6, 0x97, 0x66, 0x28, 0xcf, 0x62,
+	0x7c, 0x4e, 0x22, 0x9c, 0xad, 0xc2, 0x0c, 0x9d, 0xbf, 0x7a, 0x07, 0x06, 0xba, 0x0f, 0x33, 0xac,
+	0x11, 0xb4, 0xae, 0x65, 0x74, 0x0d, 0xa4, 0x75, 0x2f, 0x00, 0xa2, 0x02, 0x43, 0x85, 0xf1, 0x2c,
+	0x54, 0x4e, 0xc7, 0x25, 0x9e, 0x3d, 0x66, 0xbe, 0x89, 0xe5, 0xef, 0x62, 0xf9, 0x4f, 0xbb, 0xdc,
+	0x41, 0xb7, 0x62, 0x4f, 0x14, 0x7f, 0x25, 0xd0, 0x9b, 0xc4, 0x71, 0x2d, 0x62, 0x80, 0xeb, 0x5f,
+	0x99, 0x92, 0x9f, 0xc2, 0xc9, 0x34, 0x91, 0xaa, 0x66, 0x55, 0x06, 0xb8, 0xe6, 0x97, 0x4d, 0xb0,
+	0xa4, 0x1e, 0x58, 0x89, 0xc2, 0x4c, 0x3a, 0xc4, 0x6d, 0x7b, 0xf6, 0x98, 0x9a, 0x1e, 0xfc, 0x7a,
+	0x50, 0x43, 0xe0, 0x8f, 0xd0, 0xbf, 0xc6, 0x14, 0x15, 0xfe, 0x60, 0x09, 0xe3, 0x77, 0x02, 0xff,
+	0xeb, 0x96, 0xe8, 0x15, 0x1c, 0x7d, 0xed, 0x99, 0x3a, 0x95, 0xa5, 0x83, 0xfa, 0x59, 0x83, 0x59,
+	0xde, 0xa2, 0x53, 0x38, 0xde, 0x0f, 0x4a, 0x59, 0xc5, 0x6c, 0x28, 0x86, 0x7d, 0xbf, 0x93, 0xbc,
+	0x45, 0xef, 0xa0, 0x77, 0x90, 0x9a, 0x0e, 0xaa, 0x93, 0xa6, 0x3e, 0xd8, 0xd9, 0xc1, 0x1f, 0x75,
+	0x53, 0x3e, 0x14, 0xde, 0x9a, 0x77, 0x34, 0x72, 0xfe, 0x31, 0x00, 0xd5, 0x6d, 0x66, 0x1c, 0x86,
+	0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// PortForwardsClient is the client API for PortForwards service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PortForwardsClient interface {
+	AddPortForward(ctx context.Context, in *AddPortForwardReq, opts ...grpc.CallOption) (*PortForward, error)
+	ListPortForwards(ctx context.Context, in *ListPortForwardsReq, opts ...grpc.CallOption) (*ListPortForwardsRes, error)
+	DeletePortForward(ctx context.Context, in *DeletePortForwardReq, opts ...grpc.CallOption) (*empty.Empty, error)
+}
+
+type portForwardsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPortForwardsClient(cc grpc.ClientConnInterface) PortForwardsClient {
+	return &portForwardsClient{cc}
+}
+
+func (c *portForwardsClient) AddPortForward(ctx context.Context, in *AddPortForwardReq, opts ...grpc.CallOption) (*PortForward, error) {
+	out := new(PortForward)
+	err := c.cc.Invoke(ctx, "/proto.PortForwards/AddPortForward", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portForwardsClient) ListPortForwards(ctx context.Context, in *ListPortForwardsReq, opts ...grpc.CallOption) (*ListPortForwardsRes, error) {
+	out := new(ListPortForwardsRes)
+	err := c.cc.Invoke(ctx, "/proto.PortForwards/ListPortForwards", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portForwardsClient) DeletePortForward(ctx context.Context, in *DeletePortForwardReq, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/proto.PortForwards/DeletePortForward", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PortForwardsServer is the server API for PortForwards service.
+type PortForwardsServer interface {
+	AddPortForward(context.Context, *AddPortForwardReq) (*PortForward, error)
+	ListPortForwards(context.Context, *ListPortForwardsReq) (*ListPortForwardsRes, error)
+	DeletePortForward(context.Context, *DeletePortForwardReq) (*empty.Empty, error)
+}
+
+// UnimplementedPortForwardsServer can be embedded to have forward compatible implementations.
+type UnimplementedPortForwardsServer struct {
+}
+
+func (*UnimplementedPortForwardsServer) AddPortForward(ctx context.Context, req *AddPortForwardReq) (*PortForward, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPortForward not implemented")
+}
+func (*UnimplementedPortForwardsServer) ListPortForwards(ctx context.Context, req *ListPortForwardsReq) (*ListPortForwardsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPortForwards not implemented")
+}
+func (*UnimplementedPortForwardsServer) DeletePortForward(ctx context.Context, req *DeletePortForwardReq) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePortForward not implemented")
+}
+
+func RegisterPortForwardsServer(s *grpc.Server, srv PortForwardsServer) {
+	s.RegisterService(&_PortForwards_serviceDesc, srv)
+}
+
+func _PortForwards_AddPortForward_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPortForwardReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortForwardsServer).AddPortForward(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.PortForwards/AddPortForward",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortForwardsServer).AddPortForward(ctx, req.(*AddPortForwardReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortForwards_ListPortForwards_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPortForwardsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortForwardsServer).ListPortForwards(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.PortForwards/ListPortForwards",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortForwardsServer).ListPortForwards(ctx, req.(*ListPortForwardsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortForwards_DeletePortForward_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePortForwardReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortForwardsServer).DeletePortForward(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.PortForwards/DeletePortForward",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortForwardsServer).DeletePortForward(ctx, req.(*DeletePortForwardReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _PortForwards_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.PortForwards",
+	HandlerType: (*PortForwardsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddPortForward",
+			Handler:    _PortForwards_AddPortForward_Handler,
+		},
+		{
+			MethodName: "ListPortForwards",
+			Handler:    _PortForwards_ListPortForwards_Handler,
+		},
+		{
+			MethodName: "DeletePortForward",
+			Handler:    _PortForwards_DeletePortForward_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "portforwards.proto",
+}
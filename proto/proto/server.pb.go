@@ -57,18 +57,33 @@ func (m *InfoReq) XXX_DiscardUnknown() {
 var xxx_messageInfo_InfoReq proto.InternalMessageInfo
 
 type InfoRes struct {
-	PublicKey            string                `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
-	Host                 *wrappers.StringValue `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
-	Port                 int32                 `protobuf:"varint,3,opt,name=port,proto3" json:"port,omitempty"`
-	HostVpnIp            string                `protobuf:"bytes,4,opt,name=host_vpn_ip,json=hostVpnIp,proto3" json:"host_vpn_ip,omitempty"`
-	MetadataEnabled      bool                  `protobuf:"varint,5,opt,name=metadata_enabled,json=metadataEnabled,proto3" json:"metadata_enabled,omitempty"`
-	IsAdmin              bool                  `protobuf:"varint,6,opt,name=is_admin,json=isAdmin,proto3" json:"is_admin,omitempty"`
-	AllowedIps           string                `protobuf:"bytes,7,opt,name=allowed_ips,json=allowedIps,proto3" json:"allowed_ips,omitempty"`
-	DnsEnabled           bool                  `protobuf:"varint,8,opt,name=dns_enabled,json=dnsEnabled,proto3" json:"dns_enabled,omitempty"`
-	DnsAddress           string                `protobuf:"bytes,9,opt,name=dns_address,json=dnsAddress,proto3" json:"dns_address,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
-	XXX_unrecognized     []byte                `json:"-"`
-	XXX_sizecache        int32                 `json:"-"`
+	PublicKey       string                `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Host            *wrappers.StringValue `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Port            int32                 `protobuf:"varint,3,opt,name=port,proto3" json:"port,omitempty"`
+	HostVpnIp       string                `protobuf:"bytes,4,opt,name=host_vpn_ip,json=hostVpnIp,proto3" json:"host_vpn_ip,omitempty"`
+	MetadataEnabled bool                  `protobuf:"varint,5,opt,name=metadata_enabled,json=metadataEnabled,proto3" json:"metadata_enabled,omitempty"`
+	IsAdmin         bool                  `protobuf:"varint,6,opt,name=is_admin,json=isAdmin,proto3" json:"is_admin,omitempty"`
+	AllowedIps      string                `protobuf:"bytes,7,opt,name=allowed_ips,json=allowedIps,proto3" json:"allowed_ips,omitempty"`
+	DnsEnabled      bool                  `protobuf:"varint,8,opt,name=dns_enabled,json=dnsEnabled,proto3" json:"dns_enabled,omitempty"`
+	DnsAddress      string                `protobuf:"bytes,9,opt,name=dns_address,json=dnsAddress,proto3" json:"dns_address,omitempty"`
+	Mtu             int32                 `protobuf:"varint,10,opt,name=mtu,proto3" json:"mtu,omitempty"`
+	// the default keepalive interval (seconds)
+	// for client config files. 0 is disabled.
+	PersistentKeepalive int32 `protobuf:"varint,11,opt,name=persistent_keepalive,json=persistentKeepalive,proto3" json:"persistent_keepalive,omitempty"`
+	// the host:port clients should connect to.
+	// if empty, clients should use the web ui's host
+	// and the wireguard port.
+	Endpoint string `protobuf:"bytes,12,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	// additional vpn profiles that the user
+	// may add devices to. the fields above
+	// describe the default profile.
+	Profiles []*VPNProfile `protobuf:"bytes,13,rep,name=profiles,proto3" json:"profiles,omitempty"`
+	// the permissions granted by the user's roles
+	// e.g. "devices:read-all"
+	Permissions          []string `protobuf:"bytes,14,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *InfoRes) Reset()         { *m = InfoRes{} }
@@ -159,35 +174,166 @@ func (m *InfoRes) GetDnsAddress() string {
 	return ""
 }
 
+func (m *InfoRes) GetMtu() int32 {
+	if m != nil {
+		return m.Mtu
+	}
+	return 0
+}
+
+func (m *InfoRes) GetPersistentKeepalive() int32 {
+	if m != nil {
+		return m.PersistentKeepalive
+	}
+	return 0
+}
+
+func (m *InfoRes) GetEndpoint() string {
+	if m != nil {
+		return m.Endpoint
+	}
+	return ""
+}
+
+func (m *InfoRes) GetProfiles() []*VPNProfile {
+	if m != nil {
+		return m.Profiles
+	}
+	return nil
+}
+
+func (m *InfoRes) GetPermissions() []string {
+	if m != nil {
+		return m.Permissions
+	}
+	return nil
+}
+
+type VPNProfile struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Port                 int32    `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	HostVpnIp            string   `protobuf:"bytes,3,opt,name=host_vpn_ip,json=hostVpnIp,proto3" json:"host_vpn_ip,omitempty"`
+	AllowedIps           string   `protobuf:"bytes,4,opt,name=allowed_ips,json=allowedIps,proto3" json:"allowed_ips,omitempty"`
+	DnsEnabled           bool     `protobuf:"varint,5,opt,name=dns_enabled,json=dnsEnabled,proto3" json:"dns_enabled,omitempty"`
+	DnsAddress           string   `protobuf:"bytes,6,opt,name=dns_address,json=dnsAddress,proto3" json:"dns_address,omitempty"`
+	Endpoint             string   `protobuf:"bytes,7,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VPNProfile) Reset()         { *m = VPNProfile{} }
+func (m *VPNProfile) String() string { return proto.CompactTextString(m) }
+func (*VPNProfile) ProtoMessage()    {}
+func (*VPNProfile) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ad098daeda4239f7, []int{2}
+}
+
+func (m *VPNProfile) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VPNProfile.Unmarshal(m, b)
+}
+func (m *VPNProfile) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VPNProfile.Marshal(b, m, deterministic)
+}
+func (m *VPNProfile) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VPNProfile.Merge(m, src)
+}
+func (m *VPNProfile) XXX_Size() int {
+	return xxx_messageInfo_VPNProfile.Size(m)
+}
+func (m *VPNProfile) XXX_DiscardUnknown() {
+	xxx_messageInfo_VPNProfile.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VPNProfile proto.InternalMessageInfo
+
+func (m *VPNProfile) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *VPNProfile) GetPort() int32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+func (m *VPNProfile) GetHostVpnIp() string {
+	if m != nil {
+		return m.HostVpnIp
+	}
+	return ""
+}
+
+func (m *VPNProfile) GetAllowedIps() string {
+	if m != nil {
+		return m.AllowedIps
+	}
+	return ""
+}
+
+func (m *VPNProfile) GetDnsEnabled() bool {
+	if m != nil {
+		return m.DnsEnabled
+	}
+	return false
+}
+
+func (m *VPNProfile) GetDnsAddress() string {
+	if m != nil {
+		return m.DnsAddress
+	}
+	return ""
+}
+
+func (m *VPNProfile) GetEndpoint() string {
+	if m != nil {
+		return m.Endpoint
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*InfoReq)(nil), "proto.InfoReq")
 	proto.RegisterType((*InfoRes)(nil), "proto.InfoRes")
+	proto.RegisterType((*VPNProfile)(nil), "proto.VPNProfile")
 }
 
 func init() { proto.RegisterFile("server.proto", fileDescriptor_ad098daeda4239f7) }
 
 var fileDescriptor_ad098daeda4239f7 = []byte{
-	// 308 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x90, 0xcf, 0x4e, 0xf3, 0x30,
-	0x10, 0xc4, 0xbf, 0xf4, 0x4b, 0xff, 0x64, 0x8b, 0x00, 0xf9, 0x64, 0x2a, 0x28, 0x51, 0x4f, 0xe1,
-	0x92, 0xa2, 0xf2, 0x04, 0x3d, 0x70, 0xa8, 0xb8, 0xa5, 0x52, 0xaf, 0x91, 0x83, 0xb7, 0xc5, 0x22,
-	0xb5, 0x8d, 0x37, 0x6d, 0xd5, 0x27, 0xe3, 0xf5, 0x50, 0xec, 0x06, 0x89, 0x93, 0x77, 0x7e, 0x9e,
-	0xdd, 0x91, 0x06, 0xae, 0x08, 0xdd, 0x11, 0x5d, 0x6e, 0x9d, 0x69, 0x0c, 0xeb, 0xfb, 0x67, 0x32,
-	0xdd, 0x19, 0xb3, 0xab, 0x71, 0xee, 0x55, 0x75, 0xd8, 0xce, 0x4f, 0x4e, 0x58, 0x8b, 0x8e, 0x82,
-	0x6d, 0x96, 0xc0, 0x70, 0xa5, 0xb7, 0xa6, 0xc0, 0xaf, 0xd9, 0x77, 0xaf, 0x9b, 0x89, 0x3d, 0x00,
-	0xd8, 0x43, 0x55, 0xab, 0xf7, 0xf2, 0x13, 0xcf, 0x3c, 0x4a, 0xa3, 0x2c, 0x29, 0x92, 0x40, 0xde,
-	0xf0, 0xcc, 0x9e, 0x21, 0xfe, 0x30, 0xd4, 0xf0, 0x5e, 0x1a, 0x65, 0xe3, 0xc5, 0x7d, 0x1e, 0x42,
-	0xf2, 0x2e, 0x24, 0x5f, 0x37, 0x4e, 0xe9, 0xdd, 0x46, 0xd4, 0x07, 0x2c, 0xbc, 0x93, 0x31, 0x88,
-	0xad, 0x71, 0x0d, 0xff, 0x9f, 0x46, 0x59, 0xbf, 0xf0, 0x33, 0x9b, 0xc2, 0xb8, 0xfd, 0x2b, 0x8f,
-	0x56, 0x97, 0xca, 0xf2, 0x38, 0xa4, 0xb4, 0x68, 0x63, 0xf5, 0xca, 0xb2, 0x27, 0xb8, 0xdd, 0x63,
-	0x23, 0xa4, 0x68, 0x44, 0x89, 0x5a, 0x54, 0x35, 0x4a, 0xde, 0x4f, 0xa3, 0x6c, 0x54, 0xdc, 0x74,
-	0xfc, 0x35, 0x60, 0x76, 0x07, 0x23, 0x45, 0xa5, 0x90, 0x7b, 0xa5, 0xf9, 0xc0, 0x5b, 0x86, 0x8a,
-	0x96, 0xad, 0x64, 0x8f, 0x30, 0x16, 0x75, 0x6d, 0x4e, 0x28, 0x4b, 0x65, 0x89, 0x0f, 0x7d, 0x0a,
-	0x5c, 0xd0, 0xca, 0x52, 0x6b, 0x90, 0x9a, 0x7e, 0x13, 0x46, 0x7e, 0x1d, 0xa4, 0xa6, 0xee, 0xf8,
-	0xc5, 0x20, 0xa4, 0x74, 0x48, 0xc4, 0x93, 0x70, 0x41, 0x6a, 0x5a, 0x06, 0xb2, 0x58, 0xc0, 0x60,
-	0xed, 0xbb, 0x67, 0x19, 0xc4, 0x6d, 0x85, 0xec, 0x3a, 0x74, 0x91, 0x5f, 0xba, 0x9d, 0xfc, 0xd5,
-	0x34, 0xfb, 0x57, 0x0d, 0x3c, 0x78, 0xf9, 0x09, 0x00, 0x00, 0xff, 0xff, 0xc8, 0x30, 0xb0, 0x21,
-	0xb6, 0x01, 0x00, 0x00,
+	// 448 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x53, 0x5d, 0x6f, 0xd3, 0x30,
+	0x14, 0x25, 0x4b, 0xda, 0x26, 0x37, 0x63, 0x0c, 0xc3, 0x83, 0xa9, 0x60, 0x44, 0x7d, 0x0a, 0x0f,
+	0x64, 0x50, 0x7e, 0xc1, 0x1e, 0x78, 0xa8, 0x26, 0xa1, 0x29, 0x93, 0xfa, 0x1a, 0xb9, 0xf8, 0xb6,
+	0x58, 0x4b, 0x6c, 0x63, 0xbb, 0x9d, 0xf6, 0x3b, 0xf9, 0x15, 0xfc, 0x0b, 0x14, 0x27, 0xe9, 0x3e,
+	0xd0, 0xd4, 0xa7, 0xdc, 0x7b, 0xee, 0xb1, 0x8f, 0x72, 0xce, 0x35, 0x1c, 0x5b, 0x34, 0x3b, 0x34,
+	0x85, 0x36, 0xca, 0x29, 0x32, 0xf2, 0x9f, 0xe9, 0xd9, 0x46, 0xa9, 0x4d, 0x8d, 0xe7, 0xbe, 0x5b,
+	0x6d, 0xd7, 0xe7, 0xb7, 0x86, 0x69, 0x8d, 0xc6, 0x76, 0xb4, 0x59, 0x02, 0x93, 0x85, 0x5c, 0xab,
+	0x12, 0x7f, 0xcf, 0xfe, 0x86, 0x43, 0x6d, 0xc9, 0x07, 0x00, 0xbd, 0x5d, 0xd5, 0xe2, 0x67, 0x75,
+	0x83, 0x77, 0x34, 0xc8, 0x82, 0x3c, 0x29, 0x93, 0x0e, 0xb9, 0xc4, 0x3b, 0xf2, 0x05, 0xa2, 0x5f,
+	0xca, 0x3a, 0x7a, 0x94, 0x05, 0x79, 0x3a, 0x7f, 0x5f, 0x74, 0x22, 0xc5, 0x20, 0x52, 0x5c, 0x3b,
+	0x23, 0xe4, 0x66, 0xc9, 0xea, 0x2d, 0x96, 0x9e, 0x49, 0x08, 0x44, 0x5a, 0x19, 0x47, 0xc3, 0x2c,
+	0xc8, 0x47, 0xa5, 0xaf, 0xc9, 0x19, 0xa4, 0xed, 0xac, 0xda, 0x69, 0x59, 0x09, 0x4d, 0xa3, 0x4e,
+	0xa5, 0x85, 0x96, 0x5a, 0x2e, 0x34, 0xf9, 0x04, 0xa7, 0x0d, 0x3a, 0xc6, 0x99, 0x63, 0x15, 0x4a,
+	0xb6, 0xaa, 0x91, 0xd3, 0x51, 0x16, 0xe4, 0x71, 0xf9, 0x6a, 0xc0, 0xbf, 0x77, 0x30, 0x79, 0x07,
+	0xb1, 0xb0, 0x15, 0xe3, 0x8d, 0x90, 0x74, 0xec, 0x29, 0x13, 0x61, 0x2f, 0xda, 0x96, 0x7c, 0x84,
+	0x94, 0xd5, 0xb5, 0xba, 0x45, 0x5e, 0x09, 0x6d, 0xe9, 0xc4, 0xab, 0x40, 0x0f, 0x2d, 0xb4, 0x6d,
+	0x09, 0x5c, 0xda, 0xbd, 0x42, 0xec, 0x8f, 0x03, 0x97, 0x76, 0xb8, 0xbc, 0x27, 0x30, 0xce, 0x0d,
+	0x5a, 0x4b, 0x93, 0xee, 0x06, 0x2e, 0xed, 0x45, 0x87, 0x90, 0x53, 0x08, 0x1b, 0xb7, 0xa5, 0xe0,
+	0xff, 0xad, 0x2d, 0xc9, 0x57, 0x78, 0xdb, 0x9a, 0x2c, 0xac, 0x43, 0xe9, 0xaa, 0x1b, 0x44, 0xcd,
+	0x6a, 0xb1, 0x43, 0x9a, 0x7a, 0xca, 0x9b, 0xfb, 0xd9, 0xe5, 0x30, 0x22, 0x53, 0x88, 0x51, 0x72,
+	0xad, 0x84, 0x74, 0xf4, 0xd8, 0x4b, 0xec, 0x7b, 0xf2, 0x19, 0x62, 0x6d, 0xd4, 0x5a, 0xd4, 0x68,
+	0xe9, 0xcb, 0x2c, 0xcc, 0xd3, 0xf9, 0xeb, 0xce, 0xec, 0x62, 0x79, 0xf5, 0xe3, 0xaa, 0x9b, 0x94,
+	0x7b, 0x0a, 0xc9, 0x20, 0xd5, 0x68, 0x1a, 0x61, 0xad, 0x50, 0xd2, 0xd2, 0x93, 0x2c, 0xcc, 0x93,
+	0xf2, 0x21, 0x34, 0xfb, 0x13, 0x00, 0xdc, 0x1f, 0x6d, 0xd3, 0x91, 0xac, 0xc1, 0x3e, 0x68, 0x5f,
+	0xef, 0x13, 0x3b, 0x7a, 0x3e, 0xb1, 0xf0, 0x69, 0x62, 0x4f, 0xbc, 0x8e, 0x0e, 0x79, 0x3d, 0x3a,
+	0xe4, 0xf5, 0xf8, 0x3f, 0xaf, 0x1f, 0xda, 0x34, 0x79, 0x6c, 0xd3, 0x7c, 0x0e, 0xe3, 0x6b, 0xff,
+	0x06, 0x48, 0x0e, 0x51, 0xbb, 0xca, 0xe4, 0xa4, 0xb7, 0xa9, 0xdf, 0xf1, 0xe9, 0xe3, 0xde, 0xce,
+	0x5e, 0xac, 0xc6, 0x1e, 0xf8, 0xf6, 0x6f, 0x00, 0x2d, 0x84, 0xf3, 0x9b, 0x3e, 0x03, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -0,0 +1,383 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: sessions.proto
+
+package proto
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Session struct {
+	Id                   string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Owner                string               `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
+	OwnerName            string               `protobuf:"bytes,3,opt,name=owner_name,json=ownerName,proto3" json:"owner_name,omitempty"`
+	Provider             string               `protobuf:"bytes,4,opt,name=provider,proto3" json:"provider,omitempty"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ExpiresAt            *timestamp.Timestamp `protobuf:"bytes,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Session) Reset()         { *m = Session{} }
+func (m *Session) String() string { return proto.CompactTextString(m) }
+func (*Session) ProtoMessage()    {}
+func (*Session) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0475a55364240b58, []int{0}
+}
+
+func (m *Session) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Session.Unmarshal(m, b)
+}
+func (m *Session) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Session.Marshal(b, m, deterministic)
+}
+func (m *Session) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Session.Merge(m, src)
+}
+func (m *Session) XXX_Size() int {
+	return xxx_messageInfo_Session.Size(m)
+}
+func (m *Session) XXX_DiscardUnknown() {
+	xxx_messageInfo_Session.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Session proto.InternalMessageInfo
+
+func (m *Session) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Session) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *Session) GetOwnerName() string {
+	if m != nil {
+		return m.OwnerName
+	}
+	return ""
+}
+
+func (m *Session) GetProvider() string {
+	if m != nil {
+		return m.Provider
+	}
+	return ""
+}
+
+func (m *Session) GetCreatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+func (m *Session) GetExpiresAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+type ListSessionsReq struct {
+	// lists the sessions of all users if empty
+	Owner                string   `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSessionsReq) Reset()         { *m = ListSessionsReq{} }
+func (m *ListSessionsReq) String() string { return proto.CompactTextString(m) }
+func (*ListSessionsReq) ProtoMessage()    {}
+func (*ListSessionsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0475a55364240b58, []int{1}
+}
+
+func (m *ListSessionsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSessionsReq.Unmarshal(m, b)
+}
+func (m *ListSessionsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSessionsReq.Marshal(b, m, deterministic)
+}
+func (m *ListSessionsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSessionsReq.Merge(m, src)
+}
+func (m *ListSessionsReq) XXX_Size() int {
+	return xxx_messageInfo_ListSessionsReq.Size(m)
+}
+func (m *ListSessionsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSessionsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSessionsReq proto.InternalMessageInfo
+
+func (m *ListSessionsReq) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+type ListSessionsRes struct {
+	Items                []*Session `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *ListSessionsRes) Reset()         { *m = ListSessionsRes{} }
+func (m *ListSessionsRes) String() string { return proto.CompactTextString(m) }
+func (*ListSessionsRes) ProtoMessage()    {}
+func (*ListSessionsRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0475a55364240b58, []int{2}
+}
+
+func (m *ListSessionsRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSessionsRes.Unmarshal(m, b)
+}
+func (m *ListSessionsRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSessionsRes.Marshal(b, m, deterministic)
+}
+func (m *ListSessionsRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSessionsRes.Merge(m, src)
+}
+func (m *ListSessionsRes) XXX_Size() int {
+	return xxx_messageInfo_ListSessionsRes.Size(m)
+}
+func (m *ListSessionsRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSessionsRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSessionsRes proto.InternalMessageInfo
+
+func (m *ListSessionsRes) GetItems() []*Session {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+type RevokeSessionsReq struct {
+	Owner string `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	// revokes a single session of the owner.
+	// revokes all of the owner's sessions if empty.
+	Id                   string   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeSessionsReq) Reset()         { *m = RevokeSessionsReq{} }
+func (m *RevokeSessionsReq) String() string { return proto.CompactTextString(m) }
+func (*RevokeSessionsReq) ProtoMessage()    {}
+func (*RevokeSessionsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0475a55364240b58, []int{3}
+}
+
+func (m *RevokeSessionsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeSessionsReq.Unmarshal(m, b)
+}
+func (m *RevokeSessionsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeSessionsReq.Marshal(b, m, deterministic)
+}
+func (m *RevokeSessionsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeSessionsReq.Merge(m, src)
+}
+func (m *RevokeSessionsReq) XXX_Size() int {
+	return xxx_messageInfo_RevokeSessionsReq.Size(m)
+}
+func (m *RevokeSessionsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeSessionsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeSessionsReq proto.InternalMessageInfo
+
+func (m *RevokeSessionsReq) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *RevokeSessionsReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Session)(nil), "proto.Session")
+	proto.RegisterType((*ListSessionsReq)(nil), "proto.ListSessionsReq")
+	proto.RegisterType((*ListSessionsRes)(nil), "proto.ListSessionsRes")
+	proto.RegisterType((*RevokeSessionsReq)(nil), "proto.RevokeSessionsReq")
+}
+
+func init() { proto.RegisterFile("sessions.proto", fileDescriptor_0475a55364240b58) }
+
+var fileDescriptor_0475a55364240b58 = []byte{
+	// 318 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0x4f, 0x4b, 0xc3, 0x30,
+	0x14, 0x5f, 0x3a, 0x3b, 0xb7, 0x37, 0xa9, 0x18, 0x64, 0x84, 0x8a, 0x38, 0x8a, 0xe0, 0x4e, 0x1d,
+	0xcc, 0x83, 0xec, 0xe6, 0x40, 0x6f, 0xe2, 0xa1, 0x7a, 0x1f, 0x9d, 0x7d, 0x8e, 0xa0, 0x59, 0x6a,
+	0x12, 0xa7, 0x7e, 0x0d, 0x3f, 0xa1, 0x1f, 0x45, 0x96, 0xa4, 0x3a, 0x3b, 0x61, 0xa7, 0xe4, 0xfd,
+	0xfe, 0xe4, 0xfd, 0xde, 0x23, 0x10, 0x69, 0xd4, 0x9a, 0xcb, 0x85, 0x4e, 0x4b, 0x25, 0x8d, 0xa4,
+	0xa1, 0x3d, 0xe2, 0x93, 0xb9, 0x94, 0xf3, 0x67, 0x1c, 0xda, 0x6a, 0xf6, 0xfa, 0x38, 0x34, 0x5c,
+	0xa0, 0x36, 0xb9, 0x28, 0x9d, 0x2e, 0x3e, 0xaa, 0x0b, 0x50, 0x94, 0xe6, 0xc3, 0x91, 0xc9, 0x17,
+	0x81, 0xdd, 0x3b, 0xf7, 0x2e, 0x8d, 0x20, 0xe0, 0x05, 0x23, 0x7d, 0x32, 0xe8, 0x64, 0x01, 0x2f,
+	0xe8, 0x21, 0x84, 0xf2, 0x6d, 0x81, 0x8a, 0x05, 0x16, 0x72, 0x05, 0x3d, 0x06, 0xb0, 0x97, 0xe9,
+	0x22, 0x17, 0xc8, 0x9a, 0x96, 0xea, 0x58, 0xe4, 0x36, 0x17, 0x48, 0x63, 0x68, 0x97, 0x4a, 0x2e,
+	0x79, 0x81, 0x8a, 0xed, 0x58, 0xf2, 0xa7, 0xa6, 0x63, 0x80, 0x07, 0x85, 0xb9, 0xc1, 0x62, 0x9a,
+	0x1b, 0x16, 0xf6, 0xc9, 0xa0, 0x3b, 0x8a, 0x53, 0x17, 0x2f, 0xad, 0xe2, 0xa5, 0xf7, 0x55, 0xfe,
+	0xac, 0xe3, 0xd5, 0x13, 0xb3, 0xb2, 0xe2, 0x7b, 0xc9, 0x15, 0xea, 0x95, 0xb5, 0xb5, 0xdd, 0xea,
+	0xd5, 0x13, 0x93, 0x9c, 0xc1, 0xfe, 0x0d, 0xd7, 0xc6, 0x4f, 0xa9, 0x33, 0x7c, 0xf9, 0x9d, 0x8c,
+	0xac, 0x4d, 0x96, 0x5c, 0xd4, 0x85, 0x9a, 0x9e, 0x42, 0xc8, 0x0d, 0x0a, 0xcd, 0x48, 0xbf, 0x39,
+	0xe8, 0x8e, 0x22, 0xd7, 0x2a, 0xf5, 0x92, 0xcc, 0x91, 0xc9, 0x18, 0x0e, 0x32, 0x5c, 0xca, 0x27,
+	0xdc, 0xda, 0xc3, 0xef, 0x38, 0xa8, 0x76, 0x3c, 0xfa, 0x24, 0xd0, 0xae, 0x5c, 0xf4, 0x12, 0xf6,
+	0xd6, 0x03, 0xd0, 0x9e, 0x6f, 0x57, 0x8b, 0x1f, 0xff, 0x8f, 0xeb, 0xa4, 0x41, 0xaf, 0x20, 0xfa,
+	0x9b, 0x84, 0x32, 0xaf, 0xdd, 0x08, 0x18, 0xf7, 0x36, 0xd6, 0x77, 0xbd, 0xfa, 0x18, 0x49, 0x63,
+	0xd6, 0xb2, 0xc8, 0xf9, 0xf7, 0x00, 0x28, 0x32, 0xa9, 0xf2, 0x72, 0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// SessionsClient is the client API for Sessions service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SessionsClient interface {
+	ListSessions(ctx context.Context, in *ListSessionsReq, opts ...grpc.CallOption) (*ListSessionsRes, error)
+	RevokeSessions(ctx context.Context, in *RevokeSessionsReq, opts ...grpc.CallOption) (*empty.Empty, error)
+}
+
+type sessionsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSessionsClient(cc grpc.ClientConnInterface) SessionsClient {
+	return &sessionsClient{cc}
+}
+
+func (c *sessionsClient) ListSessions(ctx context.Context, in *ListSessionsReq, opts ...grpc.CallOption) (*ListSessionsRes, error) {
+	out := new(ListSessionsRes)
+	err := c.cc.Invoke(ctx, "/proto.Sessions/ListSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionsClient) RevokeSessions(ctx context.Context, in *RevokeSessionsReq, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/proto.Sessions/RevokeSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SessionsServer is the server API for Sessions service.
+type SessionsServer interface {
+	ListSessions(context.Context, *ListSessionsReq) (*ListSessionsRes, error)
+	RevokeSessions(context.Context, *RevokeSessionsReq) (*empty.Empty, error)
+}
+
+// UnimplementedSessionsServer can be embedded to have forward compatible implementations.
+type UnimplementedSessionsServer struct {
+}
+
+func (*UnimplementedSessionsServer) ListSessions(ctx context.Context, req *ListSessionsReq) (*ListSessionsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (*UnimplementedSessionsServer) RevokeSessions(ctx context.Context, req *RevokeSessionsReq) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSessions not implemented")
+}
+
+func RegisterSessionsServer(s *grpc.Server, srv SessionsServer) {
+	s.RegisterService(&_Sessions_serviceDesc, srv)
+}
+
+func _Sessions_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionsServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Sessions/ListSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionsServer).ListSessions(ctx, req.(*ListSessionsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sessions_RevokeSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionsServer).RevokeSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Sessions/RevokeSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionsServer).RevokeSessions(ctx, req.(*RevokeSessionsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Sessions_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.Sessions",
+	HandlerType: (*SessionsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSessions",
+			Handler:    _Sessions_ListSessions_Handler,
+		},
+		{
+			MethodName: "RevokeSessions",
+			Handler:    _Sessions_RevokeSessions_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sessions.proto",
+}
@@ -26,7 +26,7 @@ export class App extends React.Component {
         <Box component="div" m={2}>
           <Switch>
             <Route exact path="/" component={YourDevices} />
-            {AppState.info.isAdmin && (
+            {AppState.can('devices:read-all') && (
               <>
                 <Route exact path="/admin/all-devices" component={AllDevices} />
               </>
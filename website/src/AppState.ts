@@ -4,6 +4,12 @@ import { InfoRes } from './sdk/server_pb';
 class GlobalAppState {
   @observable
   info?: InfoRes.AsObject;
+
+  // can reports if the user's roles grant
+  // a permission e.g. "devices:read-all"
+  can(permission: string) {
+    return !!this.info?.permissions.includes(permission);
+  }
 }
 
 export const AppState = new GlobalAppState();
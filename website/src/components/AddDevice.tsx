@@ -10,6 +10,8 @@ import FormControl from '@material-ui/core/FormControl';
 import FormHelperText from '@material-ui/core/FormHelperText';
 import Input from '@material-ui/core/Input';
 import InputLabel from '@material-ui/core/InputLabel';
+import MenuItem from '@material-ui/core/MenuItem';
+import Select from '@material-ui/core/Select';
 import Typography from '@material-ui/core/Typography';
 import AddIcon from '@material-ui/icons/Add';
 import { codeBlock } from 'common-tags';
@@ -37,12 +39,25 @@ export class AddDevice extends React.Component<Props> {
   @observable
   deviceName = '';
 
+  @observable
+  profile = '';
+
+  // empty uses the server's default keepalive
+  @observable
+  persistentKeepalive = '';
+
   @observable
   configFile?: string;
 
   submit = async (event: React.FormEvent) => {
     event.preventDefault();
 
+    const persistentKeepalive = Number(this.persistentKeepalive || 0);
+    if (!Number.isInteger(persistentKeepalive) || persistentKeepalive < 0 || persistentKeepalive > 65535) {
+      this.error = 'keepalive must be between 0 and 65535 seconds';
+      return;
+    }
+
     const keypair = box_keyPair();
     const publicKey = window.btoa(String.fromCharCode(...(new Uint8Array(keypair.publicKey) as any)));
     const privateKey = window.btoa(String.fromCharCode(...(new Uint8Array(keypair.secretKey) as any)));
@@ -51,20 +66,28 @@ export class AddDevice extends React.Component<Props> {
       const device = await grpc.devices.addDevice({
         name: this.deviceName,
         publicKey,
+        persistentKeepalive,
+        profile: this.profile,
       });
       this.props.onAdd();
 
       const info = AppState.info!;
+      // additional profiles override the default profile's settings
+      const profile = info.profiles.find((p) => p.name === device.profile);
+      const network = profile ? { ...info, ...profile } : info;
+      const keepalive = device.persistentKeepalive || info.persistentKeepalive;
       const configFile = codeBlock`
         [Interface]
         PrivateKey = ${privateKey}
         Address = ${device.address}
-        ${info.dnsEnabled && `DNS = ${info.dnsAddress}`}
+        ${network.dnsEnabled && `DNS = ${network.dnsAddress}`}
+        ${!!info.mtu && `MTU = ${info.mtu}`}
 
         [Peer]
         PublicKey = ${info.publicKey}
-        AllowedIPs = ${info.allowedIps}
-        Endpoint = ${`${info.host?.value || window.location.hostname}:${info.port || '51820'}`}
+        AllowedIPs = ${network.allowedIps}
+        Endpoint = ${network.endpoint || `${info.host?.value || window.location.hostname}:${network.port || '51820'}`}
+        ${!!keepalive && `PersistentKeepalive = ${keepalive}`}
       `;
 
       this.configFile = configFile;
@@ -79,6 +102,9 @@ export class AddDevice extends React.Component<Props> {
 
   reset = () => {
     this.deviceName = '';
+    this.profile = '';
+    this.persistentKeepalive = '';
+    this.error = undefined;
   };
 
   render() {
@@ -98,6 +124,38 @@ export class AddDevice extends React.Component<Props> {
                 />
                 <FormHelperText id="device-name-text">{this.error}</FormHelperText>
               </FormControl>
+              {AppState.info!.profiles.length > 0 && (
+                <FormControl fullWidth>
+                  <InputLabel htmlFor="device-profile">Network</InputLabel>
+                  <Select
+                    id="device-profile"
+                    value={this.profile}
+                    onChange={(event) => (this.profile = event.target.value as string)}
+                  >
+                    <MenuItem value="">Default</MenuItem>
+                    {AppState.info!.profiles.map((p) => (
+                      <MenuItem key={p.name} value={p.name}>
+                        {p.name}
+                      </MenuItem>
+                    ))}
+                  </Select>
+                </FormControl>
+              )}
+              <FormControl fullWidth>
+                <InputLabel htmlFor="device-keepalive">Persistent Keepalive (seconds)</InputLabel>
+                <Input
+                  id="device-keepalive"
+                  type="number"
+                  inputProps={{ min: 0, max: 65535 }}
+                  value={this.persistentKeepalive}
+                  onChange={(event) => (this.persistentKeepalive = event.currentTarget.value)}
+                  aria-describedby="device-keepalive-text"
+                />
+                <FormHelperText id="device-keepalive-text">
+                  Optional. Keeps devices behind NAT connected while idle. Defaults to{' '}
+                  {AppState.info!.persistentKeepalive || 'off'}
+                </FormHelperText>
+              </FormControl>
               <Typography component="div" align="right">
                 <Button color="secondary" type="button" onClick={this.reset}>
                   Cancel
@@ -77,6 +77,11 @@ export class DeviceListItem extends React.Component<Props> {
                   <td>Disconnected</td>
                 </tr>
               )}
+              {device.disabled && (
+                <tr>
+                  <td>Disabled</td>
+                </tr>
+              )}
               <tr>
                 <td>Last Seen</td>
                 <td>{lastSeen(device.lastHandshakeTime)}</td>
@@ -37,7 +37,7 @@ export default function Navigation() {
           )}
         </Typography>
 
-        {AppState.info?.isAdmin && (
+        {AppState.can('devices:read-all') && (
           <Link to="/admin/all-devices" color="inherit" component={NavLink}>
             <Button color="inherit">All Devices</Button>
           </Link>
@@ -68,12 +68,14 @@ export class AllDevices extends React.Component {
                   </TableCell>
                   {showProviderCol && <TableCell>{row.ownerProvider}</TableCell>}
                   <TableCell>{row.name}</TableCell>
-                  <TableCell>{row.connected ? 'yes' : 'no'}</TableCell>
+                  <TableCell>{row.disabled ? 'disabled' : row.connected ? 'yes' : 'no'}</TableCell>
                   <TableCell>{lastSeen(row.lastHandshakeTime)}</TableCell>
                   <TableCell>
-                    <Button variant="outlined" color="secondary" onClick={() => this.deleteDevice(row)}>
-                      Delete
-                    </Button>
+                    {AppState.can('devices:delete-all') && (
+                      <Button variant="outlined" color="secondary" onClick={() => this.deleteDevice(row)}>
+                        Delete
+                      </Button>
+                    )}
                   </TableCell>
                 </TableRow>
               ))}
@@ -152,6 +152,11 @@ export declare namespace Device {
 		ownerName: string,
 		ownerEmail: string,
 		ownerProvider: string,
+		ingressLimit: number,
+		egressLimit: number,
+		persistentKeepalive: number,
+		profile: string,
+		disabled: boolean,
 	}
 }
 
@@ -271,6 +276,46 @@ export class Device extends jspb.Message {
 		(jspb.Message as any).setProto3StringField(this, 13, value);
 	}
 
+	getIngressLimit(): number {
+		return jspb.Message.getFieldWithDefault(this, 14, 0);
+	}
+
+	setIngressLimit(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 14, value);
+	}
+
+	getEgressLimit(): number {
+		return jspb.Message.getFieldWithDefault(this, 15, 0);
+	}
+
+	setEgressLimit(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 15, value);
+	}
+
+	getPersistentKeepalive(): number {
+		return jspb.Message.getFieldWithDefault(this, 16, 0);
+	}
+
+	setPersistentKeepalive(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 16, value);
+	}
+
+	getProfile(): string {
+		return jspb.Message.getFieldWithDefault(this, 17, "");
+	}
+
+	setProfile(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 17, value);
+	}
+
+	getDisabled(): boolean {
+		return jspb.Message.getFieldWithDefault(this, 18, false);
+	}
+
+	setDisabled(value: boolean): void {
+		(jspb.Message as any).setProto3BooleanField(this, 18, value);
+	}
+
 	serializeBinary(): Uint8Array {
 		const writer = new jspb.BinaryWriter();
 		Device.serializeBinaryToWriter(this, writer);
@@ -292,6 +337,11 @@ export class Device extends jspb.Message {
 			ownerName: this.getOwnerName(),
 			ownerEmail: this.getOwnerEmail(),
 			ownerProvider: this.getOwnerProvider(),
+			ingressLimit: this.getIngressLimit(),
+			egressLimit: this.getEgressLimit(),
+			persistentKeepalive: this.getPersistentKeepalive(),
+			profile: this.getProfile(),
+			disabled: this.getDisabled(),
 			
 		};
 	}
@@ -349,6 +399,26 @@ export class Device extends jspb.Message {
 		if (field13.length > 0) {
 			writer.writeString(13, field13);
 		}
+		const field14 = message.getIngressLimit();
+		if (field14 != 0) {
+			writer.writeUint64(14, field14);
+		}
+		const field15 = message.getEgressLimit();
+		if (field15 != 0) {
+			writer.writeUint64(15, field15);
+		}
+		const field16 = message.getPersistentKeepalive();
+		if (field16 != 0) {
+			writer.writeInt32(16, field16);
+		}
+		const field17 = message.getProfile();
+		if (field17.length > 0) {
+			writer.writeString(17, field17);
+		}
+		const field18 = message.getDisabled();
+		if (field18 != false) {
+			writer.writeBool(18, field18);
+		}
 	}
 
 	static deserializeBinary(bytes: Uint8Array): Device {
@@ -418,6 +488,26 @@ export class Device extends jspb.Message {
 				const field13 = reader.readString()
 				message.setOwnerProvider(field13);
 				break;
+			case 14:
+				const field14 = reader.readUint64()
+				message.setIngressLimit(field14);
+				break;
+			case 15:
+				const field15 = reader.readUint64()
+				message.setEgressLimit(field15);
+				break;
+			case 16:
+				const field16 = reader.readInt32()
+				message.setPersistentKeepalive(field16);
+				break;
+			case 17:
+				const field17 = reader.readString()
+				message.setProfile(field17);
+				break;
+			case 18:
+				const field18 = reader.readBool()
+				message.setDisabled(field18);
+				break;
 			default:
 				reader.skipField();
 				break;
@@ -431,6 +521,8 @@ export declare namespace AddDeviceReq {
 	export type AsObject = {
 		name: string,
 		publicKey: string,
+		persistentKeepalive: number,
+		profile: string,
 	}
 }
 
@@ -462,6 +554,22 @@ export class AddDeviceReq extends jspb.Message {
 		(jspb.Message as any).setProto3StringField(this, 2, value);
 	}
 
+	getPersistentKeepalive(): number {
+		return jspb.Message.getFieldWithDefault(this, 3, 0);
+	}
+
+	setPersistentKeepalive(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 3, value);
+	}
+
+	getProfile(): string {
+		return jspb.Message.getFieldWithDefault(this, 4, "");
+	}
+
+	setProfile(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 4, value);
+	}
+
 	serializeBinary(): Uint8Array {
 		const writer = new jspb.BinaryWriter();
 		AddDeviceReq.serializeBinaryToWriter(this, writer);
@@ -472,6 +580,8 @@ export class AddDeviceReq extends jspb.Message {
 		let f: any;
 		return {name: this.getName(),
 			publicKey: this.getPublicKey(),
+			persistentKeepalive: this.getPersistentKeepalive(),
+			profile: this.getProfile(),
 			
 		};
 	}
@@ -485,6 +595,14 @@ export class AddDeviceReq extends jspb.Message {
 		if (field2.length > 0) {
 			writer.writeString(2, field2);
 		}
+		const field3 = message.getPersistentKeepalive();
+		if (field3 != 0) {
+			writer.writeInt32(3, field3);
+		}
+		const field4 = message.getProfile();
+		if (field4.length > 0) {
+			writer.writeString(4, field4);
+		}
 	}
 
 	static deserializeBinary(bytes: Uint8Array): AddDeviceReq {
@@ -508,6 +626,14 @@ export class AddDeviceReq extends jspb.Message {
 				const field2 = reader.readString()
 				message.setPublicKey(field2);
 				break;
+			case 3:
+				const field3 = reader.readInt32()
+				message.setPersistentKeepalive(field3);
+				break;
+			case 4:
+				const field4 = reader.readString()
+				message.setProfile(field4);
+				break;
 			default:
 				reader.skipField();
 				break;
@@ -890,6 +1016,11 @@ function DeviceFromObject(obj: Device.AsObject | undefined): Device | undefined
 	message.setOwnerName(obj.ownerName);
 	message.setOwnerEmail(obj.ownerEmail);
 	message.setOwnerProvider(obj.ownerProvider);
+	message.setIngressLimit(obj.ingressLimit);
+	message.setEgressLimit(obj.egressLimit);
+	message.setPersistentKeepalive(obj.persistentKeepalive);
+	message.setProfile(obj.profile);
+	message.setDisabled(obj.disabled);
 	return message;
 }
 
@@ -910,6 +1041,8 @@ function AddDeviceReqFromObject(obj: AddDeviceReq.AsObject | undefined): AddDevi
 	const message = new AddDeviceReq();
 	message.setName(obj.name);
 	message.setPublicKey(obj.publicKey);
+	message.setPersistentKeepalive(obj.persistentKeepalive);
+	message.setProfile(obj.profile);
 	return message;
 }
 
@@ -116,13 +116,18 @@ export declare namespace InfoRes {
 		allowedIps: string,
 		dnsEnabled: boolean,
 		dnsAddress: string,
+		mtu: number,
+		persistentKeepalive: number,
+		endpoint: string,
+		profiles: Array<VPNProfile.AsObject>,
+		permissions: Array<string>,
 	}
 }
 
 export class InfoRes extends jspb.Message {
 
 	private static repeatedFields_ = [
-		
+		13,14,
 	];
 
 	constructor(data?: jspb.Message.MessageArray) {
@@ -203,6 +208,54 @@ export class InfoRes extends jspb.Message {
 		(jspb.Message as any).setProto3StringField(this, 9, value);
 	}
 
+	getMtu(): number {
+		return jspb.Message.getFieldWithDefault(this, 10, 0);
+	}
+
+	setMtu(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 10, value);
+	}
+
+	getPersistentKeepalive(): number {
+		return jspb.Message.getFieldWithDefault(this, 11, 0);
+	}
+
+	setPersistentKeepalive(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 11, value);
+	}
+
+	getEndpoint(): string {
+		return jspb.Message.getFieldWithDefault(this, 12, "");
+	}
+
+	setEndpoint(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 12, value);
+	}
+
+	getProfiles(): Array<VPNProfile> {
+		return jspb.Message.getRepeatedWrapperField(this, VPNProfile, 13);
+	}
+
+	setProfiles(value: Array<VPNProfile>): void {
+		(jspb.Message as any).setRepeatedWrapperField(this, 13, value);
+	}
+	
+	addProfiles(value?: VPNProfile, index?: number): VPNProfile {
+		return jspb.Message.addToRepeatedWrapperField(this, 13, value, VPNProfile, index);
+	}
+
+	getPermissions(): Array<string> {
+		return jspb.Message.getRepeatedField(this, 14) as Array<string>;
+	}
+
+	setPermissions(value: Array<string>): void {
+		(jspb.Message as any).setField(this, 14, value || []);
+	}
+	
+	addPermissions(value: string, index?: number): void {
+		(jspb.Message as any).addToRepeatedField(this, 14, value, index);
+	}
+
 	serializeBinary(): Uint8Array {
 		const writer = new jspb.BinaryWriter();
 		InfoRes.serializeBinaryToWriter(this, writer);
@@ -220,6 +273,11 @@ export class InfoRes extends jspb.Message {
 			allowedIps: this.getAllowedIps(),
 			dnsEnabled: this.getDnsEnabled(),
 			dnsAddress: this.getDnsAddress(),
+			mtu: this.getMtu(),
+			persistentKeepalive: this.getPersistentKeepalive(),
+			endpoint: this.getEndpoint(),
+			profiles: this.getProfiles().map((item) => item.toObject()),
+			permissions: this.getPermissions(),
 			
 		};
 	}
@@ -261,6 +319,26 @@ export class InfoRes extends jspb.Message {
 		if (field9.length > 0) {
 			writer.writeString(9, field9);
 		}
+		const field10 = message.getMtu();
+		if (field10 != 0) {
+			writer.writeInt32(10, field10);
+		}
+		const field11 = message.getPersistentKeepalive();
+		if (field11 != 0) {
+			writer.writeInt32(11, field11);
+		}
+		const field12 = message.getEndpoint();
+		if (field12.length > 0) {
+			writer.writeString(12, field12);
+		}
+		const field13 = message.getProfiles();
+		if (field13.length > 0) {
+			writer.writeRepeatedMessage(13, field13, VPNProfile.serializeBinaryToWriter);
+		}
+		const field14 = message.getPermissions();
+		if (field14.length > 0) {
+			writer.writeRepeatedString(14, field14);
+		}
 	}
 
 	static deserializeBinary(bytes: Uint8Array): InfoRes {
@@ -313,6 +391,208 @@ export class InfoRes extends jspb.Message {
 				const field9 = reader.readString()
 				message.setDnsAddress(field9);
 				break;
+			case 10:
+				const field10 = reader.readInt32()
+				message.setMtu(field10);
+				break;
+			case 11:
+				const field11 = reader.readInt32()
+				message.setPersistentKeepalive(field11);
+				break;
+			case 12:
+				const field12 = reader.readString()
+				message.setEndpoint(field12);
+				break;
+			case 13:
+				const field13 = new VPNProfile();
+				reader.readMessage(field13, VPNProfile.deserializeBinaryFromReader);
+				message.addProfiles(field13);
+				break;
+			case 14:
+				const field14 = reader.readString()
+				message.addPermissions(field14);
+				break;
+			default:
+				reader.skipField();
+				break;
+			}
+		}
+		return message;
+	}
+
+}
+export declare namespace VPNProfile {
+	export type AsObject = {
+		name: string,
+		port: number,
+		hostVpnIp: string,
+		allowedIps: string,
+		dnsEnabled: boolean,
+		dnsAddress: string,
+		endpoint: string,
+	}
+}
+
+export class VPNProfile extends jspb.Message {
+
+	private static repeatedFields_ = [
+		
+	];
+
+	constructor(data?: jspb.Message.MessageArray) {
+		super();
+		jspb.Message.initialize(this, data || [], 0, -1, VPNProfile.repeatedFields_, null);
+	}
+
+
+	getName(): string {
+		return jspb.Message.getFieldWithDefault(this, 1, "");
+	}
+
+	setName(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 1, value);
+	}
+
+	getPort(): number {
+		return jspb.Message.getFieldWithDefault(this, 2, 0);
+	}
+
+	setPort(value: number): void {
+		(jspb.Message as any).setProto3IntField(this, 2, value);
+	}
+
+	getHostVpnIp(): string {
+		return jspb.Message.getFieldWithDefault(this, 3, "");
+	}
+
+	setHostVpnIp(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 3, value);
+	}
+
+	getAllowedIps(): string {
+		return jspb.Message.getFieldWithDefault(this, 4, "");
+	}
+
+	setAllowedIps(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 4, value);
+	}
+
+	getDnsEnabled(): boolean {
+		return jspb.Message.getFieldWithDefault(this, 5, false);
+	}
+
+	setDnsEnabled(value: boolean): void {
+		(jspb.Message as any).setProto3BooleanField(this, 5, value);
+	}
+
+	getDnsAddress(): string {
+		return jspb.Message.getFieldWithDefault(this, 6, "");
+	}
+
+	setDnsAddress(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 6, value);
+	}
+
+	getEndpoint(): string {
+		return jspb.Message.getFieldWithDefault(this, 7, "");
+	}
+
+	setEndpoint(value: string): void {
+		(jspb.Message as any).setProto3StringField(this, 7, value);
+	}
+
+	serializeBinary(): Uint8Array {
+		const writer = new jspb.BinaryWriter();
+		VPNProfile.serializeBinaryToWriter(this, writer);
+		return writer.getResultBuffer();
+	}
+
+	toObject(): VPNProfile.AsObject {
+		let f: any;
+		return {name: this.getName(),
+			port: this.getPort(),
+			hostVpnIp: this.getHostVpnIp(),
+			allowedIps: this.getAllowedIps(),
+			dnsEnabled: this.getDnsEnabled(),
+			dnsAddress: this.getDnsAddress(),
+			endpoint: this.getEndpoint(),
+			profiles: this.getProfiles().map((item) => item.toObject()),
+			
+		};
+	}
+
+	static serializeBinaryToWriter(message: VPNProfile, writer: jspb.BinaryWriter): void {
+		const field1 = message.getName();
+		if (field1.length > 0) {
+			writer.writeString(1, field1);
+		}
+		const field2 = message.getPort();
+		if (field2 != 0) {
+			writer.writeInt32(2, field2);
+		}
+		const field3 = message.getHostVpnIp();
+		if (field3.length > 0) {
+			writer.writeString(3, field3);
+		}
+		const field4 = message.getAllowedIps();
+		if (field4.length > 0) {
+			writer.writeString(4, field4);
+		}
+		const field5 = message.getDnsEnabled();
+		if (field5 != false) {
+			writer.writeBool(5, field5);
+		}
+		const field6 = message.getDnsAddress();
+		if (field6.length > 0) {
+			writer.writeString(6, field6);
+		}
+		const field7 = message.getEndpoint();
+		if (field7.length > 0) {
+			writer.writeString(7, field7);
+		}
+	}
+
+	static deserializeBinary(bytes: Uint8Array): VPNProfile {
+		var reader = new jspb.BinaryReader(bytes);
+		var message = new VPNProfile();
+		return VPNProfile.deserializeBinaryFromReader(message, reader);
+	}
+
+	static deserializeBinaryFromReader(message: VPNProfile, reader: jspb.BinaryReader): VPNProfile {
+		while (reader.nextField()) {
+			if (reader.isEndGroup()) {
+				break;
+			}
+			const field = reader.getFieldNumber();
+			switch (field) {
+			case 1:
+				const field1 = reader.readString()
+				message.setName(field1);
+				break;
+			case 2:
+				const field2 = reader.readInt32()
+				message.setPort(field2);
+				break;
+			case 3:
+				const field3 = reader.readString()
+				message.setHostVpnIp(field3);
+				break;
+			case 4:
+				const field4 = reader.readString()
+				message.setAllowedIps(field4);
+				break;
+			case 5:
+				const field5 = reader.readBool()
+				message.setDnsEnabled(field5);
+				break;
+			case 6:
+				const field6 = reader.readString()
+				message.setDnsAddress(field6);
+				break;
+			case 7:
+				const field7 = reader.readString()
+				message.setEndpoint(field7);
+				break;
 			default:
 				reader.skipField();
 				break;
@@ -346,6 +626,29 @@ function InfoResFromObject(obj: InfoRes.AsObject | undefined): InfoRes | undefin
 	message.setAllowedIps(obj.allowedIps);
 	message.setDnsEnabled(obj.dnsEnabled);
 	message.setDnsAddress(obj.dnsAddress);
+	message.setMtu(obj.mtu);
+	message.setPersistentKeepalive(obj.persistentKeepalive);
+	message.setEndpoint(obj.endpoint);
+	(obj.profiles || [])
+		.map((item) => VPNProfileFromObject(item))
+		.forEach((item) => message.addProfiles(item));
+	(obj.permissions || [])
+		.forEach((item) => message.addPermissions(item));
+	return message;
+}
+
+function VPNProfileFromObject(obj: VPNProfile.AsObject | undefined): VPNProfile | undefined {
+	if (obj === undefined) {
+		return undefined;
+	}
+	const message = new VPNProfile();
+	message.setName(obj.name);
+	message.setPort(obj.port);
+	message.setHostVpnIp(obj.hostVpnIp);
+	message.setAllowedIps(obj.allowedIps);
+	message.setDnsEnabled(obj.dnsEnabled);
+	message.setDnsAddress(obj.dnsAddress);
+	message.setEndpoint(obj.endpoint);
 	return message;
 }
 